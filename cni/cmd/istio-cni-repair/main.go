@@ -34,6 +34,7 @@ import (
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	"k8s.io/client-go/tools/clientcmd"
 
+	"istio.io/istio/cni/pkg/plugin"
 	"istio.io/istio/cni/pkg/repair"
 	"istio.io/istio/tools/istio-iptables/pkg/constants"
 	"istio.io/pkg/log"
@@ -75,6 +76,15 @@ func parseFlags() (filters *repair.Filters, options *ControllerOptions) {
 	// Repair Options
 	pflag.Bool("delete-pods", false, "Controller will delete pods")
 	pflag.Bool("label-pods", false, "Controller will label pods")
+	pflag.Bool(
+		"repair-pods",
+		false,
+		"Controller will re-apply the traffic redirection of broken pods, and only delete or label the pods it cannot repair. "+
+			"Requires the host PID namespace")
+	pflag.String(
+		"intercept-type",
+		plugin.DefaultInterceptRuleMgrType,
+		"The type of traffic redirection used by the istio-cni plugin, used when repairing pods")
 	pflag.Bool("run-as-daemon", false, "Controller will run in a loop")
 	pflag.String(
 		"broken-pod-label-key",
@@ -115,6 +125,9 @@ func parseFlags() (filters *repair.Filters, options *ControllerOptions) {
 			LabelPods:     viper.GetBool("label-pods"),
 			PodLabelKey:   viper.GetString("broken-pod-label-key"),
 			PodLabelValue: viper.GetString("broken-pod-label-value"),
+
+			RepairPods:           viper.GetBool("repair-pods"),
+			InterceptRuleMgrType: viper.GetString("intercept-type"),
 		},
 	}
 
@@ -143,6 +156,9 @@ func logCurrentOptions(bpr *repair.BrokenPodReconciler, options *ControllerOptio
 	if options.RunAsDaemon {
 		log.Infof("Controller Option: Running as a Daemon.")
 	}
+	if bpr.Options.RepairPods {
+		log.Infof("Controller Option: Repairing the %s traffic redirection of broken pods.", bpr.Options.InterceptRuleMgrType)
+	}
 	if bpr.Options.DeletePods {
 		log.Info("Controller Option: Deleting broken pods. Pod Labeling deactivated.")
 	}
@@ -205,11 +221,16 @@ func main() {
 
 	} else {
 		err = nil
-		if podFixer.Options.LabelPods {
-			err = multierr.Append(err, podFixer.LabelBrokenPods())
-		}
-		if podFixer.Options.DeletePods {
-			err = multierr.Append(err, podFixer.DeleteBrokenPods())
+		if podFixer.Options.RepairPods {
+			// Falls back to deleting or labeling the pods which cannot be repaired.
+			err = podFixer.RepairBrokenPods()
+		} else {
+			if podFixer.Options.LabelPods {
+				err = multierr.Append(err, podFixer.LabelBrokenPods())
+			}
+			if podFixer.Options.DeletePods {
+				err = multierr.Append(err, podFixer.DeleteBrokenPods())
+			}
 		}
 		if err != nil {
 			log.Fatalf(err.Error())
@@ -30,14 +30,14 @@ import (
 	"github.com/containernetworking/cni/pkg/version"
 
 	"istio.io/api/annotation"
+	"istio.io/istio/cni/pkg/plugin"
 	"istio.io/pkg/log"
 )
 
 var (
-	nsSetupBinDir          = "/opt/cni/bin"
 	injectAnnotationKey    = annotation.SidecarInject.Name
 	sidecarStatusKey       = annotation.SidecarStatus.Name
-	interceptRuleMgrType   = defInterceptRuleMgrType
+	interceptRuleMgrType   = plugin.DefaultInterceptRuleMgrType
 	loggingOptions         = log.DefaultOptions()
 	podRetrievalMaxRetries = 30
 	podRetrievalInterval   = 1 * time.Second
@@ -142,7 +142,7 @@ func cmdAdd(args *skel.CmdArgs) error {
 	log.Infof("Getting identifiers with arguments: %s", args.Args)
 	log.Infof("Loaded k8s arguments: %v", k8sArgs)
 	if conf.Kubernetes.CNIBinDir != "" {
-		nsSetupBinDir = conf.Kubernetes.CNIBinDir
+		plugin.NsSetupBinDir = conf.Kubernetes.CNIBinDir
 	}
 	if conf.Kubernetes.InterceptRuleMgrType != "" {
 		interceptRuleMgrType = conf.Kubernetes.InterceptRuleMgrType
@@ -216,12 +216,12 @@ func cmdAdd(args *skel.CmdArgs) error {
 				}
 				if !excludePod {
 					log.Infof("setting up redirect")
-					if redirect, redirErr := NewRedirect(annotations); redirErr != nil {
+					if redirect, redirErr := plugin.NewRedirect(annotations); redirErr != nil {
 						log.Errorf("Pod redirect failed due to bad params: %v", redirErr)
 					} else {
-						log.Infof("Redirect local ports: %v", redirect.includePorts)
+						log.Infof("Redirect local ports: %v", redirect.IncludePorts)
 						// Get the constructor for the configured type of InterceptRuleMgr
-						interceptMgrCtor := GetInterceptRuleMgrCtor(interceptRuleMgrType)
+						interceptMgrCtor := plugin.GetInterceptRuleMgrCtor(interceptRuleMgrType)
 						if interceptMgrCtor == nil {
 							log.Errorf("Pod redirect failed due to unavailable InterceptRuleMgr of type %s",
 								interceptRuleMgrType)
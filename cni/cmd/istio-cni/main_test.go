@@ -17,7 +17,6 @@ package main
 import (
 	"fmt"
 	"os"
-	"reflect"
 	"strings"
 	"testing"
 
@@ -26,6 +25,9 @@ import (
 	"github.com/containernetworking/cni/pkg/types/current"
 	"github.com/containernetworking/plugins/pkg/testutils"
 	"k8s.io/client-go/kubernetes"
+
+	"istio.io/api/annotation"
+	"istio.io/istio/cni/pkg/plugin"
 )
 
 var (
@@ -94,7 +96,7 @@ var conf = `{
     }`
 
 type mockInterceptRuleMgr struct {
-	lastRedirect []*Redirect
+	lastRedirect []*plugin.Redirect
 }
 
 func init() {
@@ -102,13 +104,13 @@ func init() {
 	testAnnotations[sidecarStatusKey] = "true"
 }
 
-func (mrdir *mockInterceptRuleMgr) Program(netns string, redirect *Redirect) error {
+func (mrdir *mockInterceptRuleMgr) Program(netns string, redirect *plugin.Redirect) error {
 	nsenterFuncCalled = true
 	mrdir.lastRedirect = append(mrdir.lastRedirect, redirect)
 	return nil
 }
 
-func NewMockInterceptRuleMgr() InterceptRuleMgr {
+func NewMockInterceptRuleMgr() plugin.InterceptRuleMgr {
 	return singletonMockInterceptRuleMgr
 }
 
@@ -233,92 +235,92 @@ func TestCmdAddTwoContainers(t *testing.T) {
 	if !nsenterFuncCalled {
 		t.Fatalf("expected nsenterFunc to be called")
 	}
-	mockIntercept, ok := GetInterceptRuleMgrCtor("mock")().(*mockInterceptRuleMgr)
+	mockIntercept, ok := plugin.GetInterceptRuleMgrCtor("mock")().(*mockInterceptRuleMgr)
 	if !ok {
-		t.Fatalf("expect using mockInterceptRuleMgr, actual %v", InterceptRuleMgrTypes["mock"]())
+		t.Fatalf("expect using mockInterceptRuleMgr, actual %v", plugin.InterceptRuleMgrTypes["mock"]())
 	}
 	r := mockIntercept.lastRedirect[len(mockIntercept.lastRedirect)-1]
-	if r.includePorts != "*" {
-		t.Fatalf("expect includePorts has value '*' set by istio, actual %v", r.includePorts)
+	if r.IncludePorts != "*" {
+		t.Fatalf("expect includePorts has value '*' set by istio, actual %v", r.IncludePorts)
 	}
 }
 
 func TestCmdAddTwoContainersWithStarInboundPort(t *testing.T) {
 	defer resetGlobalTestVariables()
-	testAnnotations[includePortsKey] = "*"
+	testAnnotations[annotation.SidecarTrafficIncludeInboundPorts.Name] = "*"
 	testContainers = []string{"mockContainer", "mockContainer2"}
 	testCmdAdd(t)
 
 	if !nsenterFuncCalled {
 		t.Fatalf("expected nsenterFunc to be called")
 	}
-	mockIntercept, ok := GetInterceptRuleMgrCtor("mock")().(*mockInterceptRuleMgr)
+	mockIntercept, ok := plugin.GetInterceptRuleMgrCtor("mock")().(*mockInterceptRuleMgr)
 	if !ok {
-		t.Fatalf("expect using mockInterceptRuleMgr, actual %v", InterceptRuleMgrTypes["mock"]())
+		t.Fatalf("expect using mockInterceptRuleMgr, actual %v", plugin.InterceptRuleMgrTypes["mock"]())
 	}
 	r := mockIntercept.lastRedirect[len(mockIntercept.lastRedirect)-1]
-	if r.includePorts != "*" {
-		t.Fatalf("expect includePorts is '*', actual %v", r.includePorts)
+	if r.IncludePorts != "*" {
+		t.Fatalf("expect includePorts is '*', actual %v", r.IncludePorts)
 	}
 }
 
 func TestCmdAddTwoContainersWithEmptyInboundPort(t *testing.T) {
 	defer resetGlobalTestVariables()
-	delete(testAnnotations, includePortsKey)
+	delete(testAnnotations, annotation.SidecarTrafficIncludeInboundPorts.Name)
 	testContainers = []string{"mockContainer", "mockContainer2"}
-	testAnnotations[includePortsKey] = ""
+	testAnnotations[annotation.SidecarTrafficIncludeInboundPorts.Name] = ""
 	testCmdAdd(t)
 
 	if !nsenterFuncCalled {
 		t.Fatalf("expected nsenterFunc to be called")
 	}
-	mockIntercept, ok := GetInterceptRuleMgrCtor("mock")().(*mockInterceptRuleMgr)
+	mockIntercept, ok := plugin.GetInterceptRuleMgrCtor("mock")().(*mockInterceptRuleMgr)
 	if !ok {
-		t.Fatalf("expect using mockInterceptRuleMgr, actual %v", InterceptRuleMgrTypes["mock"])
+		t.Fatalf("expect using mockInterceptRuleMgr, actual %v", plugin.InterceptRuleMgrTypes["mock"])
 	}
 	r := mockIntercept.lastRedirect[len(mockIntercept.lastRedirect)-1]
-	if r.includePorts != "" {
-		t.Fatalf("expect includePorts is \"\", actual %v", r.includePorts)
+	if r.IncludePorts != "" {
+		t.Fatalf("expect includePorts is \"\", actual %v", r.IncludePorts)
 	}
 }
 
 func TestCmdAddTwoContainersWithEmptyExcludeInboundPort(t *testing.T) {
 	defer resetGlobalTestVariables()
-	delete(testAnnotations, includePortsKey)
+	delete(testAnnotations, annotation.SidecarTrafficIncludeInboundPorts.Name)
 	testContainers = []string{"mockContainer", "mockContainer2"}
-	testAnnotations[excludeInboundPortsKey] = ""
+	testAnnotations[annotation.SidecarTrafficExcludeInboundPorts.Name] = ""
 	testCmdAdd(t)
 
 	if !nsenterFuncCalled {
 		t.Fatalf("expected nsenterFunc to be called")
 	}
-	mockIntercept, ok := GetInterceptRuleMgrCtor("mock")().(*mockInterceptRuleMgr)
+	mockIntercept, ok := plugin.GetInterceptRuleMgrCtor("mock")().(*mockInterceptRuleMgr)
 	if !ok {
-		t.Fatalf("expect using mockInterceptRuleMgr, actual %v", InterceptRuleMgrTypes["mock"])
+		t.Fatalf("expect using mockInterceptRuleMgr, actual %v", plugin.InterceptRuleMgrTypes["mock"])
 	}
 	r := mockIntercept.lastRedirect[len(mockIntercept.lastRedirect)-1]
-	if r.excludeInboundPorts != "15020,15021,15090" {
-		t.Fatalf("expect excludeInboundPorts is \"15090\", actual %v", r.excludeInboundPorts)
+	if r.ExcludeInboundPorts != "15020,15021,15090" {
+		t.Fatalf("expect excludeInboundPorts is \"15090\", actual %v", r.ExcludeInboundPorts)
 	}
 }
 
 func TestCmdAddTwoContainersWithExplictExcludeInboundPort(t *testing.T) {
 	defer resetGlobalTestVariables()
-	delete(testAnnotations, includePortsKey)
+	delete(testAnnotations, annotation.SidecarTrafficIncludeInboundPorts.Name)
 	testContainers = []string{"mockContainer", "mockContainer2"}
-	testAnnotations[excludeInboundPortsKey] = "3306"
+	testAnnotations[annotation.SidecarTrafficExcludeInboundPorts.Name] = "3306"
 	testCmdAdd(t)
 
 	if !nsenterFuncCalled {
 		t.Fatalf("expected nsenterFunc to be called")
 	}
-	mockIntercept, ok := GetInterceptRuleMgrCtor("mock")().(*mockInterceptRuleMgr)
+	mockIntercept, ok := plugin.GetInterceptRuleMgrCtor("mock")().(*mockInterceptRuleMgr)
 	if !ok {
-		t.Fatalf("expect using mockInterceptRuleMgr, actual %v", InterceptRuleMgrTypes["mock"])
+		t.Fatalf("expect using mockInterceptRuleMgr, actual %v", plugin.InterceptRuleMgrTypes["mock"])
 	}
 	r := mockIntercept.lastRedirect[len(mockIntercept.lastRedirect)-1]
-	if r.excludeInboundPorts != "3306,15020,15021,15090" {
-		t.Fatalf("expect excludeInboundPorts is \"3306,15090\", actual %v", r.excludeInboundPorts)
+	if r.ExcludeInboundPorts != "3306,15020,15021,15090" {
+		t.Fatalf("expect excludeInboundPorts is \"3306,15090\", actual %v", r.ExcludeInboundPorts)
 	}
 }
 
@@ -369,18 +371,18 @@ func TestCmdAddExcludePodWithIstioInitContainer(t *testing.T) {
 func TestCmdAddWithKubevirtInterfaces(t *testing.T) {
 	defer resetGlobalTestVariables()
 
-	testAnnotations[kubevirtInterfacesKey] = "net1,net2"
+	testAnnotations[annotation.SidecarTrafficKubevirtInterfaces.Name] = "net1,net2"
 	testContainers = []string{"mockContainer"}
 
 	testCmdAdd(t)
 
-	value, ok := testAnnotations[kubevirtInterfacesKey]
+	value, ok := testAnnotations[annotation.SidecarTrafficKubevirtInterfaces.Name]
 	if !ok {
 		t.Fatalf("expected kubevirtInterfaces annotation to exist")
 	}
 
-	if value != testAnnotations[kubevirtInterfacesKey] {
-		t.Fatalf(fmt.Sprintf("expected kubevirtInterfaces annotation to equals %s", testAnnotations[kubevirtInterfacesKey]))
+	if value != testAnnotations[annotation.SidecarTrafficKubevirtInterfaces.Name] {
+		t.Fatalf(fmt.Sprintf("expected kubevirtInterfaces annotation to equals %s", testAnnotations[annotation.SidecarTrafficKubevirtInterfaces.Name]))
 	}
 }
 
@@ -442,58 +444,14 @@ func TestCmdDelInvalidVersion(t *testing.T) {
 	testCmdInvalidVersion(t, cmdDel)
 }
 
-func MockInterceptRuleMgrCtor() InterceptRuleMgr {
+func MockInterceptRuleMgrCtor() plugin.InterceptRuleMgr {
 	return NewMockInterceptRuleMgr()
 }
 
 func TestMain(m *testing.M) {
 	// call flag.Parse() here if TestMain uses flags
 
-	InterceptRuleMgrTypes["mock"] = MockInterceptRuleMgrCtor
+	plugin.InterceptRuleMgrTypes["mock"] = MockInterceptRuleMgrCtor
 
 	os.Exit(m.Run())
 }
-
-func Test_dedupPorts(t *testing.T) {
-	type args struct {
-		ports []string
-	}
-	tests := []struct {
-		name string
-		args args
-		want []string
-	}{
-		{
-			name: "No duplicates",
-			args: args{ports: []string{"1234", "2345"}},
-			want: []string{"1234", "2345"},
-		},
-		{
-			name: "Sequential Duplicates",
-			args: args{ports: []string{"1234", "1234", "2345", "2345"}},
-			want: []string{"1234", "2345"},
-		},
-		{
-			name: "Mixed Duplicates",
-			args: args{ports: []string{"1234", "2345", "1234", "2345"}},
-			want: []string{"1234", "2345"},
-		},
-		{
-			name: "Empty",
-			args: args{ports: []string{}},
-			want: []string{},
-		},
-		{
-			name: "Non-parseable",
-			args: args{ports: []string{"abcd", "2345", "abcd"}},
-			want: []string{"abcd", "2345"},
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := dedupPorts(tt.args.ports); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("dedupPorts() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
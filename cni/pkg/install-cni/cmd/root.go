@@ -18,6 +18,7 @@ import (
 	"context"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -41,9 +42,10 @@ var rootCmd = &cobra.Command{
 		}
 		log.Infof("install cni with configuration: \n%+v", cfg)
 
-		isReady := install.StartServer()
+		status := install.NewStatus()
+		isReady := install.StartServer(status)
 
-		installer := install.NewInstaller(cfg, isReady)
+		installer := install.NewInstaller(cfg, isReady, status)
 
 		if err = installer.Run(ctx); err != nil {
 			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
@@ -88,6 +90,11 @@ func init() {
 	registerBooleanParameter(constants.SkipTLSVerify, false, "Whether to use insecure TLS in kubeconfig file")
 	registerBooleanParameter(constants.UpdateCNIBinaries, true, "Update binaries")
 	registerStringArrayParameter(constants.SkipCNIBinaries, []string{}, "Binaries that should not be installed")
+	registerDurationParameter(constants.ReconcileInterval, 30*time.Second,
+		"Interval at which the installation is verified, in addition to the verifications on CNI config changes")
+	registerBooleanParameter(constants.TaintNode, false,
+		"Whether to add the readiness taint to the node while the Istio CNI config is not installed. Requires the taint controller, "+
+			"and the POD_NAME and POD_NAMESPACE environment variables")
 }
 
 func registerStringParameter(name, value, usage string) {
@@ -110,6 +117,11 @@ func registerBooleanParameter(name string, value bool, usage string) {
 	bindViper(name)
 }
 
+func registerDurationParameter(name string, value time.Duration, usage string) {
+	rootCmd.Flags().Duration(name, value, usage)
+	bindViper(name)
+}
+
 func bindViper(name string) {
 	if err := viper.BindPFlag(name, rootCmd.Flags().Lookup(name)); err != nil {
 		log.Error(err)
@@ -136,9 +148,14 @@ func constructConfig() (*config.Config, error) {
 		K8sServiceHost:     os.Getenv("KUBERNETES_SERVICE_HOST"),
 		K8sServicePort:     os.Getenv("KUBERNETES_SERVICE_PORT"),
 		K8sNodeName:        os.Getenv("KUBERNETES_NODE_NAME"),
+		K8sPodName:         os.Getenv("POD_NAME"),
+		K8sPodNamespace:    os.Getenv("POD_NAMESPACE"),
 
 		UpdateCNIBinaries: viper.GetBool(constants.UpdateCNIBinaries),
 		SkipCNIBinaries:   viper.GetStringSlice(constants.SkipCNIBinaries),
+
+		ReconcileInterval: viper.GetDuration(constants.ReconcileInterval),
+		TaintNode:         viper.GetBool(constants.TaintNode),
 	}
 
 	if len(cfg.K8sNodeName) == 0 {
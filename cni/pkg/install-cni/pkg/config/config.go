@@ -17,6 +17,7 @@ package config
 import (
 	"fmt"
 	"strings"
+	"time"
 )
 
 // Config struct defines the Istio CNI installation options
@@ -39,9 +40,18 @@ type Config struct {
 	K8sServiceHost     string
 	K8sServicePort     string
 	K8sNodeName        string
+	K8sPodName         string
+	K8sPodNamespace    string
 
 	UpdateCNIBinaries bool
 	SkipCNIBinaries   []string
+
+	// ReconcileInterval is the interval at which the installation is verified, in addition to the
+	// verifications triggered by modifications of the CNI config directory.
+	ReconcileInterval time.Duration
+	// TaintNode adds the readiness taint to the node while the Istio CNI config is not installed, once
+	// the install-cni pod is reported not ready.
+	TaintNode bool
 }
 
 func (c *Config) String() string {
@@ -63,7 +73,11 @@ func (c *Config) String() string {
 	b.WriteString("K8sServiceHost: " + c.K8sServiceHost + "\n")
 	b.WriteString("K8sServicePort: " + fmt.Sprint(c.K8sServicePort) + "\n")
 	b.WriteString("K8sNodeName: " + c.K8sNodeName + "\n")
+	b.WriteString("K8sPodName: " + c.K8sPodName + "\n")
+	b.WriteString("K8sPodNamespace: " + c.K8sPodNamespace + "\n")
 	b.WriteString("UpdateCNIBinaries: " + fmt.Sprint(c.UpdateCNIBinaries) + "\n")
 	b.WriteString("SkipCNIBinaries: " + fmt.Sprint(c.SkipCNIBinaries) + "\n")
+	b.WriteString("ReconcileInterval: " + c.ReconcileInterval.String() + "\n")
+	b.WriteString("TaintNode: " + fmt.Sprint(c.TaintNode) + "\n")
 	return b.String()
 }
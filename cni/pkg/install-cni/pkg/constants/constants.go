@@ -29,6 +29,8 @@ const (
 	SkipTLSVerify        = "skip-tls-verify"
 	SkipCNIBinaries      = "skip-cni-binaries"
 	UpdateCNIBinaries    = "update-cni-binaries"
+	ReconcileInterval    = "reconcile-interval"
+	TaintNode            = "taint-node"
 )
 
 // Internal constants
@@ -42,5 +44,7 @@ var (
 	// K8s liveness and readiness endpoints
 	LivenessEndpoint  = "/healthz"
 	ReadinessEndpoint = "/readyz"
-	Port              = "8000"
+	// Installation status endpoint
+	StatusEndpoint = "/status"
+	Port           = "8000"
 )
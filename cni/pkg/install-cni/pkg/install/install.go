@@ -21,12 +21,18 @@ import (
 	"os"
 	"path/filepath"
 	"sync/atomic"
+	"time"
 
 	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/kubectl/pkg/util/podutils"
 
 	"istio.io/istio/cni/pkg/install-cni/pkg/config"
 	"istio.io/istio/cni/pkg/install-cni/pkg/constants"
 	"istio.io/istio/cni/pkg/install-cni/pkg/util"
+	"istio.io/istio/cni/pkg/taint"
 	"istio.io/istio/pkg/file"
 	"istio.io/pkg/log"
 )
@@ -34,16 +40,19 @@ import (
 type Installer struct {
 	cfg                *config.Config
 	isReady            *atomic.Value
+	status             *Status
+	kubeClient         kubernetes.Interface
 	saToken            string
 	kubeconfigFilepath string
 	cniConfigFilepath  string
 }
 
 // NewInstaller returns an instance of Installer with the given config
-func NewInstaller(cfg *config.Config, isReady *atomic.Value) *Installer {
+func NewInstaller(cfg *config.Config, isReady *atomic.Value, status *Status) *Installer {
 	return &Installer{
 		cfg:     cfg,
 		isReady: isReady,
+		status:  status,
 	}
 }
 
@@ -66,15 +75,73 @@ func (in *Installer) Run(ctx context.Context) (err error) {
 		if in.cniConfigFilepath, err = createCNIConfigFile(ctx, in.cfg, in.saToken); err != nil {
 			return
 		}
+		in.status.setCNIConfigFile(in.cniConfigFilepath)
+		in.status.installed()
 
-		if err = sleepCheckInstall(ctx, in.cfg, in.cniConfigFilepath, in.isReady); err != nil {
+		if err = sleepCheckInstall(ctx, in.cfg, in.cniConfigFilepath, in.isReady, in.status); err != nil {
 			return
 		}
 		// Invalid config; pod set to "NotReady"
+		in.taintNode(ctx)
 		log.Info("Restarting...")
 	}
 }
 
+// taintNodeInterval is the interval at which the readiness of the install-cni pod is checked before tainting the node.
+var taintNodeInterval = time.Second
+
+// taintNode adds the readiness taint to the node, so that no pods are scheduled on it while the
+// Istio CNI config is not installed. The taint controller removes the taint when the install-cni pod is ready,
+// and would remove it right away while the pod is still reported ready, so the taint is only added once the
+// pod is reported not ready. It is not added if the config is installed again in the meantime.
+func (in *Installer) taintNode(ctx context.Context) {
+	if !in.cfg.TaintNode {
+		return
+	}
+	if in.cfg.K8sPodName == "" || in.cfg.K8sPodNamespace == "" {
+		log.Warnf("Failed to taint node %s: the pod name and namespace are not set", in.cfg.K8sNodeName)
+		return
+	}
+	if in.kubeClient == nil {
+		restConfig, err := rest.InClusterConfig()
+		if err != nil {
+			log.Warnf("Failed to taint node %s: %v", in.cfg.K8sNodeName, err)
+			return
+		}
+		if in.kubeClient, err = kubernetes.NewForConfig(restConfig); err != nil {
+			log.Warnf("Failed to taint node %s: %v", in.cfg.K8sNodeName, err)
+			return
+		}
+	}
+	go in.taintNodeWhenNotReady(ctx, in.kubeClient)
+}
+
+// taintNodeWhenNotReady waits until the install-cni pod is reported not ready to taint the node, and returns
+// without tainting it if the config is installed again or the context is canceled.
+func (in *Installer) taintNodeWhenNotReady(ctx context.Context, client kubernetes.Interface) {
+	ticker := time.NewTicker(taintNodeInterval)
+	defer ticker.Stop()
+	for {
+		if in.isReady.Load().(bool) {
+			return
+		}
+		pod, err := client.CoreV1().Pods(in.cfg.K8sPodNamespace).Get(ctx, in.cfg.K8sPodName, metav1.GetOptions{})
+		if err != nil {
+			log.Debugf("Failed to get pod %s/%s: %v", in.cfg.K8sPodNamespace, in.cfg.K8sPodName, err)
+		} else if !podutils.IsPodReady(pod) {
+			if err := taint.AddReadinessTaintToNode(client, in.cfg.K8sNodeName); err != nil {
+				log.Warnf("Failed to taint node %s: %v", in.cfg.K8sNodeName, err)
+			}
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
 // Cleanup remove Istio CNI's config, kubeconfig file, and binaries.
 func (in *Installer) Cleanup() error {
 	log.Info("Cleaning up.")
@@ -154,9 +221,11 @@ func readServiceAccountToken() (string, error) {
 }
 
 // sleepCheckInstall verifies the configuration then blocks until an invalid configuration is detected, and return nil.
+// The configuration is verified again on every modification of the CNI config directory, and periodically in case
+// a modification is missed.
 // If an error occurs or context is canceled, the function will return the error.
 // Returning from this function will set the pod to "NotReady".
-func sleepCheckInstall(ctx context.Context, cfg *config.Config, cniConfigFilepath string, isReady *atomic.Value) error {
+func sleepCheckInstall(ctx context.Context, cfg *config.Config, cniConfigFilepath string, isReady *atomic.Value, status *Status) error {
 	// Create file watcher before checking for installation
 	// so that no file modifications are missed while and after checking
 	watcher, fileModified, errChan, err := util.CreateFileWatcher(cfg.MountedCNINetDir)
@@ -169,7 +238,9 @@ func sleepCheckInstall(ctx context.Context, cfg *config.Config, cniConfigFilepat
 	}()
 
 	for {
-		if checkErr := checkInstall(cfg, cniConfigFilepath); checkErr != nil {
+		checkErr := checkInstall(cfg, cniConfigFilepath)
+		status.checked(checkErr)
+		if checkErr != nil {
 			// Pod set to "NotReady" due to invalid configuration
 			log.Infof("Invalid configuration. %v", checkErr)
 			return nil
@@ -185,7 +256,7 @@ func sleepCheckInstall(ctx context.Context, cfg *config.Config, cniConfigFilepat
 		default:
 			// Valid configuration; set isReady to true and wait for modifications before checking again
 			SetReady(isReady)
-			if err = util.WaitForFileMod(ctx, fileModified, errChan); err != nil {
+			if err = waitForFileModOrReconcile(ctx, fileModified, errChan, cfg.ReconcileInterval); err != nil {
 				// Pod set to "NotReady" before termination
 				return err
 			}
@@ -193,6 +264,26 @@ func sleepCheckInstall(ctx context.Context, cfg *config.Config, cniConfigFilepat
 	}
 }
 
+// waitForFileModOrReconcile waits until a file is modified or the reconcile interval elapses (returns nil),
+// the context is cancelled (returns context error), or returns error
+func waitForFileModOrReconcile(ctx context.Context, fileModified chan bool, errChan chan error, interval time.Duration) error {
+	if interval <= 0 {
+		return util.WaitForFileMod(ctx, fileModified, errChan)
+	}
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	select {
+	case <-fileModified:
+		return nil
+	case err := <-errChan:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
 // checkInstall returns an error if an invalid CNI configuration is detected
 func checkInstall(cfg *config.Config, cniConfigFilepath string) error {
 	defaultCNIConfigFilename, err := getDefaultCNINetwork(cfg.MountedCNINetDir)
@@ -224,12 +315,17 @@ func checkInstall(cfg *config.Config, cniConfigFilepath string) error {
 		if err != nil {
 			return errors.Wrap(err, cniConfigFilepath)
 		}
-		for _, rawPlugin := range plugins {
+		for i, rawPlugin := range plugins {
 			plugin, err := util.GetPlugin(rawPlugin)
 			if err != nil {
 				return errors.Wrap(err, cniConfigFilepath)
 			}
 			if plugin["type"] == "istio-cni" {
+				// istio-cni is installed as the last plugin, so that it is called after the plugins setting up
+				// the pod network, and is given their results.
+				if i != len(plugins)-1 {
+					return fmt.Errorf("istio-cni CNI config moved in CNI config file: %s", cniConfigFilepath)
+				}
 				return nil
 			}
 		}
@@ -25,8 +25,13 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
 
 	"istio.io/istio/cni/pkg/install-cni/pkg/config"
+	"istio.io/istio/cni/pkg/taint"
 	"istio.io/istio/pkg/file"
 )
 
@@ -73,6 +78,13 @@ func TestCheckInstall(t *testing.T) {
 			chainedCNIPlugin:  true,
 			existingConfFiles: map[string]string{"list.conflist": "list.conflist"},
 		},
+		{
+			name:              "istio-cni config moved in CNI config file",
+			expectedFailure:   true,
+			cniConfigFilename: "list.conflist",
+			chainedCNIPlugin:  true,
+			existingConfFiles: map[string]string{"list-moved-istio.conflist": "list.conflist"},
+		},
 		{
 			name:              "chained CNI plugin",
 			cniConfigFilename: "list.conflist",
@@ -170,6 +182,7 @@ func TestSleepCheckInstall(t *testing.T) {
 			cniConfigFilepath := filepath.Join(tempDir, c.cniConfigFilename)
 			isReady := &atomic.Value{}
 			SetNotReady(isReady)
+			status := NewStatus()
 
 			if len(c.invalidConfigFilename) > 0 {
 				// Copy an invalid config file into tempDir
@@ -179,7 +192,7 @@ func TestSleepCheckInstall(t *testing.T) {
 			}
 
 			t.Log("Expecting an invalid configuration log:")
-			if err = sleepCheckInstall(ctx, cfg, cniConfigFilepath, isReady); err != nil {
+			if err = sleepCheckInstall(ctx, cfg, cniConfigFilepath, isReady, status); err != nil {
 				t.Fatalf("error should be nil due to invalid config, got: %v", err)
 			}
 			assert.Falsef(t, isReady.Load().(bool), "isReady should still be false")
@@ -216,7 +229,7 @@ func TestSleepCheckInstall(t *testing.T) {
 			// Should detect a valid configuration and wait indefinitely for a file modification
 			errChan := make(chan error)
 			go func(ctx context.Context, cfg *config.Config, cniConfigFilepath string, isReady *atomic.Value) {
-				errChan <- sleepCheckInstall(ctx, cfg, cniConfigFilepath, isReady)
+				errChan <- sleepCheckInstall(ctx, cfg, cniConfigFilepath, isReady, status)
 			}(ctx, cfg, cniConfigFilepath, isReady)
 
 			select {
@@ -257,3 +270,187 @@ func TestSleepCheckInstall(t *testing.T) {
 		})
 	}
 }
+
+func TestSleepCheckInstallReconcile(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "test-reconcile-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err = file.AtomicCopy(filepath.Join("testdata", "list.conflist.golden"), tempDir, "list.conflist"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cfg := &config.Config{
+		MountedCNINetDir:  tempDir,
+		ChainedCNIPlugin:  true,
+		ReconcileInterval: 100 * time.Millisecond,
+	}
+	isReady := &atomic.Value{}
+	SetNotReady(isReady)
+	status := NewStatus()
+	errChan := make(chan error)
+	go func() {
+		errChan <- sleepCheckInstall(ctx, cfg, filepath.Join(tempDir, "list.conflist"), isReady, status)
+	}()
+
+	// Verifications happen periodically even without modifications of the CNI config directory.
+	var firstCheck *time.Time
+	if err := retry(func() error {
+		r := status.report(isReady.Load().(bool))
+		if !r.Ready || r.LastCheckTime == nil {
+			return fmt.Errorf("installation not verified")
+		}
+		if firstCheck == nil {
+			firstCheck = r.LastCheckTime
+			return fmt.Errorf("installation verified once")
+		}
+		if !r.LastCheckTime.After(*firstCheck) {
+			return fmt.Errorf("installation not verified again")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+	if err := <-errChan; err != context.Canceled {
+		t.Fatalf("expected the context error, got %v", err)
+	}
+}
+
+func TestStatusInstalled(t *testing.T) {
+	status := NewStatus()
+	status.installed()
+	status.checked(nil)
+	if r := status.report(true); r.Repairs != 0 || r.LastCheckError != "" {
+		t.Fatalf("expected no repairs, got %+v", r)
+	}
+
+	status.checked(fmt.Errorf("istio-cni CNI config removed"))
+	if r := status.report(false); r.Ready || r.LastCheckError != "istio-cni CNI config removed" {
+		t.Fatalf("expected the check error, got %+v", r)
+	}
+	status.installed()
+	r := status.report(false)
+	if r.Repairs != 1 || r.LastRepairReason != "istio-cni CNI config removed" || r.LastRepairTime == nil {
+		t.Fatalf("expected a repair, got %+v", r)
+	}
+}
+
+func TestTaintNodeWhenNotReady(t *testing.T) {
+	interval := taintNodeInterval
+	taintNodeInterval = 10 * time.Millisecond
+	defer func() { taintNodeInterval = interval }()
+
+	setPodReady := func(t *testing.T, client kubernetes.Interface, ready corev1.ConditionStatus) {
+		t.Helper()
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "istio-cni-node", Namespace: "kube-system"},
+			Status: corev1.PodStatus{
+				Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: ready}},
+			},
+		}
+		var err error
+		if _, err = client.CoreV1().Pods(pod.Namespace).Get(context.TODO(), pod.Name, metav1.GetOptions{}); err != nil {
+			_, err = client.CoreV1().Pods(pod.Namespace).Create(context.TODO(), pod, metav1.CreateOptions{})
+		} else {
+			_, err = client.CoreV1().Pods(pod.Namespace).Update(context.TODO(), pod, metav1.UpdateOptions{})
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	tainted := func(client kubernetes.Interface) bool {
+		node, err := client.CoreV1().Nodes().Get(context.TODO(), "node", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tt := range node.Spec.Taints {
+			if tt.Key == taint.TaintName {
+				return true
+			}
+		}
+		return false
+	}
+	newInstaller := func(t *testing.T) (*Installer, kubernetes.Interface) {
+		client := fake.NewSimpleClientset(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node"}})
+		// The pod is still reported ready when the invalid config is detected.
+		setPodReady(t, client, corev1.ConditionTrue)
+		isReady := &atomic.Value{}
+		SetNotReady(isReady)
+		in := NewInstaller(&config.Config{
+			TaintNode:       true,
+			K8sNodeName:     "node",
+			K8sPodName:      "istio-cni-node",
+			K8sPodNamespace: "kube-system",
+		}, isReady, NewStatus())
+		in.kubeClient = client
+		return in, client
+	}
+
+	t.Run("pod not ready", func(t *testing.T) {
+		in, client := newInstaller(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		done := make(chan struct{})
+		go func() {
+			in.taintNodeWhenNotReady(ctx, client)
+			close(done)
+		}()
+
+		// The taint controller would remove the taint while the pod is ready.
+		time.Sleep(5 * taintNodeInterval)
+		if tainted(client) {
+			t.Fatal("node tainted while the pod is ready")
+		}
+		setPodReady(t, client, corev1.ConditionFalse)
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("node not tainted once the pod is not ready")
+		}
+		if !tainted(client) {
+			t.Fatal("node not tainted once the pod is not ready")
+		}
+	})
+
+	t.Run("config installed again", func(t *testing.T) {
+		in, client := newInstaller(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		done := make(chan struct{})
+		go func() {
+			in.taintNodeWhenNotReady(ctx, client)
+			close(done)
+		}()
+
+		SetReady(in.isReady)
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected to stop waiting once the config is installed again")
+		}
+		setPodReady(t, client, corev1.ConditionFalse)
+		if tainted(client) {
+			t.Fatal("node tainted after the config was installed again")
+		}
+	})
+}
+
+// retry calls fn until it succeeds or times out.
+func retry(fn func() error) error {
+	var err error
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		if err = fn(); err == nil {
+			return nil
+		}
+	}
+	return err
+}
@@ -15,16 +15,17 @@
 package install
 
 import (
+	"encoding/json"
 	"net/http"
 	"sync/atomic"
 
 	"istio.io/istio/cni/pkg/install-cni/pkg/constants"
 )
 
-// StartServer initializes and starts a web server that exposes liveness and readiness endpoints at port 8000.
-func StartServer() *atomic.Value {
+// StartServer initializes and starts a web server that exposes liveness, readiness and status endpoints at port 8000.
+func StartServer(status *Status) *atomic.Value {
 	router := http.NewServeMux()
-	isReady := initRouter(router)
+	isReady := initRouter(router, status)
 
 	go func() {
 		_ = http.ListenAndServe(":"+constants.Port, router)
@@ -43,12 +44,13 @@ func SetNotReady(isReady *atomic.Value) {
 	isReady.Store(false)
 }
 
-func initRouter(router *http.ServeMux) *atomic.Value {
+func initRouter(router *http.ServeMux, status *Status) *atomic.Value {
 	isReady := &atomic.Value{}
 	isReady.Store(false)
 
 	router.HandleFunc(constants.LivenessEndpoint, healthz)
 	router.HandleFunc(constants.ReadinessEndpoint, readyz(isReady))
+	router.HandleFunc(constants.StatusEndpoint, statusz(isReady, status))
 
 	return isReady
 }
@@ -66,3 +68,15 @@ func readyz(isReady *atomic.Value) http.HandlerFunc {
 		w.WriteHeader(http.StatusOK)
 	}
 }
+
+func statusz(isReady *atomic.Value, status *Status) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		b, err := json.MarshalIndent(status.report(isReady.Load().(bool)), "", "  ")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(b)
+	}
+}
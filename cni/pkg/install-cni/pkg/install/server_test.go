@@ -15,6 +15,7 @@
 package install
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -26,7 +27,8 @@ import (
 
 func TestServer(t *testing.T) {
 	router := http.NewServeMux()
-	isReady := initRouter(router)
+	status := NewStatus()
+	isReady := initRouter(router, status)
 
 	assert.Falsef(t, isReady.Load().(bool), "isReady should be initialized to false")
 
@@ -49,6 +51,34 @@ func TestServer(t *testing.T) {
 	makeReq(t, server.URL, constants.ReadinessEndpoint, http.StatusServiceUnavailable)
 }
 
+func TestServerStatus(t *testing.T) {
+	router := http.NewServeMux()
+	status := NewStatus()
+	isReady := initRouter(router, status)
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	status.setCNIConfigFile("/host/etc/cni/net.d/10-calico.conflist")
+	status.checked(nil)
+	SetReady(isReady)
+
+	res, err := http.Get(server.URL + constants.StatusEndpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	var report StatusReport
+	if err := json.NewDecoder(res.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, report.Ready)
+	assert.Equal(t, "/host/etc/cni/net.d/10-calico.conflist", report.CNIConfigFile)
+	assert.NotNil(t, report.LastCheckTime)
+	assert.Equal(t, 0, report.Repairs)
+}
+
 func makeReq(t *testing.T, url, endpoint string, expectedStatusCode int) {
 	t.Helper()
 	res, err := http.Get(url + endpoint)
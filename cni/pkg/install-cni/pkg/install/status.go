@@ -0,0 +1,100 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package install
+
+import (
+	"sync"
+	"time"
+)
+
+// Status tracks the state of the Istio CNI installation on the node, as reported by the status endpoint.
+type Status struct {
+	mu               sync.RWMutex
+	cniConfigFile    string
+	lastCheck        time.Time
+	lastCheckErr     error
+	repairs          int
+	lastRepair       time.Time
+	lastRepairReason string
+}
+
+// StatusReport is the JSON document served by the status endpoint.
+type StatusReport struct {
+	// Ready is true when the Istio CNI config is installed, that is when new pods are protected.
+	Ready         bool   `json:"ready"`
+	CNIConfigFile string `json:"cniConfigFile,omitempty"`
+	// LastCheckTime is the last time the installation was verified.
+	LastCheckTime  *time.Time `json:"lastCheckTime,omitempty"`
+	LastCheckError string     `json:"lastCheckError,omitempty"`
+	// Repairs is the number of times the Istio CNI config was reinstalled after being removed or modified.
+	Repairs          int        `json:"repairs"`
+	LastRepairTime   *time.Time `json:"lastRepairTime,omitempty"`
+	LastRepairReason string     `json:"lastRepairReason,omitempty"`
+}
+
+// NewStatus returns an empty Status
+func NewStatus() *Status {
+	return &Status{}
+}
+
+func (s *Status) setCNIConfigFile(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cniConfigFile = path
+}
+
+// checked records the result of a verification of the installation.
+func (s *Status) checked(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastCheck = time.Now()
+	s.lastCheckErr = err
+}
+
+// installed records a (re)installation of the Istio CNI config. It is counted as a repair if the
+// last verification had found the installation invalid.
+func (s *Status) installed() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lastCheckErr == nil {
+		return
+	}
+	s.repairs++
+	s.lastRepair = time.Now()
+	s.lastRepairReason = s.lastCheckErr.Error()
+}
+
+func (s *Status) report(ready bool) StatusReport {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r := StatusReport{
+		Ready:            ready,
+		CNIConfigFile:    s.cniConfigFile,
+		Repairs:          s.repairs,
+		LastRepairReason: s.lastRepairReason,
+	}
+	if !s.lastCheck.IsZero() {
+		t := s.lastCheck
+		r.LastCheckTime = &t
+	}
+	if s.lastCheckErr != nil {
+		r.LastCheckError = s.lastCheckErr.Error()
+	}
+	if !s.lastRepair.IsZero() {
+		t := s.lastRepair
+		r.LastRepairTime = &t
+	}
+	return r
+}
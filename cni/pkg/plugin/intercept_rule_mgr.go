@@ -12,10 +12,10 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main
+package plugin
 
 const (
-	defInterceptRuleMgrType = "iptables"
+	DefaultInterceptRuleMgrType = "iptables"
 )
 
 // InterceptRuleMgr configures networking tables (e.g. iptables or nftables) for
@@ -24,10 +24,17 @@ type InterceptRuleMgr interface {
 	Program(netns string, redirect *Redirect) error
 }
 
+// InterceptRuleRepairer is implemented by the InterceptRuleMgr's able to repair the networking
+// tables of a pod whose redirection was not (or not correctly) set up.
+type InterceptRuleRepairer interface {
+	Repair(netns string, redirect *Redirect) error
+}
+
 type InterceptRuleMgrCtor func() InterceptRuleMgr
 
 var InterceptRuleMgrTypes = map[string]InterceptRuleMgrCtor{
 	"iptables": IptablesInterceptRuleMgrCtor,
+	"nftables": NftablesInterceptRuleMgrCtor,
 }
 
 // Constructor factory for known types of InterceptRuleMgr's
@@ -39,3 +46,8 @@ func GetInterceptRuleMgrCtor(interceptType string) InterceptRuleMgrCtor {
 func IptablesInterceptRuleMgrCtor() InterceptRuleMgr {
 	return newIPTables()
 }
+
+// Constructor for nftables InterceptRuleMgr
+func NftablesInterceptRuleMgrCtor() InterceptRuleMgr {
+	return newNFTables()
+}
@@ -14,7 +14,7 @@
 
 // This is a sample chained plugin that supports multiple CNI versions. It
 // parses prevResult according to the cniVersion
-package main
+package plugin
 
 import (
 	"fmt"
@@ -26,30 +26,60 @@ import (
 
 var nsSetupProg = "istio-iptables"
 
-type iptables struct{}
+// NsSetupBinDir is the directory holding the istio-iptables binary.
+var NsSetupBinDir = "/opt/cni/bin"
+
+type iptables struct {
+	// nftables programs the rules as an nftables ruleset instead of iptables rules.
+	nftables bool
+}
 
 func newIPTables() InterceptRuleMgr {
 	return &iptables{}
 }
 
+func newNFTables() InterceptRuleMgr {
+	return &iptables{nftables: true}
+}
+
 // Program defines a method which programs iptables based on the parameters
 // provided in Redirect.
 func (ipt *iptables) Program(netns string, rdrct *Redirect) error {
+	return ipt.nsenter(netns, nil, rdrct)
+}
+
+// Repair re-applies the rules if they differ from the ones Program would install, and fails if
+// they still differ afterwards. The nftables ruleset is always replaced atomically, so programming
+// it again is enough.
+func (ipt *iptables) Repair(netns string, rdrct *Redirect) error {
+	if ipt.nftables {
+		return ipt.Program(netns, rdrct)
+	}
+	return ipt.nsenter(netns, []string{"verify", "--repair"}, rdrct)
+}
+
+func (ipt *iptables) nsenter(netns string, subcommand []string, rdrct *Redirect) error {
 	netnsArg := fmt.Sprintf("--net=%s", netns)
-	nsSetupExecutable := fmt.Sprintf("%s/%s", nsSetupBinDir, nsSetupProg)
+	nsSetupExecutable := fmt.Sprintf("%s/%s", NsSetupBinDir, nsSetupProg)
 	nsenterArgs := []string{
 		netnsArg,
 		"--", // separate nsenter args from the rest with `--`, needed for hosts using BusyBox binaries
 		nsSetupExecutable,
-		"-p", rdrct.targetPort,
-		"-u", rdrct.noRedirectUID,
-		"-m", rdrct.redirectMode,
-		"-i", rdrct.includeIPCidrs,
-		"-b", rdrct.includePorts,
-		"-d", rdrct.excludeInboundPorts,
-		"-o", rdrct.excludeOutboundPorts,
-		"-x", rdrct.excludeIPCidrs,
-		"-k", rdrct.kubevirtInterfaces,
+	}
+	nsenterArgs = append(nsenterArgs, subcommand...)
+	nsenterArgs = append(nsenterArgs,
+		"-p", rdrct.TargetPort,
+		"-u", rdrct.NoRedirectUID,
+		"-m", rdrct.RedirectMode,
+		"-i", rdrct.IncludeIPCidrs,
+		"-b", rdrct.IncludePorts,
+		"-d", rdrct.ExcludeInboundPorts,
+		"-o", rdrct.ExcludeOutboundPorts,
+		"-x", rdrct.ExcludeIPCidrs,
+		"-k", rdrct.KubevirtInterfaces,
+	)
+	if ipt.nftables {
+		nsenterArgs = append(nsenterArgs, "--nftables")
 	}
 	log.Infof("nsenter args: %s", strings.Join(nsenterArgs, " "))
 	out, err := exec.Command("nsenter", nsenterArgs...).CombinedOutput()
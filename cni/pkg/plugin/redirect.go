@@ -12,8 +12,9 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Defines the redirect object and operations.
-package main
+// Package plugin holds the traffic redirection logic of the istio-cni plugin, shared with the
+// repair controller so that broken pods can be repaired in place.
+package plugin
 
 import (
 	"fmt"
@@ -39,6 +40,9 @@ const (
 )
 
 var (
+	injectAnnotationKey = annotation.SidecarInject.Name
+	sidecarStatusKey    = annotation.SidecarStatus.Name
+
 	includeIPCidrsKey       = annotation.SidecarTrafficIncludeOutboundIPRanges.Name
 	excludeIPCidrsKey       = annotation.SidecarTrafficExcludeOutboundIPRanges.Name
 	includePortsKey         = annotation.SidecarTrafficIncludeInboundPorts.Name
@@ -66,15 +70,15 @@ var (
 
 // Redirect -- the istio-cni redirect object
 type Redirect struct {
-	targetPort           string
-	redirectMode         string
-	noRedirectUID        string
-	includeIPCidrs       string
-	includePorts         string
-	excludeIPCidrs       string
-	excludeInboundPorts  string
-	excludeOutboundPorts string
-	kubevirtInterfaces   string
+	TargetPort           string
+	RedirectMode         string
+	NoRedirectUID        string
+	IncludeIPCidrs       string
+	IncludePorts         string
+	ExcludeIPCidrs       string
+	ExcludeInboundPorts  string
+	ExcludeOutboundPorts string
+	KubevirtInterfaces   string
 }
 
 type annotationValidationFunc func(value string) error
@@ -196,43 +200,43 @@ func NewRedirect(annotations map[string]string) (*Redirect, error) {
 	var valErr error
 
 	redir := &Redirect{}
-	redir.targetPort = defaultRedirectToPort
-	isFound, redir.redirectMode, valErr = getAnnotationOrDefault("redirectMode", annotations)
+	redir.TargetPort = defaultRedirectToPort
+	isFound, redir.RedirectMode, valErr = getAnnotationOrDefault("redirectMode", annotations)
 	if valErr != nil {
 		log.Errorf("Annotation value error for value %s; annotationFound = %t: %v",
 			"redirectMode", isFound, valErr)
 		return nil, valErr
 	}
-	redir.noRedirectUID = defaultNoRedirectUID
-	isFound, redir.includeIPCidrs, valErr = getAnnotationOrDefault("includeIPCidrs", annotations)
+	redir.NoRedirectUID = defaultNoRedirectUID
+	isFound, redir.IncludeIPCidrs, valErr = getAnnotationOrDefault("includeIPCidrs", annotations)
 	if valErr != nil {
 		log.Errorf("Annotation value error for value %s; annotationFound = %t: %v",
 			"includeIPCidrs", isFound, valErr)
 		return nil, valErr
 	}
-	isFound, redir.includePorts, valErr = getAnnotationOrDefault("includePorts", annotations)
+	isFound, redir.IncludePorts, valErr = getAnnotationOrDefault("includePorts", annotations)
 	if valErr != nil {
 		log.Errorf("Annotation value error for redirect ports, using ContainerPorts=\"%s\": %v",
-			redir.includePorts, valErr)
+			redir.IncludePorts, valErr)
 		return nil, valErr
 	}
 	if !isFound {
 		// reflect injection-template: istio fill the value only when the annotation is not set
-		redir.includePorts = "*"
+		redir.IncludePorts = "*"
 	}
-	isFound, redir.excludeIPCidrs, valErr = getAnnotationOrDefault("excludeIPCidrs", annotations)
+	isFound, redir.ExcludeIPCidrs, valErr = getAnnotationOrDefault("excludeIPCidrs", annotations)
 	if valErr != nil {
 		log.Errorf("Annotation value error for value %s; annotationFound = %t: %v",
 			"excludeIPCidrs", isFound, valErr)
 		return nil, valErr
 	}
-	isFound, redir.excludeInboundPorts, valErr = getAnnotationOrDefault("excludeInboundPorts", annotations)
+	isFound, redir.ExcludeInboundPorts, valErr = getAnnotationOrDefault("excludeInboundPorts", annotations)
 	if valErr != nil {
 		log.Errorf("Annotation value error for value %s; annotationFound = %t: %v",
 			"excludeInboundPorts", isFound, valErr)
 		return nil, valErr
 	}
-	isFound, redir.excludeOutboundPorts, valErr = getAnnotationOrDefault("excludeOutboundPorts", annotations)
+	isFound, redir.ExcludeOutboundPorts, valErr = getAnnotationOrDefault("excludeOutboundPorts", annotations)
 	if valErr != nil {
 		log.Errorf("Annotation value error for value %s; annotationFound = %t: %v",
 			"excludeOutboundPorts", isFound, valErr)
@@ -240,13 +244,13 @@ func NewRedirect(annotations map[string]string) (*Redirect, error) {
 	}
 	// Add 15090 to sync with non-cni injection template
 	// TODO: Revert below once https://github.com/istio/istio/pull/23037 or its follow up is merged.
-	redir.excludeInboundPorts = strings.TrimSpace(redir.excludeInboundPorts)
-	if len(redir.excludeInboundPorts) > 0 && redir.excludeInboundPorts[len(redir.excludeInboundPorts)-1] != ',' {
-		redir.excludeInboundPorts += ","
+	redir.ExcludeInboundPorts = strings.TrimSpace(redir.ExcludeInboundPorts)
+	if len(redir.ExcludeInboundPorts) > 0 && redir.ExcludeInboundPorts[len(redir.ExcludeInboundPorts)-1] != ',' {
+		redir.ExcludeInboundPorts += ","
 	}
-	redir.excludeInboundPorts += "15020,15021,15090"
-	redir.excludeInboundPorts = strings.Join(dedupPorts(splitPorts(redir.excludeInboundPorts)), ",")
-	isFound, redir.kubevirtInterfaces, valErr = getAnnotationOrDefault("kubevirtInterfaces", annotations)
+	redir.ExcludeInboundPorts += "15020,15021,15090"
+	redir.ExcludeInboundPorts = strings.Join(dedupPorts(splitPorts(redir.ExcludeInboundPorts)), ",")
+	isFound, redir.KubevirtInterfaces, valErr = getAnnotationOrDefault("kubevirtInterfaces", annotations)
 	if valErr != nil {
 		log.Errorf("Annotation value error for value %s; annotationFound = %t: %v",
 			"kubevirtInterfaces", isFound, valErr)
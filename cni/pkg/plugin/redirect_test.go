@@ -0,0 +1,64 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_dedupPorts(t *testing.T) {
+	type args struct {
+		ports []string
+	}
+	tests := []struct {
+		name string
+		args args
+		want []string
+	}{
+		{
+			name: "No duplicates",
+			args: args{ports: []string{"1234", "2345"}},
+			want: []string{"1234", "2345"},
+		},
+		{
+			name: "Sequential Duplicates",
+			args: args{ports: []string{"1234", "1234", "2345", "2345"}},
+			want: []string{"1234", "2345"},
+		},
+		{
+			name: "Mixed Duplicates",
+			args: args{ports: []string{"1234", "2345", "1234", "2345"}},
+			want: []string{"1234", "2345"},
+		},
+		{
+			name: "Empty",
+			args: args{ports: []string{}},
+			want: []string{},
+		},
+		{
+			name: "Non-parseable",
+			args: args{ports: []string{"abcd", "2345", "abcd"}},
+			want: []string{"abcd", "2345"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dedupPorts(tt.args.ports); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("dedupPorts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repair
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/cni/pkg/plugin"
+	"istio.io/pkg/log"
+)
+
+const (
+	eventSourceComponent = "istio-cni-repair"
+
+	reasonRepaired     = "RedirectionRepaired"
+	reasonRepairFailed = "RedirectionRepairFailed"
+)
+
+// procDir is the proc filesystem used to find the network namespace of pods. Processes of other pods
+// are only visible when running in the host PID namespace.
+var procDir = "/proc"
+
+// repairBrokenPod re-applies the traffic redirection in the network namespace of the pod. The
+// validation init container passes on its next restart once the redirection is in place.
+func (bpr BrokenPodReconciler) repairBrokenPod(pod v1.Pod) error {
+	m := podsRepaired.With(typeLabel.Value(repairType))
+	// Added for safety, to make sure no healthy pods get repaired.
+	if !bpr.detectPod(pod) {
+		m.With(resultLabel.Value(resultSkip)).Increment()
+		return nil
+	}
+	log.Infof("Pod detected as broken, repairing the traffic redirection: %s/%s", pod.Namespace, pod.Name)
+
+	if err := bpr.repairRedirection(pod); err != nil {
+		m.With(resultLabel.Value(resultFail)).Increment()
+		bpr.recordEvent(pod, v1.EventTypeWarning, reasonRepairFailed,
+			fmt.Sprintf("Failed to repair the traffic redirection: %v", err))
+		return err
+	}
+	m.With(resultLabel.Value(resultSuccess)).Increment()
+	bpr.recordEvent(pod, v1.EventTypeNormal, reasonRepaired, "Repaired the traffic redirection")
+	return nil
+}
+
+func (bpr BrokenPodReconciler) repairRedirection(pod v1.Pod) error {
+	redirect, err := plugin.NewRedirect(pod.Annotations)
+	if err != nil {
+		return err
+	}
+	interceptType := bpr.Options.InterceptRuleMgrType
+	if interceptType == "" {
+		interceptType = plugin.DefaultInterceptRuleMgrType
+	}
+	ctor := plugin.GetInterceptRuleMgrCtor(interceptType)
+	if ctor == nil {
+		return fmt.Errorf("unavailable InterceptRuleMgr of type %s", interceptType)
+	}
+	repairer, ok := ctor().(plugin.InterceptRuleRepairer)
+	if !ok {
+		return fmt.Errorf("InterceptRuleMgr of type %s does not support repairs", interceptType)
+	}
+	netns, err := findNetNS(pod)
+	if err != nil {
+		return err
+	}
+	return repairer.Repair(netns, redirect)
+}
+
+// findNetNS returns the network namespace of a process belonging to the pod, found from the cgroups
+// of the processes. The cgroupfs driver names the pod cgroup after the pod UID, the systemd driver
+// after the UID with the dashes replaced by underscores.
+func findNetNS(pod v1.Pod) (string, error) {
+	if pod.UID == "" {
+		return "", fmt.Errorf("pod %s/%s has no UID", pod.Namespace, pod.Name)
+	}
+	podCgroups := []string{
+		"pod" + string(pod.UID),
+		"pod" + strings.ReplaceAll(string(pod.UID), "-", "_"),
+	}
+	processes, err := ioutil.ReadDir(procDir)
+	if err != nil {
+		return "", err
+	}
+	for _, p := range processes {
+		if _, err := strconv.Atoi(p.Name()); err != nil || !p.IsDir() {
+			continue
+		}
+		cgroups, err := ioutil.ReadFile(filepath.Join(procDir, p.Name(), "cgroup"))
+		if err != nil {
+			// The process exited in the meantime.
+			continue
+		}
+		for _, c := range podCgroups {
+			if strings.Contains(string(cgroups), c) {
+				return filepath.Join(procDir, p.Name(), "ns", "net"), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no process found for pod %s/%s", pod.Namespace, pod.Name)
+}
+
+// recordEvent records an event on the pod. Failures are only logged, as events are informational.
+func (bpr BrokenPodReconciler) recordEvent(pod v1.Pod, eventType, reason, message string) {
+	now := metav1.NewTime(time.Now())
+	event := &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%v.%x", pod.Name, now.UnixNano()),
+			Namespace: pod.Namespace,
+		},
+		InvolvedObject: v1.ObjectReference{
+			Kind:            "Pod",
+			APIVersion:      "v1",
+			Namespace:       pod.Namespace,
+			Name:            pod.Name,
+			UID:             pod.UID,
+			ResourceVersion: pod.ResourceVersion,
+		},
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		Source:         v1.EventSource{Component: eventSourceComponent, Host: pod.Spec.NodeName},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	if _, err := bpr.client.CoreV1().Events(pod.Namespace).Create(context.TODO(), event, metav1.CreateOptions{}); err != nil {
+		log.Warnf("Failed to record event %s on pod %s/%s: %v", reason, pod.Namespace, pod.Name, err)
+	}
+}
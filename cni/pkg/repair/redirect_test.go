@@ -0,0 +1,212 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repair
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.opencensus.io/tag"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"istio.io/istio/cni/pkg/plugin"
+	"istio.io/istio/tools/istio-iptables/pkg/constants"
+)
+
+const testPodUID = "0d8f3f0c-6c4e-4d1a-9a3b-5b8a3f2e7c11"
+
+type mockRepairer struct {
+	err      error
+	netns    []string
+	redirect []*plugin.Redirect
+}
+
+func (m *mockRepairer) Program(netns string, redirect *plugin.Redirect) error {
+	return nil
+}
+
+func (m *mockRepairer) Repair(netns string, redirect *plugin.Redirect) error {
+	m.netns = append(m.netns, netns)
+	m.redirect = append(m.redirect, redirect)
+	return m.err
+}
+
+// setupProcDir creates a fake proc filesystem with processes in the given cgroups.
+func setupProcDir(t *testing.T, cgroups map[string]string) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "proc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	for pid, cgroup := range cgroups {
+		if err := os.MkdirAll(filepath.Join(dir, pid), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, pid, "cgroup"), []byte(cgroup), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := procDir
+	procDir = dir
+	t.Cleanup(func() { procDir = old })
+}
+
+func TestFindNetNS(t *testing.T) {
+	pod := v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "ns", UID: testPodUID}}
+	tests := []struct {
+		name    string
+		cgroups map[string]string
+		wantPid string
+	}{
+		{
+			name: "cgroupfs",
+			cgroups: map[string]string{
+				"1":    "0::/init.scope\n",
+				"42":   "12:memory:/kubepods/besteffort/pod" + testPodUID + "/4f2d\n",
+				"self": "12:memory:/kubepods/besteffort/pod" + testPodUID + "/4f2d\n",
+			},
+			wantPid: "42",
+		},
+		{
+			name: "systemd",
+			cgroups: map[string]string{
+				"1":  "0::/init.scope\n",
+				"43": "0::/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod0d8f3f0c_6c4e_4d1a_9a3b_5b8a3f2e7c11.slice/cri-containerd-4f2d.scope\n",
+			},
+			wantPid: "43",
+		},
+		{
+			name: "not found",
+			cgroups: map[string]string{
+				"1":  "0::/init.scope\n",
+				"44": "12:memory:/kubepods/besteffort/pod1c8f3f0c-6c4e-4d1a-9a3b-5b8a3f2e7c11/4f2d\n",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupProcDir(t, tt.cgroups)
+			netns, err := findNetNS(pod)
+			if tt.wantPid == "" {
+				if err == nil {
+					t.Fatalf("expected an error, got netns %s", netns)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := filepath.Join(procDir, tt.wantPid, "ns", "net"); netns != want {
+				t.Fatalf("got netns %s, want %s", netns, want)
+			}
+		})
+	}
+}
+
+func TestBrokenPodReconciler_repairBrokenPods(t *testing.T) {
+	brokenPod := *brokenPodWaiting.DeepCopy()
+	brokenPod.UID = testPodUID
+	brokenPod.Annotations["traffic.sidecar.istio.io/excludeOutboundPorts"] = "3306"
+
+	tests := []struct {
+		name       string
+		repairErr  error
+		options    *Options
+		wantErr    bool
+		wantPods   int
+		wantReason string
+		wantTags   []tag.Tag
+	}{
+		{
+			name:       "Repaired",
+			options:    &Options{RepairPods: true, DeletePods: true},
+			wantPods:   2,
+			wantReason: reasonRepaired,
+			wantTags:   []tag.Tag{{Key: tag.Key(resultLabel), Value: resultSuccess}, {Key: tag.Key(typeLabel), Value: repairType}},
+		},
+		{
+			name:       "Repair failed falls back to deletion",
+			repairErr:  fmt.Errorf("nsenter failed"),
+			options:    &Options{RepairPods: true, DeletePods: true},
+			wantPods:   1,
+			wantReason: reasonRepairFailed,
+			wantTags:   []tag.Tag{{Key: tag.Key(resultLabel), Value: resultFail}, {Key: tag.Key(typeLabel), Value: repairType}},
+		},
+		{
+			name:       "Repair failed without fallback",
+			repairErr:  fmt.Errorf("nsenter failed"),
+			options:    &Options{RepairPods: true},
+			wantErr:    true,
+			wantPods:   2,
+			wantReason: reasonRepairFailed,
+			wantTags:   []tag.Tag{{Key: tag.Key(resultLabel), Value: resultFail}, {Key: tag.Key(typeLabel), Value: repairType}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exp := initStats(tt.name)
+			setupProcDir(t, map[string]string{
+				"42": "12:memory:/kubepods/besteffort/pod" + testPodUID + "/4f2d\n",
+			})
+			repairer := &mockRepairer{err: tt.repairErr}
+			plugin.InterceptRuleMgrTypes["mock"] = func() plugin.InterceptRuleMgr { return repairer }
+			defer delete(plugin.InterceptRuleMgrTypes, "mock")
+			tt.options.InterceptRuleMgrType = "mock"
+
+			bpr := BrokenPodReconciler{
+				client: fake.NewSimpleClientset(&workingPod, &brokenPod),
+				Filters: &Filters{
+					InitContainerName:     constants.ValidationContainerName,
+					InitContainerExitCode: constants.ValidationErrorCode,
+				},
+				Options: tt.options,
+			}
+			if err := bpr.RepairBrokenPods(); (err != nil) != tt.wantErr {
+				t.Errorf("RepairBrokenPods() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if len(repairer.netns) != 1 || repairer.netns[0] != filepath.Join(procDir, "42", "ns", "net") {
+				t.Errorf("expected a single repair in the pod netns, got %v", repairer.netns)
+			}
+			if got := repairer.redirect[0].ExcludeOutboundPorts; got != "3306" {
+				t.Errorf("expected the redirect from the pod annotations, got excluded outbound ports %q", got)
+			}
+			pods, err := bpr.client.CoreV1().Pods("").List(context.TODO(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(pods.Items) != tt.wantPods {
+				t.Errorf("got %d pods, want %d", len(pods.Items), tt.wantPods)
+			}
+			events, err := bpr.client.CoreV1().Events("").List(context.TODO(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(events.Items) != 1 || events.Items[0].Reason != tt.wantReason || events.Items[0].InvolvedObject.UID != brokenPod.UID {
+				t.Errorf("expected a single %s event on the pod, got %v", tt.wantReason, events.Items)
+			}
+			if err := checkStats(1, tt.wantTags, exp); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -32,6 +32,12 @@ type Options struct {
 	PodLabelValue string `json:"pod_label_value"`
 	LabelPods     bool   `json:"label_pods"`
 	DeletePods    bool   `json:"delete_broken_pods"`
+	// RepairPods re-applies the traffic redirection in the network namespace of broken pods. Pods
+	// which cannot be repaired are deleted or labeled, if enabled.
+	RepairPods bool `json:"repair_pods"`
+	// InterceptRuleMgrType is the type of InterceptRuleMgr used to repair pods, it must match the
+	// one used by the CNI plugin.
+	InterceptRuleMgrType string `json:"intercept_type"`
 }
 
 type Filters struct {
@@ -63,6 +69,17 @@ func NewBrokenPodReconciler(client client.Interface, filters *Filters, options *
 func (bpr BrokenPodReconciler) ReconcilePod(pod v1.Pod) (err error) {
 	log.Debugf("Reconciling pod %s", pod.Name)
 
+	if bpr.Options.RepairPods {
+		repairErr := bpr.repairBrokenPod(pod)
+		if repairErr == nil {
+			return nil
+		}
+		if !bpr.Options.DeletePods && !bpr.Options.LabelPods {
+			return repairErr
+		}
+		log.Warnf("Failed to repair pod %s/%s, falling back: %v", pod.Namespace, pod.Name, repairErr)
+	}
+
 	if bpr.Options.DeletePods {
 		err = multierr.Append(err, bpr.deleteBrokenPod(pod))
 	} else if bpr.Options.LabelPods {
@@ -71,6 +88,21 @@ func (bpr BrokenPodReconciler) ReconcilePod(pod v1.Pod) (err error) {
 	return err
 }
 
+// Repair the traffic redirection of all pods detected as broken by ListPods, deleting or labeling
+// the pods which cannot be repaired if enabled
+func (bpr BrokenPodReconciler) RepairBrokenPods() (err error) {
+	// Get a list of all broken pods
+	podList, err := bpr.ListBrokenPods()
+	if err != nil {
+		return err
+	}
+
+	for _, pod := range podList.Items {
+		err = multierr.Append(err, bpr.ReconcilePod(pod))
+	}
+	return err
+}
+
 // Label all pods detected as broken by ListPods with a customizable label
 func (bpr BrokenPodReconciler) LabelBrokenPods() (err error) {
 	// Get a list of all broken pods
@@ -160,6 +160,20 @@ func (ts *Setter) AddReadinessTaint(node *v1.Node) error {
 	return nil
 }
 
+// AddReadinessTaintToNode adds the readiness taint to the node with the given name. It is used by node
+// agents which detect the node is not ready before the readiness of their pod is updated.
+func AddReadinessTaintToNode(clientset client.Interface, nodeName string) error {
+	node, err := clientset.CoreV1().Nodes().Get(context.TODO(), nodeName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get node %v: %v", nodeName, err)
+	}
+	ts := &Setter{
+		configs: []ConfigSettings{},
+		Client:  clientset,
+	}
+	return ts.AddReadinessTaint(node)
+}
+
 // DeleteTaint removes all the the taints that have the same key and effect to given taintToDelete.
 func deleteTaint(taints []v1.Taint, taintToDelete *v1.Taint) []v1.Taint {
 	newTaints := []v1.Taint{}
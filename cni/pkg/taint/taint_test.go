@@ -153,6 +153,24 @@ func TestTaintSetter_AddReadinessTaint(t *testing.T) {
 	}
 }
 
+func TestAddReadinessTaintToNode(t *testing.T) {
+	client := fakeClientset([]v1.Pod{workingPod}, []v1.Node{plainNode}, []v1.ConfigMap{})
+	if err := AddReadinessTaintToNode(client, plainNode.Name); err != nil {
+		t.Fatalf("error happened in readiness %v", err.Error())
+	}
+	updatedNode, err := client.CoreV1().Nodes().Get(context.TODO(), plainNode.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("error happened in readiness %v", err.Error())
+	}
+	wantList := []v1.Taint{{Key: TaintName, Effect: v1.TaintEffectNoSchedule}}
+	if !reflect.DeepEqual(updatedNode.Spec.Taints, wantList) {
+		t.Errorf("AddReadinessTaintToNode() gotList = %v, want %v", updatedNode.Spec.Taints, wantList)
+	}
+	if err := AddReadinessTaintToNode(client, "missing-node"); err == nil {
+		t.Errorf("AddReadinessTaintToNode() expected an error for a missing node")
+	}
+}
+
 func TestTaintSetter_HasReadinessTaint(t *testing.T) {
 	tests := []struct {
 		name   string
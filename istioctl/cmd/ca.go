@@ -0,0 +1,297 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/util/retry"
+
+	"istio.io/istio/security/pkg/pki/ca"
+)
+
+func caCommand() *cobra.Command {
+	caCmd := &cobra.Command{
+		Use:   "ca",
+		Short: "Interact with the Istiod certificate authority",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.HelpFunc()(cmd, args)
+			return nil
+		},
+	}
+	caCmd.AddCommand(caStatusCommand())
+	caCmd.AddCommand(caRevokeCommand())
+	return caCmd
+}
+
+func caStatusCommand() *cobra.Command {
+	var outputFormat string
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Shows the state of the CA certificates rotation of each Istiod",
+		Long: `Show the certificate each Istiod signs workload certificates with, the roots it distributes
+to workloads, and the progress of the rotation of the plugged-in CA certificates.
+
+Istiod only rotates the plugged-in CA certificates when AUTO_RELOAD_PLUGIN_CERTS is set.`,
+		Example: `  # Show the CA certificates rotation status.
+  istioctl x ca status
+
+  # Show the full status, including the trusted roots, as JSON.
+  istioctl x ca status -o json`,
+		Args: cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			client, err := kubeClient(kubeconfig, configContext)
+			if err != nil {
+				return fmt.Errorf("failed to create k8s client: %w", err)
+			}
+			responses, err := client.AllDiscoveryDo(context.TODO(), istioNamespace, "/debug/ca_status")
+			if err != nil {
+				return err
+			}
+			return printCAStatus(c.OutOrStdout(), responses, outputFormat)
+		},
+	}
+	statusCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", summaryOutput, "Output format: one of json|short")
+	return statusCmd
+}
+
+func printCAStatus(w io.Writer, responses map[string][]byte, format string) error {
+	statuses := map[string]ca.RotationStatus{}
+	istiods := []string{}
+	for istiod, res := range responses {
+		status := ca.RotationStatus{}
+		if err := json.Unmarshal(res, &status); err != nil {
+			return fmt.Errorf("failed to parse the CA status of %s: %v: %s", istiod, err, strings.TrimSpace(string(res)))
+		}
+		statuses[istiod] = status
+		istiods = append(istiods, istiod)
+	}
+	sort.Strings(istiods)
+
+	switch format {
+	case jsonOutput:
+		by, err := json.MarshalIndent(statuses, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(by))
+		return err
+	case summaryOutput:
+	default:
+		return fmt.Errorf("output format %q not supported", format)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 3, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "ISTIOD\tPHASE\tSIGNING CERT\tPENDING SIGNING CERT\tTRUSTED ROOTS\tRETIRING ROOTS\tNEXT TRANSITION")
+	for _, istiod := range istiods {
+		s := statuses[istiod]
+		next := "-"
+		if s.NextTransition != nil {
+			next = s.NextTransition.Format(time.RFC3339)
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%d\t%s\n", istiod, s.Phase, formatCertInfo(s.SigningCert),
+			formatCertInfo(s.PendingSigningCert), len(s.TrustedRoots), len(s.RetiringRoots), next)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, istiod := range istiods {
+		if s := statuses[istiod]; s.LastError != "" {
+			_, _ = fmt.Fprintf(w, "\n%s: last rotation error: %s\n", istiod, s.LastError)
+		}
+	}
+	return nil
+}
+
+func formatCertInfo(c *ca.CertInfo) string {
+	if c == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%s (serial %s, expires %s)", c.Subject, c.SerialNumber, c.NotAfter.Format(time.RFC3339))
+}
+
+func caRevokeCommand() *cobra.Command {
+	var (
+		reason string
+		remove bool
+		list   bool
+	)
+	revokeCmd := &cobra.Command{
+		Use:   "revoke [<serial-number>|<spiffe-id>]...",
+		Short: "Revokes workload certificates signed by the Istiod CA",
+		Long: fmt.Sprintf(`Revoke workload certificates by hex encoded serial number, or all the certificates of a SPIFFE ID.
+
+The revocations are stored in the %s ConfigMap of the Istio namespace. Istiod refuses to sign
+certificates for the revoked SPIFFE IDs, and distributes a CRL of the certificates revoked by serial
+number to workloads, which reject revoked peers. The existing certificates of a revoked SPIFFE ID are
+not listed in the CRL: Istiod caps the TTL of workload certificates to CA_REVOCATION_CERT_TTL, so they
+expire within it. Workloads stop enforcing an expired CRL until Istiod renews it.
+
+Istiod only enforces the revocations when ENABLE_CA_REVOCATION is set.`, ca.RevocationsConfigMapName),
+		Example: `  # Revoke a certificate by serial number.
+  istioctl x ca revoke 3f:a0:12:9c --reason "key compromised"
+
+  # Revoke all the certificates of a workload identity.
+  istioctl x ca revoke spiffe://cluster.local/ns/default/sa/httpbin
+
+  # Remove a revocation.
+  istioctl x ca revoke --remove spiffe://cluster.local/ns/default/sa/httpbin
+
+  # List the revocations.
+  istioctl x ca revoke --list`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if list && len(args) > 0 {
+				return fmt.Errorf("--list does not take arguments")
+			}
+			if !list && len(args) == 0 {
+				return fmt.Errorf("at least one serial number or SPIFFE ID is required")
+			}
+			return nil
+		},
+		RunE: func(c *cobra.Command, args []string) error {
+			client, err := kubeClient(kubeconfig, configContext)
+			if err != nil {
+				return fmt.Errorf("failed to create k8s client: %w", err)
+			}
+			configMaps := client.Kube().CoreV1().ConfigMaps(istioNamespace)
+			var entries []ca.RevocationEntry
+			if list {
+				entries, err = getRevocations(configMaps)
+			} else {
+				entries, err = updateRevocations(configMaps, args, reason, remove, time.Now())
+			}
+			if err != nil {
+				return err
+			}
+			return printRevocations(c.OutOrStdout(), entries)
+		},
+	}
+	revokeCmd.PersistentFlags().StringVar(&reason, "reason", "", "Why the certificates are revoked")
+	revokeCmd.PersistentFlags().BoolVar(&remove, "remove", false, "Remove the revocations instead of adding them")
+	revokeCmd.PersistentFlags().BoolVar(&list, "list", false, "List the revocations")
+	return revokeCmd
+}
+
+// getRevocations returns the revocation entries stored in the revocations ConfigMap.
+func getRevocations(configMaps corev1.ConfigMapInterface) ([]ca.RevocationEntry, error) {
+	cm, err := configMaps.Get(context.TODO(), ca.RevocationsConfigMapName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get ConfigMap %s: %v", ca.RevocationsConfigMapName, err)
+	}
+	return ca.ParseRevocations(cm.Data[ca.RevocationsConfigMapKey])
+}
+
+// updateRevocations adds the revocation entries of targets to the revocations ConfigMap, or removes
+// them, and returns the updated entries.
+func updateRevocations(configMaps corev1.ConfigMapInterface, targets []string, reason string, remove bool,
+	now time.Time) ([]ca.RevocationEntry, error) {
+	requested := make([]ca.RevocationEntry, 0, len(targets))
+	for _, t := range targets {
+		entry, err := ca.NewRevocationEntry(t, reason, now)
+		if err != nil {
+			return nil, err
+		}
+		requested = append(requested, entry)
+	}
+
+	var entries []ca.RevocationEntry
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm, err := configMaps.Get(context.TODO(), ca.RevocationsConfigMapName, metav1.GetOptions{})
+		create := errors.IsNotFound(err)
+		if create {
+			cm = &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: ca.RevocationsConfigMapName},
+			}
+		} else if err != nil {
+			return fmt.Errorf("failed to get ConfigMap %s: %v", ca.RevocationsConfigMapName, err)
+		}
+		entries, err = ca.ParseRevocations(cm.Data[ca.RevocationsConfigMapKey])
+		if err != nil {
+			return err
+		}
+
+		revoked := map[string]bool{}
+		for _, e := range entries {
+			revoked[e.Target()] = true
+		}
+		if remove {
+			removed := map[string]bool{}
+			for _, r := range requested {
+				if !revoked[r.Target()] {
+					return fmt.Errorf("%s is not revoked", r.Target())
+				}
+				removed[r.Target()] = true
+			}
+			kept := []ca.RevocationEntry{}
+			for _, e := range entries {
+				if !removed[e.Target()] {
+					kept = append(kept, e)
+				}
+			}
+			entries = kept
+		} else {
+			for _, r := range requested {
+				if !revoked[r.Target()] {
+					revoked[r.Target()] = true
+					entries = append(entries, r)
+				}
+			}
+		}
+
+		data, err := ca.MarshalRevocations(entries)
+		if err != nil {
+			return err
+		}
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[ca.RevocationsConfigMapKey] = data
+		if create {
+			_, err = configMaps.Create(context.TODO(), cm, metav1.CreateOptions{})
+		} else {
+			_, err = configMaps.Update(context.TODO(), cm, metav1.UpdateOptions{})
+		}
+		return err
+	})
+	return entries, err
+}
+
+func printRevocations(w io.Writer, entries []ca.RevocationEntry) error {
+	if len(entries) == 0 {
+		_, err := fmt.Fprintln(w, "No certificates are revoked")
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 3, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "REVOKED\tREVOKED AT\tREASON")
+	for _, e := range entries {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\n", e.Target(), e.RevokedAt.Format(time.RFC3339), e.Reason)
+	}
+	return tw.Flush()
+}
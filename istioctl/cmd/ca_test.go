@@ -0,0 +1,128 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"istio.io/istio/security/pkg/pki/ca"
+)
+
+func TestPrintCAStatus(t *testing.T) {
+	responses := map[string][]byte{
+		"istiod-a.istio-system": []byte(`{"phase": "RetiringRoots", "phaseStarted": "2021-03-01T10:00:00Z",
+			"nextTransition": "2021-03-02T10:00:00Z",
+			"signingCert": {"subject": "O=intermediate2", "issuer": "O=root2", "serialNumber": "0a", "notAfter": "2022-03-01T10:00:00Z"},
+			"trustedRoots": [{"subject": "O=root2"}, {"subject": "O=root1"}],
+			"retiringRoots": [{"subject": "O=root1"}]}`),
+		"istiod-b.istio-system": []byte(`{"phase": "Disabled", "phaseStarted": "0001-01-01T00:00:00Z",
+			"trustedRoots": [{"subject": "O=root1"}], "lastError": "failed to read the plugged-in CA key"}`),
+	}
+	out := &bytes.Buffer{}
+	if err := printCAStatus(out, responses, summaryOutput); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(out.String(), "\n")
+	if len(lines) < 3 || !strings.HasPrefix(lines[1], "istiod-a") || !strings.HasPrefix(lines[2], "istiod-b") {
+		t.Fatalf("expected one line per istiod, sorted:\n%s", out.String())
+	}
+	for _, expected := range []string{
+		"RetiringRoots",
+		"O=intermediate2 (serial 0a, expires 2022-03-01T10:00:00Z)",
+		"2021-03-02T10:00:00Z",
+		"istiod-b.istio-system: last rotation error: failed to read the plugged-in CA key",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected %q in output:\n%s", expected, out.String())
+		}
+	}
+
+	err := printCAStatus(out, map[string][]byte{"istiod-a": []byte("istiod CA is not enabled")}, summaryOutput)
+	if err == nil || !strings.Contains(err.Error(), "istiod CA is not enabled") {
+		t.Errorf("expected error from istiod, got %v", err)
+	}
+}
+
+func TestUpdateRevocations(t *testing.T) {
+	now := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	configMaps := fake.NewSimpleClientset().CoreV1().ConfigMaps("istio-system")
+	id := "spiffe://cluster.local/ns/default/sa/httpbin"
+
+	targets := func(entries []ca.RevocationEntry) string {
+		res := []string{}
+		for _, e := range entries {
+			res = append(res, e.Target())
+		}
+		return strings.Join(res, ",")
+	}
+
+	if entries, err := getRevocations(configMaps); err != nil || len(entries) != 0 {
+		t.Fatalf("got %v, %v without the ConfigMap", entries, err)
+	}
+	entries, err := updateRevocations(configMaps, []string{"3F:A0", id}, "key compromised", false, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := targets(entries); got != "3fa0,"+id {
+		t.Fatalf("got revocations %s", got)
+	}
+	// Revoking again is a no-op.
+	if entries, err = updateRevocations(configMaps, []string{"3fa0", "0b"}, "", false, now); err != nil {
+		t.Fatal(err)
+	}
+	if got := targets(entries); got != "3fa0,"+id+",0b" {
+		t.Fatalf("got revocations %s", got)
+	}
+
+	cm, err := configMaps.Get(context.TODO(), ca.RevocationsConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := ca.ParseRevocations(cm.Data[ca.RevocationsConfigMapKey])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored[0].Reason != "key compromised" || !stored[0].RevokedAt.Equal(now) {
+		t.Fatalf("unexpected stored revocation %+v", stored[0])
+	}
+
+	if entries, err = updateRevocations(configMaps, []string{id}, "", true, now); err != nil {
+		t.Fatal(err)
+	}
+	if got := targets(entries); got != "3fa0,0b" {
+		t.Fatalf("got revocations %s", got)
+	}
+	if _, err = updateRevocations(configMaps, []string{id}, "", true, now); err == nil {
+		t.Fatalf("expected an error removing a revocation which doesn't exist")
+	}
+	if _, err = updateRevocations(configMaps, []string{"not-a-serial"}, "", false, now); err == nil {
+		t.Fatalf("expected an error revoking an invalid serial number")
+	}
+
+	out := &bytes.Buffer{}
+	if err := printRevocations(out, entries); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "3fa0      2021-03-01T10:00:00Z   key compromised") {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+}
@@ -30,6 +30,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"istio.io/istio/istioctl/pkg/clioptions"
+	"istio.io/istio/pkg/kube"
 	"istio.io/pkg/log"
 )
 
@@ -92,32 +93,15 @@ func run(c *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to create k8s client: %v", err)
 	}
 
-	pl, err := client.PodsForSelector(context.TODO(), istioNamespace, "app=prometheus")
-	if err != nil {
-		return fmt.Errorf("not able to locate Prometheus pod: %v", err)
-	}
-
-	if len(pl.Items) < 1 {
-		return errors.New("no Prometheus pods found")
-	}
-
-	// only use the first pod in the list
-	promPod := pl.Items[0]
-	fw, err := client.NewPortForwarder(promPod.Name, istioNamespace, "", 0, 9090)
+	fw, err := forwardToPrometheus(client)
 	if err != nil {
-		return fmt.Errorf("could not build port forwarder for prometheus: %v", err)
-	}
-
-	if err = fw.Start(); err != nil {
-		return fmt.Errorf("failure running port forward process: %v", err)
+		return err
 	}
 
 	// Close the forwarder either when we exit or when an this processes is interrupted.
 	defer fw.Close()
 	closePortForwarderOnInterrupt(fw)
 
-	log.Debugf("port-forward to prometheus pod ready")
-
 	promAPI, err := prometheusAPI(fmt.Sprintf("http://%s", fw.Address()))
 	if err != nil {
 		return fmt.Errorf("failure running port forward process: %v", err)
@@ -137,6 +121,32 @@ func run(c *cobra.Command, args []string) error {
 	return nil
 }
 
+// forwardToPrometheus starts a port forward to the first Prometheus pod in the istio system namespace.
+func forwardToPrometheus(client kube.ExtendedClient) (kube.PortForwarder, error) {
+	pl, err := client.PodsForSelector(context.TODO(), istioNamespace, "app=prometheus")
+	if err != nil {
+		return nil, fmt.Errorf("not able to locate Prometheus pod: %v", err)
+	}
+
+	if len(pl.Items) < 1 {
+		return nil, errors.New("no Prometheus pods found")
+	}
+
+	// only use the first pod in the list
+	promPod := pl.Items[0]
+	fw, err := client.NewPortForwarder(promPod.Name, istioNamespace, "", 0, 9090)
+	if err != nil {
+		return nil, fmt.Errorf("could not build port forwarder for prometheus: %v", err)
+	}
+
+	if err = fw.Start(); err != nil {
+		return nil, fmt.Errorf("failure running port forward process: %v", err)
+	}
+
+	log.Debugf("port-forward to prometheus pod ready")
+	return fw, nil
+}
+
 func prometheusAPI(address string) (promv1.API, error) {
 	promClient, err := api.NewClient(api.Config{Address: address})
 	if err != nil {
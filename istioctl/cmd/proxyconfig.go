@@ -15,22 +15,31 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/url"
 	"os"
 	"regexp"
+	"sort"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/ghodss/yaml"
+	"github.com/pmezard/go-difflib/difflib"
 	"github.com/spf13/cobra"
 
 	"istio.io/istio/istioctl/pkg/util/handlers"
 	"istio.io/istio/istioctl/pkg/writer/envoy/clusters"
 	"istio.io/istio/istioctl/pkg/writer/envoy/configdump"
 	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/xds"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
 	"istio.io/istio/pkg/config/host"
 	"istio.io/pkg/log"
 )
@@ -932,13 +941,156 @@ func secretConfigCmd() *cobra.Command {
 	return secretConfigCmd
 }
 
+func diffConfigCmd() *cobra.Command {
+	var fromVersion, toVersion string
+
+	diffConfigCmd := &cobra.Command{
+		Use:   "diff [<type>/]<name>[.<namespace>]",
+		Short: "Shows the configuration changes pushed to the Envoy in the specified pod",
+		Long: `Show the resources that changed between two configuration versions pushed by Istiod to the
+Envoy instance in the specified pod. Without --from, the versions kept by Istiod are listed.
+
+Istiod only keeps the history when PILOT_CONFIG_HISTORY_SIZE is set.`,
+		Example: `  # List the configuration versions kept for a given pod.
+  istioctl proxy-config diff <pod-name[.namespace]>
+
+  # Show the changes between a version and the latest one.
+  istioctl proxy-config diff <pod-name[.namespace]> --from 2021-03-01T10:00:00Z/12
+
+  # Show the raw changes between two versions as JSON.
+  istioctl proxy-config diff <pod-name[.namespace]> --from <version> --to <version> -o json`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				cmd.Println(cmd.UsageString())
+				return fmt.Errorf("diff requires pod name")
+			}
+			return nil
+		},
+		RunE: func(c *cobra.Command, args []string) error {
+			podName, podNamespace, err := getPodName(args[0])
+			if err != nil {
+				return err
+			}
+			client, err := kubeClient(kubeconfig, configContext)
+			if err != nil {
+				return fmt.Errorf("failed to create k8s client: %w", err)
+			}
+			query := url.Values{}
+			query.Set("proxyID", podName+"."+podNamespace)
+			if fromVersion != "" {
+				query.Set("from", fromVersion)
+				query.Set("to", toVersion)
+			}
+			responses, err := client.AllDiscoveryDo(context.TODO(), istioNamespace, "/debug/config_diff?"+query.Encode())
+			if err != nil {
+				return err
+			}
+			return printConfigDiff(c.OutOrStdout(), responses, fromVersion != "", outputFormat)
+		},
+	}
+
+	diffConfigCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", summaryOutput, "Output format: one of json|short")
+	diffConfigCmd.PersistentFlags().StringVar(&fromVersion, "from", "", "The version to compare from")
+	diffConfigCmd.PersistentFlags().StringVar(&toVersion, "to", "", "The version to compare to, defaults to the latest version")
+	diffConfigCmd.Long += "\n\n" + ExperimentalMsg
+	return diffConfigCmd
+}
+
+// printConfigDiff prints the response of the Istiod the proxy is connected to, as the others do not know it.
+func printConfigDiff(w io.Writer, responses map[string][]byte, isDiff bool, format string) error {
+	var diff *xds.ConfigDiff
+	var versions []xds.ConfigHistoryVersion
+	var errs []string
+	for istiod, res := range responses {
+		if isDiff {
+			d := &xds.ConfigDiff{}
+			if err := json.Unmarshal(res, d); err == nil {
+				diff = d
+				break
+			}
+		} else {
+			v := struct {
+				Versions []xds.ConfigHistoryVersion `json:"versions"`
+			}{}
+			if err := json.Unmarshal(res, &v); err == nil {
+				versions = v.Versions
+				break
+			}
+		}
+		errs = append(errs, fmt.Sprintf("%s: %s", istiod, strings.TrimSpace(string(res))))
+	}
+	if diff == nil && versions == nil {
+		sort.Strings(errs)
+		return fmt.Errorf("failed to get the config history:\n%s", strings.Join(errs, "\n"))
+	}
+
+	switch format {
+	case jsonOutput:
+		var out interface{} = diff
+		if !isDiff {
+			out = versions
+		}
+		by, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(by))
+		return err
+	case summaryOutput:
+	default:
+		return fmt.Errorf("output format %q not supported", format)
+	}
+
+	if !isDiff {
+		tw := tabwriter.NewWriter(w, 0, 8, 5, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "VERSION\tTIME\tRESOURCES\tCONTENT")
+		for _, v := range versions {
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%d\t%v\n", v.Version, v.Time.Format(time.RFC3339), v.Resources, v.ContentAvailable)
+		}
+		return tw.Flush()
+	}
+
+	_, _ = fmt.Fprintf(w, "Changes from %s to %s: %d\n", diff.From, diff.To, len(diff.Changes))
+	for _, c := range diff.Changes {
+		_, _ = fmt.Fprintf(w, "\n%s %s %s\n", c.Change, v3.GetShortType(c.TypeURL), c.Name)
+		from, to := indentJSON(c.From), indentJSON(c.To)
+		if from == "" && to == "" {
+			continue
+		}
+		text, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(from),
+			B:        difflib.SplitLines(to),
+			FromFile: diff.From,
+			ToFile:   diff.To,
+			Context:  3,
+		})
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprint(w, text)
+	}
+	return nil
+}
+
+func indentJSON(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	out := &bytes.Buffer{}
+	if err := json.Indent(out, raw, "", "  "); err != nil {
+		return string(raw) + "\n"
+	}
+	out.WriteString("\n")
+	return out.String()
+}
+
 func proxyConfig() *cobra.Command {
 	configCmd := &cobra.Command{
 		Use:   "proxy-config",
 		Short: "Retrieve information about proxy configuration from Envoy [kube only]",
 		Long:  `A group of commands used to retrieve information about proxy configuration from the Envoy config dump`,
 		Example: `  # Retrieve information about proxy configuration from an Envoy instance.
-  istioctl proxy-config <clusters|listeners|routes|endpoints|bootstrap|log|secret> <pod-name[.namespace]>`,
+  istioctl proxy-config <clusters|listeners|routes|endpoints|bootstrap|log|secret|diff> <pod-name[.namespace]>`,
 		Aliases: []string{"pc"},
 	}
 
@@ -952,6 +1104,7 @@ func proxyConfig() *cobra.Command {
 	configCmd.AddCommand(bootstrapConfigCmd())
 	configCmd.AddCommand(endpointConfigCmd())
 	configCmd.AddCommand(secretConfigCmd())
+	configCmd.AddCommand(diffConfigCmd())
 
 	return configCmd
 }
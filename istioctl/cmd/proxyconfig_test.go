@@ -229,3 +229,44 @@ func mockEnvoyClientFactoryGenerator(testResults map[string][]byte) func(kubecon
 
 	return outFactory
 }
+
+func TestPrintConfigDiff(t *testing.T) {
+	responses := map[string][]byte{
+		"istiod-a": []byte("Proxy not connected to this Pilot instance"),
+		"istiod-b": []byte(`{"from": "v1", "to": "v2", "changes": [
+			{"typeUrl": "type.googleapis.com/envoy.config.cluster.v3.Cluster", "name": "outbound|80||a.default.svc.cluster.local",
+			 "change": "modified", "from": {"name": "a", "connectTimeout": "1s"}, "to": {"name": "a", "connectTimeout": "2s"}},
+			{"typeUrl": "type.googleapis.com/envoy.config.listener.v3.Listener", "name": "0.0.0.0_80", "change": "removed"}]}`),
+	}
+	out := &bytes.Buffer{}
+	if err := printConfigDiff(out, responses, true, summaryOutput); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"Changes from v1 to v2: 2",
+		"modified CDS outbound|80||a.default.svc.cluster.local",
+		`-  "connectTimeout": "1s"`,
+		`+  "connectTimeout": "2s"`,
+		"removed LDS 0.0.0.0_80",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected %q in output:\n%s", expected, out.String())
+		}
+	}
+
+	out.Reset()
+	versions := map[string][]byte{
+		"istiod-a": []byte(`{"versions": [{"version": "v1", "time": "2021-03-01T10:00:00Z", "resources": 3, "contentAvailable": true}]}`),
+	}
+	if err := printConfigDiff(out, versions, false, summaryOutput); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "v1") || !strings.Contains(out.String(), "2021-03-01T10:00:00Z") {
+		t.Errorf("unexpected versions output:\n%s", out.String())
+	}
+
+	err := printConfigDiff(out, map[string][]byte{"istiod-a": []byte("Config history is disabled")}, true, summaryOutput)
+	if err == nil || !strings.Contains(err.Error(), "Config history is disabled") {
+		t.Errorf("expected error from istiod, got %v", err)
+	}
+}
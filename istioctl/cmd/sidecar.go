@@ -0,0 +1,256 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"istio.io/istio/istioctl/pkg/sidecar"
+	"istio.io/istio/istioctl/pkg/util/handlers"
+	"istio.io/istio/pkg/config/schema/collections"
+	"istio.io/pkg/log"
+)
+
+func sidecarCommand() *cobra.Command {
+	sidecarCmd := &cobra.Command{
+		Use:   "sidecar",
+		Short: "Commands to assist in managing Sidecar configuration",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("unknown subcommand %q", args[0])
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.HelpFunc()(cmd, args)
+			return nil
+		},
+	}
+	sidecarCmd.AddCommand(sidecarRecommendCommand())
+	return sidecarCmd
+}
+
+func sidecarRecommendCommand() *cobra.Command {
+	var (
+		usePrometheus bool
+		window        time.Duration
+		dryRun        bool
+	)
+	recommendCmd := &cobra.Command{
+		Use:   "recommend [<type>/]<name>[.<namespace>]",
+		Short: "Recommends a minimal Sidecar resource based on the traffic sent by a workload",
+		Long: `Recommends a minimal Sidecar resource for the workload of the given pod.
+
+The dependencies of the workload are derived from the traffic it actually sent: by default
+from the upstream_rq_total and upstream_cx_total statistics of each outbound cluster in the Envoy admin stats, or,
+with --prometheus, from the istio_requests_total and istio_tcp_connections_opened_total
+metrics reported by the workload over the given window.
+
+The recommendation is compared with the Sidecar scope currently computed by Istiod for the
+proxy. Use --dry-run to print the differences and an estimate of the configuration that
+would be removed, instead of the Sidecar resource.`,
+		Example: `  # Recommend a Sidecar for the workload of a pod
+  istioctl x sidecar recommend productpage-v1-c7765c886-7zzd4.default
+
+  # Use the requests seen by Prometheus over the last week
+  istioctl x sidecar recommend deployment/productpage-v1 --prometheus --window 168h
+
+  # Show the changes and the estimated config reduction
+  istioctl x sidecar recommend productpage-v1-c7765c886-7zzd4 --dry-run`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				cmd.Println(cmd.UsageString())
+				return fmt.Errorf("recommend requires pod name or deployment")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := kubeClient(kubeconfig, configContext)
+			if err != nil {
+				return fmt.Errorf("failed to create k8s client: %w", err)
+			}
+			podName, ns, err := handlers.InferPodInfoFromTypedResource(args[0],
+				handlers.HandleNamespace(namespace, defaultNamespace),
+				client.UtilFactory())
+			if err != nil {
+				return err
+			}
+			pod, err := client.CoreV1().Pods(ns).Get(context.TODO(), podName, metav1.GetOptions{})
+			if err != nil {
+				return fmt.Errorf("failed to get pod %s.%s: %v", podName, ns, err)
+			}
+			workload := workloadNameForPod(pod)
+
+			// The stats are always needed, as they list the clusters currently sent to the proxy.
+			stats, err := client.EnvoyDo(context.TODO(), podName, ns, "GET", "stats?filter="+url.QueryEscape(sidecar.StatsFilter), nil)
+			if err != nil {
+				return fmt.Errorf("failed to get stats for %s.%s: %v", podName, ns, err)
+			}
+			traffic, clusters, err := sidecar.ParseClusterStats(stats)
+			if err != nil {
+				return err
+			}
+
+			if usePrometheus {
+				fw, err := forwardToPrometheus(client)
+				if err != nil {
+					return err
+				}
+				defer fw.Close()
+				closePortForwarderOnInterrupt(fw)
+				promAPI, err := prometheusAPI(fmt.Sprintf("http://%s", fw.Address()))
+				if err != nil {
+					return err
+				}
+				if traffic, err = prometheusTraffic(promAPI, workload, ns, window); err != nil {
+					return err
+				}
+			}
+
+			var scope *sidecar.Scope
+			path := fmt.Sprintf("/debug/sidecarz?proxyID=%s.%s", podName, ns)
+			scopes, err := client.AllDiscoveryDo(context.TODO(), istioNamespace, path)
+			if err != nil {
+				return err
+			}
+			// Only the Istiod instance the proxy is connected to knows its scope.
+			for _, data := range scopes {
+				if s, err := sidecar.ParseScope(data); err == nil {
+					scope = s
+					break
+				}
+			}
+			if scope == nil {
+				log.Warnf("could not find the sidecar scope of %s.%s, the recommendation is not compared with it", podName, ns)
+			}
+
+			r, err := sidecar.Recommend(workload, ns, workloadSelector(pod), traffic, scope, clusters)
+			if err != nil {
+				return err
+			}
+			if dryRun {
+				r.PrintDiff(cmd.OutOrStdout())
+				return nil
+			}
+			out, err := sidecarYAML(r)
+			if err != nil {
+				return err
+			}
+			_, err = cmd.OutOrStdout().Write(out)
+			return err
+		},
+	}
+	recommendCmd.PersistentFlags().BoolVar(&usePrometheus, "prometheus", false,
+		"Derive the dependencies from Prometheus metrics instead of the Envoy stats")
+	recommendCmd.PersistentFlags().DurationVar(&window, "window", 24*time.Hour,
+		"The time window of Prometheus metrics to consider")
+	recommendCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false,
+		"Print the changes compared to the current Sidecar scope instead of the Sidecar resource")
+	return recommendCmd
+}
+
+// workloadNameForPod returns the name of the workload owning the pod, matching the Istio source_workload label.
+func workloadNameForPod(pod *v1.Pod) string {
+	for _, ref := range pod.OwnerReferences {
+		if ref.Controller == nil || !*ref.Controller {
+			continue
+		}
+		if ref.Kind == "ReplicaSet" {
+			if hash, f := pod.Labels["pod-template-hash"]; f {
+				return strings.TrimSuffix(ref.Name, "-"+hash)
+			}
+		}
+		return ref.Name
+	}
+	return pod.Name
+}
+
+// workloadSelector returns the labels selecting the pods of the workload.
+func workloadSelector(pod *v1.Pod) map[string]string {
+	if app, f := pod.Labels["app"]; f {
+		selector := map[string]string{"app": app}
+		if version, f := pod.Labels["version"]; f {
+			selector["version"] = version
+		}
+		return selector
+	}
+	selector := map[string]string{}
+	for k, v := range pod.Labels {
+		if k == "pod-template-hash" {
+			continue
+		}
+		selector[k] = v
+	}
+	return selector
+}
+
+// prometheusTraffic returns the destinations the workload sent requests or opened connections to over the window.
+func prometheusTraffic(promAPI promv1.API, workload, ns string, window time.Duration) (sidecar.Traffic, error) {
+	traffic := sidecar.Traffic{}
+	rangeStr := model.Duration(window).String()
+	for _, metric := range []string{reqTot, "istio_tcp_connections_opened_total"} {
+		query := fmt.Sprintf(`sum(increase(%s{reporter="source",source_workload=%q,source_workload_namespace=%q}[%s])) by (destination_service)`,
+			metric, workload, ns, rangeStr)
+		log.Debugf("executing query: %s", query)
+		val, _, err := promAPI.Query(context.Background(), query, time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("query() failure for '%s': %v", query, err)
+		}
+		vector, ok := val.(model.Vector)
+		if !ok {
+			return nil, errors.New("bad metric value type returned for query")
+		}
+		for _, sample := range vector {
+			dest := string(sample.Metric["destination_service"])
+			if dest == "" || dest == "unknown" || sample.Value <= 0 {
+				continue
+			}
+			traffic.Add(dest, uint64(sample.Value))
+		}
+	}
+	return traffic, nil
+}
+
+func sidecarYAML(r *sidecar.Recommendation) ([]byte, error) {
+	u := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": collections.IstioNetworkingV1Alpha3Sidecars.Resource().APIVersion(),
+			"kind":       collections.IstioNetworkingV1Alpha3Sidecars.Resource().Kind(),
+			"metadata": map[string]interface{}{
+				"name":      r.Name,
+				"namespace": r.Namespace,
+			},
+		},
+	}
+	spec, err := unstructureIstioType(r.Sidecar)
+	if err != nil {
+		return nil, err
+	}
+	u.Object["spec"] = spec
+	return yaml.Marshal(u.Object)
+}
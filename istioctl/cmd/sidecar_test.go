@@ -0,0 +1,100 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/istioctl/pkg/sidecar"
+)
+
+func TestWorkloadNameForPod(t *testing.T) {
+	controller := true
+	cases := []struct {
+		name     string
+		pod      *v1.Pod
+		expected string
+	}{
+		{
+			name: "deployment",
+			pod: &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Name:            "productpage-v1-c7765c886-7zzd4",
+				Labels:          map[string]string{"pod-template-hash": "c7765c886"},
+				OwnerReferences: []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "productpage-v1-c7765c886", Controller: &controller}},
+			}},
+			expected: "productpage-v1",
+		},
+		{
+			name: "statefulset",
+			pod: &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Name:            "db-0",
+				OwnerReferences: []metav1.OwnerReference{{Kind: "StatefulSet", Name: "db", Controller: &controller}},
+			}},
+			expected: "db",
+		},
+		{
+			name:     "bare pod",
+			pod:      &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "debug"}},
+			expected: "debug",
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := workloadNameForPod(tt.pod); got != tt.expected {
+				t.Fatalf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestWorkloadSelector(t *testing.T) {
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Labels: map[string]string{"app": "reviews", "version": "v2", "pod-template-hash": "abc"},
+	}}
+	if got := workloadSelector(pod); !reflect.DeepEqual(got, map[string]string{"app": "reviews", "version": "v2"}) {
+		t.Fatalf("unexpected selector %v", got)
+	}
+	pod.Labels = map[string]string{"role": "db", "pod-template-hash": "abc"}
+	if got := workloadSelector(pod); !reflect.DeepEqual(got, map[string]string{"role": "db"}) {
+		t.Fatalf("unexpected selector %v", got)
+	}
+}
+
+func TestSidecarYAML(t *testing.T) {
+	r, err := sidecar.Recommend("reviews-v2", "default", map[string]string{"app": "reviews"},
+		sidecar.Traffic{"ratings.default.svc.cluster.local": 3}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := sidecarYAML(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"kind: Sidecar",
+		"name: reviews-v2",
+		"- default/ratings.default.svc.cluster.local",
+		"app: reviews",
+	} {
+		if !strings.Contains(string(out), expected) {
+			t.Errorf("expected %q in output:\n%s", expected, out)
+		}
+	}
+}
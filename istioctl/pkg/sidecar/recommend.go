@@ -0,0 +1,270 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sidecar derives minimal Sidecar resources from the traffic a workload actually sends.
+package sidecar
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/model"
+)
+
+const (
+	upstreamRequestsStat    = ".upstream_rq_total"
+	upstreamConnectionsStat = ".upstream_cx_total"
+)
+
+// StatsFilter is the Envoy admin stats filter selecting the statistics read by ParseClusterStats.
+const StatsFilter = `upstream_(rq|cx)_total$`
+
+// Traffic is the number of requests and connections observed per destination hostname.
+type Traffic map[string]uint64
+
+// Add records count requests or connections to the hostname.
+func (t Traffic) Add(hostname string, count uint64) {
+	t[hostname] += count
+}
+
+// ParseClusterStats extracts the per-host request and connection counts from the Envoy admin stats
+// output. Connections are counted too, as TCP clusters never see requests. As HTTP clusters see both,
+// the larger of the two counts is taken for each cluster. Only outbound clusters are considered. The
+// returned cluster list holds every outbound cluster known to the proxy, including those that never
+// received any traffic.
+func ParseClusterStats(data []byte) (Traffic, []string, error) {
+	requests := map[string]uint64{}
+	connections := map[string]uint64{}
+	clusters := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "cluster.outbound|") {
+			continue
+		}
+		idx := strings.LastIndex(line, ": ")
+		if idx < 0 {
+			continue
+		}
+		name, value := line[:idx], line[idx+2:]
+		var stat string
+		var counts map[string]uint64
+		switch {
+		case strings.HasSuffix(name, upstreamRequestsStat):
+			stat, counts = upstreamRequestsStat, requests
+		case strings.HasSuffix(name, upstreamConnectionsStat):
+			stat, counts = upstreamConnectionsStat, connections
+		default:
+			continue
+		}
+		cluster := strings.TrimSuffix(strings.TrimPrefix(name, "cluster."), stat)
+		count, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid value for stat %s: %v", name, err)
+		}
+		_, seenRequests := requests[cluster]
+		_, seenConnections := connections[cluster]
+		if !seenRequests && !seenConnections {
+			clusters = append(clusters, cluster)
+		}
+		counts[cluster] = count
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(clusters)
+
+	traffic := Traffic{}
+	for _, cluster := range clusters {
+		_, _, hostname, _ := model.ParseSubsetKey(cluster)
+		if hostname == "" {
+			continue
+		}
+		count := requests[cluster]
+		if connections[cluster] > count {
+			count = connections[cluster]
+		}
+		if count > 0 {
+			traffic.Add(string(hostname), count)
+		}
+	}
+	return traffic, clusters, nil
+}
+
+// Scope is the subset of a proxy's SidecarScope, as returned by the istiod /debug/sidecarz endpoint,
+// needed to build a recommendation.
+type Scope struct {
+	Services []struct {
+		Hostname   string `json:"hostname"`
+		Attributes struct {
+			Namespace string
+		}
+	} `json:"services"`
+	Sidecar *networking.Sidecar `json:"sidecar"`
+}
+
+// ParseScope parses the output of the istiod /debug/sidecarz endpoint.
+func ParseScope(data []byte) (*Scope, error) {
+	scope := &Scope{}
+	if err := json.Unmarshal(data, scope); err != nil {
+		return nil, fmt.Errorf("failed to parse sidecar scope: %v", err)
+	}
+	return scope, nil
+}
+
+// egressHosts returns the egress hosts of the existing Sidecar, if any.
+func (s *Scope) egressHosts() []string {
+	if s == nil || s.Sidecar == nil {
+		return nil
+	}
+	hosts := []string{}
+	for _, e := range s.Sidecar.Egress {
+		hosts = append(hosts, e.Hosts...)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// namespaceOf returns the namespace the hostname is defined in, as known by the scope.
+func (s *Scope) namespaceOf(hostname string) string {
+	if s != nil {
+		for _, svc := range s.Services {
+			if svc.Hostname == hostname && svc.Attributes.Namespace != "" {
+				return svc.Attributes.Namespace
+			}
+		}
+	}
+	// Kubernetes services carry their namespace in the hostname.
+	if parts := strings.Split(hostname, "."); len(parts) > 3 && parts[2] == "svc" {
+		return parts[1]
+	}
+	return "*"
+}
+
+// Recommendation is a minimal Sidecar derived from observed traffic.
+type Recommendation struct {
+	Name      string
+	Namespace string
+	Selector  map[string]string
+	Sidecar   *networking.Sidecar
+
+	// Added and Removed are the egress hosts that differ from the Sidecar currently applied.
+	Added   []string
+	Removed []string
+
+	// ServicesBefore and ServicesAfter are the number of services visible to the proxy.
+	ServicesBefore int
+	ServicesAfter  int
+	// ClustersBefore and ClustersAfter are the number of outbound clusters sent to the proxy.
+	ClustersBefore int
+	ClustersAfter  int
+}
+
+// Recommend builds the minimal Sidecar for a workload. The current scope and clusters are used to
+// compute the difference with the configuration currently in effect; either may be empty. An error is
+// returned if no traffic was observed, as a Sidecar needs at least one egress host.
+func Recommend(name, namespace string, selector map[string]string, traffic Traffic, scope *Scope, clusters []string) (*Recommendation, error) {
+	if len(traffic) == 0 {
+		return nil, fmt.Errorf("no outbound traffic observed for %s.%s, cannot recommend egress hosts", name, namespace)
+	}
+	hostnames := make([]string, 0, len(traffic))
+	for h := range traffic {
+		hostnames = append(hostnames, h)
+	}
+	sort.Strings(hostnames)
+
+	hosts := make([]string, 0, len(hostnames))
+	for _, h := range hostnames {
+		hosts = append(hosts, scope.namespaceOf(h)+"/"+h)
+	}
+
+	r := &Recommendation{
+		Name:      name,
+		Namespace: namespace,
+		Selector:  selector,
+		Sidecar: &networking.Sidecar{
+			Egress: []*networking.IstioEgressListener{{Hosts: hosts}},
+		},
+	}
+	if len(selector) > 0 {
+		r.Sidecar.WorkloadSelector = &networking.WorkloadSelector{Labels: selector}
+	}
+
+	existing := map[string]struct{}{}
+	for _, h := range scope.egressHosts() {
+		existing[h] = struct{}{}
+	}
+	recommended := map[string]struct{}{}
+	for _, h := range hosts {
+		recommended[h] = struct{}{}
+		if _, f := existing[h]; !f {
+			r.Added = append(r.Added, h)
+		}
+	}
+	for _, h := range scope.egressHosts() {
+		if _, f := recommended[h]; !f {
+			r.Removed = append(r.Removed, h)
+		}
+	}
+
+	if scope != nil {
+		r.ServicesBefore = len(scope.Services)
+		for _, svc := range scope.Services {
+			if _, f := traffic[svc.Hostname]; f {
+				r.ServicesAfter++
+			}
+		}
+	}
+	r.ClustersBefore = len(clusters)
+	for _, c := range clusters {
+		_, _, hostname, _ := model.ParseSubsetKey(c)
+		if _, f := traffic[string(hostname)]; f {
+			r.ClustersAfter++
+		}
+	}
+	return r, nil
+}
+
+// PrintDiff writes the egress host changes and the estimated configuration reduction.
+func (r *Recommendation) PrintDiff(w io.Writer) {
+	_, _ = fmt.Fprintf(w, "Sidecar %s.%s\n", r.Name, r.Namespace)
+	if len(r.Added) == 0 && len(r.Removed) == 0 {
+		_, _ = fmt.Fprintln(w, "No changes to egress hosts")
+	}
+	for _, h := range r.Added {
+		_, _ = fmt.Fprintf(w, "+ %s\n", h)
+	}
+	for _, h := range r.Removed {
+		_, _ = fmt.Fprintf(w, "- %s\n", h)
+	}
+	if r.ServicesBefore > 0 {
+		_, _ = fmt.Fprintf(w, "Services: %d -> %d (%s removed)\n",
+			r.ServicesBefore, r.ServicesAfter, percentRemoved(r.ServicesBefore, r.ServicesAfter))
+	}
+	if r.ClustersBefore > 0 {
+		_, _ = fmt.Fprintf(w, "Outbound clusters: %d -> %d (%s removed)\n",
+			r.ClustersBefore, r.ClustersAfter, percentRemoved(r.ClustersBefore, r.ClustersAfter))
+	}
+}
+
+func percentRemoved(before, after int) string {
+	return fmt.Sprintf("%.0f%%", float64(before-after)*100/float64(before))
+}
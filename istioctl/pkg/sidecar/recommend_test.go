@@ -0,0 +1,161 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sidecar
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const stats = `cluster.BlackHoleCluster.upstream_rq_total: 0
+cluster.inbound|9080||.upstream_rq_total: 42
+cluster.outbound|9080||reviews.default.svc.cluster.local.upstream_rq_total: 10
+cluster.outbound|9080|v1|reviews.default.svc.cluster.local.upstream_rq_total: 5
+cluster.outbound|9080||details.default.svc.cluster.local.upstream_rq_total: 3
+cluster.outbound|9080||ratings.default.svc.cluster.local.upstream_rq_total: 0
+cluster.outbound|443||api.example.com.upstream_rq_total: 1
+cluster.outbound|15010||istiod.istio-system.svc.cluster.local.upstream_rq_total: 0
+cluster.outbound|9080||details.default.svc.cluster.local.upstream_rq_active: 7
+cluster.outbound|9080||reviews.default.svc.cluster.local.upstream_cx_total: 2
+cluster.outbound|9080||details.default.svc.cluster.local.upstream_cx_total: 1
+cluster.outbound|15010||istiod.istio-system.svc.cluster.local.upstream_cx_total: 0
+`
+
+const scope = `{
+  "name": "default",
+  "namespace": "default",
+  "services": [
+    {"hostname": "reviews.default.svc.cluster.local", "Attributes": {"Namespace": "default"}},
+    {"hostname": "details.default.svc.cluster.local", "Attributes": {"Namespace": "default"}},
+    {"hostname": "ratings.default.svc.cluster.local", "Attributes": {"Namespace": "default"}},
+    {"hostname": "api.example.com", "Attributes": {"Namespace": "egress"}},
+    {"hostname": "istiod.istio-system.svc.cluster.local", "Attributes": {"Namespace": "istio-system"}}
+  ],
+  "sidecar": {
+    "egress": [{"hosts": ["default/ratings.default.svc.cluster.local", "default/reviews.default.svc.cluster.local"]}]
+  }
+}`
+
+func TestParseClusterStats(t *testing.T) {
+	traffic, clusters, err := ParseClusterStats([]byte(stats))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedTraffic := Traffic{
+		"reviews.default.svc.cluster.local": 15,
+		"details.default.svc.cluster.local": 3,
+		"api.example.com":                   1,
+	}
+	if !reflect.DeepEqual(traffic, expectedTraffic) {
+		t.Errorf("expected traffic %v, got %v", expectedTraffic, traffic)
+	}
+	if len(clusters) != 6 {
+		t.Errorf("expected 6 outbound clusters, got %v", clusters)
+	}
+
+	tcp := `cluster.outbound|3306||mysql.db.svc.cluster.local.upstream_rq_total: 0
+cluster.outbound|3306||mysql.db.svc.cluster.local.upstream_cx_total: 4
+cluster.outbound|6379||redis.db.svc.cluster.local.upstream_cx_total: 0
+`
+	traffic, clusters, err = ParseClusterStats([]byte(tcp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (Traffic{"mysql.db.svc.cluster.local": 4}); !reflect.DeepEqual(traffic, expected) {
+		t.Errorf("expected TCP traffic %v, got %v", expected, traffic)
+	}
+	if expected := []string{"outbound|3306||mysql.db.svc.cluster.local", "outbound|6379||redis.db.svc.cluster.local"}; !reflect.DeepEqual(clusters, expected) {
+		t.Errorf("expected clusters %v, got %v", expected, clusters)
+	}
+
+	if _, _, err := ParseClusterStats([]byte("cluster.outbound|80||a.example.com.upstream_rq_total: x\n")); err == nil {
+		t.Error("expected error for invalid stat value")
+	}
+}
+
+func TestRecommend(t *testing.T) {
+	traffic, clusters, err := ParseClusterStats([]byte(stats))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := ParseScope([]byte(scope))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := Recommend("productpage-v1", "default", map[string]string{"app": "productpage"}, traffic, s, clusters)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedHosts := []string{
+		"egress/api.example.com",
+		"default/details.default.svc.cluster.local",
+		"default/reviews.default.svc.cluster.local",
+	}
+	if got := r.Sidecar.Egress[0].Hosts; !reflect.DeepEqual(got, expectedHosts) {
+		t.Errorf("expected hosts %v, got %v", expectedHosts, got)
+	}
+	if got := r.Sidecar.WorkloadSelector.Labels; !reflect.DeepEqual(got, map[string]string{"app": "productpage"}) {
+		t.Errorf("unexpected selector %v", got)
+	}
+	if !reflect.DeepEqual(r.Added, []string{"egress/api.example.com", "default/details.default.svc.cluster.local"}) {
+		t.Errorf("unexpected added hosts %v", r.Added)
+	}
+	if !reflect.DeepEqual(r.Removed, []string{"default/ratings.default.svc.cluster.local"}) {
+		t.Errorf("unexpected removed hosts %v", r.Removed)
+	}
+	if r.ServicesBefore != 5 || r.ServicesAfter != 3 {
+		t.Errorf("unexpected services estimate %d -> %d", r.ServicesBefore, r.ServicesAfter)
+	}
+	if r.ClustersBefore != 6 || r.ClustersAfter != 4 {
+		t.Errorf("unexpected clusters estimate %d -> %d", r.ClustersBefore, r.ClustersAfter)
+	}
+
+	out := &bytes.Buffer{}
+	r.PrintDiff(out)
+	for _, expected := range []string{
+		"+ egress/api.example.com",
+		"- default/ratings.default.svc.cluster.local",
+		"Services: 5 -> 3 (40% removed)",
+		"Outbound clusters: 6 -> 4 (33% removed)",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected %q in output:\n%s", expected, out.String())
+		}
+	}
+}
+
+func TestRecommendWithoutScope(t *testing.T) {
+	traffic := Traffic{"reviews.default.svc.cluster.local": 1, "api.example.com": 1}
+	r, err := Recommend("productpage", "default", nil, traffic, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedHosts := []string{"*/api.example.com", "default/reviews.default.svc.cluster.local"}
+	if got := r.Sidecar.Egress[0].Hosts; !reflect.DeepEqual(got, expectedHosts) {
+		t.Errorf("expected hosts %v, got %v", expectedHosts, got)
+	}
+	if r.Sidecar.WorkloadSelector != nil {
+		t.Errorf("expected no workload selector, got %v", r.Sidecar.WorkloadSelector)
+	}
+}
+
+func TestRecommendWithoutTraffic(t *testing.T) {
+	if _, err := Recommend("productpage", "default", nil, Traffic{}, nil, nil); err == nil {
+		t.Error("expected error when no traffic was observed")
+	}
+}
@@ -232,6 +232,10 @@ type CNIConfig struct {
 	Repair               *CNIRepairConfig        `protobuf:"bytes,13,opt,name=repair,proto3" json:"repair,omitempty"`
 	Chained              *protobuf.BoolValue     `protobuf:"bytes,14,opt,name=chained,proto3" json:"chained,omitempty"`
 	Taint                *CNITaintConfig         `protobuf:"bytes,15,opt,name=taint,proto3" json:"taint,omitempty"`
+	// Interval at which the Istio CNI config is verified and reinstalled if it was removed or moved, e.g. 30s.
+	ReconcileInterval    string                  `protobuf:"bytes,16,opt,name=reconcileInterval,proto3" json:"reconcileInterval,omitempty"`
+	// Type of traffic redirection programmed by the CNI plugin, iptables or nftables.
+	InterceptType        string                  `protobuf:"bytes,17,opt,name=interceptType,proto3" json:"interceptType,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}                `json:"-"`
 	XXX_unrecognized     []byte                  `json:"-"`
 	XXX_sizecache        int32                   `json:"-"`
@@ -367,6 +371,20 @@ func (m *CNIConfig) GetTaint() *CNITaintConfig {
 	return nil
 }
 
+func (m *CNIConfig) GetReconcileInterval() string {
+	if m != nil {
+		return m.ReconcileInterval
+	}
+	return ""
+}
+
+func (m *CNIConfig) GetInterceptType() string {
+	if m != nil {
+		return m.InterceptType
+	}
+	return ""
+}
+
 type CNITaintConfig struct {
 	// Controls whether taint behavior is enabled.
 	Enabled              *protobuf.BoolValue `protobuf:"bytes,1,opt,name=enabled,proto3" json:"enabled,omitempty"`
@@ -419,6 +437,8 @@ type CNIRepairConfig struct {
 	BrokenPodLabelKey    string   `protobuf:"bytes,8,opt,name=brokenPodLabelKey,proto3" json:"brokenPodLabelKey,omitempty"`
 	BrokenPodLabelValue  string   `protobuf:"bytes,9,opt,name=brokenPodLabelValue,proto3" json:"brokenPodLabelValue,omitempty"`
 	InitContainerName    string   `protobuf:"bytes,10,opt,name=initContainerName,proto3" json:"initContainerName,omitempty"`
+	// Controls whether the redirection of broken pods is re-applied in place, before labeling or deleting them.
+	RepairPods           bool     `protobuf:"varint,11,opt,name=repairPods,proto3" json:"repairPods,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -519,6 +539,13 @@ func (m *CNIRepairConfig) GetInitContainerName() string {
 	return ""
 }
 
+func (m *CNIRepairConfig) GetRepairPods() bool {
+	if m != nil {
+		return m.RepairPods
+	}
+	return false
+}
+
 // Configuration for CPU target utilization for HorizontalPodAutoscaler target.
 type CPUTargetUtilizationConfig struct {
 	// K8s utilization setting for HorizontalPodAutoscaler target.
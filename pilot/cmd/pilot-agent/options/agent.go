@@ -36,11 +36,19 @@ func NewAgentOptions(proxy *model.Proxy) *istioagent.AgentOptions {
 		ProxyType:                proxy.Type,
 		EnableDynamicProxyConfig: enableProxyConfigXdsEnv,
 		ProxyIPAddresses:         proxy.IPAddresses,
+		WasmRequireChecksum:      wasmRequireChecksum,
+		WasmSignaturePublicKey:   wasmSignaturePublicKey,
+		XDSConfigPersistenceDir:  xdsConfigPersistenceDir,
+		XDSConfigMaxStaleness:    xdsConfigMaxStaleness,
+	}
+	if wasmAllowedURLPrefixes != "" {
+		o.WasmAllowedURLPrefixes = strings.Split(wasmAllowedURLPrefixes, ",")
 	}
 	extractXDSHeadersFromEnv(o)
 	if proxyXDSViaAgent {
 		o.ProxyXDSViaAgent = true
 		o.DNSCapture = dnsCaptureByAgent
+		o.DNSCacheSize = dnsCacheSize
 		o.ProxyNamespace = PodNamespaceVar.Get()
 		o.ProxyDomain = proxy.DNSDomain
 	}
@@ -84,6 +84,25 @@ var (
 	// This is a copy of the env var in the init code.
 	dnsCaptureByAgent = env.RegisterBoolVar("ISTIO_META_DNS_CAPTURE", false,
 		"If set to true, enable the capture of outgoing DNS packets on port 53, redirecting to istio-agent on :15053").Get()
+	dnsCacheSize = env.RegisterIntVar("DNS_CACHE_SIZE", 1024,
+		"The number of responses of the upstream DNS resolvers cached by istio-agent when DNS capture is enabled. "+
+			"Responses are cached for their TTL, and negative responses for the negative TTL of their zone. "+
+			"Caching is disabled if 0.").Get()
+
+	wasmAllowedURLPrefixes = env.RegisterStringVar("WASM_ALLOWED_URL_PREFIXES", "",
+		"Comma separated list of URL prefixes, such as oci://gcr.io/my-project/, that Wasm modules may be fetched from. "+
+			"If empty, Wasm modules may be fetched from any URL.").Get()
+	wasmRequireChecksum = env.RegisterBoolVar("WASM_REQUIRE_CHECKSUM", false,
+		"If set to true, Wasm modules without a sha256 checksum (or image digest for oci:// modules) are rejected.").Get()
+	wasmSignaturePublicKey = env.RegisterStringVar("WASM_SIGNATURE_PUBLIC_KEY", "",
+		"Path to a PEM encoded public key. If set, only oci:// Wasm modules with a cosign signature made with "+
+			"the corresponding private key are loaded.").Get()
+
+	xdsConfigPersistenceDir = env.RegisterStringVar("XDS_CONFIG_PERSISTENCE_DIR", "",
+		"If set, the xDS configuration last accepted by Envoy is persisted in this directory, and served to Envoy "+
+			"when it connects while istiod is unreachable. Persistence is disabled if empty.").Get()
+	xdsConfigMaxStaleness = env.RegisterDurationVar("XDS_CONFIG_MAX_STALENESS", 24*time.Hour,
+		"The maximum age of persisted xDS configuration that may be served to Envoy while istiod is unreachable.").Get()
 
 	// Ability of istio-agent to retrieve proxyConfig via XDS for dynamic configuration updates
 	enableProxyConfigXdsEnv = env.RegisterBoolVar("PROXY_CONFIG_XDS_AGENT", false,
@@ -88,8 +88,12 @@ type KubeAppProbers map[string]*Prober
 
 // Prober represents a single container prober
 type Prober struct {
-	HTTPGet        *apimirror.HTTPGetAction `json:"httpGet"`
+	HTTPGet        *apimirror.HTTPGetAction `json:"httpGet,omitempty"`
+	GRPC           *apimirror.GRPCAction    `json:"grpc,omitempty"`
 	TimeoutSeconds int32                    `json:"timeoutSeconds,omitempty"`
+	// GRPCTLS enables TLS for the gRPC probe. Kubernetes gRPC probes are plain text, so it is only set
+	// for probers configured directly in the app prober config.
+	GRPCTLS bool `json:"grpcTLS,omitempty"`
 }
 
 // Options for the status server.
@@ -115,6 +119,7 @@ type Server struct {
 	appProbersDestination string
 	appKubeProbers        KubeAppProbers
 	appProbeClient        map[string]*http.Client
+	appProbeDialer        *net.Dialer
 	statusPort            uint16
 	lastProbeSuccessful   bool
 	envoyStatsPort        int
@@ -191,24 +196,28 @@ func NewServer(config Options) (*Server, error) {
 	}
 
 	s.appProbeClient = make(map[string]*http.Client, len(s.appKubeProbers))
+	localAddr := UpstreamLocalAddressIPv4
+	if config.IPv6 {
+		localAddr = UpstreamLocalAddressIPv6
+	}
+	s.appProbeDialer = &net.Dialer{
+		LocalAddr: localAddr,
+	}
 	// Validate the map key matching the regex pattern.
 	for path, prober := range s.appKubeProbers {
 		if !appProberPattern.Match([]byte(path)) {
 			return nil, fmt.Errorf(`invalid key, must be in form of regex pattern ^/app-health/[^\/]+/(livez|readyz)$`)
 		}
+		if prober.GRPC != nil {
+			// gRPC probes dial a new connection for every probe, as kubelet does.
+			continue
+		}
 		if prober.HTTPGet == nil {
-			return nil, fmt.Errorf(`invalid prober type, must be of type httpGet`)
+			return nil, fmt.Errorf(`invalid prober type, must be of type httpGet or grpc`)
 		}
 		if prober.HTTPGet.Port.Type != intstr.Int {
 			return nil, fmt.Errorf("invalid prober config for %v, the port must be int type", path)
 		}
-		localAddr := UpstreamLocalAddressIPv4
-		if config.IPv6 {
-			localAddr = UpstreamLocalAddressIPv6
-		}
-		d := &net.Dialer{
-			LocalAddr: localAddr,
-		}
 		// Construct a http client and cache it in order to reuse the connection.
 		s.appProbeClient[path] = &http.Client{
 			Timeout: time.Duration(prober.TimeoutSeconds) * time.Second,
@@ -216,7 +225,7 @@ func NewServer(config Options) (*Server, error) {
 			// https://kubernetes.io/docs/tasks/configure-pod-container/configure-liveness-readiness-probes/#configure-probes
 			Transport: &http.Transport{
 				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-				DialContext:     d.DialContext,
+				DialContext:     s.appProbeDialer.DialContext,
 			},
 		}
 	}
@@ -521,6 +530,10 @@ func (s *Server) handleAppProbe(w http.ResponseWriter, req *http.Request) {
 		_, _ = w.Write([]byte(fmt.Sprintf("app prober config does not exists for %v", path)))
 		return
 	}
+	if prober.GRPC != nil {
+		s.handleAppProbeGRPC(w, prober)
+		return
+	}
 	// get the http client must exist because
 	httpClient := s.appProbeClient[path]
 
@@ -581,6 +594,28 @@ func (s *Server) handleAppProbe(w http.ResponseWriter, req *http.Request) {
 	w.WriteHeader(response.StatusCode)
 }
 
+// handleAppProbeGRPC probes the application with the gRPC health checking protocol.
+func (s *Server) handleAppProbeGRPC(w http.ResponseWriter, prober *Prober) {
+	timeout := time.Duration(prober.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	addr := net.JoinHostPort(s.appProbersDestination, strconv.Itoa(int(prober.GRPC.Port)))
+	var service string
+	if prober.GRPC.Service != nil {
+		service = *prober.GRPC.Service
+	}
+	if err := GRPCHealthCheck(ctx, s.appProbeDialer, addr, service, prober.GRPCTLS); err != nil {
+		log.Errorf("gRPC health check of app %v failed: %v", addr, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
 // notifyExit sends SIGTERM to itself
 func notifyExit() {
 	p, err := os.FindProcess(os.Getpid())
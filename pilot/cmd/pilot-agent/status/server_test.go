@@ -32,6 +32,10 @@ import (
 	"time"
 
 	"github.com/prometheus/common/expfmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 
 	"istio.io/istio/pilot/cmd/pilot-agent/status/ready"
@@ -554,6 +558,106 @@ func TestHttpsAppProbe(t *testing.T) {
 	}
 }
 
+func TestGRPCAppProbe(t *testing.T) {
+	// Starts the application first.
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Errorf("failed to allocate unused port %v", err)
+	}
+	hs := grpchealth.NewServer()
+	hs.SetServingStatus("serving", healthpb.HealthCheckResponse_SERVING)
+	hs.SetServingStatus("not-serving", healthpb.HealthCheckResponse_NOT_SERVING)
+	grpcServer := grpc.NewServer()
+	healthpb.RegisterHealthServer(grpcServer, hs)
+	go grpcServer.Serve(listener)
+	defer grpcServer.Stop()
+	appPort := listener.Addr().(*net.TCPAddr).Port
+
+	// The same health service, over TLS.
+	tlsListener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Errorf("failed to allocate unused port %v", err)
+	}
+	creds, err := credentials.NewServerTLSFromFile(env.IstioSrc+"/pilot/cmd/pilot-agent/status/test-cert/cert.crt",
+		env.IstioSrc+"/pilot/cmd/pilot-agent/status/test-cert/cert.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tlsServer := grpc.NewServer(grpc.Creds(creds))
+	healthpb.RegisterHealthServer(tlsServer, hs)
+	go tlsServer.Serve(tlsListener)
+	defer tlsServer.Stop()
+	tlsPort := tlsListener.Addr().(*net.TCPAddr).Port
+
+	// Starts the pilot agent status server.
+	server, err := NewServer(Options{
+		StatusPort: 0,
+		KubeAppProbers: fmt.Sprintf(`{"/app-health/hello-world/readyz": {"grpc": {"port": %v}},
+"/app-health/hello-world/livez": {"grpc": {"port": %v, "service": "serving"}},
+"/app-health/not-serving/readyz": {"grpc": {"port": %v, "service": "not-serving"}},
+"/app-health/unknown/readyz": {"grpc": {"port": %v, "service": "unknown"}},
+"/app-health/tls/readyz": {"grpc": {"port": %v, "service": "serving"}, "grpcTLS": true}}`,
+			appPort, appPort, appPort, appPort, tlsPort),
+	})
+	if err != nil {
+		t.Errorf("failed to create status server %v", err)
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.Run(ctx)
+
+	var statusPort uint16
+	if err := retry.UntilSuccess(func() error {
+		server.mutex.RLock()
+		statusPort = server.statusPort
+		server.mutex.RUnlock()
+		if statusPort == 0 {
+			return fmt.Errorf("no port allocated")
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("failed to getport: %v", err)
+	}
+	testCases := []struct {
+		probePath  string
+		statusCode int
+	}{
+		{
+			probePath:  "app-health/hello-world/readyz",
+			statusCode: http.StatusOK,
+		},
+		{
+			probePath:  "app-health/hello-world/livez",
+			statusCode: http.StatusOK,
+		},
+		{
+			probePath:  "app-health/not-serving/readyz",
+			statusCode: http.StatusInternalServerError,
+		},
+		{
+			probePath:  "app-health/unknown/readyz",
+			statusCode: http.StatusInternalServerError,
+		},
+		{
+			probePath:  "app-health/tls/readyz",
+			statusCode: http.StatusOK,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.probePath, func(t *testing.T) {
+			resp, err := http.Get(fmt.Sprintf("http://localhost:%v/%s", statusPort, tc.probePath))
+			if err != nil {
+				t.Fatal("request failed: ", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tc.statusCode {
+				t.Errorf("[%v] unexpected status code, want = %v, got = %v", tc.probePath, tc.statusCode, resp.StatusCode)
+			}
+		})
+	}
+}
+
 func TestProbeHeader(t *testing.T) {
 	headerChecker := func(t *testing.T, header http.Header) net.Listener {
 		listener, err := net.Listen("tcp", ":0")
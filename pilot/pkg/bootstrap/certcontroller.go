@@ -167,6 +167,18 @@ func (s *Server) initDNSCerts(hostname, customHost, namespace string) error {
 			if err != nil {
 				return fmt.Errorf("failed reading %s: %v", path.Join(LocalCertDir.Get(), "root-cert.pem"), err)
 			}
+			if s.caCertsRotator != nil {
+				// The plugged-in certs are rotated in place, and the CA distributes the old and new roots
+				// during the rotation.
+				caBundle = s.CA.GetCAKeyCertBundle().GetRootCertPem()
+				s.addStartFunc(func(stop <-chan struct{}) error {
+					go func() {
+						// regenerate istiod key cert when the signing cert or the roots change.
+						s.watchRootCertAndGenKeyCert(names, stop)
+					}()
+					return nil
+				})
+			}
 		}
 	} else {
 		log.Infof("User specified cert provider: %v", features.PilotCertProvider.Get())
@@ -178,14 +190,15 @@ func (s *Server) initDNSCerts(hostname, customHost, namespace string) error {
 
 // TODO(hzxuzonghu): support async notification instead of polling the CA root cert.
 func (s *Server) watchRootCertAndGenKeyCert(names []string, stop <-chan struct{}) {
-	caBundle := s.CA.GetCAKeyCertBundle().GetRootCertPem()
+	signingCert, _, _, caBundle := s.CA.GetCAKeyCertBundle().GetAllPem()
 	for {
 		select {
 		case <-stop:
 			return
 		case <-time.After(controller.NamespaceResyncPeriod):
-			newRootCert := s.CA.GetCAKeyCertBundle().GetRootCertPem()
-			if !bytes.Equal(caBundle, newRootCert) {
+			newSigningCert, _, _, newRootCert := s.CA.GetCAKeyCertBundle().GetAllPem()
+			if !bytes.Equal(caBundle, newRootCert) || !bytes.Equal(signingCert, newSigningCert) {
+				signingCert = newSigningCert
 				caBundle = newRootCert
 				certChain, keyPEM, err := s.CA.GenKeyCert(names, SelfSignedCACertTTL.Get(), false)
 				if err != nil {
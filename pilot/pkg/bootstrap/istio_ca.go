@@ -15,20 +15,24 @@
 package bootstrap
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"path"
 	"strings"
 	"time"
 
 	"google.golang.org/grpc"
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/cache"
 
 	"istio.io/api/security/v1beta1"
 	"istio.io/istio/pilot/pkg/features"
@@ -36,11 +40,13 @@ import (
 	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/jwt"
 	kubelib "istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/kube/configmapwatcher"
 	"istio.io/istio/pkg/security"
 	"istio.io/istio/security/pkg/cmd"
 	"istio.io/istio/security/pkg/pki/ca"
 	"istio.io/istio/security/pkg/pki/ra"
 	caserver "istio.io/istio/security/pkg/server/ca"
+	"istio.io/istio/security/pkg/server/ca/audit"
 	"istio.io/istio/security/pkg/server/ca/authenticate"
 	"istio.io/pkg/env"
 	"istio.io/pkg/log"
@@ -126,6 +132,39 @@ var (
 	audience = env.RegisterStringVar("AUDIENCE", "",
 		"Expected audience in the tokens. ")
 
+	caAuthenticationPolicy = env.RegisterStringVar("CA_AUTHENTICATION_POLICY", "",
+		"The authenticators of CA requests, in YAML or JSON. Selects the enabled authenticators "+
+			"(ClientCertAuthenticator, KubeJWTAuthenticator, IDTokenAuthenticator), the order they are tried in, "+
+			"and for each the allowedIssuers, allowedAudiences and allowedIdentities. "+
+			"If not set, all the configured authenticators are used.")
+
+	caAuditLogSink = env.RegisterStringVar("CA_AUDIT_LOG_SINK", "",
+		"Where the audit records of the certificate requests are written: stdout, file://<path>, or the "+
+			"http(s) URL of a collector the records are posted to. If not set, the requests are not audited.")
+
+	caAuditLogMaxSize = env.RegisterIntVar("CA_AUDIT_LOG_MAX_SIZE", 100,
+		"The size in megabytes the audit log file is rotated at.")
+
+	caAuditLogMaxBackups = env.RegisterIntVar("CA_AUDIT_LOG_MAX_BACKUPS", 10,
+		"The number of rotated audit log files to keep.")
+
+	caAuditLogIndexSize = env.RegisterIntVar("CA_AUDIT_LOG_INDEX_SIZE", 10000,
+		"The number of most recently issued certificates which can be looked up by serial number.")
+
+	enableCARevocation = env.RegisterBoolVar("ENABLE_CA_REVOCATION", false,
+		"If enabled, istiod refuses to sign certificates for the SPIFFE IDs revoked in the "+
+			ca.RevocationsConfigMapName+" ConfigMap, caps the TTL of workload certificates to "+
+			"CA_REVOCATION_CERT_TTL, and distributes a CRL of the certificates revoked by serial number to "+
+			"workloads. The CRL is only distributed when the CA signing certificate is self-signed.")
+
+	caRevocationCertTTL = env.RegisterDurationVar("CA_REVOCATION_CERT_TTL", time.Hour,
+		"The max TTL of the workload certificates when ENABLE_CA_REVOCATION is set. The certificates of a "+
+			"revoked SPIFFE ID are not listed in the CRL, they expire within this TTL.")
+
+	caCRLValidity = env.RegisterDurationVar("CA_CRL_VALIDITY", 24*time.Hour,
+		"How long the CRL of the revoked certificates is valid for. Istiod re-signs it after half of "+
+			"the validity. Workloads stop enforcing an expired CRL until they receive a new one.")
+
 	caRSAKeySize = env.RegisterIntVar("CITADEL_SELF_SIGNED_CA_RSA_KEY_SIZE", 2048,
 		"Specify the RSA key size to use for self-signed Istio CA certificates.")
 
@@ -137,8 +176,38 @@ var (
 	// TODO: Likely to be removed and added to mesh config
 	k8sSigner = env.RegisterStringVar("K8S_SIGNER", "",
 		"Kubernates CA Signer type. Valid from Kubernates 1.18").Get()
+
+	k8sSignerApproveCSR = env.RegisterBoolVar("K8S_SIGNER_APPROVE_CSR", true,
+		"If true, istiod approves the CertificateSigningRequests it creates for K8S_SIGNER. "+
+			"Set to false when the requests are approved by an external approver.").Get()
+
+	k8sSignerTimeout = env.RegisterDurationVar("K8S_SIGNER_TIMEOUT", ra.DefaultCSRTimeout,
+		"The maximum time to wait for a CertificateSigningRequest created for K8S_SIGNER "+
+			"to be approved and issued.").Get()
+
+	autoReloadPluginCerts = env.RegisterBoolVar("AUTO_RELOAD_PLUGIN_CERTS", false,
+		"If enabled, istiod watches the plugged-in CA certificates mounted from the cacerts Secret, "+
+			"and rotates to new ones without a restart.")
+
+	pluginCertsTrustPropagationPeriod = env.RegisterDurationVar("PLUGIN_CERTS_TRUST_PROPAGATION_PERIOD",
+		cmd.DefaultWorkloadCertTTL,
+		"When the plugged-in CA certificates are rotated to a new root, how long the new root is distributed "+
+			"to workloads before istiod signs with the new certificates. It must be long enough for all "+
+			"workloads to renew their certificates.")
+
+	pluginCertsOverlapPeriod = env.RegisterDurationVar("PLUGIN_CERTS_OVERLAP_PERIOD",
+		cmd.DefaultWorkloadCertTTL,
+		"When the plugged-in CA certificates are rotated to a new root, how long the old root is still "+
+			"distributed to workloads after istiod signs with the new certificates. It must be longer than "+
+			"the TTL of workload certificates.")
 )
 
+// caStatusPath is the debug endpoint reporting the state of the CA certs rotation.
+const caStatusPath = "/debug/ca_status"
+
+// certificateAuditPath is the debug endpoint returning the audit record of an issued certificate.
+const certificateAuditPath = "/debug/certificate"
+
 // EnableCA returns whether CA functionality is enabled in istiod.
 // The logic of this function is from the logic of whether running CA
 // in RunCA(). The reason for moving this logic from RunCA into EnableCA() is
@@ -217,6 +286,20 @@ func (s *Server) RunCA(grpc *grpc.Server, ca caserver.CertificateAuthority, opts
 		}
 	}
 
+	if policy := caAuthenticationPolicy.Get(); policy != "" {
+		authnPolicy, err := authenticate.ParsePolicy(policy)
+		if err != nil {
+			log.Fatalf("invalid %s: %v", caAuthenticationPolicy.Name, err)
+		}
+		authenticators, err := authnPolicy.Apply(caServer.Authenticators)
+		if err != nil {
+			log.Fatalf("invalid %s: %v", caAuthenticationPolicy.Name, err)
+		}
+		caServer.Authenticators = authenticators
+		log.Infof("Using CA authentication policy: %s", policy)
+	}
+
+	caServer.AuditLog = s.certAuditLog
 	caServer.Register(grpc)
 
 	log.Info("Istiod CA has started")
@@ -292,6 +375,7 @@ func (s *Server) loadRemoteCACerts(caOpts *caOptions, dir string) error {
 //   which may contain multiple roots. A 'cert-chain.pem' file has the full cert chain.
 func (s *Server) createIstioCA(client corev1.CoreV1Interface, opts *caOptions) (*ca.IstioCA, error) {
 	var caOpts *ca.IstioCAOptions
+	var rotatorConfig *ca.PluggedCertRotatorConfig
 	var err error
 
 	// In pods, this is the optional 'cacerts' Secret.
@@ -338,11 +422,29 @@ func (s *Server) createIstioCA(client corev1.CoreV1Interface, opts *caOptions) (
 		if err != nil {
 			return nil, fmt.Errorf("failed to create an istiod CA: %v", err)
 		}
+		if _, err := os.Stat(signingKeyFile); err == nil && autoReloadPluginCerts.Get() {
+			rotatorConfig = &ca.PluggedCertRotatorConfig{
+				SigningCertFile:        signingCertFile,
+				SigningKeyFile:         signingKeyFile,
+				CertChainFile:          certChainFile,
+				RootCertFile:           rootCertFile,
+				TrustPropagationPeriod: pluginCertsTrustPropagationPeriod.Get(),
+				OverlapPeriod:          pluginCertsOverlapPeriod.Get(),
+			}
+			if client != nil {
+				rotatorConfig.StateStore = ca.NewSecretRotationStateStore(client, opts.Namespace, "cacerts")
+			}
+		}
 	}
 	istioCA, err := ca.NewIstioCA(caOpts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create an istiod CA: %v", err)
 	}
+	if rotatorConfig != nil {
+		if err := s.initPluggedCertRotator(istioCA, rotatorConfig); err != nil {
+			return nil, fmt.Errorf("failed to watch the plugged-in CA certs: %v", err)
+		}
+	}
 	// TODO: provide an endpoint returning all the roots. SDS can only pull a single root in current impl.
 	// ca.go saves or uses the secret, but also writes to the configmap "istio-security", under caTLSRootCert
 	// rootCertRotatorChan channel accepts signals to stop root cert rotator for
@@ -353,6 +455,285 @@ func (s *Server) createIstioCA(client corev1.CoreV1Interface, opts *caOptions) (
 	return istioCA, nil
 }
 
+// initPluggedCertRotator rotates the plugged-in CA certs when the files mounted from the cacerts Secret change.
+func (s *Server) initPluggedCertRotator(istioCA *ca.IstioCA, config *ca.PluggedCertRotatorConfig) error {
+	s.caCertsRotator = ca.NewPluggedCertRotator(config, istioCA, s.onCACertsRotated)
+	files := []string{config.SigningCertFile, config.SigningKeyFile, config.CertChainFile, config.RootCertFile}
+	for _, file := range files {
+		log.Infof("adding watcher for plugged-in CA cert %s", file)
+		if err := s.fileWatcher.Add(file); err != nil {
+			return fmt.Errorf("could not watch %v: %v", file, err)
+		}
+	}
+	s.addStartFunc(func(stop <-chan struct{}) error {
+		// The files of a Secret are updated together, so a single reload follows changes to any of them.
+		changed := make(chan struct{}, 1)
+		for _, file := range files {
+			file := file
+			go func() {
+				for {
+					select {
+					case <-s.fileWatcher.Events(file):
+						select {
+						case changed <- struct{}{}:
+						default:
+						}
+					case err := <-s.fileWatcher.Errors(file):
+						log.Errorf("error watching %v: %v", file, err)
+					case <-stop:
+						return
+					}
+				}
+			}()
+		}
+		go func() {
+			var reloadTimerC <-chan time.Time
+			for {
+				select {
+				case <-reloadTimerC:
+					reloadTimerC = nil
+					if err := s.caCertsRotator.Reload(); err != nil {
+						log.Errorf("failed to reload the plugged-in CA certs: %v", err)
+					}
+				case <-changed:
+					if reloadTimerC == nil {
+						reloadTimerC = time.After(watchDebounceDelay)
+					}
+				case <-stop:
+					return
+				}
+			}
+		}()
+		return nil
+	})
+	return nil
+}
+
+// onCACertsRotated distributes the roots of the CA after the plugged-in CA certs were rotated.
+// Workloads get the new roots with their next certificate, and the istio-ca-root-cert ConfigMaps
+// and the istiod cert are updated by their own watches.
+func (s *Server) onCACertsRotated() {
+	if features.MultiRootMesh.Get() {
+		if err := s.addIstioCAToTrustBundle(); err != nil {
+			log.Errorf("failed to update the trust bundle with the rotated CA roots: %v", err)
+		}
+	}
+}
+
+// addCADebugHandlers adds the debug handlers of the CA to mux.
+func (s *Server) addCADebugHandlers(mux *http.ServeMux) {
+	s.XDSServer.AddDebugHandler(mux, caStatusPath, "State of the CA certificates rotation", s.caStatusHandler)
+	s.XDSServer.AddDebugHandler(mux, certificateAuditPath, "Audit record of the certificate with the passed in serial",
+		s.certificateAuditHandler)
+}
+
+// caStatusHandler reports the state of the CA certs rotation.
+func (s *Server) caStatusHandler(w http.ResponseWriter, _ *http.Request) {
+	var status ca.RotationStatus
+	switch {
+	case s.caCertsRotator != nil:
+		status = s.caCertsRotator.Status()
+	case s.CA != nil:
+		status = ca.StaticRotationStatus(s.CA)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte("istiod CA is not enabled"))
+		return
+	}
+	b, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(b)
+}
+
+// initCertAuditLog creates the audit log of the certificate requests, if enabled.
+func (s *Server) initCertAuditLog() error {
+	if caAuditLogSink.Get() == "" || (s.CA == nil && s.RA == nil) {
+		return nil
+	}
+	sink, err := audit.NewSink(caAuditLogSink.Get(), audit.FileOptions{
+		MaxSizeMB:  caAuditLogMaxSize.Get(),
+		MaxBackups: caAuditLogMaxBackups.Get(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create the CA audit log: %v", err)
+	}
+	s.certAuditLog = audit.NewLog(sink, caAuditLogIndexSize.Get())
+	s.addTerminatingStartFunc(func(stop <-chan struct{}) error {
+		<-stop
+		if err := s.certAuditLog.Close(); err != nil {
+			log.Errorf("failed to close the CA audit log: %v", err)
+		}
+		return nil
+	})
+	log.Infof("Auditing certificate requests to %s", caAuditLogSink.Get())
+	return nil
+}
+
+// crlCheckInterval is how often istiod checks whether the CRL must be re-signed.
+const crlCheckInterval = time.Minute
+
+// initCARevocation watches the revocation entries of the CA, if enabled.
+func (s *Server) initCARevocation(args *PilotArgs) error {
+	if !enableCARevocation.Get() || s.CA == nil {
+		return nil
+	}
+	if s.kubeClient == nil {
+		return fmt.Errorf("%s requires a Kubernetes cluster", enableCARevocation.Name)
+	}
+	s.caRevocation = true
+	s.CA.SetRevocationCertTTL(caRevocationCertTTL.Get())
+	if signingCert, _, _, _ := s.CA.GetCAKeyCertBundle().GetAll(); signingCert != nil {
+		if err := ca.CheckCRLSign(signingCert); err != nil {
+			// Self-signed roots created by older versions are reused as is, until they are rotated.
+			log.Warnf("certificates revoked by serial number cannot be distributed to workloads: %v. "+
+				"The revoked SPIFFE IDs are still enforced at signing", err)
+		}
+	}
+	c := configmapwatcher.NewController(s.kubeClient, args.Namespace, ca.RevocationsConfigMapName, func(cm *v1.ConfigMap) {
+		s.updateRevocations(cm)
+	})
+	s.addStartFunc(func(stop <-chan struct{}) error {
+		go c.Run(stop)
+		// Load the revocations before the CA server starts, so revoked identities are never signed.
+		cache.WaitForCacheSync(stop, c.HasSynced)
+		go s.refreshCRL(stop)
+		return nil
+	})
+	log.Infof("Watching CA revocations in ConfigMap %s/%s", args.Namespace, ca.RevocationsConfigMapName)
+	return nil
+}
+
+// updateRevocations sets the revocation entries of the CA from the revocations ConfigMap, and
+// re-signs the CRL.
+func (s *Server) updateRevocations(cm *v1.ConfigMap) {
+	var data string
+	if cm != nil {
+		data = cm.Data[ca.RevocationsConfigMapKey]
+	}
+	entries, err := ca.ParseRevocations(data)
+	if err != nil {
+		// Keep the last known revocations in case there's a misconfiguration issue.
+		log.Errorf("failed to read the CA revocations from ConfigMap %s: %v", ca.RevocationsConfigMapName, err)
+		return
+	}
+	s.CA.SetRevocations(entries)
+	log.Infof("CA revocations updated, %d entries", len(entries))
+	s.updateCRL()
+}
+
+// refreshCRL re-signs the CRL when the CA signing certificate is rotated, or before it expires.
+func (s *Server) refreshCRL(stop <-chan struct{}) {
+	ticker := time.NewTicker(crlCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.crlMu.RLock()
+			stale := !bytes.Equal(s.crlSigner, s.caSigningCert()) || time.Since(s.crlUpdated) > caCRLValidity.Get()/2
+			s.crlMu.RUnlock()
+			if stale {
+				s.updateCRL()
+			}
+		}
+	}
+}
+
+// updateCRL signs the CRL of the certificates revoked by serial number. No CRL is distributed when
+// there is no such revocation, so workloads don't depend on istiod to re-sign it. The revoked SPIFFE
+// IDs are enforced by the CA at signing instead.
+func (s *Server) updateCRL() {
+	var crl []byte
+	signingCert, _, _, _ := s.CA.GetCAKeyCertBundle().GetAll()
+	if hasRevokedSerialNumbers(s.CA.Revocations()) {
+		var err error
+		switch {
+		case signingCert == nil:
+			err = fmt.Errorf("the CA is not ready")
+		case !bytes.Equal(signingCert.RawIssuer, signingCert.RawSubject):
+			// Envoy requires the CRLs of all the CAs in the chain, which istiod doesn't have.
+			err = fmt.Errorf("the CA signing certificate is not self-signed")
+		default:
+			crl, err = s.CA.GenerateCRL(caCRLValidity.Get())
+		}
+		if err != nil {
+			// Keep the last CRL, it is still valid until it expires.
+			log.Errorf("failed to sign the CRL, the revoked certificates are not distributed: %v", err)
+			return
+		}
+	}
+	s.crlMu.Lock()
+	defer s.crlMu.Unlock()
+	s.crl = crl
+	s.crlSigner = nil
+	if signingCert != nil {
+		s.crlSigner = signingCert.Raw
+	}
+	s.crlUpdated = time.Now()
+}
+
+// hasRevokedSerialNumbers returns whether one of the revocation entries revokes a serial number.
+func hasRevokedSerialNumbers(entries []ca.RevocationEntry) bool {
+	for _, e := range entries {
+		if e.SerialNumber != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// caSigningCert returns the raw CA signing certificate, or nil.
+func (s *Server) caSigningCert() []byte {
+	signingCert, _, _, _ := s.CA.GetCAKeyCertBundle().GetAll()
+	if signingCert == nil {
+		return nil
+	}
+	return signingCert.Raw
+}
+
+// currentCRL returns the CRL distributed to workloads, or nil.
+func (s *Server) currentCRL() []byte {
+	s.crlMu.RLock()
+	defer s.crlMu.RUnlock()
+	return s.crl
+}
+
+// certificateAuditHandler returns the audit record of the certificate with the serial number in the
+// serial query parameter.
+func (s *Server) certificateAuditHandler(w http.ResponseWriter, req *http.Request) {
+	if s.certAuditLog == nil {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte("CA audit log is not enabled"))
+		return
+	}
+	serial := req.URL.Query().Get("serial")
+	if serial == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("the serial query parameter is required"))
+		return
+	}
+	record := s.certAuditLog.Lookup(serial)
+	if record == nil {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(fmt.Sprintf("certificate %s not found", serial)))
+		return
+	}
+	b, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(b)
+}
+
 // createIstioRA initializes the Istio RA signing functionality.
 // the caOptions defines the external provider
 func (s *Server) createIstioRA(client kubelib.Client,
@@ -362,14 +743,16 @@ func (s *Server) createIstioRA(client kubelib.Client,
 		caCertFile = defaultCACertPath
 	}
 	raOpts := &ra.IstioRAOptions{
-		ExternalCAType: opts.ExternalCAType,
-		DefaultCertTTL: workloadCertTTL.Get(),
-		MaxCertTTL:     maxWorkloadCertTTL.Get(),
-		CaSigner:       opts.ExternalCASigner,
-		CaCertFile:     caCertFile,
-		VerifyAppendCA: true,
-		K8sClient:      client.CertificatesV1beta1(),
-		TrustDomain:    opts.TrustDomain,
+		ExternalCAType:  opts.ExternalCAType,
+		DefaultCertTTL:  workloadCertTTL.Get(),
+		MaxCertTTL:      maxWorkloadCertTTL.Get(),
+		CaSigner:        opts.ExternalCASigner,
+		CaCertFile:      caCertFile,
+		VerifyAppendCA:  true,
+		K8sClient:       client.CertificatesV1beta1(),
+		TrustDomain:     opts.TrustDomain,
+		SkipCSRApproval: !k8sSignerApproveCSR,
+		CSRTimeout:      k8sSignerTimeout,
 	}
 	return ra.NewIstioRA(raOpts)
 }
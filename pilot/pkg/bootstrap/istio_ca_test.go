@@ -16,17 +16,29 @@ package bootstrap
 
 import (
 	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path"
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"istio.io/istio/pilot/pkg/server"
+	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/kube"
+
 	"istio.io/istio/pkg/test/env"
+	"istio.io/istio/security/pkg/pki/ca"
+	"istio.io/istio/security/pkg/server/ca/audit"
+	"istio.io/pkg/filewatcher"
 )
 
 const namespace = "istio-system"
@@ -69,6 +81,135 @@ func TestRemoteCerts(t *testing.T) {
 	g.Expect(err).NotTo(BeNil())
 }
 
+func TestCAStatusHandler(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	for _, f := range []string{"ca-cert.pem", "ca-key.pem", "cert-chain.pem", "root-cert.pem"} {
+		data, err := readSampleCertFromFile(f)
+		g.Expect(err).Should(BeNil())
+		g.Expect(ioutil.WriteFile(path.Join(dir, f), data, 0600)).Should(Succeed())
+	}
+	config := &ca.PluggedCertRotatorConfig{
+		SigningCertFile: path.Join(dir, "ca-cert.pem"),
+		SigningKeyFile:  path.Join(dir, "ca-key.pem"),
+		CertChainFile:   path.Join(dir, "cert-chain.pem"),
+		RootCertFile:    path.Join(dir, "root-cert.pem"),
+	}
+	caOpts, err := ca.NewPluggedCertIstioCAOptions(config.CertChainFile, config.SigningCertFile, config.SigningKeyFile,
+		config.RootCertFile, time.Hour, time.Hour, 2048)
+	g.Expect(err).Should(BeNil())
+	istioCA, err := ca.NewIstioCA(caOpts)
+	g.Expect(err).Should(BeNil())
+
+	getStatus := func(s *Server) ca.RotationStatus {
+		rec := httptest.NewRecorder()
+		s.caStatusHandler(rec, httptest.NewRequest("GET", caStatusPath, nil))
+		g.Expect(rec.Code).Should(Equal(http.StatusOK))
+		status := ca.RotationStatus{}
+		g.Expect(json.Unmarshal(rec.Body.Bytes(), &status)).Should(Succeed())
+		return status
+	}
+
+	s := &Server{CA: istioCA, fileWatcher: filewatcher.NewWatcher(), server: server.New()}
+	status := getStatus(s)
+	g.Expect(status.Phase).Should(Equal(ca.RotationDisabled))
+	g.Expect(status.TrustedRoots).Should(HaveLen(1))
+
+	g.Expect(s.initPluggedCertRotator(istioCA, config)).Should(Succeed())
+	status = getStatus(s)
+	g.Expect(status.Phase).Should(Equal(ca.RotationIdle))
+	g.Expect(status.SigningCert).ShouldNot(BeNil())
+	g.Expect(status.TrustedRoots).Should(HaveLen(1))
+
+	rec := httptest.NewRecorder()
+	(&Server{}).caStatusHandler(rec, httptest.NewRequest("GET", caStatusPath, nil))
+	g.Expect(rec.Code).Should(Equal(http.StatusNotFound))
+}
+
+func TestCertificateAuditHandler(t *testing.T) {
+	g := NewWithT(t)
+
+	get := func(s *Server, query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.certificateAuditHandler(rec, httptest.NewRequest("GET", certificateAuditPath+query, nil))
+		return rec
+	}
+
+	g.Expect(get(&Server{}, "?serial=0a").Code).Should(Equal(http.StatusNotFound))
+
+	s := &Server{certAuditLog: audit.NewLog(audit.NewWriterSink(ioutil.Discard), 10)}
+	s.certAuditLog.Record(&audit.Record{
+		Result:           audit.ResultIssued,
+		CallerIdentities: []string{"spiffe://cluster.local/ns/default/sa/foo"},
+		SerialNumber:     "0a",
+	})
+	g.Expect(get(s, "").Code).Should(Equal(http.StatusBadRequest))
+	g.Expect(get(s, "?serial=0b").Code).Should(Equal(http.StatusNotFound))
+
+	rec := get(s, "?serial=0a")
+	g.Expect(rec.Code).Should(Equal(http.StatusOK))
+	record := audit.Record{}
+	g.Expect(json.Unmarshal(rec.Body.Bytes(), &record)).Should(Succeed())
+	g.Expect(record.CallerIdentities).Should(Equal([]string{"spiffe://cluster.local/ns/default/sa/foo"}))
+}
+
+func TestCARevocation(t *testing.T) {
+	g := NewWithT(t)
+
+	client := kube.NewFakeClient()
+	caOpts, err := ca.NewSelfSignedIstioCAOptions(context.Background(), 0, time.Hour, time.Hour, time.Hour, time.Hour,
+		"cluster.local", false, namespace, -1, client.Kube().CoreV1(), "", false, 2048)
+	g.Expect(err).Should(BeNil())
+	istioCA, err := ca.NewIstioCA(caOpts)
+	g.Expect(err).Should(BeNil())
+
+	revokedID := "spiffe://cluster.local/ns/default/sa/revoked"
+	s := &Server{CA: istioCA, caRevocation: true}
+	revocations := func(entries string) *v1.ConfigMap {
+		return &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: ca.RevocationsConfigMapName, Namespace: namespace},
+			Data:       map[string]string{ca.RevocationsConfigMapKey: entries},
+		}
+	}
+	revokedSerials := func() []string {
+		block, _ := pem.Decode([]byte(s.fetchCARoot()[constants.CACRLNamespaceConfigMapDataName]))
+		g.Expect(block).ShouldNot(BeNil())
+		crl, err := x509.ParseCRL(block.Bytes)
+		g.Expect(err).Should(BeNil())
+		serials := []string{}
+		for _, r := range crl.TBSCertList.RevokedCertificates {
+			serials = append(serials, r.SerialNumber.Text(16))
+		}
+		return serials
+	}
+
+	s.updateRevocations(nil)
+	g.Expect(s.fetchCARoot()).Should(HaveKeyWithValue(constants.CACRLNamespaceConfigMapDataName, ""))
+
+	// Revoked SPIFFE IDs are enforced at signing, not listed in the CRL.
+	s.updateRevocations(revocations(`[{"spiffeID": "` + revokedID + `"}]`))
+	g.Expect(istioCA.Revocations()).Should(HaveLen(1))
+	g.Expect(s.fetchCARoot()).Should(HaveKeyWithValue(constants.CACRLNamespaceConfigMapDataName, ""))
+
+	s.updateRevocations(revocations(`[{"serialNumber": "0a"}, {"spiffeID": "` + revokedID + `"}]`))
+	g.Expect(istioCA.Revocations()).Should(HaveLen(2))
+	g.Expect(revokedSerials()).Should(ConsistOf("a"))
+
+	// Invalid entries are ignored, keeping the previous revocations.
+	s.updateRevocations(revocations(`[{"serialNumber": "not-a-serial"}]`))
+	g.Expect(istioCA.Revocations()).Should(HaveLen(2))
+	g.Expect(revokedSerials()).Should(ConsistOf("a"))
+
+	s.updateRevocations(revocations(`[]`))
+	g.Expect(istioCA.Revocations()).Should(BeEmpty())
+	g.Expect(s.fetchCARoot()).Should(HaveKeyWithValue(constants.CACRLNamespaceConfigMapDataName, ""))
+
+	// Without revocation, agents are not told to watch the CRL.
+	s.caRevocation = false
+	g.Expect(s.fetchCARoot()).ShouldNot(HaveKey(constants.CACRLNamespaceConfigMapDataName))
+}
+
 func removeSilent(dir string) {
 	_ = os.RemoveAll(dir)
 }
@@ -70,6 +70,7 @@ import (
 	"istio.io/istio/security/pkg/k8s/chiron"
 	"istio.io/istio/security/pkg/pki/ca"
 	"istio.io/istio/security/pkg/pki/ra"
+	"istio.io/istio/security/pkg/server/ca/audit"
 	"istio.io/istio/security/pkg/server/ca/authenticate"
 	"istio.io/istio/security/pkg/server/ca/authenticate/kubeauth"
 	"istio.io/pkg/ctrlz"
@@ -156,6 +157,20 @@ type Server struct {
 	certController *chiron.WebhookController
 	CA             *ca.IstioCA
 	RA             ra.RegistrationAuthority
+	// caCertsRotator rotates the plugged-in CA certs, if enabled.
+	caCertsRotator *ca.PluggedCertRotator
+	// certAuditLog records the certificate requests handled by the CA server, if enabled.
+	certAuditLog *audit.Log
+	// caRevocation is true if certificate revocation is enabled. The CRL file is then always distributed
+	// with the CA root cert, possibly empty, so that the agents only watch it when revocation is enabled.
+	caRevocation bool
+	// crl is the PEM encoded CRL of the revoked workload certificates, distributed with the CA root
+	// cert when certificate revocation is enabled.
+	crlMu sync.RWMutex
+	crl   []byte
+	// crlSigner is the raw CA signing certificate when the crl was updated, and crlUpdated when.
+	crlSigner  []byte
+	crlUpdated time.Time
 
 	// TrustAnchors for workload to workload mTLS
 	workloadTrustBundle     *tb.TrustBundle
@@ -340,6 +355,13 @@ func NewServer(args *PilotArgs, initFuncs ...func(*Server)) (*Server, error) {
 	}
 	caOpts.Authenticators = authenticators
 
+	if err := s.initCertAuditLog(); err != nil {
+		return nil, err
+	}
+	if err := s.initCARevocation(args); err != nil {
+		return nil, err
+	}
+
 	// Start CA or RA server. This should be called after CA and Istiod certs have been created.
 	s.startCA(caOpts)
 
@@ -533,6 +555,9 @@ func (s *Server) initSDSServer(args *PilotArgs) {
 					})
 				})
 				s.XDSServer.Generators[v3.SecretType] = xds.NewSecretGen(sc, s.XDSServer.Cache)
+				if ecdsGen, ok := s.XDSServer.Generators[v3.ExtensionConfigurationType].(*xds.EcdsGenerator); ok {
+					ecdsGen.SetCredController(sc)
+				}
 				s.secretsController = sc
 				return nil
 			})
@@ -618,11 +643,13 @@ func (s *Server) initIstiodAdminServer(args *PilotArgs, whc func() map[string]st
 
 	// Debug Server.
 	s.XDSServer.InitDebug(s.monitoringMux, s.ServiceController(), args.ServerOptions.EnableProfiling, whc)
+	s.addCADebugHandlers(s.monitoringMux)
 
 	// Debug handlers are currently added on monitoring mux and readiness mux.
 	// If monitoring addr is empty, the mux is shared and we only add it once on the shared mux .
 	if !shouldMultiplex {
 		s.XDSServer.AddDebugHandlers(s.httpMux, args.ServerOptions.EnableProfiling, whc)
+		s.addCADebugHandlers(s.httpMux)
 	}
 
 	// Monitoring Server.
@@ -1098,9 +1125,13 @@ func (s *Server) fetchCARoot() map[string]string {
 		return nil
 	}
 
-	return map[string]string{
+	data := map[string]string{
 		constants.CACertNamespaceConfigMapDataName: string(s.CA.GetCAKeyCertBundle().GetRootCertPem()),
 	}
+	if s.caRevocation {
+		data[constants.CACRLNamespaceConfigMapDataName] = string(s.currentCRL())
+	}
+	return data
 }
 
 // initMeshHandlers initializes mesh and network handlers.
@@ -1123,7 +1154,7 @@ func (s *Server) initMeshHandlers() {
 	})
 }
 
-func (s *Server) addIstioCAToTrustBundle(args *PilotArgs) error {
+func (s *Server) addIstioCAToTrustBundle() error {
 	var err error
 	if s.CA != nil {
 		// If IstioCA is setup, derive trustAnchor directly from CA
@@ -1133,7 +1164,7 @@ func (s *Server) addIstioCAToTrustBundle(args *PilotArgs) error {
 			Source:            tb.SourceIstioCA,
 		})
 		if err != nil {
-			log.Errorf("unable to add CA root from namespace %s as trustAnchor", args.Namespace)
+			log.Errorf("unable to add CA root as trustAnchor")
 			return err
 		}
 		return nil
@@ -1172,7 +1203,7 @@ func (s *Server) initWorkloadTrustBundle(args *PilotArgs) error {
 		_ = s.workloadTrustBundle.AddMeshConfigUpdate(s.environment.Mesh())
 	})
 
-	err = s.addIstioCAToTrustBundle(args)
+	err = s.addIstioCAToTrustBundle()
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,161 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package dns
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/golang-lru/simplelru"
+	"github.com/miekg/dns"
+)
+
+// cacheKey identifies the question a cached response answers.
+type cacheKey struct {
+	name   string
+	qtype  uint16
+	qclass uint16
+}
+
+type cacheEntry struct {
+	response *dns.Msg
+	stored   time.Time
+	expiry   time.Time
+}
+
+// responseCache is a bounded LRU cache of the responses of the upstream resolvers. Entries expire
+// according to the TTLs of the records they hold. Negative responses (NXDOMAIN, or NOERROR without
+// answers) are cached for the negative TTL of the zone, as described in RFC 2308.
+type responseCache struct {
+	mu      sync.Mutex
+	entries *simplelru.LRU
+	now     func() time.Time
+}
+
+func newResponseCache(size int) (*responseCache, error) {
+	entries, err := simplelru.NewLRU(size, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &responseCache{
+		entries: entries,
+		now:     time.Now,
+	}, nil
+}
+
+func keyOf(q dns.Question) cacheKey {
+	return cacheKey{name: strings.ToLower(q.Name), qtype: q.Qtype, qclass: q.Qclass}
+}
+
+// get returns the cached response to the request, with its TTLs decreased by the time spent in
+// the cache, or nil if there is no unexpired response.
+func (c *responseCache) get(req *dns.Msg) *dns.Msg {
+	if len(req.Question) != 1 {
+		return nil
+	}
+	key := keyOf(req.Question[0])
+	now := c.now()
+	c.mu.Lock()
+	v, f := c.entries.Get(key)
+	if !f {
+		c.mu.Unlock()
+		return nil
+	}
+	entry := v.(*cacheEntry)
+	if !now.Before(entry.expiry) {
+		c.entries.Remove(key)
+		c.mu.Unlock()
+		return nil
+	}
+	c.mu.Unlock()
+
+	response := entry.response.Copy()
+	response.Id = req.Id
+	response.Question = req.Question
+	age := uint32(now.Sub(entry.stored) / time.Second)
+	for _, section := range [][]dns.RR{response.Answer, response.Ns, response.Extra} {
+		for _, rr := range section {
+			if rr.Header().Ttl > age {
+				rr.Header().Ttl -= age
+			} else {
+				rr.Header().Ttl = 0
+			}
+		}
+	}
+	return response
+}
+
+// add caches the response, if it is cacheable.
+func (c *responseCache) add(response *dns.Msg) {
+	if len(response.Question) != 1 {
+		return
+	}
+	ttl, cacheable := cacheTTL(response)
+	if !cacheable {
+		return
+	}
+	stored := response.Copy()
+	// The OPT pseudo record describes the exchange with the upstream resolver rather than the answer.
+	extra := make([]dns.RR, 0, len(stored.Extra))
+	for _, rr := range stored.Extra {
+		if rr.Header().Rrtype != dns.TypeOPT {
+			extra = append(extra, rr)
+		}
+	}
+	stored.Extra = extra
+	now := c.now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries.Add(keyOf(response.Question[0]), &cacheEntry{
+		response: stored,
+		stored:   now,
+		expiry:   now.Add(time.Duration(ttl) * time.Second),
+	})
+}
+
+// cacheTTL returns how long, in seconds, a response may be cached.
+func cacheTTL(response *dns.Msg) (uint32, bool) {
+	if response.Truncated {
+		return 0, false
+	}
+	switch {
+	case response.Rcode == dns.RcodeSuccess && len(response.Answer) > 0:
+		ttl := response.Answer[0].Header().Ttl
+		for _, rr := range response.Answer[1:] {
+			if rr.Header().Ttl < ttl {
+				ttl = rr.Header().Ttl
+			}
+		}
+		return ttl, ttl > 0
+	case response.Rcode == dns.RcodeSuccess || response.Rcode == dns.RcodeNameError:
+		// Negative responses are only cacheable if they carry the SOA record of the zone.
+		for _, rr := range response.Ns {
+			if soa, ok := rr.(*dns.SOA); ok {
+				ttl := soa.Hdr.Ttl
+				if soa.Minttl < ttl {
+					ttl = soa.Minttl
+				}
+				return ttl, ttl > 0
+			}
+		}
+	}
+	return 0, false
+}
+
+func (c *responseCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entries.Len()
+}
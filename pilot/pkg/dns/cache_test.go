@@ -0,0 +1,182 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package dns
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func newTestCache(t *testing.T, size int) (*responseCache, *time.Time) {
+	c, err := newResponseCache(size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	return c, &now
+}
+
+func response(host string, rcode int, answers []dns.RR, ns []dns.RR) *dns.Msg {
+	req := new(dns.Msg)
+	req.SetQuestion(host, dns.TypeA)
+	res := new(dns.Msg)
+	res.SetReply(req)
+	res.Rcode = rcode
+	res.Answer = answers
+	res.Ns = ns
+	return res
+}
+
+func soa(zone string, ttl, minTTL uint32) []dns.RR {
+	return []dns.RR{&dns.SOA{
+		Hdr:    dns.RR_Header{Name: zone, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: ttl},
+		Ns:     "ns." + zone,
+		Mbox:   "admin." + zone,
+		Minttl: minTTL,
+	}}
+}
+
+func TestCacheTTL(t *testing.T) {
+	cases := []struct {
+		name      string
+		response  *dns.Msg
+		ttl       uint32
+		cacheable bool
+	}{
+		{
+			name:      "answers",
+			response:  response("example.com.", dns.RcodeSuccess, a("example.com.", []net.IP{net.ParseIP("1.1.1.1")}), nil),
+			ttl:       defaultTTLInSeconds,
+			cacheable: true,
+		},
+		{
+			name: "lowest answer ttl",
+			response: response("example.com.", dns.RcodeSuccess, []dns.RR{
+				&dns.A{Hdr: dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 300}, A: net.ParseIP("1.1.1.1")},
+				&dns.A{Hdr: dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60}, A: net.ParseIP("2.2.2.2")},
+			}, nil),
+			ttl:       60,
+			cacheable: true,
+		},
+		{
+			name:      "nxdomain with soa",
+			response:  response("missing.example.com.", dns.RcodeNameError, nil, soa("example.com.", 3600, 120)),
+			ttl:       120,
+			cacheable: true,
+		},
+		{
+			name:      "nodata with soa",
+			response:  response("example.com.", dns.RcodeSuccess, nil, soa("example.com.", 60, 120)),
+			ttl:       60,
+			cacheable: true,
+		},
+		{
+			name:     "nxdomain without soa",
+			response: response("missing.example.com.", dns.RcodeNameError, nil, nil),
+		},
+		{
+			name:     "server failure",
+			response: response("example.com.", dns.RcodeServerFailure, nil, soa("example.com.", 60, 60)),
+		},
+		{
+			name: "truncated",
+			response: func() *dns.Msg {
+				r := response("example.com.", dns.RcodeSuccess, a("example.com.", []net.IP{net.ParseIP("1.1.1.1")}), nil)
+				r.Truncated = true
+				return r
+			}(),
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			ttl, cacheable := cacheTTL(tt.response)
+			if cacheable != tt.cacheable || ttl != tt.ttl {
+				t.Fatalf("expected ttl %d (cacheable=%v), got %d (cacheable=%v)", tt.ttl, tt.cacheable, ttl, cacheable)
+			}
+		})
+	}
+}
+
+func TestResponseCache(t *testing.T) {
+	c, now := newTestCache(t, 10)
+	res := response("Example.com.", dns.RcodeSuccess, a("example.com.", []net.IP{net.ParseIP("1.1.1.1")}), nil)
+	res.SetEdns0(dns.DefaultMsgSize, false)
+	c.add(res)
+
+	req := new(dns.Msg)
+	req.SetQuestion("example.COM.", dns.TypeA)
+	*now = now.Add(10 * time.Second)
+	got := c.get(req)
+	if got == nil {
+		t.Fatal("expected a cached response")
+	}
+	if got.Id != req.Id || got.Question[0].Name != "example.COM." {
+		t.Fatalf("expected the response to match the request, got %v", got)
+	}
+	if ttl := got.Answer[0].Header().Ttl; ttl != defaultTTLInSeconds-10 {
+		t.Fatalf("expected ttl %d, got %d", defaultTTLInSeconds-10, ttl)
+	}
+	if len(got.Extra) != 0 {
+		t.Fatalf("expected the OPT record not to be cached, got %v", got.Extra)
+	}
+
+	aaaa := new(dns.Msg)
+	aaaa.SetQuestion("example.com.", dns.TypeAAAA)
+	if c.get(aaaa) != nil {
+		t.Fatal("expected no cached response for a different query type")
+	}
+
+	*now = now.Add(defaultTTLInSeconds * time.Second)
+	if c.get(req) != nil {
+		t.Fatal("expected the cached response to expire")
+	}
+	if c.len() != 0 {
+		t.Fatalf("expected expired response to be removed, got %d entries", c.len())
+	}
+}
+
+func TestResponseCacheNegative(t *testing.T) {
+	c, now := newTestCache(t, 10)
+	c.add(response("missing.example.com.", dns.RcodeNameError, nil, soa("example.com.", 3600, 5)))
+
+	req := new(dns.Msg)
+	req.SetQuestion("missing.example.com.", dns.TypeA)
+	got := c.get(req)
+	if got == nil || got.Rcode != dns.RcodeNameError {
+		t.Fatalf("expected a cached NXDOMAIN response, got %v", got)
+	}
+	*now = now.Add(5 * time.Second)
+	if c.get(req) != nil {
+		t.Fatal("expected the negative response to expire after the SOA minimum TTL")
+	}
+}
+
+func TestResponseCacheBounded(t *testing.T) {
+	c, _ := newTestCache(t, 2)
+	for _, host := range []string{"a.example.com.", "b.example.com.", "c.example.com."} {
+		c.add(response(host, dns.RcodeSuccess, a(host, []net.IP{net.ParseIP("1.1.1.1")}), nil))
+	}
+	if c.len() != 2 {
+		t.Fatalf("expected 2 cached responses, got %d", c.len())
+	}
+	req := new(dns.Msg)
+	req.SetQuestion("a.example.com.", dns.TypeA)
+	if c.get(req) != nil {
+		t.Fatal("expected the least recently used response to be evicted")
+	}
+}
@@ -16,8 +16,10 @@ package dns
 
 import (
 	"net"
+	"sort"
 	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/miekg/dns"
@@ -36,6 +38,9 @@ type LocalDNSServer struct {
 	udpDNSProxy *dnsProxy
 	tcpDNSProxy *dnsProxy
 
+	// Caches the responses of the upstream resolvers. Nil if caching is disabled.
+	cache *responseCache
+
 	resolvConfServers []string
 	searchNamespaces  []string
 	// The namespace where the proxy resides
@@ -63,6 +68,9 @@ type LookupTable struct {
 	// The cname records here (comprised of different variants of the hosts above,
 	// expanded by the search namespaces) pointing to the actual host.
 	cname map[string][]dns.RR
+	// The key is a SRV query name (like _http._tcp.productpage.ns1.svc.cluster.local., or the host itself),
+	// the value is pre-created SRV records pointing to the host, or to its pods for headless services.
+	srv map[string][]dns.RR
 }
 
 const (
@@ -72,10 +80,20 @@ const (
 	defaultTTLInSeconds = 30
 )
 
-func NewLocalDNSServer(proxyNamespace, proxyDomain string) (*LocalDNSServer, error) {
+// NewLocalDNSServer creates a DNS server resolving the hosts of the name table sent by istiod, and forwarding
+// other queries to the resolvers of /etc/resolv.conf. Up to cacheSize responses of the upstream resolvers are
+// cached; caching is disabled if cacheSize is 0.
+func NewLocalDNSServer(proxyNamespace, proxyDomain string, cacheSize int) (*LocalDNSServer, error) {
 	h := &LocalDNSServer{
 		proxyNamespace: proxyNamespace,
 	}
+	if cacheSize > 0 {
+		cache, err := newResponseCache(cacheSize)
+		if err != nil {
+			return nil, err
+		}
+		h.cache = cache
+	}
 
 	// proxyDomain could contain the namespace making it redundant.
 	// we just need the .svc.cluster.local piece
@@ -133,6 +151,20 @@ func (h *LocalDNSServer) UpdateLookupTable(nt *nds.NameTable) {
 		name4:    map[string][]dns.RR{},
 		name6:    map[string][]dns.RR{},
 		cname:    map[string][]dns.RR{},
+		srv:      map[string][]dns.RR{},
+	}
+	// The pods of headless services are named <pod hostname>.<service host>, with a short name
+	// of <pod hostname>.<subdomain>. They are the targets of the SRV records of the service.
+	pods := map[string][]string{}
+	for host, ni := range nt.Table {
+		if ni.Registry != "Kubernetes" || !strings.Contains(ni.Shortname, ".") {
+			continue
+		}
+		if parts := strings.SplitN(host, ".", 2); len(parts) == 2 {
+			if _, f := nt.Table[parts[1]]; f {
+				pods[parts[1]] = append(pods[parts[1]], host)
+			}
+		}
 	}
 	for host, ni := range nt.Table {
 		// Given a host
@@ -151,6 +183,13 @@ func (h *LocalDNSServer) UpdateLookupTable(nt *nds.NameTable) {
 			continue
 		}
 		lookupTable.buildDNSAnswers(altHosts, ipv4, ipv6, h.searchNamespaces)
+		if len(ni.Ports) > 0 {
+			targets := pods[host]
+			if len(targets) == 0 {
+				targets = []string{host}
+			}
+			lookupTable.buildSRVAnswers(altHosts, targets, ni.Ports)
+		}
 	}
 	h.lookupTable.Store(lookupTable)
 	log.Debugf("updated lookup table with %d hosts", len(lookupTable.allHosts))
@@ -175,6 +214,8 @@ func (h *LocalDNSServer) ServeDNS(proxy *dnsProxy, w dns.ResponseWriter, req *dn
 	}
 	// we expect only one question in the query even though the spec allows many
 	// clients usually do not do more than one query either.
+	qtype := req.Question[0].Qtype
+	requests.With(queryType(qtype)).Increment()
 
 	lp := h.lookupTable.Load()
 	hostname := strings.ToLower(req.Question[0].Name)
@@ -190,9 +231,10 @@ func (h *LocalDNSServer) ServeDNS(proxy *dnsProxy, w dns.ResponseWriter, req *dn
 	var answers []dns.RR
 
 	// This name will always end in a dot
-	answers, hostFound := lookupTable.lookupHost(req.Question[0].Qtype, hostname)
+	answers, hostFound := lookupTable.lookupHost(qtype, hostname)
 
 	if hostFound {
+		nameTableHits.With(queryType(qtype)).Increment()
 		response = new(dns.Msg)
 		response.SetReply(req)
 		// We are the authority here, since we control DNS for known hostnames
@@ -202,6 +244,9 @@ func (h *LocalDNSServer) ServeDNS(proxy *dnsProxy, w dns.ResponseWriter, req *dn
 		// a client (ie curl, see https://github.com/istio/istio/issues/31250) sending parallel
 		// requests for A and AAAA may get NXDOMAIN for AAAA and treat the entire thing as a NXDOMAIN
 		response.Answer = answers
+		if qtype == dns.TypeSRV {
+			response.Extra = lookupTable.srvTargetAddresses(answers)
+		}
 		// Randomize the responses; this ensures for things like headless services we can do DNS-LB
 		// This matches standard kube-dns behavior. We only do this for cached responses as the
 		// upstream DNS server would already round robin if desired.
@@ -216,9 +261,22 @@ func (h *LocalDNSServer) ServeDNS(proxy *dnsProxy, w dns.ResponseWriter, req *dn
 		return
 	}
 
-	// We did not find the host in our internal cache. Query upstream and return the response as is.
+	// We did not find the host in our internal cache. Query upstream and return the response as is,
+	// unless we recently got a response for the same question.
+	if h.cache != nil {
+		if response = h.cache.get(req); response != nil {
+			cacheHits.With(queryType(qtype)).Increment()
+			response.Truncate(size(proxy.protocol, req))
+			log.Debugf("response for hostname %q (cached=true): %v", hostname, response)
+			_ = w.WriteMsg(response)
+			return
+		}
+	}
 	log.Debugf("response for hostname %q (found=false): %v", hostname, response)
 	response = h.queryUpstream(proxy.upstreamClient, req, log)
+	if h.cache != nil {
+		h.cache.add(response)
+	}
 	// Compress the response - we don't know if the incoming response was compressed or not. If it was,
 	// but we don't compress on the outbound, we will run into issues. For example, if the compressed
 	// size is 450 bytes but uncompressed 1000 bytes now we are outside of the non-eDNS UDP size limits
@@ -301,12 +359,17 @@ func (h *LocalDNSServer) Close() {
 // TODO: Figure out how to send parallel queries to all nameservers
 func (h *LocalDNSServer) queryUpstream(upstreamClient *dns.Client, req *dns.Msg, scope *istiolog.Scope) *dns.Msg {
 	var response *dns.Msg
+	qtype := req.Question[0].Qtype
 	for _, upstream := range h.resolvConfServers {
+		upstreamRequests.With(queryType(qtype)).Increment()
+		start := time.Now()
 		cResponse, _, err := upstreamClient.Exchange(req, upstream)
+		recordUpstreamLatency(qtype, start)
 		if err == nil {
 			response = cResponse
 			break
 		} else {
+			upstreamFailures.With(queryType(qtype)).Increment()
 			scope.Infof("upstream failure: %v", err)
 		}
 	}
@@ -380,6 +443,12 @@ func (table *LookupTable) lookupHost(qtype uint16, hostname string) ([]dns.RR, b
 		ipAnswers = table.name4[hostname]
 	case dns.TypeAAAA:
 		ipAnswers = table.name6[hostname]
+	case dns.TypeSRV:
+		ipAnswers = table.srv[hostname]
+		if len(ipAnswers) == 0 {
+			// We do not know the ports of this host, let the upstream resolvers answer.
+			return nil, false
+		}
 	default:
 		// TODO: handle PTR records for reverse dns lookups
 		return nil, false
@@ -445,6 +514,50 @@ func (table *LookupTable) buildDNSAnswers(altHosts map[string]struct{}, ipv4 []n
 	}
 }
 
+// buildSRVAnswers stores the SRV records of a host. Following Kubernetes DNS, queries for
+// _<port name>._<protocol>.<host> return the record of the named port, and queries for the
+// host itself return the records of all its ports. Headless services have one record per pod.
+func (table *LookupTable) buildSRVAnswers(altHosts map[string]struct{}, targets []string, ports []*nds.NameTable_Port) {
+	sort.Strings(targets)
+	for h := range altHosts {
+		h = strings.ToLower(h)
+		for _, port := range ports {
+			records := srv(h, targets, port.Port)
+			table.srv[h] = append(table.srv[h], records...)
+			if port.Name == "" {
+				continue
+			}
+			proto := "_tcp."
+			if port.Protocol == "UDP" {
+				proto = "_udp."
+			}
+			name := strings.ToLower("_" + port.Name + "." + proto + h)
+			table.srv[name] = srv(name, targets, port.Port)
+			table.allHosts[name] = struct{}{}
+		}
+	}
+}
+
+// srvTargetAddresses returns the A and AAAA records of the targets of SRV records, which are sent
+// in the additional section so that clients do not need to resolve the targets.
+func (table *LookupTable) srvTargetAddresses(answers []dns.RR) []dns.RR {
+	var out []dns.RR
+	seen := map[string]struct{}{}
+	for _, answer := range answers {
+		record, ok := answer.(*dns.SRV)
+		if !ok {
+			continue
+		}
+		if _, f := seen[record.Target]; f {
+			continue
+		}
+		seen[record.Target] = struct{}{}
+		out = append(out, table.name4[record.Target]...)
+		out = append(out, table.name6[record.Target]...)
+	}
+	return out
+}
+
 // Borrowed from https://github.com/coredns/coredns/blob/master/plugin/hosts/hosts.go
 // a takes a slice of net.IPs and returns a slice of A RRs.
 func a(host string, ips []net.IP) []dns.RR {
@@ -482,6 +595,26 @@ func cname(host string, targetHost string) []dns.RR {
 	return []dns.RR{answer}
 }
 
+// srv returns the SRV records of a port served by the given hosts. The weight is shared equally
+// between the hosts.
+func srv(name string, targets []string, port uint32) []dns.RR {
+	weight := 100 / len(targets)
+	if weight == 0 {
+		weight = 1
+	}
+	answers := make([]dns.RR, len(targets))
+	for i, target := range targets {
+		r := new(dns.SRV)
+		r.Hdr = dns.RR_Header{Name: name, Rrtype: dns.TypeSRV, Class: dns.ClassINET, Ttl: defaultTTLInSeconds}
+		r.Priority = 0
+		r.Weight = uint16(weight)
+		r.Port = uint16(port)
+		r.Target = dns.Fqdn(strings.ToLower(target))
+		answers[i] = r
+	}
+	return answers
+}
+
 // Size returns if buffer size *advertised* in the requests OPT record.
 // Or when the request was over TCP, we return the maximum allowed size of 64K.
 func size(proto string, r *dns.Msg) int {
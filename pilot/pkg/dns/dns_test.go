@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"net"
 	"reflect"
+	"sort"
 	"strings"
 	"testing"
 	"time"
@@ -38,7 +39,9 @@ func TestDNS(t *testing.T) {
 		host                     string
 		id                       int
 		queryAAAA                bool
+		qtype                    uint16
 		expected                 []dns.RR
+		expectedExtra            []dns.RR
 		expectResolutionFailure  int
 		expectExternalResolution bool
 		modifyReq                func(msg *dns.Msg)
@@ -156,6 +159,53 @@ func TestDNS(t *testing.T) {
 			host:      "ipv4.localhost.",
 			queryAAAA: true,
 		},
+		{
+			name:     "success: headless service pod - fqdn",
+			host:     "pod-0.headless.ns1.svc.cluster.local.",
+			expected: a("pod-0.headless.ns1.svc.cluster.local.", []net.IP{net.ParseIP("10.0.0.1").To4()}),
+		},
+		{
+			name:     "success: headless service pod - hostname.subdomain",
+			host:     "pod-1.headless.",
+			expected: a("pod-1.headless.", []net.IP{net.ParseIP("10.0.0.2").To4()}),
+		},
+		{
+			name: "success: headless service pod - hostname.subdomain with search namespace yields cname+A record",
+			host: "pod-1.headless.ns1.svc.cluster.local.ns1.svc.cluster.local.",
+			expected: append(cname("pod-1.headless.ns1.svc.cluster.local.ns1.svc.cluster.local.", "pod-1.headless.ns1.svc.cluster.local."),
+				a("pod-1.headless.ns1.svc.cluster.local.", []net.IP{net.ParseIP("10.0.0.2").To4()})...),
+		},
+		{
+			name:     "success: SRV query for named port",
+			host:     "_http._tcp.productpage.ns1.svc.cluster.local.",
+			qtype:    dns.TypeSRV,
+			expected: srv("_http._tcp.productpage.ns1.svc.cluster.local.", []string{"productpage.ns1.svc.cluster.local."}, 9080),
+			expectedExtra: a("productpage.ns1.svc.cluster.local.",
+				[]net.IP{net.ParseIP("9.9.9.9").To4()}),
+		},
+		{
+			name:     "success: SRV query for k8s host - shortname",
+			host:     "productpage.",
+			qtype:    dns.TypeSRV,
+			expected: srv("productpage.", []string{"productpage.ns1.svc.cluster.local."}, 9080),
+			expectedExtra: a("productpage.ns1.svc.cluster.local.",
+				[]net.IP{net.ParseIP("9.9.9.9").To4()}),
+		},
+		{
+			name:  "success: SRV query for headless service yields a record per pod",
+			host:  "_tcp-db._tcp.headless.ns1.svc.cluster.local.",
+			qtype: dns.TypeSRV,
+			expected: srv("_tcp-db._tcp.headless.ns1.svc.cluster.local.",
+				[]string{"pod-0.headless.ns1.svc.cluster.local.", "pod-1.headless.ns1.svc.cluster.local."}, 3306),
+			expectedExtra: append(a("pod-0.headless.ns1.svc.cluster.local.", []net.IP{net.ParseIP("10.0.0.1").To4()}),
+				a("pod-1.headless.ns1.svc.cluster.local.", []net.IP{net.ParseIP("10.0.0.2").To4()})...),
+		},
+		{
+			name:                    "success: SRV query for host without ports is forwarded upstream",
+			host:                    "www.google.com.",
+			qtype:                   dns.TypeSRV,
+			expectResolutionFailure: dns.RcodeNameError,
+		},
 		{
 			name: "udp: large request",
 			host: "giant.",
@@ -213,6 +263,9 @@ func TestDNS(t *testing.T) {
 				if tt.queryAAAA {
 					q = dns.TypeAAAA
 				}
+				if tt.qtype != 0 {
+					q = tt.qtype
+				}
 				m.SetQuestion(tt.host, q)
 				if tt.modifyReq != nil {
 					tt.modifyReq(m)
@@ -244,6 +297,9 @@ func TestDNS(t *testing.T) {
 							t.Log(res)
 							t.Errorf("dns responses for %s do not match. \n got %v\nwant %v", tt.host, res.Answer, tt.expected)
 						}
+						if tt.expectedExtra != nil && !equalsDNSrecords(sortRecords(res.Extra), sortRecords(tt.expectedExtra)) {
+							t.Errorf("dns additional records for %s do not match. \n got %v\nwant %v", tt.host, res.Extra, tt.expectedExtra)
+						}
 					}
 				}
 			})
@@ -399,7 +455,7 @@ func makeUpstream(t test.Failer, responses map[string]string) string {
 
 func initDNS(t test.Failer) *LocalDNSServer {
 	srv := makeUpstream(t, map[string]string{"www.bing.com.": "1.1.1.1"})
-	testAgentDNS, err := NewLocalDNSServer("ns1", "ns1.svc.cluster.local")
+	testAgentDNS, err := NewLocalDNSServer("ns1", "ns1.svc.cluster.local", 100)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -417,6 +473,26 @@ func initDNS(t test.Failer) *LocalDNSServer {
 				Registry:  "Kubernetes",
 				Namespace: "ns1",
 				Shortname: "productpage",
+				Ports:     []*nds.NameTable_Port{{Name: "http", Port: 9080, Protocol: "HTTP"}},
+			},
+			"headless.ns1.svc.cluster.local": {
+				Ips:       []string{"10.0.0.1", "10.0.0.2"},
+				Registry:  "Kubernetes",
+				Namespace: "ns1",
+				Shortname: "headless",
+				Ports:     []*nds.NameTable_Port{{Name: "tcp-db", Port: 3306, Protocol: "TCP"}},
+			},
+			"pod-0.headless.ns1.svc.cluster.local": {
+				Ips:       []string{"10.0.0.1"},
+				Registry:  "Kubernetes",
+				Namespace: "ns1",
+				Shortname: "pod-0.headless",
+			},
+			"pod-1.headless.ns1.svc.cluster.local": {
+				Ips:       []string{"10.0.0.2"},
+				Registry:  "Kubernetes",
+				Namespace: "ns1",
+				Shortname: "pod-1.headless",
 			},
 			"example.ns2.svc.cluster.local": {
 				Ips:       []string{"10.10.10.10"},
@@ -457,3 +533,10 @@ func equalsDNSrecords(got []dns.RR, want []dns.RR) bool {
 	}
 	return reflect.DeepEqual(got, want)
 }
+
+func sortRecords(records []dns.RR) []dns.RR {
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].String() < records[j].String()
+	})
+	return records
+}
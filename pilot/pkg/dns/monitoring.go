@@ -0,0 +1,83 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package dns
+
+import (
+	"time"
+
+	"github.com/miekg/dns"
+
+	"istio.io/pkg/monitoring"
+)
+
+var (
+	typeTag = monitoring.MustCreateLabel("type")
+
+	requests = monitoring.NewSum(
+		"dns_requests_total",
+		"Total number of DNS requests, by query type.",
+		monitoring.WithLabels(typeTag),
+	)
+
+	nameTableHits = monitoring.NewSum(
+		"dns_name_table_hits_total",
+		"Total number of DNS requests answered from the name table sent by istiod.",
+		monitoring.WithLabels(typeTag),
+	)
+
+	cacheHits = monitoring.NewSum(
+		"dns_cache_hits_total",
+		"Total number of DNS requests answered from the cache of upstream responses.",
+		monitoring.WithLabels(typeTag),
+	)
+
+	upstreamRequests = monitoring.NewSum(
+		"dns_upstream_requests_total",
+		"Total number of DNS requests forwarded to the upstream resolvers.",
+		monitoring.WithLabels(typeTag),
+	)
+
+	upstreamFailures = monitoring.NewSum(
+		"dns_upstream_failures_total",
+		"Total number of failed queries to the upstream resolvers.",
+		monitoring.WithLabels(typeTag),
+	)
+
+	upstreamLatency = monitoring.NewDistribution(
+		"dns_upstream_request_duration_seconds",
+		"Time in seconds the upstream resolvers take to answer a DNS request.",
+		[]float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+		monitoring.WithLabels(typeTag),
+	)
+)
+
+func init() {
+	monitoring.MustRegister(
+		requests,
+		nameTableHits,
+		cacheHits,
+		upstreamRequests,
+		upstreamFailures,
+		upstreamLatency,
+	)
+}
+
+// queryType returns the metric label of a DNS query type, such as A, AAAA or SRV.
+func queryType(qtype uint16) monitoring.LabelValue {
+	return typeTag.Value(dns.Type(qtype).String())
+}
+
+func recordUpstreamLatency(qtype uint16, start time.Time) {
+	upstreamLatency.With(queryType(qtype)).Record(time.Since(start).Seconds())
+}
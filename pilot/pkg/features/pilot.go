@@ -432,6 +432,17 @@ var (
 		"If enabled, pilot will only send the delta configs as opposed to the state of the world on a "+
 			"Resource Request")
 
+	EnableOnDemandXDS = env.RegisterBoolVar("PILOT_ENABLE_ON_DEMAND_XDS", false,
+		"If enabled, sidecars connected with delta xDS and with the ON_DEMAND_XDS proxy metadata set receive "+
+			"outbound route configurations without virtual hosts, fetch the virtual hosts on demand through VHDS, "+
+			"and only receive the clusters of the virtual hosts they fetched. Pushes triggered by services "+
+			"are only sent to the proxies which fetched them.").Get()
+
+	ConfigHistorySize = env.RegisterIntVar("PILOT_CONFIG_HISTORY_SIZE", 0,
+		"The number of pushed config versions for which istiod keeps the full generated resources of each proxy, "+
+			"for the /debug/config_diff endpoint. The hashes of the resources are kept for four times as many versions. "+
+			"If 0, no history is kept.").Get()
+
 	EnableLegacyAutoPassthrough = env.RegisterBoolVar(
 		"PILOT_ENABLE_LEGACY_AUTO_PASSTHROUGH",
 		false,
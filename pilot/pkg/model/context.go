@@ -256,6 +256,11 @@ type Proxy struct {
 	// of configuration.
 	XdsResourceGenerator XdsResourceGenerator
 
+	// OnDemand is true if the proxy receives outbound route configurations without virtual hosts, and fetches
+	// them on demand through VHDS. The proxy only receives the clusters of the virtual hosts it fetched.
+	// This is set at connect time, based on node metadata and on the type of the connection.
+	OnDemand bool
+
 	// WatchedResources contains the list of watched resources for the proxy, keyed by the DiscoveryRequest TypeUrl.
 	WatchedResources map[string]*WatchedResource
 }
@@ -551,6 +556,10 @@ type NodeMetadata struct {
 	// This depends on DNSCapture.
 	DNSAutoAllocate StringBool `json:"DNS_AUTO_ALLOCATE,omitempty"`
 
+	// OnDemandXDS indicates whether the workload should fetch the virtual hosts and clusters of the outbound
+	// services on demand. This only takes effect for sidecars using delta xDS, when enabled in istiod.
+	OnDemandXDS StringBool `json:"ON_DEMAND_XDS,omitempty"`
+
 	// AutoRegister will enable auto registration of the connected endpoint to the service registry using the given WorkloadGroup name
 	AutoRegisterGroup string `json:"AUTO_REGISTER_GROUP,omitempty"`
 
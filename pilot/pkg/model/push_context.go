@@ -305,6 +305,14 @@ type PushRequest struct {
 	// There should only be multiple reasons if the push request is the result of two distinct triggers, rather than
 	// classifying a single trigger as having multiple reasons.
 	Reason []TriggerReason
+
+	// Trace records the events that led to this push request, oldest first, such as the config
+	// and registry updates it was created from and the debouncing that merged them.
+	// It is bounded by MaxPushTraceEvents and is only used for debugging.
+	Trace []PushTraceEvent
+
+	// traceEvent caches the PushTraceEvent recorded for each connection the request is pushed to.
+	traceEvent atomic.Value
 }
 
 type TriggerReason string
@@ -356,6 +364,8 @@ func (pr *PushRequest) Merge(other *PushRequest) *PushRequest {
 
 		// Merge the two reasons. Note that we shouldn't deduplicate here, or we would under count
 		Reason: reason,
+
+		Trace: mergeTraces(pr.Trace, other.Trace),
 	}
 
 	// Do not merge when any one is empty
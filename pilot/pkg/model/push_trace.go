@@ -0,0 +1,97 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	// MaxPushTraceEvents is the maximum number of events kept in the trace of a push request.
+	// When exceeded, the oldest events are dropped.
+	MaxPushTraceEvents = 64
+
+	// maxTraceConfigs is the maximum number of config keys recorded in a single trace event.
+	maxTraceConfigs = 10
+)
+
+// PushTraceEvent describes an event that contributed to a push request.
+type PushTraceEvent struct {
+	Time    time.Time       `json:"time"`
+	Reason  []TriggerReason `json:"reason,omitempty"`
+	Configs []string        `json:"configs,omitempty"`
+	Message string          `json:"message"`
+}
+
+// NewPushTraceEvent builds a trace event for the reasons and configs of the push request.
+func NewPushTraceEvent(pr *PushRequest, message string) PushTraceEvent {
+	ev := PushTraceEvent{
+		Time:    time.Now(),
+		Message: message,
+	}
+	if pr == nil {
+		return ev
+	}
+	ev.Reason = append(ev.Reason, pr.Reason...)
+	configs := make([]string, 0, len(pr.ConfigsUpdated))
+	for key := range pr.ConfigsUpdated {
+		configs = append(configs, key.String())
+	}
+	sort.Strings(configs)
+	if len(configs) > maxTraceConfigs {
+		configs = append(configs[:maxTraceConfigs], fmt.Sprintf("and %d more", len(configs)-maxTraceConfigs))
+	}
+	if len(configs) > 0 {
+		ev.Configs = configs
+	}
+	return ev
+}
+
+// TraceEvent returns the trace event for the reasons and configs of the push request. It is built
+// on first use and shared by all the connections the request is pushed to, so the request must not be
+// modified once pushed.
+func (pr *PushRequest) TraceEvent() PushTraceEvent {
+	if ev, ok := pr.traceEvent.Load().(PushTraceEvent); ok {
+		return ev
+	}
+	// Connections pushed concurrently may both build the event, which is harmless.
+	ev := NewPushTraceEvent(pr, "")
+	pr.traceEvent.Store(ev)
+	return ev
+}
+
+// AddTrace appends an event to the trace of the push request.
+func (pr *PushRequest) AddTrace(ev PushTraceEvent) {
+	pr.Trace = mergeTraces(pr.Trace, []PushTraceEvent{ev})
+}
+
+// mergeTraces returns the events of both traces in time order, keeping at most MaxPushTraceEvents.
+func mergeTraces(a, b []PushTraceEvent) []PushTraceEvent {
+	if len(a) == 0 && len(b) == 0 {
+		return nil
+	}
+	out := make([]PushTraceEvent, 0, len(a)+len(b))
+	out = append(out, a...)
+	out = append(out, b...)
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Time.Before(out[j].Time)
+	})
+	if len(out) > MaxPushTraceEvents {
+		out = out[len(out)-MaxPushTraceEvents:]
+	}
+	return out
+}
@@ -0,0 +1,77 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/config/schema/gvk"
+)
+
+func TestNewPushTraceEvent(t *testing.T) {
+	req := &PushRequest{
+		Reason:         []TriggerReason{ConfigUpdate},
+		ConfigsUpdated: map[ConfigKey]struct{}{},
+	}
+	for i := 0; i < maxTraceConfigs+2; i++ {
+		req.ConfigsUpdated[ConfigKey{Kind: gvk.VirtualService, Name: fmt.Sprintf("vs-%02d", i), Namespace: "default"}] = struct{}{}
+	}
+	ev := NewPushTraceEvent(req, "update received")
+	if ev.Message != "update received" || !reflect.DeepEqual(ev.Reason, []TriggerReason{ConfigUpdate}) {
+		t.Fatalf("unexpected event %+v", ev)
+	}
+	if len(ev.Configs) != maxTraceConfigs+1 {
+		t.Fatalf("expected %d configs, got %v", maxTraceConfigs+1, ev.Configs)
+	}
+	if ev.Configs[0] != "VirtualService/default/vs-00" || ev.Configs[maxTraceConfigs] != "and 2 more" {
+		t.Fatalf("unexpected configs %v", ev.Configs)
+	}
+
+	// The event recorded for each connection is built once per request.
+	shared := req.TraceEvent()
+	if again := req.TraceEvent(); !again.Time.Equal(shared.Time) || !reflect.DeepEqual(again.Configs, ev.Configs) {
+		t.Fatalf("expected the same event, got %+v and %+v", shared, again)
+	}
+	merged := req.Merge(&PushRequest{Reason: []TriggerReason{ServiceUpdate}})
+	if got := merged.TraceEvent(); !reflect.DeepEqual(got.Reason, []TriggerReason{ConfigUpdate, ServiceUpdate}) {
+		t.Fatalf("unexpected merged event %+v", got)
+	}
+}
+
+func TestMergePushTrace(t *testing.T) {
+	now := time.Now()
+	first := &PushRequest{Trace: []PushTraceEvent{{Time: now, Message: "a"}, {Time: now.Add(2 * time.Second), Message: "c"}}}
+	second := &PushRequest{Trace: []PushTraceEvent{{Time: now.Add(time.Second), Message: "b"}}}
+	merged := first.Merge(second)
+	messages := []string{}
+	for _, ev := range merged.Trace {
+		messages = append(messages, ev.Message)
+	}
+	if !reflect.DeepEqual(messages, []string{"a", "b", "c"}) {
+		t.Fatalf("expected events in time order, got %v", messages)
+	}
+
+	long := &PushRequest{}
+	for i := 0; i < MaxPushTraceEvents+10; i++ {
+		long.AddTrace(PushTraceEvent{Time: now.Add(time.Duration(i) * time.Millisecond), Message: fmt.Sprint(i)})
+	}
+	if len(long.Trace) != MaxPushTraceEvents || long.Trace[0].Message != "10" {
+		t.Fatalf("expected trace bounded to the latest %d events, got %d starting at %v",
+			MaxPushTraceEvents, len(long.Trace), long.Trace[0].Message)
+	}
+}
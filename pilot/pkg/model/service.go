@@ -80,10 +80,11 @@ type Service struct {
 	// hostnames. The IPs assigned to services are not
 	// synchronized across istiod replicas as the DNS resolution
 	// for these service entries happens completely inside a pod
-	// whose proxy is managed by one istiod. That said, the algorithm
-	// to allocate IPs is pretty deterministic that at stable state, two
-	// istiods will allocate the exact same set of IPs for a given set of
-	// service entries.
+	// whose proxy is managed by one istiod. That said, the IPs are derived
+	// from the service namespace and hostname, so that two istiods
+	// allocate the exact same set of IPs for a given set of service
+	// entries. The IP of a service may change when other service
+	// entries are added or removed, if their hashes collide.
 	AutoAllocatedAddress string `json:"autoAllocatedAddress,omitempty"`
 
 	// Protect concurrent ClusterVIPs read/write
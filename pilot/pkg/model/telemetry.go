@@ -104,6 +104,16 @@ func (t *Telemetries) namespaceWideTelemetry(namespace string) *tpb.Telemetry {
 	return nil
 }
 
+// EffectiveTelemetryForProxy returns the effective Telemetry configuration for the given proxy.
+func (t *Telemetries) EffectiveTelemetryForProxy(proxy *Proxy) *tpb.Telemetry {
+	if t == nil || proxy == nil || proxy.Metadata == nil {
+		return nil
+	}
+	return t.EffectiveTelemetry(proxy.ConfigNamespace, labels.Collection{proxy.Metadata.Labels})
+}
+
+// shallowMerge merges child into parent. Each top level section of the spec (currently only tracing)
+// is merged independently, so a child that only configures one section inherits the others.
 func shallowMerge(parent, child *tpb.Telemetry) *tpb.Telemetry {
 	if parent == nil {
 		return child
@@ -111,38 +121,38 @@ func shallowMerge(parent, child *tpb.Telemetry) *tpb.Telemetry {
 	if child == nil {
 		return parent
 	}
-	return shallowMergeTracing(parent, child)
+	merged := parent.DeepCopy()
+	merged.Tracing = shallowMergeTracing(parent.GetTracing(), child.GetTracing())
+	return merged
 }
 
-func shallowMergeTracing(parent, child *tpb.Telemetry) *tpb.Telemetry {
-	if parent.GetTracing() == nil || len(parent.GetTracing()) == 0 {
+func shallowMergeTracing(parent, child []*tpb.Tracing) []*tpb.Tracing {
+	if len(parent) == 0 {
 		return child
 	}
-	if child.GetTracing() == nil || len(child.GetTracing()) == 0 {
+	if len(child) == 0 {
 		return parent
 	}
 
-	merged := parent.DeepCopy()
-	childCopy := child.DeepCopy()
+	merged := parent[0].DeepCopy()
+	childTracing := child[0].DeepCopy()
 
 	// only use the first Tracing for now (all that is suppported)
-	childTracing := childCopy.Tracing[0]
-	mergedTracing := merged.Tracing[0]
 	if len(childTracing.Providers) != 0 {
-		mergedTracing.Providers = childTracing.Providers
+		merged.Providers = childTracing.Providers
 	}
 
 	if childTracing.GetCustomTags() != nil {
-		mergedTracing.CustomTags = childTracing.CustomTags
+		merged.CustomTags = childTracing.CustomTags
 	}
 
 	if childTracing.GetDisableSpanReporting() != nil {
-		mergedTracing.DisableSpanReporting = childTracing.DisableSpanReporting
+		merged.DisableSpanReporting = childTracing.DisableSpanReporting
 	}
 
 	if childTracing.GetRandomSamplingPercentage() != nil {
-		mergedTracing.RandomSamplingPercentage = childTracing.RandomSamplingPercentage
+		merged.RandomSamplingPercentage = childTracing.RandomSamplingPercentage
 	}
 
-	return merged
+	return []*tpb.Tracing{merged}
 }
@@ -84,6 +84,12 @@ func TestTelemetries_EffectiveTelemetry(t *testing.T) {
 		},
 	}
 
+	emptyFoo := &tpb.Telemetry{
+		Selector: &v1beta1.WorkloadSelector{
+			MatchLabels: map[string]string{"service.istio.io/canonical-name": "foo"},
+		},
+	}
+
 	cases := []struct {
 		name           string
 		ns             string
@@ -218,6 +224,27 @@ func TestTelemetries_EffectiveTelemetry(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:           "workload-specific over root without tracing",
+			ns:             "foo",
+			workloadLabels: map[string]string{"service.istio.io/canonical-name": "foo"},
+			configs: []config.Config{
+				newTelemetry("root", "istio-system", &tpb.Telemetry{}),
+				newTelemetry("foo", "foo", fooTrace),
+			},
+			// The merged configuration only takes the sections of the workload-specific Telemetry.
+			want: &tpb.Telemetry{Tracing: fooTrace.Tracing},
+		},
+		{
+			name:           "workload-specific without tracing inherits",
+			ns:             "foo",
+			workloadLabels: map[string]string{"service.istio.io/canonical-name": "foo"},
+			configs: []config.Config{
+				newTelemetry("root", "istio-system", rootTrace),
+				newTelemetry("foo", "foo", emptyFoo),
+			},
+			want: rootTrace,
+		},
 	}
 
 	for _, v := range cases {
@@ -1287,6 +1287,11 @@ func buildHTTPConnectionManager(listenerOpts buildListenerOpts, httpOpts *httpLi
 		filters = append(filters, xdsfilters.Alpn)
 	}
 
+	// The on demand filter must run before the filters that depend on the route.
+	if listenerOpts.class == ListenerClassSidecarOutbound && listenerOpts.proxy != nil && listenerOpts.proxy.OnDemand {
+		filters = append(filters, xdsfilters.OnDemand)
+	}
+
 	filters = append(filters, xdsfilters.Cors, xdsfilters.Fault, xdsfilters.Router)
 
 	if httpOpts.connectionManager == nil {
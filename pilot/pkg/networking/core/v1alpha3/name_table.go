@@ -77,40 +77,10 @@ func (configgen *ConfigGeneratorImpl) BuildNameTable(node *model.Proxy, push *mo
 			// And for each individual pod, populate the dns table with the endpoint IP with a manufactured host name.
 			if svc.Attributes.ServiceRegistry == string(serviceregistry.Kubernetes) &&
 				svc.Resolution == model.Passthrough && len(svc.Ports) > 0 {
-				for _, instance := range push.ServiceInstancesByPort(svc, svc.Ports[0].Port, nil) {
-					// Add individual addresses even for cross cluster.
-					if instance.Endpoint.SubDomain != "" && instance.Endpoint.Network == node.Metadata.Network {
-						// Follow k8s pods dns naming convention of "<hostname>.<subdomain>.<pod namespace>.svc.<cluster domain>"
-						// i.e. "mysql-0.mysql.default.svc.cluster.local".
-						parts := strings.SplitN(string(svc.Hostname), ".", 2)
-						if len(parts) != 2 {
-							continue
-						}
-						address := []string{instance.Endpoint.Address}
-						shortName := instance.Endpoint.HostName + "." + instance.Endpoint.SubDomain
-						host := shortName + "." + parts[1] // Add cluster domain.
-						nameInfo := &nds.NameTable_NameInfo{
-							Ips:       address,
-							Registry:  svc.Attributes.ServiceRegistry,
-							Namespace: svc.Attributes.Namespace,
-							Shortname: shortName,
-						}
-						out.Table[host] = nameInfo
-					}
-
-					if instance.Endpoint.Locality.ClusterID != node.Metadata.ClusterID {
-						// We take only cluster-local endpoints. While this seems contradictory to
-						// our logic other parts of the code, where cross-cluster is the default.
-						// However, this only impacts the DNS response. If we were to send all
-						// endpoints, cross network routing would break, as we do passthrough LB and
-						// don't go through the network gateway. While we could, hypothetically, send
-						// "network-local" endpoints, this would still make enabling DNS give vastly
-						// different load balancing than without, so its probably best to filter.
-						// This ends up matching the behavior of Kubernetes DNS.
-						continue
-					}
-					// TODO: should we skip the node's own IP like we do in listener?
-					addressList = append(addressList, instance.Endpoint.Address)
+				var pods map[string]*nds.NameTable_NameInfo
+				addressList, pods = headlessServiceRecords(node, push, svc)
+				for host, nameInfo := range pods {
+					out.Table[host] = nameInfo
 				}
 			}
 
@@ -131,6 +101,7 @@ func (configgen *ConfigGeneratorImpl) BuildNameTable(node *model.Proxy, push *mo
 		nameInfo := &nds.NameTable_NameInfo{
 			Ips:      addressList,
 			Registry: svc.Attributes.ServiceRegistry,
+			Ports:    nameTablePorts(svc.Ports),
 		}
 		if svc.Attributes.ServiceRegistry == string(serviceregistry.Kubernetes) {
 			// The agent will take care of resolving a, a.ns, a.ns.svc, etc.
@@ -142,3 +113,77 @@ func (configgen *ConfigGeneratorImpl) BuildNameTable(node *model.Proxy, push *mo
 	}
 	return out
 }
+
+// nameTablePorts returns the ports of a service, which the agent uses to answer SRV queries.
+func nameTablePorts(ports model.PortList) []*nds.NameTable_Port {
+	out := make([]*nds.NameTable_Port, 0, len(ports))
+	for _, port := range ports {
+		out = append(out, &nds.NameTable_Port{
+			Name:     port.Name,
+			Port:     uint32(port.Port),
+			Protocol: string(port.Protocol),
+		})
+	}
+	return out
+}
+
+// headlessServiceRecords returns the addresses of a headless service, and the records of its individual pods.
+// Following the k8s pods dns naming convention, pods are named "<hostname>.<subdomain>.<pod namespace>.svc.<cluster domain>",
+// i.e. "mysql-0.mysql.default.svc.cluster.local" for the pods of a StatefulSet.
+//
+// Endpoints of other clusters are only used if they are on the network of the proxy, as we do passthrough LB
+// and don't go through the network gateway. The service resolves to the endpoints of the cluster of the proxy
+// only, which matches the behavior of Kubernetes DNS, unless there are none. Pods are resolved across clusters;
+// when a pod hostname exists in several clusters (i.e. a StatefulSet deployed in each cluster), the pod of the
+// cluster of the proxy wins, followed by the pod of the first cluster by name.
+func headlessServiceRecords(node *model.Proxy, push *model.PushContext,
+	svc *model.Service) ([]string, map[string]*nds.NameTable_NameInfo) {
+	var local, remote []string
+	pods := map[string]*nds.NameTable_NameInfo{}
+	podClusters := map[string]string{}
+	parts := strings.SplitN(string(svc.Hostname), ".", 2)
+	for _, instance := range push.ServiceInstancesByPort(svc, svc.Ports[0].Port, nil) {
+		ep := instance.Endpoint
+		cluster := ep.Locality.ClusterID
+		if cluster == node.Metadata.ClusterID {
+			// TODO: should we skip the node's own IP like we do in listener?
+			local = append(local, ep.Address)
+		} else if ep.Network == node.Metadata.Network {
+			remote = append(remote, ep.Address)
+		} else {
+			continue
+		}
+
+		if ep.SubDomain == "" || len(parts) != 2 {
+			continue
+		}
+		shortName := ep.HostName + "." + ep.SubDomain
+		host := shortName + "." + parts[1] // Add cluster domain.
+		if current, f := podClusters[host]; f && !preferCluster(cluster, current, node.Metadata.ClusterID) {
+			continue
+		}
+		podClusters[host] = cluster
+		pods[host] = &nds.NameTable_NameInfo{
+			Ips:       []string{ep.Address},
+			Registry:  svc.Attributes.ServiceRegistry,
+			Namespace: svc.Attributes.Namespace,
+			Shortname: shortName,
+		}
+	}
+	if len(local) > 0 {
+		return local, pods
+	}
+	return remote, pods
+}
+
+// preferCluster returns true if the pod of the candidate cluster should be used rather than the pod with the
+// same hostname in the current cluster.
+func preferCluster(candidate, current, proxyCluster string) bool {
+	if current == proxyCluster {
+		return false
+	}
+	if candidate == proxyCluster {
+		return true
+	}
+	return candidate < current
+}
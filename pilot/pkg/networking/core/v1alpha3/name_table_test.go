@@ -39,6 +39,18 @@ func makeServiceInstances(proxy *model.Proxy, service *model.Service, hostname,
 	return instances
 }
 
+func makeClusterServiceInstances(ip, clusterID, network string, service *model.Service,
+	hostname, subdomain string) map[int][]*model.ServiceInstance {
+	instances := makeServiceInstances(&model.Proxy{IPAddresses: []string{ip}}, service, hostname, subdomain)
+	for _, portInstances := range instances {
+		for _, instance := range portInstances {
+			instance.Endpoint.Locality.ClusterID = clusterID
+			instance.Endpoint.Network = network
+		}
+	}
+	return instances
+}
+
 func TestNameTable(t *testing.T) {
 	proxy := &model.Proxy{
 		IPAddresses: []string{"9.9.9.9"},
@@ -83,6 +95,42 @@ func TestNameTable(t *testing.T) {
 	push.AddServiceInstances(headlessService,
 		makeServiceInstances(pod2, headlessService, "pod2", "headless-svc"))
 
+	multiclusterProxy := &model.Proxy{
+		IPAddresses: []string{"9.9.9.9"},
+		Metadata: &model.NodeMetadata{
+			ClusterID: "cluster-1",
+			Network:   "network-1",
+		},
+		Type:      model.SidecarProxy,
+		DNSDomain: "testns.svc.cluster.local",
+	}
+
+	multiclusterPush := model.NewPushContext()
+	multiclusterPush.AddPublicServices([]*model.Service{headlessService})
+	multiclusterPush.AddServiceInstances(headlessService,
+		makeClusterServiceInstances("1.2.3.4", "cluster-1", "network-1", headlessService, "pod1", "headless-svc"))
+	multiclusterPush.AddServiceInstances(headlessService,
+		makeClusterServiceInstances("9.6.7.8", "cluster-2", "network-1", headlessService, "pod2", "headless-svc"))
+	// The same pod hostname in another cluster, i.e. the replica of a StatefulSet deployed in each cluster.
+	multiclusterPush.AddServiceInstances(headlessService,
+		makeClusterServiceInstances("5.5.5.5", "cluster-2", "network-1", headlessService, "pod1", "headless-svc"))
+	// Pods on another network are not reachable without going through the network gateway.
+	multiclusterPush.AddServiceInstances(headlessService,
+		makeClusterServiceInstances("7.7.7.7", "cluster-3", "network-2", headlessService, "pod3", "headless-svc"))
+
+	remotePush := model.NewPushContext()
+	remotePush.AddPublicServices([]*model.Service{headlessService})
+	remotePush.AddServiceInstances(headlessService,
+		makeClusterServiceInstances("6.6.6.6", "cluster-3", "network-1", headlessService, "pod1", "headless-svc"))
+	remotePush.AddServiceInstances(headlessService,
+		makeClusterServiceInstances("5.5.5.5", "cluster-2", "network-1", headlessService, "pod1", "headless-svc"))
+
+	headlessPorts := []*nds.NameTable_Port{{
+		Name:     "tcp-port",
+		Port:     9000,
+		Protocol: "TCP",
+	}}
+
 	cases := []struct {
 		name              string
 		proxy             *model.Proxy
@@ -112,6 +160,57 @@ func TestNameTable(t *testing.T) {
 						Registry:  "Kubernetes",
 						Shortname: "headless-svc",
 						Namespace: "testns",
+						Ports:     headlessPorts,
+					},
+				},
+			},
+		},
+		{
+			name:  "multicluster headless service pods",
+			proxy: multiclusterProxy,
+			push:  multiclusterPush,
+			expectedNameTable: &nds.NameTable{
+				Table: map[string]*nds.NameTable_NameInfo{
+					"pod1.headless-svc.testns.svc.cluster.local": {
+						Ips:       []string{"1.2.3.4"},
+						Registry:  "Kubernetes",
+						Shortname: "pod1.headless-svc",
+						Namespace: "testns",
+					},
+					"pod2.headless-svc.testns.svc.cluster.local": {
+						Ips:       []string{"9.6.7.8"},
+						Registry:  "Kubernetes",
+						Shortname: "pod2.headless-svc",
+						Namespace: "testns",
+					},
+					"headless-svc.testns.svc.cluster.local": {
+						Ips:       []string{"1.2.3.4"},
+						Registry:  "Kubernetes",
+						Shortname: "headless-svc",
+						Namespace: "testns",
+						Ports:     headlessPorts,
+					},
+				},
+			},
+		},
+		{
+			name:  "headless service pods in remote clusters only",
+			proxy: multiclusterProxy,
+			push:  remotePush,
+			expectedNameTable: &nds.NameTable{
+				Table: map[string]*nds.NameTable_NameInfo{
+					"pod1.headless-svc.testns.svc.cluster.local": {
+						Ips:       []string{"5.5.5.5"},
+						Registry:  "Kubernetes",
+						Shortname: "pod1.headless-svc",
+						Namespace: "testns",
+					},
+					"headless-svc.testns.svc.cluster.local": {
+						Ips:       []string{"6.6.6.6", "5.5.5.5"},
+						Registry:  "Kubernetes",
+						Shortname: "headless-svc",
+						Namespace: "testns",
+						Ports:     headlessPorts,
 					},
 				},
 			},
@@ -38,7 +38,6 @@ import (
 	"istio.io/istio/pilot/pkg/model"
 	authz_model "istio.io/istio/pilot/pkg/security/authz/model"
 	"istio.io/istio/pkg/bootstrap/platform"
-	"istio.io/istio/pkg/config/labels"
 	"istio.io/pkg/log"
 )
 
@@ -46,7 +45,7 @@ import (
 var clusterLookupFn = extensionproviders.LookupCluster
 
 func configureTracing(opts buildListenerOpts, hcm *hpb.HttpConnectionManager) {
-	spec := opts.push.Telemetry.EffectiveTelemetry(opts.proxy.ConfigNamespace, labels.Collection{opts.proxy.Metadata.Labels})
+	spec := opts.push.Telemetry.EffectiveTelemetryForProxy(opts.proxy)
 	configureTracingFromSpec(spec, opts, hcm)
 }
 
@@ -135,6 +135,22 @@ func (a *AggregateController) GetCaCert(name, namespace string) (cert []byte) {
 	return nil
 }
 
+func (a *AggregateController) GetDockerCredential(name, namespace string) ([]byte, error) {
+	// Search through all clusters, find first non-empty result
+	var firstError error
+	for _, c := range a.controllers {
+		k, err := c.GetDockerCredential(name, namespace)
+		if err != nil {
+			if firstError == nil {
+				firstError = err
+			}
+			continue
+		}
+		return k, nil
+	}
+	return nil, firstError
+}
+
 func (a *AggregateController) Authorize(serviceAccount, namespace string) error {
 	return a.authController.Authorize(serviceAccount, namespace)
 }
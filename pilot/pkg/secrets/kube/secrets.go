@@ -204,6 +204,28 @@ func (s *SecretsController) GetCaCert(name, namespace string) (cert []byte) {
 	return rootCert
 }
 
+// GetDockerCredential returns the docker config json of the image pull secret with the given name.
+func (s *SecretsController) GetDockerCredential(name, namespace string) ([]byte, error) {
+	k8sSecret, err := s.secrets.Lister().Secrets(namespace).Get(name)
+	if err != nil || k8sSecret == nil {
+		return nil, fmt.Errorf("secret %v/%v not found", namespace, name)
+	}
+	switch k8sSecret.Type {
+	case v1.SecretTypeDockerConfigJson:
+		if cred, ok := k8sSecret.Data[v1.DockerConfigJsonKey]; ok {
+			return cred, nil
+		}
+		return nil, fmt.Errorf("cannot find %v in secret %v/%v", v1.DockerConfigJsonKey, namespace, name)
+	case v1.SecretTypeDockercfg:
+		if cred, ok := k8sSecret.Data[v1.DockerConfigKey]; ok {
+			return cred, nil
+		}
+		return nil, fmt.Errorf("cannot find %v in secret %v/%v", v1.DockerConfigKey, namespace, name)
+	default:
+		return nil, fmt.Errorf("secret %v/%v of type %v is not an image pull secret", namespace, name, k8sSecret.Type)
+	}
+}
+
 // extractKeyAndCert extracts server key, certificate
 func extractKeyAndCert(scrt *v1.Secret) (key, cert []byte) {
 	if len(scrt.Data[GenericScrtCert]) > 0 {
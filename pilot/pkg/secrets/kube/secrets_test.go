@@ -128,6 +128,39 @@ func TestSecretsController(t *testing.T) {
 	}
 }
 
+func TestGetDockerCredential(t *testing.T) {
+	dockerConfigJSON := makeSecret("pull-secret", map[string]string{corev1.DockerConfigJsonKey: `{"auths":{}}`})
+	dockerConfigJSON.Type = corev1.SecretTypeDockerConfigJson
+	dockerCfg := makeSecret("legacy-pull-secret", map[string]string{corev1.DockerConfigKey: `{}`})
+	dockerCfg.Type = corev1.SecretTypeDockercfg
+	client := kube.NewFakeClient(dockerConfigJSON, dockerCfg, genericCert)
+	sc := NewSecretsController(client, "")
+	client.RunAndWait(make(chan struct{}))
+
+	cases := []struct {
+		name      string
+		namespace string
+		want      string
+		wantErr   bool
+	}{
+		{"pull-secret", "default", `{"auths":{}}`, false},
+		{"legacy-pull-secret", "default", `{}`, false},
+		{"generic", "default", "", true},
+		{"pull-secret", "wrong-namespace", "", true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sc.GetDockerCredential(tt.name, tt.namespace)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, wanted error %v", err, tt.wantErr)
+			}
+			if tt.want != string(got) {
+				t.Errorf("got credential %q, wanted %q", string(got), tt.want)
+			}
+		})
+	}
+}
+
 func allowIdentities(c kube.Client, identities ...string) {
 	allowed := sets.NewSet(identities...)
 	c.Kube().(*fake.Clientset).Fake.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
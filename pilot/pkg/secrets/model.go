@@ -17,6 +17,7 @@ package secrets
 type Controller interface {
 	GetKeyAndCert(name, namespace string) (key []byte, cert []byte)
 	GetCaCert(name, namespace string) (cert []byte)
+	GetDockerCredential(name, namespace string) (cred []byte, err error)
 	Authorize(serviceAccount, namespace string) error
 	AddEventHandler(func(name, namespace string))
 }
@@ -16,10 +16,12 @@ package controller
 
 import (
 	"fmt"
+	"reflect"
 	"time"
 
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	listerv1 "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
@@ -45,6 +47,8 @@ type NamespaceController struct {
 	// getData is the function to fetch the data we will insert into the config map
 	getData func() map[string]string
 	client  corev1.CoreV1Interface
+	// dataCheckInterval is how often getData is checked for changes, such as a rotated CA root cert.
+	dataCheckInterval time.Duration
 
 	queue              queue.Instance
 	namespacesInformer cache.SharedInformer
@@ -56,9 +60,10 @@ type NamespaceController struct {
 // NewNamespaceController returns a pointer to a newly constructed NamespaceController instance.
 func NewNamespaceController(data func() map[string]string, kubeClient kube.Client) *NamespaceController {
 	c := &NamespaceController{
-		getData: data,
-		client:  kubeClient.CoreV1(),
-		queue:   queue.NewQueue(time.Second),
+		getData:           data,
+		client:            kubeClient.CoreV1(),
+		dataCheckInterval: NamespaceResyncPeriod,
+		queue:             queue.NewQueue(time.Second),
 	}
 
 	c.configMapInformer = kubeClient.KubeInformer().Core().V1().ConfigMaps().Informer()
@@ -125,6 +130,39 @@ func (nc *NamespaceController) Run(stopCh <-chan struct{}) {
 	cache.WaitForCacheSync(stopCh, nc.namespacesInformer.HasSynced, nc.configMapInformer.HasSynced)
 	log.Infof("Namespace controller started")
 	go nc.queue.Run(stopCh)
+	go nc.syncOnDataChange(stopCh)
+}
+
+// syncOnDataChange updates the configmap in every namespace when the data changes, for example
+// when the CA root cert is rotated.
+func (nc *NamespaceController) syncOnDataChange(stopCh <-chan struct{}) {
+	data := nc.getData()
+	ticker := time.NewTicker(nc.dataCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			newData := nc.getData()
+			if reflect.DeepEqual(data, newData) {
+				continue
+			}
+			data = newData
+			namespaces, err := nc.namespaceLister.List(labels.Everything())
+			if err != nil {
+				log.Errorf("failed to list namespaces: %v", err)
+				continue
+			}
+			log.Infof("configmap data changed, updating %d namespaces", len(namespaces))
+			for _, ns := range namespaces {
+				ns := ns
+				nc.queue.Push(func() error {
+					return nc.namespaceChange(ns)
+				})
+			}
+		}
+	}
 }
 
 // insertDataForNamespace will add data into the configmap for the specified namespace
@@ -18,6 +18,7 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 
@@ -56,6 +57,35 @@ func TestNamespaceController(t *testing.T) {
 	expectConfigMap(t, nc.configmapLister, "foo", testdata)
 }
 
+func TestNamespaceControllerDataChange(t *testing.T) {
+	client := kube.NewFakeClient()
+	var mu sync.Mutex
+	testdata := map[string]string{"key": "value"}
+	nc := NewNamespaceController(func() map[string]string {
+		mu.Lock()
+		defer mu.Unlock()
+		return testdata
+	}, client)
+	nc.dataCheckInterval = 10 * time.Millisecond
+	nc.configmapLister = client.KubeInformer().Core().V1().ConfigMaps().Lister()
+	stop := make(chan struct{})
+	defer close(stop)
+	client.RunAndWait(stop)
+	nc.Run(stop)
+
+	createNamespace(t, client, "foo", nil)
+	createNamespace(t, client, "bar", nil)
+	expectConfigMap(t, nc.configmapLister, "foo", testdata)
+	expectConfigMap(t, nc.configmapLister, "bar", testdata)
+
+	// The configmaps are updated when the data changes, e.g. when the CA root cert is rotated.
+	mu.Lock()
+	testdata = map[string]string{"key": "rotated"}
+	mu.Unlock()
+	expectConfigMap(t, nc.configmapLister, "foo", map[string]string{"key": "rotated"})
+	expectConfigMap(t, nc.configmapLister, "bar", map[string]string{"key": "rotated"})
+}
+
 func deleteConfigMap(t *testing.T, client kubernetes.Interface, ns string) {
 	t.Helper()
 	_, err := client.CoreV1().ConfigMaps(ns).Get(context.TODO(), CACertNamespaceConfigMap, metav1.GetOptions{})
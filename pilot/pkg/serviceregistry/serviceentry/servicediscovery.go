@@ -16,7 +16,9 @@ package serviceentry
 
 import (
 	"fmt"
+	"hash/fnv"
 	"reflect"
+	"sort"
 	"strconv"
 	"sync"
 
@@ -880,29 +882,15 @@ func selectorChanged(old, curr config.Config) bool {
 // NOTE: If DNS capture is not enabled by the proxy, the automatically
 // allocated IP addresses do not take effect.
 //
-// The current algorithm to allocate IPs is deterministic across all istiods.
-// At stable state, given two istiods with exact same set of services, there should
-// be no change in XDS as the algorithm is just a dumb iterative one that allocates sequentially.
-//
-// TODO: Rather than sequentially allocate IPs, switch to a hash based allocation mechanism so that
-// deletion of the oldest service entry does not cause change of IPs for all other service entries.
-// Currently, the sequential allocation will result in unnecessary XDS reloads (lds/rds) when a
-// service entry with auto allocated IP is deleted. We are trading off a perf problem (xds reload)
-// for a usability problem (e.g., multiple cloud SQL or AWS RDS tcp services with no VIPs end up having
-// the same port, causing traffic to go to the wrong place). Once we move to a deterministic hash-based
-// allocation with deterministic collision resolution, the perf problem will go away. If the collision guarantee
-// cannot be made within the IP address space we have (which is about 64K services), then we may need to
-// have the sequential allocation algorithm as a fallback when too many collisions take place.
+// The preferred IP of a service is derived from a hash of its namespace and hostname, so
+// adding or deleting a service entry usually does not change the IPs of the others, which
+// would otherwise cause unnecessary XDS reloads (lds/rds). When the preferred IPs of services
+// collide, the services are allocated the next free IPs in hostname and namespace order, so
+// the allocation is the same across all istiods with the same set of services. In that case,
+// adding or deleting a service entry may change the IPs of the services colliding with it, or
+// allocated after it by the probing.
 func autoAllocateIPs(services []*model.Service) []*model.Service {
-	// i is everything from 240.240.0.(j) to 240.240.255.(j)
-	// j is everything from 240.240.(i).1 to 240.240.(i).254
-	// we can capture this in one integer variable.
-	// given X, we can compute i by X/255, and j is X%255
-	// To avoid allocating 240.240.(i).255, if X % 255 is 0, increment X.
-	// For example, when X=510, the resulting IP would be 240.240.2.0 (invalid)
-	// So we bump X to 511, so that the resulting IP is 240.240.2.1
-	maxIPs := 255 * 255 // are we going to exceeed this limit by processing 64K services?
-	x := 0
+	candidates := make([]*model.Service, 0, len(services))
 	for _, svc := range services {
 		// we can allocate IPs only if
 		// 1. the service has resolution set to static/dns. We cannot allocate
@@ -911,22 +899,51 @@ func autoAllocateIPs(services []*model.Service) []*model.Service {
 		// 3. the hostname is not a wildcard
 		if svc.Address == constants.UnspecifiedIP && !svc.Hostname.IsWildCarded() &&
 			svc.Resolution != model.Passthrough {
-			x++
-			if x%255 == 0 {
-				x++
-			}
-			if x >= maxIPs {
-				log.Errorf("out of IPs to allocate for service entries")
-				return services
+			candidates = append(candidates, svc)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Hostname != candidates[j].Hostname {
+			return candidates[i].Hostname < candidates[j].Hostname
+		}
+		return candidates[i].Attributes.Namespace < candidates[j].Attributes.Namespace
+	})
+
+	allocated := make(map[int]struct{}, len(candidates))
+	for _, svc := range candidates {
+		if len(allocated) >= maxAutoAllocatedIPs {
+			log.Errorf("out of IPs to allocate for service entries")
+			return services
+		}
+		ip := autoAllocatedIPIndex(svc)
+		for {
+			if _, f := allocated[ip]; !f {
+				break
 			}
-			thirdOctet := x / 255
-			fourthOctet := x % 255
-			svc.AutoAllocatedAddress = fmt.Sprintf("240.240.%d.%d", thirdOctet, fourthOctet)
+			ip = (ip + 1) % maxAutoAllocatedIPs
 		}
+		allocated[ip] = struct{}{}
+		svc.AutoAllocatedAddress = autoAllocatedIP(ip)
 	}
 	return services
 }
 
+// The auto allocated IPs are 240.240.(i).(j), where i is everything from 0 to 255 and j everything
+// from 1 to 254, so that the network and broadcast addresses of each /24 are never allocated.
+const maxAutoAllocatedIPs = 256 * 254
+
+// autoAllocatedIPIndex returns the index of the preferred auto allocated IP of the service.
+func autoAllocatedIPIndex(svc *model.Service) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(svc.Attributes.Namespace + "/" + string(svc.Hostname)))
+	return int(h.Sum32() % maxAutoAllocatedIPs)
+}
+
+// autoAllocatedIP returns the auto allocated IP with the given index.
+func autoAllocatedIP(index int) string {
+	return fmt.Sprintf("240.240.%d.%d", index/254, index%254+1)
+}
+
 func makeConfigKey(svc *model.Service) model.ConfigKey {
 	return model.ConfigKey{
 		Kind:      gvk.ServiceEntry,
@@ -1313,7 +1313,7 @@ func Test_autoAllocateIP_conditions(t *testing.T) {
 					Hostname:             "foo.com",
 					Resolution:           model.ClientSideLB,
 					Address:              "0.0.0.0",
-					AutoAllocatedAddress: "240.240.0.1",
+					AutoAllocatedAddress: "240.240.228.54",
 				},
 			},
 		},
@@ -1331,7 +1331,7 @@ func Test_autoAllocateIP_conditions(t *testing.T) {
 					Hostname:             "foo.com",
 					Resolution:           model.DNSLB,
 					Address:              "0.0.0.0",
-					AutoAllocatedAddress: "240.240.0.1",
+					AutoAllocatedAddress: "240.240.228.54",
 				},
 			},
 		},
@@ -1357,30 +1357,17 @@ func Test_autoAllocateIP_values(t *testing.T) {
 	}
 	gotServices := autoAllocateIPs(inServices)
 
-	// out of the 512 IPs, we dont expect the following IPs
-	// 240.240.0.0
-	// 240.240.0.255
-	// 240.240.1.0
-	// 240.240.1.255
-	// 240.240.2.0
-	// 240.240.2.255
-	// The last IP should be 240.240.2.4
-	doNotWant := map[string]bool{
-		"240.240.0.0":   true,
-		"240.240.0.255": true,
-		"240.240.1.0":   true,
-		"240.240.1.255": true,
-		"240.240.2.0":   true,
-		"240.240.2.255": true,
-	}
-	expectedLastIP := "240.240.2.4"
-	if gotServices[len(gotServices)-1].AutoAllocatedAddress != expectedLastIP {
-		t.Errorf("expected last IP address to be %s, got %s", expectedLastIP, gotServices[len(gotServices)-1].AutoAllocatedAddress)
+	// Colliding services get the next free IPs, skipping the network and broadcast
+	// addresses of each /24, i.e. 240.240.(i).0 and 240.240.(i).255
+	expectedFirstIP := "240.240.228.54"
+	if gotServices[0].AutoAllocatedAddress != expectedFirstIP {
+		t.Errorf("expected first IP address to be %s, got %s", expectedFirstIP, gotServices[0].AutoAllocatedAddress)
 	}
 
 	gotIPMap := make(map[string]bool)
 	for _, svc := range gotServices {
-		if svc.AutoAllocatedAddress == "" || doNotWant[svc.AutoAllocatedAddress] {
+		if svc.AutoAllocatedAddress == "" || strings.HasSuffix(svc.AutoAllocatedAddress, ".0") ||
+			strings.HasSuffix(svc.AutoAllocatedAddress, ".255") {
 			t.Errorf("unexpected value for auto allocated IP address %s", svc.AutoAllocatedAddress)
 		}
 		if gotIPMap[svc.AutoAllocatedAddress] {
@@ -1390,6 +1377,65 @@ func Test_autoAllocateIP_values(t *testing.T) {
 	}
 }
 
+func Test_autoAllocateIP_stable(t *testing.T) {
+	makeServices := func(hostnames ...host.Name) []*model.Service {
+		services := make([]*model.Service, 0, len(hostnames))
+		for _, h := range hostnames {
+			services = append(services, &model.Service{
+				Hostname:   h,
+				Resolution: model.DNSLB,
+				Address:    constants.UnspecifiedIP,
+			})
+		}
+		return services
+	}
+	allocatedIPs := func(services []*model.Service) map[host.Name]string {
+		out := map[host.Name]string{}
+		for _, svc := range autoAllocateIPs(services) {
+			out[svc.Hostname] = svc.AutoAllocatedAddress
+		}
+		return out
+	}
+
+	want := map[host.Name]string{
+		"a.com": "240.240.109.117",
+		"b.com": "240.240.172.88",
+		"c.com": "240.240.235.31",
+	}
+	if got := allocatedIPs(makeServices("a.com", "b.com", "c.com")); !reflect.DeepEqual(got, want) {
+		t.Errorf("autoAllocateIPs() = %v, want %v", got, want)
+	}
+	// The allocation does not depend on the order of the services.
+	if got := allocatedIPs(makeServices("c.com", "b.com", "a.com")); !reflect.DeepEqual(got, want) {
+		t.Errorf("autoAllocateIPs() with reversed services = %v, want %v", got, want)
+	}
+	// Removing a service does not change the IPs of the others.
+	delete(want, "b.com")
+	if got := allocatedIPs(makeServices("a.com", "c.com")); !reflect.DeepEqual(got, want) {
+		t.Errorf("autoAllocateIPs() after removing a service = %v, want %v", got, want)
+	}
+
+	// Adding a service whose preferred IP collides, and which comes first in hostname order,
+	// moves the other service to the next IP.
+	want = map[host.Name]string{
+		"16811.example.com": "240.240.109.117",
+		"a.com":             "240.240.109.118",
+		"c.com":             "240.240.235.31",
+	}
+	if got := allocatedIPs(makeServices("a.com", "c.com", "16811.example.com")); !reflect.DeepEqual(got, want) {
+		t.Errorf("autoAllocateIPs() after adding a colliding service = %v, want %v", got, want)
+	}
+	// A colliding service which comes last in hostname order gets the next IP instead.
+	want = map[host.Name]string{
+		"a.com":         "240.240.109.117",
+		"c.com":         "240.240.235.31",
+		"svc-44325.com": "240.240.109.118",
+	}
+	if got := allocatedIPs(makeServices("a.com", "c.com", "svc-44325.com")); !reflect.DeepEqual(got, want) {
+		t.Errorf("autoAllocateIPs() after adding a colliding service = %v, want %v", got, want)
+	}
+}
+
 func TestWorkloadEntryOnlyMode(t *testing.T) {
 	store, registry, _, cleanup := initServiceDiscoveryWithOpts(DisableServiceEntryProcessing())
 	defer cleanup()
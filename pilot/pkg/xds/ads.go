@@ -108,6 +108,13 @@ type Connection struct {
 	// (last push not ACKed). When we get an ACK from Envoy, if the type is populated here, we will trigger
 	// the push.
 	blockedPushes map[string]*model.PushRequest
+
+	// pushTrace records the most recent pushes to this connection, for debugging.
+	pushTrace pushTrace
+
+	// configHistory keeps the most recent config versions pushed to this connection, for debugging.
+	// It is nil unless enabled with PILOT_CONFIG_HISTORY_SIZE.
+	configHistory *configHistory
 }
 
 // Event represents a config or registry event that results in a push.
@@ -128,6 +135,7 @@ func newConnection(peerAddr string, stream DiscoveryStream) *Connection {
 		Connect:       time.Now(),
 		stream:        stream,
 		blockedPushes: map[string]*model.PushRequest{},
+		configHistory: newConfigHistory(features.ConfigHistorySize),
 	}
 }
 
@@ -457,7 +465,7 @@ func shouldUnsubscribe(request *discovery.DiscoveryRequest) bool {
 // resource names.
 func isWildcardTypeURL(typeURL string) bool {
 	switch typeURL {
-	case v3.SecretType, v3.EndpointType, v3.RouteType, v3.ExtensionConfigurationType:
+	case v3.SecretType, v3.EndpointType, v3.RouteType, v3.VirtualHostType, v3.ExtensionConfigurationType:
 		// By XDS spec, these are not wildcard
 		return false
 	case v3.ClusterType, v3.ListenerType:
@@ -500,6 +508,9 @@ func (s *DiscoveryServer) initConnection(node *core.Node, con *Connection) error
 		return err
 	}
 	con.proxy = proxy
+	// On-demand xDS relies on VHDS, which is only available over delta xDS.
+	con.proxy.OnDemand = con.deltaStream != nil && features.EnableOnDemandXDS &&
+		proxy.Type == model.SidecarProxy && bool(proxy.Metadata.OnDemandXDS)
 	if features.EnableXDSIdentityCheck && con.Identities != nil {
 		// TODO: allow locking down, rejecting unauthenticated requests.
 		id, err := checkConnectionIdentity(con)
@@ -679,6 +690,7 @@ func (s *DiscoveryServer) pushConnection(con *Connection, pushEv *Event) error {
 	}
 
 	if !s.ProxyNeedsPush(con.proxy, pushRequest) {
+		con.pushTrace.record(pushRequest, false)
 		log.Debugf("Skipping push to %v, no updates required", con.ConID)
 		if pushRequest.Full {
 			// Only report for full versions, incremental pushes do not have a new version
@@ -687,6 +699,7 @@ func (s *DiscoveryServer) pushConnection(con *Connection, pushEv *Event) error {
 		return nil
 	}
 
+	con.pushTrace.record(pushRequest, true)
 	currentVersion := versionInfo()
 
 	// Send pushes to all generators
@@ -805,12 +818,14 @@ func (s *DiscoveryServer) ProxyUpdate(clusterID, ip string) {
 		}
 	}
 
-	s.pushQueue.Enqueue(connection, &model.PushRequest{
+	req := &model.PushRequest{
 		Full:   true,
 		Push:   s.globalPushContext(),
 		Start:  time.Now(),
 		Reason: []model.TriggerReason{model.ProxyUpdate},
-	})
+	}
+	req.AddTrace(model.NewPushTraceEvent(req, "proxy "+ip+" updated in cluster "+clusterID))
+	s.pushQueue.Enqueue(connection, req)
 }
 
 // AdsPushAll will send updates to all nodes, for a full config or incremental EDS.
@@ -862,6 +877,9 @@ func (s *DiscoveryServer) startPush(req *model.PushRequest) {
 		}
 	}
 	req.Start = time.Now()
+	if req.Push != nil {
+		req.AddTrace(model.NewPushTraceEvent(nil, "push "+req.Push.PushVersion+" started"))
+	}
 	for _, p := range s.AllClients() {
 		s.pushQueue.Enqueue(p, req)
 	}
@@ -15,8 +15,13 @@
 package xds
 
 import (
+	"strconv"
+
 	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/util/sets"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
 	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/schema/gvk"
 )
 
@@ -35,6 +40,8 @@ func ConfigAffectsProxy(req *model.PushRequest, proxy *model.Proxy) bool {
 		return true
 	}
 
+	// The hosts an on-demand proxy has subscribed to, computed on first use.
+	var onDemand map[host.Name]struct{}
 	for config := range req.ConfigsUpdated {
 		affected := true
 
@@ -50,6 +57,14 @@ func ConfigAffectsProxy(req *model.PushRequest, proxy *model.Proxy) bool {
 		}
 
 		if affected && checkProxyDependencies(proxy, config) {
+			if proxy.OnDemand && config.Kind == gvk.ServiceEntry {
+				if onDemand == nil {
+					onDemand = onDemandHosts(proxy)
+				}
+				if !onDemandServiceAffected(proxy, config, onDemand) {
+					continue
+				}
+			}
 			return true
 		}
 	}
@@ -57,6 +72,40 @@ func ConfigAffectsProxy(req *model.PushRequest, proxy *model.Proxy) bool {
 	return false
 }
 
+// onDemandServiceAffected checks whether a service change affects an on-demand proxy. The proxy only holds
+// the configuration of the outbound HTTP services it has subscribed to, so other HTTP services do not affect
+// it, as long as it already watches the routes of their ports.
+func onDemandServiceAffected(proxy *model.Proxy, config model.ConfigKey, subscribed map[host.Name]struct{}) bool {
+	var svc *model.Service
+	for _, s := range proxy.SidecarScope.Services() {
+		if string(s.Hostname) == config.Name && s.Attributes.Namespace == config.Namespace {
+			svc = s
+			break
+		}
+	}
+	if svc == nil {
+		// The service was removed, or is no longer visible to the proxy.
+		return true
+	}
+	if _, f := subscribed[svc.Hostname]; f {
+		return true
+	}
+	proxy.RLock()
+	defer proxy.RUnlock()
+	w := proxy.WatchedResources[v3.RouteType]
+	if w == nil {
+		return true
+	}
+	routes := sets.NewSet(w.ResourceNames...)
+	for _, port := range svc.Ports {
+		if !routes.Contains(strconv.Itoa(port.Port)) {
+			// A new listener and route configuration are needed for the port.
+			return true
+		}
+	}
+	return false
+}
+
 func checkProxyDependencies(proxy *model.Proxy, config model.ConfigKey) bool {
 	// Detailed config dependencies check.
 	switch proxy.Type {
@@ -20,6 +20,7 @@ import (
 	"testing"
 
 	model "istio.io/istio/pilot/pkg/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/schema/gvk"
 	"istio.io/istio/pkg/spiffe"
@@ -260,3 +261,54 @@ func TestCheckConnectionIdentity(t *testing.T) {
 		})
 	}
 }
+
+func TestOnDemandProxyNeedsPush(t *testing.T) {
+	s := NewFakeDiscoveryServer(t, FakeOptions{ConfigString: onDemandServiceEntries + `
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: c
+  namespace: default
+spec:
+  hosts:
+  - c.example.com
+  http:
+  - route:
+    - destination:
+        host: b.example.com
+`})
+
+	cases := []struct {
+		name    string
+		vhosts  []string
+		routes  []string
+		service string
+		want    bool
+	}{
+		{"subscribed service", []string{"80/a.example.com"}, []string{"80"}, "a.example.com", true},
+		{"unsubscribed service", []string{"80/a.example.com"}, []string{"80"}, "b.example.com", false},
+		{"subscribed short name", []string{"80/a:80"}, []string{"80"}, "a.example.com", true},
+		{"virtual service destination", []string{"80/c.example.com"}, []string{"80"}, "b.example.com", true},
+		{"tcp service", nil, []string{"80"}, "tcp.example.com", true},
+		{"unwatched route", nil, nil, "b.example.com", true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy := s.SetupProxy(&model.Proxy{ConfigNamespace: "default"})
+			proxy.OnDemand = true
+			proxy.WatchedResources = map[string]*model.WatchedResource{
+				v3.VirtualHostType: {TypeUrl: v3.VirtualHostType, ResourceNames: tt.vhosts},
+			}
+			if tt.routes != nil {
+				proxy.WatchedResources[v3.RouteType] = &model.WatchedResource{TypeUrl: v3.RouteType, ResourceNames: tt.routes}
+			}
+			req := &model.PushRequest{ConfigsUpdated: map[model.ConfigKey]struct{}{
+				{Kind: gvk.ServiceEntry, Name: tt.service, Namespace: "default"}: {},
+			}}
+			if got := DefaultProxyNeedsPush(proxy, req); got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
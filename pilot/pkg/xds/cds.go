@@ -15,9 +15,12 @@
 package xds
 
 import (
+	"strings"
+
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/util"
 	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/schema/gvk"
 )
 
@@ -74,8 +77,20 @@ func (c CdsGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w *m
 		return nil, nil
 	}
 	rawClusters := c.Server.ConfigGenerator.BuildClusters(proxy, push)
+	var wanted map[host.Name]struct{}
+	if proxy.OnDemand {
+		// Only send the outbound clusters of the services the proxy has asked for.
+		wanted = onDemandHosts(proxy)
+	}
 	resources := model.Resources{}
 	for _, c := range rawClusters {
+		if wanted != nil && strings.HasPrefix(c.Name, "outbound|") {
+			if _, _, h, _ := model.ParseSubsetKey(c.Name); h != "" {
+				if _, f := wanted[h]; !f {
+					continue
+				}
+			}
+		}
 		resources = append(resources, util.MessageToAny(c))
 	}
 	return resources, nil
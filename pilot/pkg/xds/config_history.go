@@ -0,0 +1,326 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/golang/protobuf/jsonpb"
+	any "github.com/golang/protobuf/ptypes/any"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// hashHistoryFactor is how many more versions are kept with only resource hashes, compared to full content.
+const hashHistoryFactor = 4
+
+// ConfigHistoryVersion describes a version of the config pushed to a proxy.
+type ConfigHistoryVersion struct {
+	Version   string    `json:"version"`
+	Time      time.Time `json:"time"`
+	Resources int       `json:"resources"`
+	// ContentAvailable is false when only the hashes of the resources are still kept.
+	ContentAvailable bool `json:"contentAvailable"`
+}
+
+// ConfigChange describes a resource that differs between two versions.
+type ConfigChange struct {
+	TypeURL string `json:"typeUrl"`
+	Name    string `json:"name"`
+	// Change is one of added, removed or modified.
+	Change string          `json:"change"`
+	From   json.RawMessage `json:"from,omitempty"`
+	To     json.RawMessage `json:"to,omitempty"`
+}
+
+// ConfigDiff lists the resources changed between two versions pushed to a proxy.
+type ConfigDiff struct {
+	From    string         `json:"from"`
+	To      string         `json:"to"`
+	Changes []ConfigChange `json:"changes"`
+}
+
+type configHistoryEntry struct {
+	version string
+	time    time.Time
+	// hashes and resources are keyed by type URL, then resource name. Inner maps are never
+	// modified once stored, so they are shared between entries.
+	hashes map[string]map[string]uint64
+	// resources is nil once the entry is too old to keep the full content.
+	resources map[string]map[string]*any.Any
+}
+
+func (e *configHistoryEntry) size() int {
+	n := 0
+	for _, h := range e.hashes {
+		n += len(h)
+	}
+	return n
+}
+
+// configHistory keeps the most recent config versions pushed to a connection.
+type configHistory struct {
+	mu      sync.Mutex
+	size    int
+	entries []*configHistoryEntry
+}
+
+func newConfigHistory(size int) *configHistory {
+	if size <= 0 {
+		return nil
+	}
+	return &configHistory{size: size}
+}
+
+// record stores the resources pushed for a type. If replace is false, the resources are
+// merged with those previously pushed, as done for incremental pushes.
+func (h *configHistory) record(version, typeURL string, resources []*discovery.Resource, replace bool) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var last *configHistoryEntry
+	if len(h.entries) > 0 {
+		last = h.entries[len(h.entries)-1]
+	}
+	entry := last
+	if last == nil || last.version != version {
+		entry = &configHistoryEntry{
+			version:   version,
+			time:      time.Now(),
+			hashes:    map[string]map[string]uint64{},
+			resources: map[string]map[string]*any.Any{},
+		}
+		if last != nil {
+			for t, v := range last.hashes {
+				entry.hashes[t] = v
+			}
+			for t, v := range last.resources {
+				entry.resources[t] = v
+			}
+		}
+		h.entries = append(h.entries, entry)
+	}
+
+	hashes := map[string]uint64{}
+	content := map[string]*any.Any{}
+	if !replace {
+		for k, v := range entry.hashes[typeURL] {
+			hashes[k] = v
+		}
+		for k, v := range entry.resources[typeURL] {
+			content[k] = v
+		}
+	}
+	for i, r := range resources {
+		name := r.Name
+		if name == "" {
+			name = fmt.Sprint(i)
+		}
+		hashes[name] = hashResource(r.Resource)
+		content[name] = r.Resource
+	}
+	entry.hashes[typeURL] = hashes
+	entry.resources[typeURL] = content
+
+	if maxEntries := h.size * hashHistoryFactor; len(h.entries) > maxEntries {
+		h.entries = h.entries[len(h.entries)-maxEntries:]
+	}
+	for i := 0; i < len(h.entries)-h.size; i++ {
+		h.entries[i].resources = nil
+	}
+}
+
+func hashResource(r *any.Any) uint64 {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(r.TypeUrl))
+	_, _ = hash.Write(r.Value)
+	return hash.Sum64()
+}
+
+// versions lists the versions kept, oldest first.
+func (h *configHistory) versions() []ConfigHistoryVersion {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make([]ConfigHistoryVersion, 0, len(h.entries))
+	for _, e := range h.entries {
+		out = append(out, ConfigHistoryVersion{
+			Version:          e.version,
+			Time:             e.time,
+			Resources:        e.size(),
+			ContentAvailable: e.resources != nil,
+		})
+	}
+	return out
+}
+
+func (h *configHistory) find(version string) *configHistoryEntry {
+	for _, e := range h.entries {
+		if e.version == version {
+			return e
+		}
+	}
+	return nil
+}
+
+// diff returns the changes between two versions. If to is empty, the latest version is used.
+func (h *configHistory) diff(from, to string) (*ConfigDiff, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.entries) == 0 {
+		return nil, fmt.Errorf("no config history")
+	}
+	if to == "" {
+		to = h.entries[len(h.entries)-1].version
+	}
+	a, b := h.find(from), h.find(to)
+	if a == nil {
+		return nil, fmt.Errorf("version %q not found in config history", from)
+	}
+	if b == nil {
+		return nil, fmt.Errorf("version %q not found in config history", to)
+	}
+
+	out := &ConfigDiff{From: from, To: to, Changes: []ConfigChange{}}
+	types := map[string]struct{}{}
+	for t := range a.hashes {
+		types[t] = struct{}{}
+	}
+	for t := range b.hashes {
+		types[t] = struct{}{}
+	}
+	for t := range types {
+		names := map[string]struct{}{}
+		for n := range a.hashes[t] {
+			names[n] = struct{}{}
+		}
+		for n := range b.hashes[t] {
+			names[n] = struct{}{}
+		}
+		for n := range names {
+			ha, inA := a.hashes[t][n]
+			hb, inB := b.hashes[t][n]
+			change := ConfigChange{TypeURL: t, Name: n}
+			switch {
+			case !inA:
+				change.Change = "added"
+			case !inB:
+				change.Change = "removed"
+			case ha == hb:
+				continue
+			default:
+				ra, rb := a.lookup(t, n), b.lookup(t, n)
+				// Hashes may differ for equal resources, as marshaling is not deterministic for maps.
+				if ra != nil && rb != nil && resourcesEqual(ra, rb) {
+					continue
+				}
+				change.Change = "modified"
+			}
+			change.From = resourceJSON(a.lookup(t, n))
+			change.To = resourceJSON(b.lookup(t, n))
+			out.Changes = append(out.Changes, change)
+		}
+	}
+	sort.Slice(out.Changes, func(i, j int) bool {
+		if out.Changes[i].TypeURL != out.Changes[j].TypeURL {
+			return out.Changes[i].TypeURL < out.Changes[j].TypeURL
+		}
+		return out.Changes[i].Name < out.Changes[j].Name
+	})
+	return out, nil
+}
+
+func (e *configHistoryEntry) lookup(typeURL, name string) *any.Any {
+	if e.resources == nil {
+		return nil
+	}
+	return e.resources[typeURL][name]
+}
+
+func resourcesEqual(a, b *any.Any) bool {
+	ma, err := anypb.UnmarshalNew(a, proto.UnmarshalOptions{})
+	if err != nil {
+		return false
+	}
+	mb, err := anypb.UnmarshalNew(b, proto.UnmarshalOptions{})
+	if err != nil {
+		return false
+	}
+	return proto.Equal(ma, mb)
+}
+
+func resourceJSON(r *any.Any) json.RawMessage {
+	if r == nil {
+		return nil
+	}
+	s, err := (&jsonpb.Marshaler{}).MarshalToString(r)
+	if err != nil {
+		return nil
+	}
+	return json.RawMessage(s)
+}
+
+// ConfigDiffHandler lists the config versions kept for a proxy or, if from is set, returns the
+// resources changed between the from and to versions.
+// It is mapped to /debug/config_diff
+func (s *DiscoveryServer) ConfigDiffHandler(w http.ResponseWriter, req *http.Request) {
+	proxyID := req.URL.Query().Get("proxyID")
+	if proxyID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("You must provide a proxyID in the query string"))
+		return
+	}
+	con := s.getProxyConnection(proxyID)
+	if con == nil {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte("Proxy not connected to this Pilot instance"))
+		return
+	}
+	if con.configHistory == nil {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte("Config history is disabled, set PILOT_CONFIG_HISTORY_SIZE to enable it"))
+		return
+	}
+
+	var out interface{}
+	if from := req.URL.Query().Get("from"); from != "" {
+		diff, err := con.configHistory.diff(from, req.URL.Query().Get("to"))
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		out = diff
+	} else {
+		out = map[string]interface{}{"versions": con.configHistory.versions()}
+	}
+	by, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	_, _ = w.Write(by)
+}
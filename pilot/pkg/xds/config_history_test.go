@@ -0,0 +1,173 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+func historyClusters(names ...string) []*discovery.Resource {
+	out := []*discovery.Resource{}
+	for _, n := range names {
+		out = append(out, &discovery.Resource{Name: n, Resource: util.MessageToAny(&cluster.Cluster{Name: n})})
+	}
+	return out
+}
+
+func changesOf(d *ConfigDiff) []string {
+	out := []string{}
+	for _, c := range d.Changes {
+		out = append(out, c.Change+" "+v3.GetShortType(c.TypeURL)+" "+c.Name)
+	}
+	return out
+}
+
+func TestConfigHistory(t *testing.T) {
+	if newConfigHistory(0) != nil {
+		t.Fatal("expected history to be disabled")
+	}
+	h := newConfigHistory(2)
+	h.record("v1", v3.ClusterType, historyClusters("a", "b"), true)
+	h.record("v1", v3.EndpointType, []*discovery.Resource{
+		{Name: "a", Resource: util.MessageToAny(&endpoint.ClusterLoadAssignment{ClusterName: "a"})},
+	}, true)
+	modified := historyClusters("a", "c")
+	modified[0].Resource = util.MessageToAny(&cluster.Cluster{Name: "a", AltStatName: "changed"})
+	h.record("v2", v3.ClusterType, modified, true)
+
+	diff, err := h.diff("v1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"modified CDS a", "removed CDS b", "added CDS c"}
+	if got := changesOf(diff); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected changes %v, got %v", expected, got)
+	}
+	if diff.To != "v2" || !strings.Contains(string(diff.Changes[0].To), "changed") ||
+		strings.Contains(string(diff.Changes[0].From), "changed") {
+		t.Fatalf("unexpected diff %+v", diff)
+	}
+
+	// Incremental pushes are merged with the previous resources
+	h.record("v3", v3.EndpointType, []*discovery.Resource{
+		{Name: "b", Resource: util.MessageToAny(&endpoint.ClusterLoadAssignment{ClusterName: "b"})},
+	}, false)
+	diff, err = h.diff("v2", "v3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := changesOf(diff); !reflect.DeepEqual(got, []string{"added EDS b"}) {
+		t.Fatalf("unexpected changes %v", got)
+	}
+
+	// Only the latest versions keep their content
+	versions := h.versions()
+	if len(versions) != 3 || versions[0].ContentAvailable || !versions[1].ContentAvailable || versions[2].Resources != 4 {
+		t.Fatalf("unexpected versions %+v", versions)
+	}
+	diff, err = h.diff("v1", "v3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.Changes[0].From != nil || diff.Changes[0].To == nil {
+		t.Fatalf("expected only the new content, got %+v", diff.Changes[0])
+	}
+
+	for _, v := range []string{"v4", "v5", "v6", "v7", "v8", "v9"} {
+		h.record(v, v3.ClusterType, historyClusters("a"), true)
+	}
+	if versions := h.versions(); len(versions) != 2*hashHistoryFactor || versions[0].Version != "v2" {
+		t.Fatalf("expected history to be bounded, got %+v", versions)
+	}
+	if _, err := h.diff("v1", ""); err == nil {
+		t.Fatal("expected error for evicted version")
+	}
+}
+
+func TestConfigDiffHandler(t *testing.T) {
+	original := features.ConfigHistorySize
+	t.Cleanup(func() {
+		features.ConfigHistorySize = original
+	})
+	get := func(s *DiscoveryServer, query string, wantCode int, out interface{}) {
+		t.Helper()
+		req, err := http.NewRequest("GET", "/debug/config_diff?"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(s.ConfigDiffHandler).ServeHTTP(rr, req)
+		if rr.Code != wantCode {
+			t.Fatalf("wanted response code %v, got %v: %s", wantCode, rr.Code, rr.Body.String())
+		}
+		if out != nil {
+			if err := json.Unmarshal(rr.Body.Bytes(), out); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	features.ConfigHistorySize = 0
+	s := NewFakeDiscoveryServer(t, FakeOptions{})
+	s.ConnectADS().WithType(v3.ClusterType).RequestResponseAck(nil)
+	get(s.Discovery, "proxyID=test.default", http.StatusNotFound, nil)
+
+	features.ConfigHistorySize = 2
+	s = NewFakeDiscoveryServer(t, FakeOptions{})
+	ads := s.ConnectADS().WithType(v3.ClusterType)
+	ads.RequestResponseAck(nil)
+	get(s.Discovery, "", http.StatusBadRequest, nil)
+
+	s.Discovery.ConfigUpdate(&model.PushRequest{
+		Full:           true,
+		ConfigsUpdated: map[model.ConfigKey]struct{}{{Kind: gvk.EnvoyFilter, Name: "filter", Namespace: "default"}: {}},
+		Reason:         []model.TriggerReason{model.ConfigUpdate},
+	})
+	ads.ExpectResponse()
+
+	versions := struct {
+		Versions []ConfigHistoryVersion `json:"versions"`
+	}{}
+	retry.UntilSuccessOrFail(t, func() error {
+		get(s.Discovery, "proxyID=test.default", http.StatusOK, &versions)
+		if len(versions.Versions) != 2 {
+			return fmt.Errorf("expected 2 versions, got %+v", versions.Versions)
+		}
+		return nil
+	})
+	diff := &ConfigDiff{}
+	get(s.Discovery, "proxyID=test.default&from="+versions.Versions[0].Version, http.StatusOK, diff)
+	if diff.To != versions.Versions[1].Version || len(diff.Changes) != 0 {
+		t.Fatalf("expected no changes, got %+v", diff)
+	}
+	get(s.Discovery, "proxyID=test.default&from=unknown", http.StatusNotFound, nil)
+}
@@ -183,17 +183,30 @@ func (s *DiscoveryServer) AddDebugHandlers(mux *http.ServeMux, enableProfiling b
 	s.addDebugHandler(mux, "/debug/instancesz", "Debug support for service instances", s.instancesz)
 
 	s.addDebugHandler(mux, "/debug/authorizationz", "Internal authorization policies", s.Authorizationz)
-	s.addDebugHandler(mux, "/debug/telemetryz", "Debug Telemetry configuration", s.telemetryz)
+	s.addDebugHandler(mux, "/debug/telemetryz", "Debug Telemetry configuration, or the effective configuration for the passed in proxyID", s.telemetryz)
 	s.addDebugHandler(mux, "/debug/config_dump", "ConfigDump in the form of the Envoy admin config dump API for passed in proxyID", s.ConfigDump)
 	s.addDebugHandler(mux, "/debug/push_status", "Last PushContext Details", s.PushStatusHandler)
 	s.addDebugHandler(mux, "/debug/pushcontext", "Debug support for current push context", s.PushContextHandler)
 	s.addDebugHandler(mux, "/debug/connections", "Info about the connected XDS clients", s.ConnectionsHandler)
+	s.addDebugHandler(mux, "/debug/config_diff", "Config versions pushed to the passed in proxyID, or the diff between the from and to versions",
+		s.ConfigDiffHandler)
+	s.addDebugHandler(mux, "/debug/push_trace", "Recent pushes to the passed in proxyID, with the events that caused them", s.PushTraceHandler)
 
 	s.addDebugHandler(mux, "/debug/inject", "Active inject template", s.InjectTemplateHandler(webhook))
 	s.addDebugHandler(mux, "/debug/mesh", "Active mesh config", s.MeshHandler)
 	s.addDebugHandler(mux, "/debug/networkz", "List cross-network gateways", s.networkz)
 }
 
+// AddDebugHandler adds a debug handler of another component of istiod to mux. It is served and
+// listed the same way as the debug handlers of the discovery server.
+func (s *DiscoveryServer) AddDebugHandler(mux *http.ServeMux, path string, help string,
+	handler func(http.ResponseWriter, *http.Request)) {
+	if !features.EnableDebugOnHTTP {
+		return
+	}
+	s.addDebugHandler(mux, path, help, handler)
+}
+
 func (s *DiscoveryServer) addDebugHandler(mux *http.ServeMux, path string, help string,
 	handler func(http.ResponseWriter, *http.Request)) {
 	s.debugHandlers[path] = help
@@ -471,8 +484,26 @@ func (s *DiscoveryServer) Authorizationz(w http.ResponseWriter, req *http.Reques
 	}
 }
 
+// telemetryz dumps the Telemetry configuration. If a proxyID is provided, the effective
+// Telemetry configuration for that proxy is returned instead.
 func (s *DiscoveryServer) telemetryz(w http.ResponseWriter, req *http.Request) {
 	w.Header().Add("Content-Type", "application/json")
+	if proxyID := req.URL.Query().Get("proxyID"); proxyID != "" {
+		con := s.getProxyConnection(proxyID)
+		if con == nil {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte("Proxy not connected to this Pilot instance"))
+			return
+		}
+		b, err := json.MarshalIndent(s.globalPushContext().Telemetry.EffectiveTelemetryForProxy(con.proxy), " ", " ")
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		_, _ = w.Write(b)
+		return
+	}
 	t := s.globalPushContext().Telemetry
 	b, err := json.MarshalIndent(t, " ", " ")
 	if err != nil {
@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
@@ -203,6 +204,66 @@ func getConfigDump(t *testing.T, s *xds.DiscoveryServer, proxyID string, wantCod
 	return got
 }
 
+func TestTelemetryz(t *testing.T) {
+	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{ConfigString: `
+apiVersion: telemetry.istio.io/v1alpha1
+kind: Telemetry
+metadata:
+  name: mesh-default
+  namespace: istio-system
+spec:
+  tracing:
+  - randomSamplingPercentage: 10
+---
+apiVersion: telemetry.istio.io/v1alpha1
+kind: Telemetry
+metadata:
+  name: default
+  namespace: default
+spec:
+  tracing:
+  - customTags:
+      team:
+        literal:
+          value: test
+`})
+	ads := s.ConnectADS()
+	ads.RequestResponseAck(&discovery.DiscoveryRequest{TypeUrl: v3.ClusterType})
+
+	mux := http.NewServeMux()
+	s.Discovery.AddDebugHandlers(mux, false, nil)
+	get := func(proxyID string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", "/debug/telemetryz?proxyID="+proxyID, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := get("not-found"); rr.Code != http.StatusNotFound {
+		t.Fatalf("wanted response code %v, got %v", http.StatusNotFound, rr.Code)
+	}
+	rr := get("test.default")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("wanted response code %v, got %v: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	got := map[string]interface{}{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	tracing, _ := got["tracing"].([]interface{})
+	if len(tracing) != 1 {
+		t.Fatalf("expected the effective tracing configuration, got %s", rr.Body.String())
+	}
+	// The namespace configuration is merged over the mesh-wide one.
+	effective := tracing[0].(map[string]interface{})
+	if effective["randomSamplingPercentage"] == nil || effective["customTags"] == nil {
+		t.Fatalf("expected the merged tracing configuration, got %s", rr.Body.String())
+	}
+}
+
 func TestDebugHandlers(t *testing.T) {
 	leak.Check(t)
 	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{})
@@ -217,3 +278,25 @@ func TestDebugHandlers(t *testing.T) {
 		t.Errorf("Error in generatating debug endpoint list")
 	}
 }
+
+func TestAddDebugHandler(t *testing.T) {
+	leak.Check(t)
+	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{})
+	mux := http.NewServeMux()
+	s.Discovery.AddDebugHandlers(mux, false, nil)
+	s.Discovery.AddDebugHandler(mux, "/debug/extra", "Extra debug handler", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("extra"))
+	})
+
+	for path, want := range map[string]string{"/debug/extra": "extra", "/debug": "Extra debug handler"} {
+		req, err := http.NewRequest("GET", path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), want) {
+			t.Fatalf("%s: got %d %q, want %q", path, rr.Code, rr.Body.String(), want)
+		}
+	}
+}
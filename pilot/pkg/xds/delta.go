@@ -280,7 +280,7 @@ func (conn *Connection) sendDelta(res *discovery.DeltaDiscoveryResponse) error {
 		if err == nil {
 			sz := 0
 			for _, rc := range res.Resources {
-				sz += len(rc.Resource.Value)
+				sz += len(rc.GetResource().GetValue())
 			}
 			conn.proxy.Lock()
 			if res.Nonce != "" {
@@ -340,6 +340,16 @@ func (s *DiscoveryServer) processDeltaRequest(req *discovery.DeltaDiscoveryReque
 
 	push := s.globalPushContext()
 
+	if req.TypeUrl == v3.VirtualHostType && con.proxy.OnDemand && shouldRespond {
+		// Newly subscribed virtual hosts may reference clusters the proxy does not have yet. Send
+		// those first so the routes do not point at unknown clusters.
+		if w := con.Watched(v3.ClusterType); w != nil {
+			if err := s.pushDeltaXds(con, push, versionInfo(), w, nil, &model.PushRequest{Full: true}); err != nil {
+				return err
+			}
+		}
+	}
+
 	return s.pushDeltaXds(con, push, versionInfo(), con.Watched(req.TypeUrl), req.ResourceNamesSubscribe, request)
 }
 
@@ -478,11 +488,18 @@ func (s *DiscoveryServer) pushDeltaXds(con *Connection, push *model.PushContext,
 		Nonce:             nonce(push.LedgerVersion),
 		Resources:         deltaResponse,
 	}
-	// We take the set of watched resources and anything not in the response is sent as RemovedResources
-	// This is similar to SotW, but done on the server side instead of the client.
-	cur := sets.NewSet(w.ResourceNames...)
-	cur.Delete(extractNames(originalResponse)...)
-	resp.RemovedResources = cur.SortedList()
+	if w.TypeUrl == v3.VirtualHostType {
+		// Envoy holds the requests for a host until its virtual host is resolved. Names that cannot
+		// be resolved are answered with a resource holding only the alias, rather than being removed,
+		// so Envoy stops waiting for them.
+		resp.Resources = append(resp.Resources, unresolvedVirtualHosts(currentVersion, w, subscribe, originalResponse)...)
+	} else {
+		// We take the set of watched resources and anything not in the response is sent as RemovedResources
+		// This is similar to SotW, but done on the server side instead of the client.
+		cur := sets.NewSet(w.ResourceNames...)
+		cur.Delete(extractNames(originalResponse)...)
+		resp.RemovedResources = cur.SortedList()
+	}
 	if len(resp.RemovedResources) > 0 {
 		log.Infof("ADS:%v REMOVE %v", v3.GetShortType(w.TypeUrl), resp.RemovedResources)
 	}
@@ -497,6 +514,7 @@ func (s *DiscoveryServer) pushDeltaXds(con *Connection, push *model.PushContext,
 		recordSendError(w.TypeUrl, con.ConID, err)
 		return err
 	}
+	con.configHistory.record(currentVersion, w.TypeUrl, originalResponse, req == nil || req.Full || isWildcardTypeURL(w.TypeUrl))
 
 	// Some types handle logs inside Generate, skip them here
 	// TODO because we filter out after the fact, SkipLogTypes report wrong info
@@ -523,6 +541,7 @@ func newDeltaConnection(peerAddr string, stream DeltaDiscoveryStream) *Connectio
 		Connect:       time.Now(),
 		deltaStream:   stream,
 		blockedPushes: map[string]*model.PushRequest{},
+		configHistory: newConfigHistory(features.ConfigHistorySize),
 	}
 }
 
@@ -549,6 +568,10 @@ func convertResponseToDelta(ver string, resources model.Resources) []*discovery.
 			aa := &route.RouteConfiguration{}
 			_ = r.UnmarshalTo(aa)
 			name = aa.Name
+		case v3.VirtualHostType:
+			aa := &route.VirtualHost{}
+			_ = r.UnmarshalTo(aa)
+			name = aa.Name
 		case v3.SecretType:
 			aa := &tls.Secret{}
 			_ = r.UnmarshalTo(aa)
@@ -568,6 +591,28 @@ func convertResponseToDelta(ver string, resources model.Resources) []*discovery.
 	return convert
 }
 
+// unresolvedVirtualHosts returns alias only resources for the requested VHDS names that are not in the response.
+// If subscribe is nil, all watched names are requested.
+func unresolvedVirtualHosts(ver string, w *model.WatchedResource, subscribe []string, resolved []*discovery.Resource) []*discovery.Resource {
+	requested := subscribe
+	if requested == nil {
+		requested = w.ResourceNames
+	}
+	found := sets.NewSet(extractNames(resolved)...)
+	out := []*discovery.Resource{}
+	for _, name := range requested {
+		if found.Contains(name) {
+			continue
+		}
+		out = append(out, &discovery.Resource{
+			Name:    name,
+			Version: ver,
+			Aliases: []string{name},
+		})
+	}
+	return out
+}
+
 // To satisfy methods that need DiscoveryRequest. Not suitable for real usage
 func deltaToSotwRequest(request *discovery.DeltaDiscoveryRequest) *discovery.DiscoveryRequest {
 	return &discovery.DiscoveryRequest{
@@ -15,6 +15,7 @@
 package xds
 
 import (
+	"fmt"
 	"strconv"
 	"sync"
 	"time"
@@ -351,6 +352,7 @@ func (s *DiscoveryServer) globalPushContext() *model.PushContext {
 func (s *DiscoveryServer) ConfigUpdate(req *model.PushRequest) {
 	inboundConfigUpdates.Increment()
 	s.InboundUpdates.Inc()
+	req.AddTrace(model.NewPushTraceEvent(req, "update received"))
 	s.pushChannel <- req
 }
 
@@ -395,6 +397,8 @@ func debounce(ch chan *model.PushRequest, stopCh <-chan struct{}, opts debounceO
 					pushCounter, debouncedEvents,
 					quietTime, eventDelay, req.Full)
 
+				req.AddTrace(model.NewPushTraceEvent(nil,
+					fmt.Sprintf("debounced %d events over %v", debouncedEvents, eventDelay)))
 				free = false
 				go push(req, debouncedEvents)
 				req = nil
@@ -523,6 +527,7 @@ func (s *DiscoveryServer) initGenerators(env *model.Environment, systemNameSpace
 	s.Generators[v3.ClusterType] = &CdsGenerator{Server: s}
 	s.Generators[v3.ListenerType] = &LdsGenerator{Server: s}
 	s.Generators[v3.RouteType] = &RdsGenerator{Server: s}
+	s.Generators[v3.VirtualHostType] = &VhdsGenerator{Server: s}
 	s.Generators[v3.EndpointType] = edsGen
 	s.Generators[v3.NameTableType] = &NdsGenerator{Server: s}
 	s.Generators[v3.ExtensionConfigurationType] = &EcdsGenerator{Server: s}
@@ -15,14 +15,31 @@
 package xds
 
 import (
+	"fmt"
+	"strings"
+
+	udpa "github.com/cncf/udpa/go/udpa/type/v1"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	wasm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/wasm/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/conversion"
+	"github.com/golang/protobuf/ptypes"
+
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pilot/pkg/secrets"
 	"istio.io/istio/pkg/config/schema/gvk"
+	pkgwasm "istio.io/istio/pkg/wasm"
+)
+
+const (
+	typedStructType    = "type.googleapis.com/udpa.type.v1.TypedStruct"
+	wasmHTTPFilterType = "type.googleapis.com/envoy.extensions.filters.http.wasm.v3.Wasm"
 )
 
 // EcdsGenerator generates ECDS configuration.
 type EcdsGenerator struct {
-	Server *DiscoveryServer
+	Server           *DiscoveryServer
+	secretController secrets.MulticlusterController
 }
 
 var _ model.XdsResourceGenerator = &EcdsGenerator{}
@@ -60,7 +77,77 @@ func (e *EcdsGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w
 
 	resources := make(model.Resources, 0, len(ec))
 	for _, c := range ec {
-		resources = append(resources, util.MessageToAny(c))
+		resources = append(resources, util.MessageToAny(e.resolvePullSecret(proxy, c)))
 	}
 	return resources, nil
 }
+
+// SetCredController sets the secret controller used to resolve Wasm image pull secrets.
+func (e *EcdsGenerator) SetCredController(sc secrets.MulticlusterController) {
+	e.secretController = sc
+}
+
+// resolvePullSecret replaces the name of the image pull secret referenced by a Wasm extension config,
+// through the ISTIO_META_WASM_IMAGE_PULL_SECRET VM environment variable, with the docker config of the secret.
+// The agent uses it to authenticate with the registry when fetching oci:// modules.
+// As with SDS, only secrets in the namespace of the proxy may be referenced.
+func (e *EcdsGenerator) resolvePullSecret(proxy *model.Proxy, ec *core.TypedExtensionConfig) *core.TypedExtensionConfig {
+	if ec.GetTypedConfig().GetTypeUrl() != typedStructType {
+		return ec
+	}
+	wasmStruct := &udpa.TypedStruct{}
+	// nolint: staticcheck
+	if err := ptypes.UnmarshalAny(ec.GetTypedConfig(), wasmStruct); err != nil || wasmStruct.TypeUrl != wasmHTTPFilterType {
+		return ec
+	}
+	wasmHTTPFilterConfig := &wasm.Wasm{}
+	if err := conversion.StructToMessage(wasmStruct.Value, wasmHTTPFilterConfig); err != nil {
+		return ec
+	}
+	envs := wasmHTTPFilterConfig.GetConfig().GetVmConfig().GetEnvironmentVariables()
+	secretName := envs.GetKeyValues()[pkgwasm.WasmSecretEnv]
+	if secretName == "" {
+		return ec
+	}
+
+	// The secret name is never forwarded as is; if it cannot be resolved, the module is fetched without credentials.
+	// The secret is only resolved for modules fetched by the agent from a registry, since Envoy loads the other
+	// modules itself, and would expose the credential to the module.
+	delete(envs.KeyValues, pkgwasm.WasmSecretEnv)
+	uri := wasmHTTPFilterConfig.GetConfig().GetVmConfig().GetCode().GetRemote().GetHttpUri().GetUri()
+	if strings.HasPrefix(uri, pkgwasm.OCIScheme) {
+		if cred, err := e.pullSecret(proxy, secretName); err != nil {
+			log.Warnf("failed to resolve image pull secret %v for extension config %v of proxy %v: %v", secretName, ec.Name, proxy.ID, err)
+		} else {
+			envs.KeyValues[pkgwasm.WasmSecretEnv] = string(cred)
+		}
+	}
+
+	value, err := conversion.MessageToStruct(wasmHTTPFilterConfig)
+	if err != nil {
+		log.Warnf("failed to convert extension config %v: %v", ec.Name, err)
+		return ec
+	}
+	wasmStruct.Value = value
+	return &core.TypedExtensionConfig{
+		Name:        ec.Name,
+		TypedConfig: util.MessageToAny(wasmStruct),
+	}
+}
+
+func (e *EcdsGenerator) pullSecret(proxy *model.Proxy, name string) ([]byte, error) {
+	if e.secretController == nil {
+		return nil, fmt.Errorf("no secret controller is configured")
+	}
+	if proxy.VerifiedIdentity == nil {
+		return nil, fmt.Errorf("proxy is not authenticated")
+	}
+	sc, err := e.secretController.ForCluster(proxy.Metadata.ClusterID)
+	if err != nil {
+		return nil, err
+	}
+	if err := sc.Authorize(proxy.VerifiedIdentity.ServiceAccount, proxy.VerifiedIdentity.Namespace); err != nil {
+		return nil, err
+	}
+	return sc.GetDockerCredential(name, proxy.ConfigNamespace)
+}
@@ -16,11 +16,24 @@ package xds_test
 import (
 	"testing"
 
+	udpa "github.com/cncf/udpa/go/udpa/type/v1"
 	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	wasm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/wasm/v3"
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/conversion"
+	"github.com/golang/protobuf/ptypes"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
 
+	"istio.io/istio/pilot/pkg/model"
+	kubesecrets "istio.io/istio/pilot/pkg/secrets/kube"
 	"istio.io/istio/pilot/pkg/xds"
 	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/spiffe"
+	pkgwasm "istio.io/istio/pkg/wasm"
 )
 
 func TestECDS(t *testing.T) {
@@ -47,3 +60,87 @@ func TestECDS(t *testing.T) {
 		t.Errorf("extension config name got %v want %v", ec.Name, wantExtensionConfigName)
 	}
 }
+
+func TestECDSPullSecret(t *testing.T) {
+	pullSecret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pull-secret",
+			Namespace: "default",
+		},
+		Type: v1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{
+			v1.DockerConfigJsonKey: []byte(`{"auths":{"test-registry":{"auth":"dXNlcjpwYXNz"}}}`),
+		},
+	}
+	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{
+		ConfigString:      mustReadFile(t, "./testdata/ecds-pull-secret.yaml"),
+		KubernetesObjects: []runtime.Object{pullSecret},
+		KubeClientModifier: func(c kube.Client) {
+			kubesecrets.DisableAuthorizationForTest(c.Kube().(*fake.Clientset))
+		},
+	})
+	gen := s.Discovery.Generators[v3.ExtensionConfigurationType]
+
+	cases := []struct {
+		name     string
+		proxy    *model.Proxy
+		resource string
+		want     string
+	}{
+		{
+			name:  "same namespace",
+			proxy: &model.Proxy{VerifiedIdentity: &spiffe.Identity{Namespace: "default"}, ConfigNamespace: "default"},
+			want:  string(pullSecret.Data[v1.DockerConfigJsonKey]),
+		},
+		{
+			// Envoy fetches the module itself, the credential would be readable by the module.
+			name:     "not oci",
+			proxy:    &model.Proxy{VerifiedIdentity: &spiffe.Identity{Namespace: "default"}, ConfigNamespace: "default"},
+			resource: "http-extension-config",
+		},
+		{
+			name:  "other namespace",
+			proxy: &model.Proxy{VerifiedIdentity: &spiffe.Identity{Namespace: "other"}, ConfigNamespace: "other"},
+		},
+		{
+			name:  "unauthenticated",
+			proxy: &model.Proxy{ConfigNamespace: "default"},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			resource := tt.resource
+			if resource == "" {
+				resource = "extension-config"
+			}
+			res, err := gen.Generate(s.SetupProxy(tt.proxy), s.PushContext(),
+				&model.WatchedResource{ResourceNames: []string{resource}}, &model.PushRequest{Full: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(res) != 1 {
+				t.Fatalf("got %d extension configs, want 1", len(res))
+			}
+			ec := &corev3.TypedExtensionConfig{}
+			if err := res[0].UnmarshalTo(ec); err != nil {
+				t.Fatal(err)
+			}
+			wasmStruct := &udpa.TypedStruct{}
+			// nolint: staticcheck
+			if err := ptypes.UnmarshalAny(ec.TypedConfig, wasmStruct); err != nil {
+				t.Fatal(err)
+			}
+			wasmHTTPFilterConfig := &wasm.Wasm{}
+			if err := conversion.StructToMessage(wasmStruct.Value, wasmHTTPFilterConfig); err != nil {
+				t.Fatal(err)
+			}
+			got, f := wasmHTTPFilterConfig.GetConfig().GetVmConfig().GetEnvironmentVariables().GetKeyValues()[pkgwasm.WasmSecretEnv]
+			if f && tt.want == "" {
+				t.Errorf("got pull secret %q, want none", got)
+			}
+			if got != tt.want {
+				t.Errorf("got pull secret %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -122,6 +122,10 @@ func (s *DiscoveryServer) pushXds(con *Connection, push *model.PushContext,
 		recordSendError(w.TypeUrl, con.ConID, err)
 		return err
 	}
+	if con.configHistory != nil {
+		con.configHistory.record(currentVersion, w.TypeUrl, convertResponseToDelta(currentVersion, res),
+			req == nil || req.Full || isWildcardTypeURL(w.TypeUrl))
+	}
 
 	// Some types handle logs inside Generate, skip them here
 	if _, f := SkipLogTypes[w.TypeUrl]; !f {
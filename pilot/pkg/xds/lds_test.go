@@ -16,6 +16,9 @@ package xds_test
 import (
 	"io/ioutil"
 	"os"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
 
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
@@ -202,6 +205,54 @@ func TestLDS(t *testing.T) {
 	})
 }
 
+// TestLDSAutoAllocatedAddresses checks that TCP service entries without addresses on the same port
+// get their own listener on the auto allocated addresses, which are also served by NDS.
+func TestLDSAutoAllocatedAddresses(t *testing.T) {
+	cases := []struct {
+		name     string
+		meta     model.NodeMetadata
+		expected []string
+	}{
+		{
+			name: "auto allocate",
+			meta: model.NodeMetadata{
+				DNSCapture:      true,
+				DNSAutoAllocate: true,
+			},
+			expected: []string{"240.240.107.84_3306", "240.240.43.85_3306"},
+		},
+		{
+			name:     "just capture",
+			meta:     model.NodeMetadata{DNSCapture: true},
+			expected: []string{"0.0.0.0_3306"},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{
+				ConfigString: mustReadFile(t, "./testdata/lds-se-auto-allocate.yaml"),
+			})
+			ads := s.ConnectADS().WithType(v3.ListenerType)
+			res := ads.RequestResponseAck(&discovery.DiscoveryRequest{
+				Node: &core.Node{
+					Id:       ads.ID,
+					Metadata: tt.meta.ToStruct(),
+				},
+			})
+			got := []string{}
+			for _, l := range xdstest.UnmarshalListener(t, res.Resources) {
+				if strings.HasSuffix(l.Name, "_3306") {
+					got = append(got, l.Name)
+				}
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Fatalf("expected listeners %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
 // TestLDS using sidecar scoped on workload without Service
 func TestLDSWithSidecarForWorkloadWithoutService(t *testing.T) {
 	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{
@@ -138,6 +138,21 @@ var (
 		[]float64{.1, .5, 1, 3, 5, 10, 20, 30},
 	)
 
+	priorityTag = monitoring.MustCreateLabel("priority")
+
+	pushQueueDepth = monitoring.NewGauge(
+		"pilot_push_queue_depth",
+		"Number of proxies waiting in the push queue, labeled by push priority.",
+		monitoring.WithLabels(priorityTag),
+	)
+
+	pushQueueTime = monitoring.NewDistribution(
+		"pilot_push_queue_time_seconds",
+		"Time in seconds a proxy waits in the push queue, labeled by push priority.",
+		[]float64{.01, .1, .5, 1, 3, 5, 10, 20, 30},
+		monitoring.WithLabels(priorityTag),
+	)
+
 	pushTriggers = monitoring.NewSum(
 		"pilot_push_triggers",
 		"Total number of times a push was triggered, labeled by reason for the push.",
@@ -191,6 +206,14 @@ func recordPushTriggers(reasons ...model.TriggerReason) {
 	}
 }
 
+func recordPushQueueDepth(prio pushPriority, depth int) {
+	pushQueueDepth.With(priorityTag.Value(prio.String())).Record(float64(depth))
+}
+
+func recordPushQueueTime(prio pushPriority, d time.Duration) {
+	pushQueueTime.With(priorityTag.Value(prio.String())).Record(d.Seconds())
+}
+
 func isUnexpectedError(err error) bool {
 	s, ok := status.FromError(err)
 	// Unavailable or canceled code will be sent when a connection is closing down. This is very normal,
@@ -254,6 +277,8 @@ func init() {
 		pushTime,
 		proxiesConvergeDelay,
 		proxiesQueueTime,
+		pushQueueDepth,
+		pushQueueTime,
 		pushContextErrors,
 		totalXDSInternalErrors,
 		inboundUpdates,
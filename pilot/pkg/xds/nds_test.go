@@ -29,6 +29,7 @@ import (
 )
 
 func TestNDS(t *testing.T) {
+	httpPorts := []*nds.NameTable_Port{{Name: "http", Port: 80, Protocol: "HTTP"}}
 	cases := []struct {
 		name     string
 		meta     model.NodeMetadata
@@ -43,16 +44,19 @@ func TestNDS(t *testing.T) {
 			expected: &nds.NameTable{
 				Table: map[string]*nds.NameTable_NameInfo{
 					"random-1.host.example": {
-						Ips:      []string{"240.240.0.1"},
+						Ips:      []string{"240.240.116.137"},
 						Registry: "External",
+						Ports:    httpPorts,
 					},
 					"random-2.host.example": {
 						Ips:      []string{"9.9.9.9"},
 						Registry: "External",
+						Ports:    httpPorts,
 					},
 					"random-3.host.example": {
-						Ips:      []string{"240.240.0.2"},
+						Ips:      []string{"240.240.81.181"},
 						Registry: "External",
+						Ports:    httpPorts,
 					},
 				},
 			},
@@ -67,6 +71,7 @@ func TestNDS(t *testing.T) {
 					"random-2.host.example": {
 						Ips:      []string{"9.9.9.9"},
 						Registry: "External",
+						Ports:    httpPorts,
 					},
 				},
 			},
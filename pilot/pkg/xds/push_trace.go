@@ -0,0 +1,101 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"istio.io/istio/pilot/pkg/model"
+)
+
+// pushTraceSize is the number of pushes recorded for each connection.
+const pushTraceSize = 20
+
+// PushTraceRecord describes a push processed for a connection, and the events that caused it.
+type PushTraceRecord struct {
+	Time time.Time `json:"time"`
+	Full bool      `json:"full"`
+	// Pushed is false if the push was skipped because the proxy did not depend on the changes.
+	Pushed  bool                   `json:"pushed"`
+	Reason  []model.TriggerReason  `json:"reason,omitempty"`
+	Configs []string               `json:"configs,omitempty"`
+	Trace   []model.PushTraceEvent `json:"trace,omitempty"`
+}
+
+// pushTrace keeps the most recent pushes of a connection.
+type pushTrace struct {
+	mu      sync.Mutex
+	records []PushTraceRecord
+}
+
+func (t *pushTrace) record(req *model.PushRequest, pushed bool) {
+	ev := req.TraceEvent()
+	r := PushTraceRecord{
+		Time:    time.Now(),
+		Full:    req.Full,
+		Pushed:  pushed,
+		Reason:  ev.Reason,
+		Configs: ev.Configs,
+		Trace:   req.Trace,
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.records = append(t.records, r)
+	if len(t.records) > pushTraceSize {
+		t.records = t.records[len(t.records)-pushTraceSize:]
+	}
+}
+
+func (t *pushTrace) list() []PushTraceRecord {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]PushTraceRecord{}, t.records...)
+}
+
+// PushTrace returns the most recent pushes processed for the connection, oldest first.
+func (con *Connection) PushTrace() []PushTraceRecord {
+	return con.pushTrace.list()
+}
+
+// PushTraceHandler returns the most recent pushes of a proxy, along with the events that caused them.
+// It is mapped to /debug/push_trace
+func (s *DiscoveryServer) PushTraceHandler(w http.ResponseWriter, req *http.Request) {
+	proxyID := req.URL.Query().Get("proxyID")
+	if proxyID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("You must provide a proxyID in the query string"))
+		return
+	}
+	con := s.getProxyConnection(proxyID)
+	if con == nil {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte("Proxy not connected to this Pilot instance"))
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	by, err := json.MarshalIndent(map[string]interface{}{
+		"connectionID": con.ConID,
+		"pushes":       con.PushTrace(),
+	}, "", "  ")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+	_, _ = w.Write(by)
+}
@@ -0,0 +1,121 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"istio.io/istio/pilot/pkg/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+func getPushTrace(t *testing.T, s *DiscoveryServer, proxyID string, wantCode int) []PushTraceRecord {
+	t.Helper()
+	req, err := http.NewRequest("GET", "/debug/push_trace?proxyID="+proxyID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(s.PushTraceHandler).ServeHTTP(rr, req)
+	if rr.Code != wantCode {
+		t.Fatalf("wanted response code %v, got %v", wantCode, rr.Code)
+	}
+	if wantCode != http.StatusOK {
+		return nil
+	}
+	got := struct {
+		Pushes []PushTraceRecord `json:"pushes"`
+	}{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	return got.Pushes
+}
+
+func TestPushTrace(t *testing.T) {
+	s := NewFakeDiscoveryServer(t, FakeOptions{})
+	ads := s.ConnectADS().WithType(v3.ClusterType)
+	ads.RequestResponseAck(nil)
+
+	getPushTrace(t, s.Discovery, "", http.StatusBadRequest)
+	getPushTrace(t, s.Discovery, "not-found", http.StatusNotFound)
+	if pushes := getPushTrace(t, s.Discovery, "test.default", http.StatusOK); len(pushes) != 0 {
+		t.Fatalf("expected no pushes, got %v", pushes)
+	}
+
+	// A change the proxy does not depend on is recorded as skipped
+	s.Discovery.ConfigUpdate(&model.PushRequest{
+		Full:           true,
+		ConfigsUpdated: map[model.ConfigKey]struct{}{{Kind: gvk.ServiceEntry, Name: "unrelated.example.com", Namespace: "other"}: {}},
+		Reason:         []model.TriggerReason{model.ServiceUpdate},
+	})
+	retry.UntilSuccessOrFail(t, func() error {
+		pushes := getPushTrace(t, s.Discovery, "test.default", http.StatusOK)
+		if len(pushes) != 1 {
+			return fmt.Errorf("expected 1 push, got %v", pushes)
+		}
+		p := pushes[0]
+		if p.Pushed || !p.Full {
+			return fmt.Errorf("expected skipped full push, got %+v", p)
+		}
+		if len(p.Configs) != 1 || p.Configs[0] != "ServiceEntry/other/unrelated.example.com" {
+			return fmt.Errorf("unexpected configs %v", p.Configs)
+		}
+		if len(p.Trace) < 3 {
+			return fmt.Errorf("expected update, debounce and push events, got %+v", p.Trace)
+		}
+		if p.Trace[0].Message != "update received" || p.Trace[0].Configs[0] != "ServiceEntry/other/unrelated.example.com" {
+			return fmt.Errorf("unexpected first event %+v", p.Trace[0])
+		}
+		return nil
+	})
+
+	s.Discovery.ConfigUpdate(&model.PushRequest{
+		Full:           true,
+		ConfigsUpdated: map[model.ConfigKey]struct{}{{Kind: gvk.EnvoyFilter, Name: "filter", Namespace: "default"}: {}},
+		Reason:         []model.TriggerReason{model.ConfigUpdate},
+	})
+	retry.UntilSuccessOrFail(t, func() error {
+		pushes := getPushTrace(t, s.Discovery, "test.default", http.StatusOK)
+		if len(pushes) != 2 {
+			return fmt.Errorf("expected 2 pushes, got %v", pushes)
+		}
+		p := pushes[1]
+		if !p.Pushed || len(p.Reason) != 1 || p.Reason[0] != model.ConfigUpdate {
+			return fmt.Errorf("unexpected push %+v", p)
+		}
+		return nil
+	})
+}
+
+func TestPushTraceBounded(t *testing.T) {
+	trace := &pushTrace{}
+	for i := 0; i < pushTraceSize+5; i++ {
+		trace.record(&model.PushRequest{Reason: []model.TriggerReason{model.TriggerReason(fmt.Sprint(i))}}, true)
+	}
+	records := trace.list()
+	if len(records) != pushTraceSize {
+		t.Fatalf("expected %d records, got %d", pushTraceSize, len(records))
+	}
+	if records[0].Reason[0] != "5" {
+		t.Fatalf("expected oldest records to be dropped, got %v", records[0].Reason)
+	}
+}
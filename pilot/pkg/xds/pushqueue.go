@@ -16,10 +16,56 @@ package xds
 
 import (
 	"sync"
+	"time"
 
 	"istio.io/istio/pilot/pkg/model"
 )
 
+// pushPriority determines the order in which queued pushes are sent.
+type pushPriority int
+
+const (
+	// pushPriorityLow is used for full config pushes.
+	pushPriorityLow pushPriority = iota
+	// pushPriorityHigh is used for incremental EDS pushes and pushes to newly connected proxies,
+	// which are cheap to generate and should not wait behind a full push storm.
+	pushPriorityHigh
+
+	numPushPriorities = 2
+)
+
+func (p pushPriority) String() string {
+	if p == pushPriorityHigh {
+		return "high"
+	}
+	return "low"
+}
+
+var (
+	// maxHighPriorityStreak is the number of consecutive high priority pushes after which a
+	// waiting low priority push is sent, so full pushes are never starved.
+	maxHighPriorityStreak = 10
+
+	// newConnectionPriorityWindow is how long after connecting a proxy has its pushes prioritized.
+	newConnectionPriorityWindow = 10 * time.Second
+)
+
+// priorityFor returns the priority of a push to the connection.
+func priorityFor(con *Connection, request *model.PushRequest) pushPriority {
+	if !request.Full {
+		return pushPriorityHigh
+	}
+	for _, r := range request.Reason {
+		if r == model.ProxyUpdate {
+			return pushPriorityHigh
+		}
+	}
+	if !con.Connect.IsZero() && time.Since(con.Connect) < newConnectionPriorityWindow {
+		return pushPriorityHigh
+	}
+	return pushPriorityLow
+}
+
 type PushQueue struct {
 	cond *sync.Cond
 
@@ -27,8 +73,15 @@ type PushQueue struct {
 	// the PushRequest will be merged.
 	pending map[*Connection]*model.PushRequest
 
-	// queue maintains ordering of the queue
-	queue []*Connection
+	// queues maintains ordering of the queue, per priority
+	queues [numPushPriorities][]*Connection
+
+	// priority and enqueued store the queue a pending connection is in, and when it was added
+	priority map[*Connection]pushPriority
+	enqueued map[*Connection]time.Time
+
+	// highStreak is the number of high priority pushes dequeued since the last low priority one
+	highStreak int
 
 	// processing stores all connections that have been Dequeue(), but not MarkDone().
 	// The value stored will be initially be nil, but may be populated if the connection is Enqueue().
@@ -41,6 +94,8 @@ type PushQueue struct {
 func NewPushQueue() *PushQueue {
 	return &PushQueue{
 		pending:    make(map[*Connection]*model.PushRequest),
+		priority:   make(map[*Connection]pushPriority),
+		enqueued:   make(map[*Connection]time.Time),
 		processing: make(map[*Connection]*model.PushRequest),
 		cond:       sync.NewCond(&sync.Mutex{}),
 	}
@@ -63,35 +118,92 @@ func (p *PushQueue) Enqueue(con *Connection, pushRequest *model.PushRequest) {
 	}
 
 	if request, f := p.pending[con]; f {
-		p.pending[con] = request.Merge(pushRequest)
+		merged := request.Merge(pushRequest)
+		p.pending[con] = merged
+		// A merged push keeps its place in line, unless it now deserves a higher priority
+		if prio := priorityFor(con, merged); prio > p.priority[con] {
+			p.remove(con)
+			p.push(con, prio)
+		}
 		return
 	}
 
 	p.pending[con] = pushRequest
-	p.queue = append(p.queue, con)
+	p.enqueued[con] = time.Now()
+	p.push(con, priorityFor(con, pushRequest))
 	// Signal waiters on Dequeue that a new item is available
 	p.cond.Signal()
 }
 
+// push adds the connection to the end of the queue for the priority.
+func (p *PushQueue) push(con *Connection, prio pushPriority) {
+	p.priority[con] = prio
+	p.queues[prio] = append(p.queues[prio], con)
+	recordPushQueueDepth(prio, len(p.queues[prio]))
+}
+
+// remove removes the connection from the queue it is in.
+func (p *PushQueue) remove(con *Connection) {
+	prio := p.priority[con]
+	q := p.queues[prio]
+	for i, c := range q {
+		if c == con {
+			p.queues[prio] = append(q[:i:i], q[i+1:]...)
+			break
+		}
+	}
+	delete(p.priority, con)
+	recordPushQueueDepth(prio, len(p.queues[prio]))
+}
+
+// next selects the priority to dequeue from. High priority pushes go first, but after
+// maxHighPriorityStreak of them a waiting low priority push is let through.
+func (p *PushQueue) next() pushPriority {
+	high, low := len(p.queues[pushPriorityHigh]), len(p.queues[pushPriorityLow])
+	if high > 0 && (low == 0 || p.highStreak < maxHighPriorityStreak) {
+		if low == 0 {
+			p.highStreak = 0
+		} else {
+			p.highStreak++
+		}
+		return pushPriorityHigh
+	}
+	p.highStreak = 0
+	return pushPriorityLow
+}
+
+func (p *PushQueue) len() int {
+	total := 0
+	for _, q := range p.queues {
+		total += len(q)
+	}
+	return total
+}
+
 // Remove a proxy from the queue. If there are no proxies ready to be removed, this will block
 func (p *PushQueue) Dequeue() (con *Connection, request *model.PushRequest, shutdown bool) {
 	p.cond.L.Lock()
 	defer p.cond.L.Unlock()
 
 	// Block until there is one to remove. Enqueue will signal when one is added.
-	for len(p.queue) == 0 && !p.shuttingDown {
+	for p.len() == 0 && !p.shuttingDown {
 		p.cond.Wait()
 	}
 
-	if len(p.queue) == 0 {
+	if p.len() == 0 {
 		// We must be shutting down.
 		return nil, nil, true
 	}
 
-	con, p.queue = p.queue[0], p.queue[1:]
+	prio := p.next()
+	con, p.queues[prio] = p.queues[prio][0], p.queues[prio][1:]
+	recordPushQueueDepth(prio, len(p.queues[prio]))
+	recordPushQueueTime(prio, time.Since(p.enqueued[con]))
 
 	request = p.pending[con]
 	delete(p.pending, con)
+	delete(p.priority, con)
+	delete(p.enqueued, con)
 
 	// Mark the connection as in progress
 	p.processing[con] = nil
@@ -109,7 +221,8 @@ func (p *PushQueue) MarkDone(con *Connection) {
 	// This means we need to add it back to the queue.
 	if request != nil {
 		p.pending[con] = request
-		p.queue = append(p.queue, con)
+		p.enqueued[con] = time.Now()
+		p.push(con, priorityFor(con, request))
 		p.cond.Signal()
 	}
 }
@@ -118,7 +231,7 @@ func (p *PushQueue) MarkDone(con *Connection) {
 func (p *PushQueue) Pending() int {
 	p.cond.L.Lock()
 	defer p.cond.L.Unlock()
-	return len(p.queue)
+	return p.len()
 }
 
 // ShutDown will cause queue to ignore all new items added to it. As soon as the
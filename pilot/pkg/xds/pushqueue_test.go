@@ -294,3 +294,87 @@ func TestProxyQueue(t *testing.T) {
 		}
 	})
 }
+
+func TestProxyQueuePriority(t *testing.T) {
+	original := maxHighPriorityStreak
+	maxHighPriorityStreak = 2
+	t.Cleanup(func() {
+		maxHighPriorityStreak = original
+	})
+	proxies := make([]*Connection, 0, 10)
+	for p := 0; p < 10; p++ {
+		proxies = append(proxies, &Connection{ConID: fmt.Sprintf("proxy-%d", p)})
+	}
+	full := func() *model.PushRequest {
+		return &model.PushRequest{Full: true}
+	}
+
+	t.Run("incremental overtakes full", func(t *testing.T) {
+		p := NewPushQueue()
+		defer p.ShutDown()
+		p.Enqueue(proxies[0], full())
+		p.Enqueue(proxies[1], full())
+		p.Enqueue(proxies[2], &model.PushRequest{})
+
+		ExpectDequeue(t, p, proxies[2])
+		ExpectDequeue(t, p, proxies[0])
+		ExpectDequeue(t, p, proxies[1])
+	})
+
+	t.Run("new connection overtakes full", func(t *testing.T) {
+		p := NewPushQueue()
+		defer p.ShutDown()
+		newCon := &Connection{ConID: "new", Connect: time.Now()}
+		oldCon := &Connection{ConID: "old", Connect: time.Now().Add(-time.Hour)}
+		p.Enqueue(oldCon, full())
+		p.Enqueue(newCon, full())
+
+		ExpectDequeue(t, p, newCon)
+		ExpectDequeue(t, p, oldCon)
+	})
+
+	t.Run("proxy update overtakes full", func(t *testing.T) {
+		p := NewPushQueue()
+		defer p.ShutDown()
+		p.Enqueue(proxies[0], full())
+		p.Enqueue(proxies[1], full())
+		p.Enqueue(proxies[1], &model.PushRequest{Full: true, Reason: []model.TriggerReason{model.ProxyUpdate}})
+
+		ExpectDequeue(t, p, proxies[1])
+		ExpectDequeue(t, p, proxies[0])
+		if p.Pending() != 0 {
+			t.Fatalf("expected empty queue, got %d", p.Pending())
+		}
+	})
+
+	t.Run("full pushes are not starved", func(t *testing.T) {
+		p := NewPushQueue()
+		defer p.ShutDown()
+		p.Enqueue(proxies[0], full())
+		for i := 1; i <= 5; i++ {
+			p.Enqueue(proxies[i], &model.PushRequest{})
+		}
+
+		ExpectDequeue(t, p, proxies[1])
+		ExpectDequeue(t, p, proxies[2])
+		ExpectDequeue(t, p, proxies[0])
+		ExpectDequeue(t, p, proxies[3])
+		ExpectDequeue(t, p, proxies[4])
+		ExpectDequeue(t, p, proxies[5])
+	})
+
+	t.Run("merged full push keeps priority", func(t *testing.T) {
+		p := NewPushQueue()
+		defer p.ShutDown()
+		p.Enqueue(proxies[0], &model.PushRequest{})
+		p.Enqueue(proxies[1], &model.PushRequest{})
+		// Merging in a full push does not move the proxy out of the fast lane
+		p.Enqueue(proxies[0], full())
+
+		con, req, _ := p.Dequeue()
+		if con != proxies[0] || !req.Full {
+			t.Fatalf("expected merged full push for %v, got %v %+v", proxies[0], con, req)
+		}
+		ExpectDequeue(t, p, proxies[1])
+	})
+}
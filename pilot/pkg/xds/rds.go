@@ -15,10 +15,16 @@
 package xds
 
 import (
+	"strings"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/util"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/proto"
 )
 
 type RdsGenerator struct {
@@ -61,10 +67,57 @@ func (c RdsGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w *m
 	if !rdsNeedsPush(req) {
 		return nil, nil
 	}
-	rawRoutes := c.Server.ConfigGenerator.BuildHTTPRoutes(proxy, push, w.ResourceNames)
 	resources := model.Resources{}
+	routeNames := w.ResourceNames
+	if proxy.OnDemand {
+		if updated := updatedServices(req); updated != nil && !proxyServiceUpdated(proxy, updated) {
+			// The route skeletons do not depend on services, and the inbound routes only on the
+			// services of the proxy. Other services are pushed through VHDS.
+			return nil, nil
+		}
+		// Outbound virtual hosts are delivered lazily through VHDS; only send the route skeleton.
+		routeNames = nil
+		for _, name := range w.ResourceNames {
+			if strings.HasPrefix(name, "inbound|") {
+				routeNames = append(routeNames, name)
+				continue
+			}
+			resources = append(resources, util.MessageToAny(onDemandRouteConfiguration(name)))
+		}
+		if len(routeNames) == 0 {
+			return resources, nil
+		}
+	}
+	rawRoutes := c.Server.ConfigGenerator.BuildHTTPRoutes(proxy, push, routeNames)
 	for _, c := range rawRoutes {
 		resources = append(resources, util.MessageToAny(c))
 	}
 	return resources, nil
 }
+
+// proxyServiceUpdated checks whether any of the services of the proxy is updated.
+func proxyServiceUpdated(proxy *model.Proxy, updated map[string]struct{}) bool {
+	for _, si := range proxy.ServiceInstances {
+		if _, f := updated[string(si.Service.Hostname)]; f {
+			return true
+		}
+	}
+	return false
+}
+
+// onDemandRouteConfiguration builds an empty route configuration that instructs Envoy
+// to fetch its virtual hosts from istiod through VHDS.
+func onDemandRouteConfiguration(name string) *route.RouteConfiguration {
+	return &route.RouteConfiguration{
+		Name:             name,
+		ValidateClusters: proto.BoolFalse,
+		Vhds: &route.Vhds{
+			ConfigSource: &core.ConfigSource{
+				ConfigSourceSpecifier: &core.ConfigSource_Ads{
+					Ads: &core.AggregatedConfigSource{},
+				},
+				ResourceApiVersion: core.ApiVersion_V3,
+			},
+		},
+	}
+}
@@ -31,6 +31,8 @@ const (
 	SecretType                 = resource.SecretType
 	ExtensionConfigurationType = resource.ExtensionConfigType
 
+	VirtualHostType = envoyTypePrefix + "config.route.v3.VirtualHost"
+
 	NameTableType   = apiTypePrefix + "istio.networking.nds.v1.NameTable"
 	HealthInfoType  = apiTypePrefix + "istio.v1.HealthInformation"
 	ProxyConfigType = apiTypePrefix + "istio.mesh.v1alpha1.ProxyConfig"
@@ -48,6 +50,8 @@ func GetShortType(typeURL string) string {
 		return "LDS"
 	case RouteType:
 		return "RDS"
+	case VirtualHostType:
+		return "VHDS"
 	case EndpointType:
 		return "EDS"
 	case SecretType:
@@ -72,6 +76,8 @@ func GetMetricType(typeURL string) string {
 		return "lds"
 	case RouteType:
 		return "rds"
+	case VirtualHostType:
+		return "vhds"
 	case EndpointType:
 		return "eds"
 	case SecretType:
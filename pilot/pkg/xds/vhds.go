@@ -0,0 +1,316 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"net"
+	"strings"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"github.com/golang/protobuf/proto"
+
+	networking "istio.io/api/networking/v1alpha3"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/core"
+	"istio.io/istio/pilot/pkg/networking/util"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/schema/gvk"
+)
+
+// VhdsGenerator serves virtual hosts on demand to sidecars that opted into on-demand xDS.
+// Envoy subscribes to resources named "<route config name>/<host>" the first time it sees
+// a request for a host that is not yet known in a route configuration delivered with VHDS enabled.
+type VhdsGenerator struct {
+	Server *DiscoveryServer
+}
+
+var _ model.XdsResourceGenerator = &VhdsGenerator{}
+
+func (v VhdsGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w *model.WatchedResource, req *model.PushRequest) (model.Resources, error) {
+	// VHDS depends on the same configs as RDS.
+	if !rdsNeedsPush(req) {
+		return nil, nil
+	}
+	if len(w.ResourceNames) == 0 {
+		return nil, nil
+	}
+	vhosts := onDemandVirtualHosts(v.Server.ConfigGenerator, proxy, push, w.ResourceNames)
+	if updated := updatedServices(req); updated != nil && !virtualHostsReference(w.ResourceNames, vhosts, updated) {
+		// Only services were updated, and none of them is served by the subscribed virtual hosts.
+		return nil, nil
+	}
+	resources := model.Resources{}
+	for _, name := range w.ResourceNames {
+		if vh, f := vhosts[name]; f {
+			resources = append(resources, util.MessageToAny(vh))
+		}
+	}
+	return resources, nil
+}
+
+// onDemandVirtualHosts resolves VHDS resource names into virtual hosts, keyed by resource name.
+// Names that do not match any virtual host of the named route are omitted; pushDeltaXds answers
+// them with an alias only resource.
+func onDemandVirtualHosts(cg core.ConfigGenerator, proxy *model.Proxy, push *model.PushContext, names []string) map[string]*route.VirtualHost {
+	hostsByRoute := map[string][]string{}
+	routeNames := []string{}
+	for _, name := range names {
+		routeName, host, ok := parseVhdsResourceName(name)
+		if !ok {
+			continue
+		}
+		if _, f := hostsByRoute[routeName]; !f {
+			routeNames = append(routeNames, routeName)
+		}
+		hostsByRoute[routeName] = append(hostsByRoute[routeName], host)
+	}
+	out := map[string]*route.VirtualHost{}
+	if len(routeNames) == 0 {
+		return out
+	}
+	for _, rc := range cg.BuildHTTPRoutes(proxy, push, routeNames) {
+		for _, host := range hostsByRoute[rc.Name] {
+			vh := matchVirtualHost(rc.VirtualHosts, host)
+			if vh == nil {
+				continue
+			}
+			name := rc.Name + "/" + host
+			vh = proto.Clone(vh).(*route.VirtualHost)
+			vh.Name = name
+			vh.Domains = []string{host}
+			out[name] = vh
+		}
+	}
+	return out
+}
+
+// parseVhdsResourceName splits a VHDS resource name into the route config name and host.
+func parseVhdsResourceName(name string) (string, string, bool) {
+	idx := strings.Index(name, "/")
+	if idx <= 0 || idx == len(name)-1 {
+		return "", "", false
+	}
+	return name[:idx], name[idx+1:], true
+}
+
+// matchVirtualHost picks the virtual host Envoy would select for the host, following the same
+// precedence: exact domain, then the longest suffix wildcard, then the catch all "*".
+func matchVirtualHost(vhosts []*route.VirtualHost, host string) *route.VirtualHost {
+	host = strings.ToLower(host)
+	candidates := []string{host}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		candidates = append(candidates, h)
+	}
+	for _, candidate := range candidates {
+		for _, vh := range vhosts {
+			for _, d := range vh.Domains {
+				if d == candidate {
+					return vh
+				}
+			}
+		}
+	}
+	var best *route.VirtualHost
+	bestLen := 0
+	for _, candidate := range candidates {
+		for _, vh := range vhosts {
+			for _, d := range vh.Domains {
+				if len(d) > 1 && strings.HasPrefix(d, "*") && strings.HasSuffix(candidate, d[1:]) && len(d) > bestLen {
+					best, bestLen = vh, len(d)
+				}
+			}
+		}
+	}
+	if best != nil {
+		return best
+	}
+	for _, vh := range vhosts {
+		for _, d := range vh.Domains {
+			if d == "*" {
+				return vh
+			}
+		}
+	}
+	return nil
+}
+
+// updatedServices returns the hostnames of the updated services if the push was only triggered by
+// service changes, or nil otherwise.
+func updatedServices(req *model.PushRequest) map[string]struct{} {
+	if req == nil || len(req.ConfigsUpdated) == 0 {
+		return nil
+	}
+	hosts := map[string]struct{}{}
+	for config := range req.ConfigsUpdated {
+		if config.Kind != gvk.ServiceEntry {
+			return nil
+		}
+		hosts[config.Name] = struct{}{}
+	}
+	return hosts
+}
+
+// virtualHostsReference checks whether any of the services is requested by name through the VHDS
+// resource names, or is the destination of a route of the resolved virtual hosts. Short names, such as
+// "reviews", are resolved by the route configuration and so are matched through the route destinations.
+func virtualHostsReference(names []string, vhosts map[string]*route.VirtualHost, services map[string]struct{}) bool {
+	for _, name := range names {
+		_, host, ok := parseVhdsResourceName(name)
+		if !ok {
+			continue
+		}
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if _, f := services[strings.ToLower(host)]; f {
+			return true
+		}
+	}
+	for _, vh := range vhosts {
+		for cluster := range virtualHostClusters(vh) {
+			if _, _, h, _ := model.ParseSubsetKey(cluster); h != "" {
+				if _, f := services[string(h)]; f {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// virtualHostClusters returns the clusters the routes of the virtual host forward to.
+func virtualHostClusters(vh *route.VirtualHost) map[string]struct{} {
+	clusters := map[string]struct{}{}
+	for _, r := range vh.Routes {
+		action, ok := r.Action.(*route.Route_Route)
+		if !ok {
+			continue
+		}
+		switch cs := action.Route.ClusterSpecifier.(type) {
+		case *route.RouteAction_Cluster:
+			clusters[cs.Cluster] = struct{}{}
+		case *route.RouteAction_WeightedClusters:
+			for _, wc := range cs.WeightedClusters.Clusters {
+				clusters[wc.Name] = struct{}{}
+			}
+		}
+	}
+	return clusters
+}
+
+// onDemandHosts returns the hosts of the outbound services an on-demand proxy needs the clusters of: the
+// services with ports that are not HTTP, as TCP and TLS listeners are not delivered on demand, the services
+// the proxy has subscribed to through VHDS, and the destinations of the virtual services for those hosts.
+// It only relies on the sidecar scope and the subscribed resource names, so no configuration is built.
+func onDemandHosts(proxy *model.Proxy) map[host.Name]struct{} {
+	proxy.RLock()
+	var subscribed []string
+	if w := proxy.WatchedResources[v3.VirtualHostType]; w != nil {
+		for _, name := range w.ResourceNames {
+			_, h, ok := parseVhdsResourceName(name)
+			if !ok {
+				continue
+			}
+			if hostname, _, err := net.SplitHostPort(h); err == nil {
+				h = hostname
+			}
+			subscribed = append(subscribed, strings.ToLower(h))
+		}
+	}
+	proxy.RUnlock()
+
+	hosts := map[host.Name]struct{}{}
+	for _, svc := range proxy.SidecarScope.Services() {
+		if !httpOnly(svc) || subscribedHost(subscribed, svc.Hostname, svc.GetServiceAddressForProxy(proxy)) {
+			hosts[svc.Hostname] = struct{}{}
+		}
+	}
+	if proxy.SidecarScope == nil {
+		return hosts
+	}
+	for _, el := range proxy.SidecarScope.EgressListeners {
+		for _, c := range el.VirtualServices() {
+			vs, ok := c.Spec.(*networking.VirtualService)
+			if !ok {
+				continue
+			}
+			for _, d := range virtualServiceDestinations(vs, subscribed) {
+				hosts[host.Name(d)] = struct{}{}
+			}
+		}
+	}
+	return hosts
+}
+
+// httpOnly checks whether all the ports of the service are HTTP.
+func httpOnly(svc *model.Service) bool {
+	for _, p := range svc.Ports {
+		if !p.Protocol.IsHTTP() {
+			return false
+		}
+	}
+	return true
+}
+
+// subscribedHost checks whether any of the subscribed hosts resolves to the hostname or address. Subscribed
+// hosts may be short names, such as "reviews" or "reviews.default", so these are matched on the domain labels.
+func subscribedHost(subscribed []string, hostname host.Name, address string) bool {
+	for _, s := range subscribed {
+		if s == string(hostname) || s == address || strings.HasPrefix(string(hostname), s+".") {
+			return true
+		}
+		if hostname.IsWildCarded() && host.Name(s).SubsetOf(hostname) {
+			return true
+		}
+	}
+	return false
+}
+
+// virtualServiceDestinations returns the destinations of the TCP and TLS routes of the virtual service, which
+// are not delivered on demand, and the destinations of its HTTP routes if one of its hosts is subscribed.
+func virtualServiceDestinations(vs *networking.VirtualService, subscribed []string) []string {
+	var out []string
+	for _, t := range vs.Tcp {
+		for _, r := range t.Route {
+			out = append(out, r.GetDestination().GetHost())
+		}
+	}
+	for _, t := range vs.Tls {
+		for _, r := range t.Route {
+			out = append(out, r.GetDestination().GetHost())
+		}
+	}
+	matched := false
+	for _, h := range vs.Hosts {
+		if subscribedHost(subscribed, host.Name(h), "") {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return out
+	}
+	for _, h := range vs.Http {
+		for _, r := range h.Route {
+			out = append(out, r.GetDestination().GetHost())
+		}
+		if h.Mirror != nil {
+			out = append(out, h.Mirror.Host)
+		}
+	}
+	return out
+}
@@ -0,0 +1,249 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"reflect"
+	"testing"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pilot/test/xdstest"
+	"istio.io/istio/pkg/config/schema/gvk"
+)
+
+const onDemandServiceEntries = `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: a
+  namespace: default
+spec:
+  hosts:
+  - a.example.com
+  ports:
+  - number: 80
+    name: http
+    protocol: HTTP
+  resolution: DNS
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: b
+  namespace: default
+spec:
+  hosts:
+  - b.example.com
+  ports:
+  - number: 80
+    name: http
+    protocol: HTTP
+  resolution: DNS
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: tcp
+  namespace: default
+spec:
+  hosts:
+  - tcp.example.com
+  addresses:
+  - 240.0.0.10
+  ports:
+  - number: 9000
+    name: tcp
+    protocol: TCP
+  resolution: DNS
+`
+
+func TestOnDemandVhds(t *testing.T) {
+	original := features.EnableOnDemandXDS
+	t.Cleanup(func() {
+		features.EnableOnDemandXDS = original
+	})
+	features.EnableOnDemandXDS = true
+
+	s := NewFakeDiscoveryServer(t, FakeOptions{ConfigString: onDemandServiceEntries})
+	ads := s.ConnectDeltaADS().WithMetadata(model.NodeMetadata{OnDemandXDS: true})
+
+	clusterNames := func(res *discovery.DeltaDiscoveryResponse) []string {
+		t.Helper()
+		names := []string{}
+		for _, r := range res.Resources {
+			names = append(names, r.Name)
+		}
+		return names
+	}
+	contains := func(names []string, name string) bool {
+		for _, n := range names {
+			if n == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Initially, no outbound HTTP clusters are sent.
+	res := ads.WithType(v3.ClusterType).RequestResponseAck(nil)
+	if names := clusterNames(res); contains(names, "outbound|80||a.example.com") || contains(names, "outbound|80||b.example.com") {
+		t.Fatalf("unexpected on-demand clusters sent: %v", names)
+	}
+	// TCP listeners are not served on demand, so the clusters they forward to are always sent.
+	if names := clusterNames(res); !contains(names, "outbound|9000||tcp.example.com") {
+		t.Fatalf("expected TCP cluster, got %v", names)
+	}
+
+	// Routes are sent without virtual hosts, pointing at VHDS.
+	res = ads.WithType(v3.RouteType).RequestResponseAck(&discovery.DeltaDiscoveryRequest{ResourceNamesSubscribe: []string{"80"}})
+	routes := xdstest.UnmarshalRouteConfiguration(t, ConvertDeltaToResponse(res.Resources))
+	if len(routes) != 1 || routes[0].Name != "80" {
+		t.Fatalf("unexpected routes: %v", routes)
+	}
+	if routes[0].Vhds == nil || len(routes[0].VirtualHosts) != 0 {
+		t.Fatalf("expected an empty VHDS route config, got %v", routes[0])
+	}
+
+	// Subscribing to a virtual host first sends the clusters it needs, then the virtual host itself.
+	ads.WithType(v3.VirtualHostType).Request(&discovery.DeltaDiscoveryRequest{ResourceNamesSubscribe: []string{"80/a.example.com"}})
+	res = ads.ExpectResponse()
+	if res.TypeUrl != v3.ClusterType {
+		t.Fatalf("expected cluster response, got %v", res.TypeUrl)
+	}
+	if names := clusterNames(res); !contains(names, "outbound|80||a.example.com") || contains(names, "outbound|80||b.example.com") {
+		t.Fatalf("unexpected clusters: %v", names)
+	}
+	res = ads.ExpectResponse()
+	if res.TypeUrl != v3.VirtualHostType {
+		t.Fatalf("expected virtual host response, got %v", res.TypeUrl)
+	}
+	if len(res.Resources) != 1 {
+		t.Fatalf("expected one virtual host, got %v", res.Resources)
+	}
+	vh := &route.VirtualHost{}
+	if err := res.Resources[0].Resource.UnmarshalTo(vh); err != nil {
+		t.Fatal(err)
+	}
+	if vh.Name != "80/a.example.com" || !reflect.DeepEqual(vh.Domains, []string{"a.example.com"}) {
+		t.Fatalf("unexpected virtual host: %v", vh)
+	}
+	cluster := vh.Routes[0].GetRoute().GetCluster()
+	if cluster != "outbound|80||a.example.com" {
+		t.Fatalf("unexpected cluster %v", cluster)
+	}
+
+	// Names that cannot be resolved are answered with an alias only resource.
+	ads.WithType(v3.VirtualHostType).Request(&discovery.DeltaDiscoveryRequest{ResourceNamesSubscribe: []string{"invalid"}})
+	if res = ads.ExpectResponse(); res.TypeUrl != v3.ClusterType {
+		t.Fatalf("expected cluster response, got %v", res.TypeUrl)
+	}
+	res = ads.ExpectResponse()
+	if len(res.Resources) != 1 || len(res.RemovedResources) != 0 {
+		t.Fatalf("expected one alias only resource, got %v", res)
+	}
+	if r := res.Resources[0]; r.Name != "invalid" || r.Resource != nil || !reflect.DeepEqual(r.Aliases, []string{"invalid"}) {
+		t.Fatalf("unexpected resource: %v", r)
+	}
+}
+
+func TestVirtualHostsReference(t *testing.T) {
+	names := []string{"80/a.example.com:80", "80/reviews", "invalid"}
+	vhosts := map[string]*route.VirtualHost{
+		"80/reviews": {
+			Name: "80/reviews",
+			Routes: []*route.Route{{
+				Action: &route.Route_Route{Route: &route.RouteAction{
+					ClusterSpecifier: &route.RouteAction_WeightedClusters{WeightedClusters: &route.WeightedCluster{
+						Clusters: []*route.WeightedCluster_ClusterWeight{
+							{Name: "outbound|80|v1|reviews.default.svc.cluster.local"},
+							{Name: "outbound|80||ratings.default.svc.cluster.local"},
+						},
+					}},
+				}},
+			}},
+		},
+	}
+	cases := []struct {
+		host     string
+		expected bool
+	}{
+		{"a.example.com", true},
+		{"reviews.default.svc.cluster.local", true},
+		{"ratings.default.svc.cluster.local", true},
+		// Host names are not matched on prefixes.
+		{"a.example.com.cn", false},
+		{"reviews.other.svc.cluster.local", false},
+		{"c.example.com", false},
+	}
+	for _, tt := range cases {
+		t.Run(tt.host, func(t *testing.T) {
+			req := &model.PushRequest{ConfigsUpdated: map[model.ConfigKey]struct{}{
+				{Kind: gvk.ServiceEntry, Name: tt.host, Namespace: "default"}: {},
+			}}
+			if got := virtualHostsReference(names, vhosts, updatedServices(req)); got != tt.expected {
+				t.Fatalf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestUpdatedServices(t *testing.T) {
+	if got := updatedServices(&model.PushRequest{Full: true}); got != nil {
+		t.Fatalf("expected nil for a push without updated configs, got %v", got)
+	}
+	req := &model.PushRequest{ConfigsUpdated: map[model.ConfigKey]struct{}{
+		{Kind: gvk.ServiceEntry, Name: "a.example.com", Namespace: "default"}: {},
+		{Kind: gvk.VirtualService, Name: "a", Namespace: "default"}:           {},
+	}}
+	if got := updatedServices(req); got != nil {
+		t.Fatalf("expected nil for a push updating other configs, got %v", got)
+	}
+	delete(req.ConfigsUpdated, model.ConfigKey{Kind: gvk.VirtualService, Name: "a", Namespace: "default"})
+	if got := updatedServices(req); !reflect.DeepEqual(got, map[string]struct{}{"a.example.com": {}}) {
+		t.Fatalf("unexpected updated services %v", got)
+	}
+}
+
+func TestMatchVirtualHost(t *testing.T) {
+	vhosts := []*route.VirtualHost{
+		{Name: "exact", Domains: []string{"a.example.com", "a.example.com:80"}},
+		{Name: "wildcard", Domains: []string{"*.example.com"}},
+		{Name: "narrow-wildcard", Domains: []string{"*.foo.example.com"}},
+		{Name: "catchall", Domains: []string{"*"}},
+	}
+	cases := []struct {
+		host     string
+		expected string
+	}{
+		{"a.example.com", "exact"},
+		{"a.example.com:80", "exact"},
+		{"a.example.com:8080", "exact"},
+		{"b.example.com", "wildcard"},
+		{"b.foo.example.com", "narrow-wildcard"},
+		{"other.com", "catchall"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := matchVirtualHost(vhosts, tt.host); got == nil || got.Name != tt.expected {
+				t.Fatalf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
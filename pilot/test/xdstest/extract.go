@@ -257,6 +257,18 @@ func ExtractTLSSecrets(t test.Failer, secrets []*any.Any) map[string]*tls.Secret
 	return res
 }
 
+func UnmarshalListener(t test.Failer, resp []*any.Any) []*listener.Listener {
+	un := make([]*listener.Listener, 0, len(resp))
+	for _, r := range resp {
+		u := &listener.Listener{}
+		if err := r.UnmarshalTo(u); err != nil {
+			t.Fatal(err)
+		}
+		un = append(un, u)
+	}
+	return un
+}
+
 func UnmarshalRouteConfiguration(t test.Failer, resp []*any.Any) []*route.RouteConfiguration {
 	un := make([]*route.RouteConfiguration, 0, len(resp))
 	for _, r := range resp {
@@ -40,6 +40,7 @@ import (
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes/any"
 	pstruct "github.com/golang/protobuf/ptypes/struct"
+	"google.golang.org/genproto/googleapis/rpc/status"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 
@@ -113,6 +114,13 @@ type Config struct {
 	ResponseHandler ResponseHandler
 
 	GrpcOpts []grpc.DialOption
+
+	// Recorder, if set, records the requests sent and the responses received on the stream.
+	Recorder *Recorder
+
+	// Reject, if set, is called on each DiscoveryResponse before it is processed. If it returns an error,
+	// the response is not processed and is rejected with a NACK.
+	Reject func(response *discovery.DiscoveryResponse) error
 }
 
 // ADSC implements a basic client for ADS, for use in stress tests and tools
@@ -448,6 +456,8 @@ func (a *ADSC) handleRecv() {
 			return
 		}
 
+		a.cfg.Recorder.RecordResponse(msg)
+
 		// Group-value-kind - used for high level api generator.
 		gvk := strings.SplitN(msg.TypeUrl, "/", 3)
 
@@ -456,6 +466,12 @@ func (a *ADSC) handleRecv() {
 		if a.cfg.ResponseHandler != nil {
 			a.cfg.ResponseHandler.HandleResponse(a, msg)
 		}
+		if a.cfg.Reject != nil {
+			if err := a.cfg.Reject(msg); err != nil {
+				a.nack(msg, err)
+				continue
+			}
+		}
 
 		if msg.TypeUrl == collections.IstioMeshV1Alpha1MeshConfig.Resource().GroupVersionKind().String() &&
 			len(msg.Resources) > 0 {
@@ -526,8 +542,6 @@ func (a *ADSC) handleRecv() {
 		// If we got no resource - still save to the store with empty name/namespace, to notify sync
 		// This scheme also allows us to chunk large responses !
 
-		// TODO: add hook to inject nacks
-
 		a.mutex.Lock()
 		if len(gvk) == 3 {
 			gt := config.GroupVersionKind{Group: gvk[0], Version: gvk[1], Kind: gvk[2]}
@@ -774,6 +788,12 @@ func (a *ADSC) Send(req *discovery.DiscoveryRequest) error {
 		a.sendNodeMeta = false
 	}
 	req.ResponseNonce = time.Now().String()
+	return a.send(req)
+}
+
+// send sends a request on the stream, recording it if a Recorder is configured.
+func (a *ADSC) send(req *discovery.DiscoveryRequest) error {
+	a.cfg.Recorder.RecordRequest(req)
 	return a.stream.Send(req)
 }
 
@@ -794,7 +814,7 @@ func (a *ADSC) handleEDS(eds []*endpoint.ClusterLoadAssignment) {
 	}
 	if a.InitialLoad == 0 {
 		// first load - Envoy loads listeners after endpoints
-		_ = a.stream.Send(&discovery.DiscoveryRequest{
+		_ = a.send(&discovery.DiscoveryRequest{
 			Node:    a.node(),
 			TypeUrl: v3.ListenerType,
 		})
@@ -972,7 +992,7 @@ func XdsInitialRequests() []*discovery.DiscoveryRequest {
 // it will start watching RDS and LDS.
 func (a *ADSC) Watch() {
 	a.watchTime = time.Now()
-	_ = a.stream.Send(&discovery.DiscoveryRequest{
+	_ = a.send(&discovery.DiscoveryRequest{
 		Node:    a.node(),
 		TypeUrl: v3.ClusterType,
 	})
@@ -994,14 +1014,14 @@ func ConfigInitialRequests() []*discovery.DiscoveryRequest {
 
 // WatchConfig will use the new experimental API watching, similar with MCP.
 func (a *ADSC) WatchConfig() {
-	_ = a.stream.Send(&discovery.DiscoveryRequest{
+	_ = a.send(&discovery.DiscoveryRequest{
 		ResponseNonce: time.Now().String(),
 		Node:          a.node(),
 		TypeUrl:       collections.IstioMeshV1Alpha1MeshConfig.Resource().GroupVersionKind().String(),
 	})
 
 	for _, sch := range collections.Pilot.All() {
-		_ = a.stream.Send(&discovery.DiscoveryRequest{
+		_ = a.send(&discovery.DiscoveryRequest{
 			ResponseNonce: time.Now().String(),
 			Node:          a.node(),
 			TypeUrl:       sch.Resource().GroupVersionKind().String(),
@@ -1037,7 +1057,7 @@ func (a *ADSC) sendRsc(typeurl string, rsc []string) {
 		version = ex.VersionInfo
 		nonce = ex.Nonce
 	}
-	_ = a.stream.Send(&discovery.DiscoveryRequest{
+	_ = a.send(&discovery.DiscoveryRequest{
 		ResponseNonce: nonce,
 		VersionInfo:   version,
 		Node:          a.node(),
@@ -1047,24 +1067,44 @@ func (a *ADSC) sendRsc(typeurl string, rsc []string) {
 }
 
 func (a *ADSC) ack(msg *discovery.DiscoveryResponse) {
+	_ = a.send(&discovery.DiscoveryRequest{
+		ResponseNonce: msg.Nonce,
+		TypeUrl:       msg.TypeUrl,
+		Node:          a.node(),
+		VersionInfo:   msg.VersionInfo,
+		ResourceNames: a.watchedResources(msg.TypeUrl),
+	})
+}
+
+// watchedResources returns the resource names to keep subscribed to for a type. Must be called with the mutex held.
+func (a *ADSC) watchedResources(typeURL string) []string {
 	var resources []string
-	if msg.TypeUrl == v3.EndpointType {
+	if typeURL == v3.EndpointType {
 		for c := range a.edsClusters {
 			resources = append(resources, c)
 		}
 	}
-	if msg.TypeUrl == v3.RouteType {
+	if typeURL == v3.RouteType {
 		for r := range a.routes {
 			resources = append(resources, r)
 		}
 	}
+	return resources
+}
 
-	_ = a.stream.Send(&discovery.DiscoveryRequest{
+// nack rejects a response, keeping the version previously accepted for its type.
+func (a *ADSC) nack(msg *discovery.DiscoveryResponse, err error) {
+	adscLog.Infof("Rejecting %s nonce=%s: %v", msg.TypeUrl, msg.Nonce, err)
+	a.mutex.RLock()
+	resources := a.watchedResources(msg.TypeUrl)
+	a.mutex.RUnlock()
+	_ = a.send(&discovery.DiscoveryRequest{
 		ResponseNonce: msg.Nonce,
 		TypeUrl:       msg.TypeUrl,
 		Node:          a.node(),
-		VersionInfo:   msg.VersionInfo,
+		VersionInfo:   a.VersionInfo[msg.TypeUrl],
 		ResourceNames: resources,
+		ErrorDetail:   &status.Status{Message: err.Error()},
 	})
 }
 
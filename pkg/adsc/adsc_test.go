@@ -15,12 +15,16 @@
 package adsc
 
 import (
+	"bytes"
+	"errors"
 	"io/ioutil"
 	"log"
 	"net"
 	"os"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
@@ -37,7 +41,9 @@ import (
 	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pilot/pkg/config/memory"
 	"istio.io/istio/pilot/pkg/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
 	"istio.io/istio/pkg/config/schema/collections"
+	"istio.io/istio/pkg/test/util/retry"
 )
 
 type testAdscRunServer struct{}
@@ -415,3 +421,67 @@ func constructResource(name string, host string, address string) *any.Any {
 		Value:   resAny.Value,
 	}
 }
+
+func TestADSC_Reject(t *testing.T) {
+	recording := []*RecordedMessage{
+		{Response: &xdsapi.DiscoveryResponse{TypeUrl: v3.ListenerType, VersionInfo: "1", Nonce: "n1"}},
+		{Response: &xdsapi.DiscoveryResponse{TypeUrl: v3.ListenerType, VersionInfo: "2", Nonce: "n2"}},
+	}
+	buf := &syncBuffer{}
+	adsc, err := New(startReplayer(t, recording), &Config{
+		InitialDiscoveryRequests: []*xdsapi.DiscoveryRequest{{TypeUrl: v3.ListenerType}},
+		Recorder:                 NewRecorder(buf),
+		Reject: func(resp *xdsapi.DiscoveryResponse) error {
+			if resp.Nonce == "n2" {
+				return errors.New("invalid listener")
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := adsc.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := adsc.Wait(5*time.Second, v3.ListenerType); err != nil {
+		t.Fatal(err)
+	}
+	retry.UntilSuccessOrFail(t, func() error {
+		if strings.Count(buf.String(), `"responseNonce"`) < 3 {
+			return errors.New("waiting for NACK")
+		}
+		return nil
+	})
+	adsc.Close()
+	adsc.RecvWg.Wait()
+
+	messages, err := ReadRecording(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nack := messages[len(messages)-1].Request
+	if nack == nil || nack.ResponseNonce != "n2" || nack.ErrorDetail.GetMessage() != "invalid listener" || nack.VersionInfo != "1" {
+		t.Fatalf("expected NACK keeping version 1, got %v", nack)
+	}
+	if adsc.VersionInfo[v3.ListenerType] != "1" {
+		t.Fatalf("expected rejected response not to be applied, got version %v", adsc.VersionInfo[v3.ListenerType])
+	}
+}
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,164 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adsc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/golang/protobuf/jsonpb"
+)
+
+// RecordedMessage is a single message of an xDS stream capture. Exactly one of Request or Response is set.
+// Captures are written as one JSON encoded RecordedMessage per line, with the protos encoded as jsonpb,
+// so they can be inspected and edited with standard tools.
+type RecordedMessage struct {
+	// Offset is the time elapsed since the start of the recording.
+	Offset time.Duration `json:"offset"`
+
+	Request  *discovery.DiscoveryRequest  `json:"-"`
+	Response *discovery.DiscoveryResponse `json:"-"`
+}
+
+type recordedMessageJSON struct {
+	Offset   time.Duration   `json:"offset"`
+	Request  json.RawMessage `json:"request,omitempty"`
+	Response json.RawMessage `json:"response,omitempty"`
+}
+
+// MarshalJSON encodes the message, using jsonpb for the request or response.
+func (m *RecordedMessage) MarshalJSON() ([]byte, error) {
+	out := recordedMessageJSON{Offset: m.Offset}
+	marshaler := &jsonpb.Marshaler{}
+	buf := &bytes.Buffer{}
+	switch {
+	case m.Request != nil:
+		if err := marshaler.Marshal(buf, m.Request); err != nil {
+			return nil, err
+		}
+		out.Request = buf.Bytes()
+	case m.Response != nil:
+		if err := marshaler.Marshal(buf, m.Response); err != nil {
+			return nil, err
+		}
+		out.Response = buf.Bytes()
+	default:
+		return nil, fmt.Errorf("recorded message at %v has no request or response", m.Offset)
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON decodes a message encoded by MarshalJSON.
+func (m *RecordedMessage) UnmarshalJSON(data []byte) error {
+	in := recordedMessageJSON{}
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	m.Offset = in.Offset
+	// Captures may come from a newer version, do not fail on unknown fields.
+	unmarshaler := &jsonpb.Unmarshaler{AllowUnknownFields: true}
+	switch {
+	case len(in.Request) > 0:
+		m.Request = &discovery.DiscoveryRequest{}
+		return unmarshaler.Unmarshal(bytes.NewReader(in.Request), m.Request)
+	case len(in.Response) > 0:
+		m.Response = &discovery.DiscoveryResponse{}
+		return unmarshaler.Unmarshal(bytes.NewReader(in.Response), m.Response)
+	default:
+		return fmt.Errorf("recorded message at %v has no request or response", in.Offset)
+	}
+}
+
+// Recorder writes the messages of an xDS stream to a capture. It is safe for concurrent use.
+type Recorder struct {
+	mu    sync.Mutex
+	w     io.Writer
+	start time.Time
+	err   error
+}
+
+// NewRecorder creates a Recorder writing the capture to w. The offsets of the messages are relative
+// to the creation of the Recorder.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{w: w, start: time.Now()}
+}
+
+// RecordRequest adds a request sent to the server to the capture.
+func (r *Recorder) RecordRequest(req *discovery.DiscoveryRequest) {
+	r.record(&RecordedMessage{Request: req})
+}
+
+// RecordResponse adds a response received from the server to the capture.
+func (r *Recorder) RecordResponse(resp *discovery.DiscoveryResponse) {
+	r.record(&RecordedMessage{Response: resp})
+}
+
+func (r *Recorder) record(m *RecordedMessage) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	m.Offset = time.Since(r.start)
+	by, err := json.Marshal(m)
+	if err != nil {
+		r.err = err
+		return
+	}
+	if _, err := r.w.Write(append(by, '\n')); err != nil {
+		r.err = err
+	}
+}
+
+// Err returns the first error encountered while writing the capture. No more messages are
+// recorded after an error.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// ReadRecording reads a capture written by a Recorder.
+func ReadRecording(r io.Reader) ([]*RecordedMessage, error) {
+	out := []*RecordedMessage{}
+	scanner := bufio.NewScanner(r)
+	// Responses can be large, as they hold all the resources of a type.
+	scanner.Buffer(make([]byte, 0, 64*1024), 256*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		m := &RecordedMessage{}
+		if err := json.Unmarshal(scanner.Bytes(), m); err != nil {
+			return nil, fmt.Errorf("invalid recorded message on line %d: %v", line, err)
+		}
+		out = append(out, m)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,161 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adsc
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	xdsapi "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	"istio.io/istio/pilot/pkg/networking/util"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+func TestRecordingRoundTrip(t *testing.T) {
+	buf := &bytes.Buffer{}
+	r := NewRecorder(buf)
+	req := &xdsapi.DiscoveryRequest{TypeUrl: v3.ClusterType, ResourceNames: []string{"a"}}
+	resp := &xdsapi.DiscoveryResponse{
+		TypeUrl:     v3.ClusterType,
+		VersionInfo: "1",
+		Nonce:       "n1",
+		Resources:   []*any.Any{util.MessageToAny(&cluster.Cluster{Name: "a"})},
+	}
+	r.RecordRequest(req)
+	r.RecordResponse(resp)
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	messages, err := ReadRecording(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(messages))
+	}
+	if diff := cmp.Diff(req, messages[0].Request, protocmp.Transform()); diff != "" || messages[0].Response != nil {
+		t.Fatalf("unexpected request: %v", diff)
+	}
+	if diff := cmp.Diff(resp, messages[1].Response, protocmp.Transform()); diff != "" || messages[1].Request != nil {
+		t.Fatalf("unexpected response: %v", diff)
+	}
+	if messages[1].Offset < messages[0].Offset {
+		t.Fatalf("expected increasing offsets, got %v and %v", messages[0].Offset, messages[1].Offset)
+	}
+
+	if _, err := ReadRecording(bytes.NewBufferString(`{"offset": 1}`)); err == nil {
+		t.Fatal("expected error for message without request or response")
+	}
+}
+
+func TestReplayOptionsDelay(t *testing.T) {
+	cases := []struct {
+		opts     ReplayOptions
+		gap      time.Duration
+		expected time.Duration
+	}{
+		{ReplayOptions{}, time.Second, 0},
+		{ReplayOptions{Speed: 1}, time.Second, time.Second},
+		{ReplayOptions{Speed: 10}, time.Second, 100 * time.Millisecond},
+		{ReplayOptions{Speed: 1, MaxDelay: time.Millisecond}, time.Second, time.Millisecond},
+		{ReplayOptions{Speed: 1}, -time.Second, 0},
+	}
+	for _, tt := range cases {
+		if got := tt.opts.delay(tt.gap); got != tt.expected {
+			t.Errorf("delay(%v) with %+v: expected %v, got %v", tt.gap, tt.opts, tt.expected, got)
+		}
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	recording := []*RecordedMessage{
+		{Offset: 0, Request: &xdsapi.DiscoveryRequest{TypeUrl: v3.ClusterType}},
+		{Offset: time.Millisecond, Response: &xdsapi.DiscoveryResponse{
+			TypeUrl:     v3.ClusterType,
+			VersionInfo: "1",
+			Nonce:       "n1",
+			Resources: []*any.Any{util.MessageToAny(&cluster.Cluster{
+				Name:                 "outbound|80||a.default.svc.cluster.local",
+				ClusterDiscoveryType: &cluster.Cluster_Type{Type: cluster.Cluster_STATIC},
+			})},
+		}},
+		// Never requested by the client, so never sent.
+		{Offset: 2 * time.Millisecond, Response: &xdsapi.DiscoveryResponse{TypeUrl: v3.ListenerType, VersionInfo: "1", Nonce: "n2"}},
+	}
+
+	buf := &bytes.Buffer{}
+	adsc, err := New(startReplayer(t, recording), &Config{
+		InitialDiscoveryRequests: []*xdsapi.DiscoveryRequest{{TypeUrl: v3.ClusterType}},
+		Recorder:                 NewRecorder(buf),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := adsc.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := adsc.Wait(5*time.Second, v3.ClusterType); err != nil {
+		t.Fatal(err)
+	}
+	if _, f := adsc.GetClusters()["outbound|80||a.default.svc.cluster.local"]; !f {
+		t.Fatalf("expected replayed cluster, got %v", adsc.GetClusters())
+	}
+	adsc.Close()
+	adsc.RecvWg.Wait()
+
+	captured, err := ReadRecording(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var requests, responses []string
+	for _, m := range captured {
+		if m.Request != nil {
+			requests = append(requests, m.Request.TypeUrl+" "+m.Request.ResponseNonce)
+		} else {
+			responses = append(responses, m.Response.TypeUrl+" "+m.Response.Nonce)
+		}
+	}
+	// The initial request nonce is a timestamp, only check the ACK.
+	if len(requests) != 2 || requests[1] != v3.ClusterType+" n1" {
+		t.Fatalf("unexpected requests recorded: %v", requests)
+	}
+	if diff := cmp.Diff([]string{v3.ClusterType + " n1"}, responses); diff != "" {
+		t.Fatalf("unexpected responses recorded: %v", diff)
+	}
+}
+
+// startReplayer serves the recording and returns the address of the server.
+func startReplayer(t *testing.T, recording []*RecordedMessage) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	NewReplayer(recording, ReplayOptions{Speed: 1}).Register(server)
+	go func() {
+		_ = server.Serve(l)
+	}()
+	t.Cleanup(server.Stop)
+	return l.Addr().String()
+}
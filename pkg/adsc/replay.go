@@ -0,0 +1,147 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adsc
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/grpc"
+)
+
+// ReplayOptions control the timing of a replay.
+type ReplayOptions struct {
+	// Speed scales the recorded delays between messages: 1 preserves the original timing, 10 replays
+	// ten times faster. If 0, responses are sent as soon as the client subscribed to their type.
+	Speed float64
+	// MaxDelay caps the delay between two responses, to skip long idle periods of a capture. Ignored if 0.
+	MaxDelay time.Duration
+}
+
+func (o ReplayOptions) delay(gap time.Duration) time.Duration {
+	if o.Speed <= 0 || gap <= 0 {
+		return 0
+	}
+	d := time.Duration(float64(gap) / o.Speed)
+	if o.MaxDelay > 0 && d > o.MaxDelay {
+		d = o.MaxDelay
+	}
+	return d
+}
+
+// Replayer is a fake ADS server sending the responses of a capture, in order, to each client.
+// A response is only sent once the client requested its type, as a real control plane would.
+// The delay before a response is the time elapsed since the previous message of the capture,
+// scaled by the ReplayOptions.
+type Replayer struct {
+	discovery.UnimplementedAggregatedDiscoveryServiceServer
+
+	messages []*RecordedMessage
+	opts     ReplayOptions
+}
+
+// NewReplayer creates a Replayer for a capture read with ReadRecording.
+func NewReplayer(messages []*RecordedMessage, opts ReplayOptions) *Replayer {
+	return &Replayer{messages: messages, opts: opts}
+}
+
+// Register adds the Replayer to a gRPC server as the ADS service.
+func (r *Replayer) Register(s *grpc.Server) {
+	discovery.RegisterAggregatedDiscoveryServiceServer(s, r)
+}
+
+// StreamAggregatedResources replays the capture on the stream. Once all the responses are sent,
+// the stream is kept open until the client closes it.
+func (r *Replayer) StreamAggregatedResources(stream discovery.AggregatedDiscoveryService_StreamAggregatedResourcesServer) error {
+	var mu sync.Mutex
+	subscribed := map[string]struct{}{}
+	// notify is signaled when a new type is subscribed.
+	notify := make(chan struct{}, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				errCh <- err
+				return
+			}
+			if req.ErrorDetail != nil {
+				adscLog.Warnf("Replayed response %s %s rejected: %v", req.TypeUrl, req.ResponseNonce, req.ErrorDetail.Message)
+			}
+			mu.Lock()
+			if _, f := subscribed[req.TypeUrl]; !f {
+				subscribed[req.TypeUrl] = struct{}{}
+				select {
+				case notify <- struct{}{}:
+				default:
+				}
+			}
+			mu.Unlock()
+		}
+	}()
+	isSubscribed := func(typeURL string) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		_, f := subscribed[typeURL]
+		return f
+	}
+
+	ctx := stream.Context()
+	var last time.Duration
+	for _, m := range r.messages {
+		gap := m.Offset - last
+		last = m.Offset
+		if m.Response == nil {
+			continue
+		}
+		for !isSubscribed(m.Response.TypeUrl) {
+			select {
+			case <-notify:
+			case err := <-errCh:
+				return replayStreamError(err)
+			case <-ctx.Done():
+				return nil
+			}
+		}
+		if d := r.opts.delay(gap); d > 0 {
+			select {
+			case <-time.After(d):
+			case err := <-errCh:
+				return replayStreamError(err)
+			case <-ctx.Done():
+				return nil
+			}
+		}
+		if err := stream.Send(m.Response); err != nil {
+			return err
+		}
+	}
+	adscLog.Infof("All %d recorded messages replayed", len(r.messages))
+	select {
+	case err := <-errCh:
+		return replayStreamError(err)
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+func replayStreamError(err error) error {
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
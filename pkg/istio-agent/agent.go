@@ -21,6 +21,7 @@ import (
 	"os"
 	"path"
 	"strings"
+	"time"
 
 	"google.golang.org/grpc"
 
@@ -113,6 +114,9 @@ type AgentOptions struct {
 	// ProxyDomain is the DNS domain associated with the proxy (assumed
 	// to include the namespace as well) (for local dns resolution)
 	ProxyDomain string
+	// DNSCacheSize is the number of responses of the upstream DNS resolvers cached by the
+	// local DNS server. Caching is disabled if 0.
+	DNSCacheSize int
 
 	// XDSRootCerts is the location of the root CA for the XDS connection. Used for setting platform certs or
 	// using custom roots.
@@ -138,6 +142,22 @@ type AgentOptions struct {
 	ProxyIPAddresses []string
 
 	DownstreamGrpcOptions []grpc.ServerOption
+
+	// WasmAllowedURLPrefixes restricts the URLs Wasm modules may be fetched from. If empty, all URLs are allowed.
+	WasmAllowedURLPrefixes []string
+
+	// WasmRequireChecksum rejects Wasm modules that are not pinned with a checksum.
+	WasmRequireChecksum bool
+
+	// WasmSignaturePublicKey is the path to the public key used to verify the signature of Wasm modules.
+	WasmSignaturePublicKey string
+
+	// XDSConfigPersistenceDir is the directory the xDS configuration accepted by Envoy is persisted to, to be
+	// served to Envoy while istiod is unreachable. If empty, the configuration is not persisted.
+	XDSConfigPersistenceDir string
+
+	// XDSConfigMaxStaleness is the maximum age of persisted xDS configuration that may be served to Envoy.
+	XDSConfigMaxStaleness time.Duration
 }
 
 // NewAgent hosts the functionality for local SDS and XDS. This consists of the local SDS server and
@@ -187,7 +207,7 @@ func (a *Agent) Start() error {
 func (a *Agent) initLocalDNSServer() (err error) {
 	// we dont need dns server on gateways
 	if a.cfg.DNSCapture && a.cfg.ProxyXDSViaAgent && a.cfg.ProxyType == model.SidecarProxy {
-		if a.localDNSServer, err = dns.NewLocalDNSServer(a.cfg.ProxyNamespace, a.cfg.ProxyDomain); err != nil {
+		if a.localDNSServer, err = dns.NewLocalDNSServer(a.cfg.ProxyNamespace, a.cfg.ProxyDomain, a.cfg.DNSCacheSize); err != nil {
 			return err
 		}
 		a.localDNSServer.StartDNS()
@@ -335,5 +355,13 @@ func (a *Agent) newSecretManager() (*cache.SecretManagerClient, error) {
 		return nil, err
 	}
 
+	if a.secOpts.CRLFilePath == "" && a.FindRootCAForCA() == path.Join(CitadelCACertPath, constants.CACertNamespaceConfigMapDataName) {
+		// The CRL of Istiod is distributed in the mounted config map, with the root cert. Istiod only
+		// adds it when revocation is enabled, otherwise there is nothing to watch.
+		crlFile := path.Join(CitadelCACertPath, constants.CACRLNamespaceConfigMapDataName)
+		if _, err := os.Stat(crlFile); err == nil {
+			a.secOpts.CRLFilePath = crlFile
+		}
+	}
 	return cache.NewSecretManagerClient(caClient, a.secOpts)
 }
@@ -94,6 +94,7 @@ func NewWorkloadHealthChecker(cfg *v1alpha3.ReadinessProbe, envoyProbe ready.Pro
 		prober = &TCPProber{Config: healthCheckMethod.TcpSocket}
 	case *v1alpha3.ReadinessProbe_Exec:
 		prober = &ExecProber{Config: healthCheckMethod.Exec}
+	// TODO: use the GRPCProber once the WorkloadGroup ReadinessProbe API has a gRPC health check method.
 	default:
 		prober = nil
 	}
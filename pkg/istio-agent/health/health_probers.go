@@ -162,6 +162,49 @@ func (t *TCPProber) Probe(timeout time.Duration) (ProbeResult, error) {
 	return Healthy, nil
 }
 
+// GRPCHealthCheckConfig configures a health check using the standard gRPC health checking protocol.
+type GRPCHealthCheckConfig struct {
+	Host string
+	Port uint32
+	// Service is the name of the service to check. If empty, the overall health of the server is checked.
+	Service string
+	// TLS enables TLS. As for HTTPS probes, the server certificate is not verified.
+	TLS bool
+}
+
+type GRPCProber struct {
+	Config *GRPCHealthCheckConfig
+	Dialer *net.Dialer
+}
+
+var _ Prober = &GRPCProber{}
+
+func NewGRPCProber(cfg *GRPCHealthCheckConfig, ipv6 bool) *GRPCProber {
+	d := &net.Dialer{
+		LocalAddr: status.UpstreamLocalAddressIPv4,
+	}
+	if ipv6 {
+		d.LocalAddr = status.UpstreamLocalAddressIPv6
+	}
+	return &GRPCProber{
+		Config: cfg,
+		Dialer: d,
+	}
+}
+
+// Probe will return whether or not the target is healthy (true -> healthy)
+// by calling the Check method of the gRPC health service.
+func (g *GRPCProber) Probe(timeout time.Duration) (ProbeResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	addr := net.JoinHostPort(g.Config.Host, strconv.Itoa(int(g.Config.Port)))
+	if err := status.GRPCHealthCheck(ctx, g.Dialer, addr, g.Config.Service, g.Config.TLS); err != nil {
+		return Unhealthy, err
+	}
+	return Healthy, nil
+}
+
 type ExecProber struct {
 	Config *v1alpha3.ExecHealthCheckConfig
 }
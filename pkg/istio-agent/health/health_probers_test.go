@@ -24,7 +24,13 @@ import (
 	"testing"
 	"time"
 
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
 	"istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pkg/test/env"
 	"istio.io/istio/tests/util/leak"
 )
 
@@ -124,6 +130,69 @@ func TestTcpProber(t *testing.T) {
 	}
 }
 
+func TestGRPCProber(t *testing.T) {
+	tests := []struct {
+		desc                string
+		service             string
+		down                bool
+		tls                 bool
+		expectedProbeResult ProbeResult
+	}{
+		{
+			desc:                "Healthy",
+			expectedProbeResult: Healthy,
+		},
+		{
+			desc:                "Healthy service over TLS",
+			service:             "serving",
+			tls:                 true,
+			expectedProbeResult: Healthy,
+		},
+		{
+			desc:                "Healthy service",
+			service:             "serving",
+			expectedProbeResult: Healthy,
+		},
+		{
+			desc:                "Unhealthy service",
+			service:             "not-serving",
+			expectedProbeResult: Unhealthy,
+		},
+		{
+			desc:                "Unknown service",
+			service:             "unknown",
+			expectedProbeResult: Unhealthy,
+		},
+		{
+			desc:                "Unhealthy - Could not connect to server",
+			down:                true,
+			expectedProbeResult: Unhealthy,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			server, port := createGRPCServer(t, tt.tls)
+			defer server.Stop()
+			if tt.down {
+				server.Stop()
+			}
+			grpcProber := NewGRPCProber(
+				&GRPCHealthCheckConfig{
+					Host:    "127.0.0.1",
+					Port:    port,
+					Service: tt.service,
+					TLS:     tt.tls,
+				}, false)
+
+			got, err := grpcProber.Probe(time.Second)
+			if got != tt.expectedProbeResult || (got == Healthy) != (err == nil) {
+				t.Errorf("%s: got: %v, expected: %v, got error: %v", tt.desc, got, tt.expectedProbeResult, err)
+			}
+		})
+	}
+}
+
 func TestExecProber(t *testing.T) {
 	tests := []struct {
 		desc                string
@@ -187,3 +256,29 @@ func createHTTPServer(statusCode int) (*httptest.Server, uint32) {
 
 	return server, uint32(port)
 }
+
+func createGRPCServer(t *testing.T, useTLS bool) (*grpc.Server, uint32) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hs := grpchealth.NewServer()
+	hs.SetServingStatus("serving", healthpb.HealthCheckResponse_SERVING)
+	hs.SetServingStatus("not-serving", healthpb.HealthCheckResponse_NOT_SERVING)
+	var opts []grpc.ServerOption
+	if useTLS {
+		creds, err := credentials.NewServerTLSFromFile(env.IstioSrc+"/pilot/cmd/pilot-agent/status/test-cert/cert.crt",
+			env.IstioSrc+"/pilot/cmd/pilot-agent/status/test-cert/cert.key")
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+	server := grpc.NewServer(opts...)
+	healthpb.RegisterHealthServer(server, hs)
+	go func() {
+		_ = server.Serve(l)
+	}()
+
+	return server, uint32(l.Addr().(*net.TCPAddr).Port)
+}
@@ -20,6 +20,7 @@ import (
 
 var (
 	disconnectionTypeTag = monitoring.MustCreateLabel("type")
+	typeURLTag           = monitoring.MustCreateLabel("type_url")
 
 	// IstiodConnectionFailures records total number of connection failures to Istiod.
 	IstiodConnectionFailures = monitoring.NewSum(
@@ -55,12 +56,30 @@ var (
 		"The total number of Xds Proxy Responses",
 	)
 
+	// XdsProxyPersistedResponses records total number of persisted responses served to Envoy while istiod was unreachable.
+	XdsProxyPersistedResponses = monitoring.NewSum(
+		"xds_proxy_persisted_responses",
+		"The total number of persisted Xds responses served to Envoy while Istiod was unreachable",
+		monitoring.WithLabels(typeURLTag),
+	)
+
+	// XdsProxyServingPersistedConfig is 1 while the proxy serves persisted configuration to Envoy, and 0 otherwise.
+	XdsProxyServingPersistedConfig = monitoring.NewGauge(
+		"xds_proxy_serving_persisted_config",
+		"Whether the Xds Proxy is serving persisted configuration to Envoy while Istiod is unreachable",
+	)
+
 	IstiodConnectionCancellations = istiodDisconnections.With(disconnectionTypeTag.Value(Cancel))
 	IstiodConnectionErrors        = istiodDisconnections.With(disconnectionTypeTag.Value(Error))
 	EnvoyConnectionCancellations  = envoyDisconnections.With(disconnectionTypeTag.Value(Cancel))
 	EnvoyConnectionErrors         = envoyDisconnections.With(disconnectionTypeTag.Value(Error))
 )
 
+// PersistedResponseServed records a persisted response of the given type served to Envoy.
+func PersistedResponseServed(typeURL string) {
+	XdsProxyPersistedResponses.With(typeURLTag.Value(typeURL)).Increment()
+}
+
 var (
 	Cancel = "cancelled"
 	Error  = "error"
@@ -72,5 +91,7 @@ func init() {
 		IstiodConnectionErrors,
 		istiodDisconnections,
 		envoyDisconnections,
+		XdsProxyPersistedResponses,
+		XdsProxyServingPersistedConfig,
 	)
 }
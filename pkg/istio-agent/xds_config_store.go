@@ -0,0 +1,207 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istioagent
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	wasm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/wasm/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/golang/protobuf/ptypes/any"
+	"google.golang.org/protobuf/proto"
+
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+// persistedTypes are the types whose responses are persisted. Secrets are served by the agent SDS server
+// and are never persisted; ProxyConfig only carries trust bundles, which are persisted by the secret cache.
+var persistedTypes = map[string]struct{}{
+	v3.ClusterType:                {},
+	v3.ListenerType:               {},
+	v3.RouteType:                  {},
+	v3.EndpointType:               {},
+	v3.ExtensionConfigurationType: {},
+	v3.NameTableType:              {},
+}
+
+// xdsConfigStore persists the last xDS responses accepted by Envoy (and the name table accepted by the agent),
+// so that Envoy can be served the last-known-good configuration when it connects while istiod is unreachable,
+// for example after a restart of the pod during an istiod outage.
+type xdsConfigStore struct {
+	dir          string
+	maxStaleness time.Duration
+
+	mu sync.Mutex
+	// pending holds, per type, the last response forwarded to Envoy that has not been ACKed yet.
+	pending map[string]*discovery.DiscoveryResponse
+}
+
+func newXdsConfigStore(dir string, maxStaleness time.Duration) (*xdsConfigStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create xDS config persistence directory: %v", err)
+	}
+	return &xdsConfigStore{
+		dir:          dir,
+		maxStaleness: maxStaleness,
+		pending:      map[string]*discovery.DiscoveryResponse{},
+	}, nil
+}
+
+func (s *xdsConfigStore) path(typeURL string) string {
+	return filepath.Join(s.dir, strings.ToLower(v3.GetShortType(typeURL))+".pb")
+}
+
+// onResponse records a response forwarded to Envoy. It is persisted once Envoy ACKs it.
+func (s *xdsConfigStore) onResponse(resp *discovery.DiscoveryResponse) {
+	if _, f := persistedTypes[resp.TypeUrl]; !f {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending[resp.TypeUrl] = resp
+}
+
+// onRequest persists the pending response of the request type if the request ACKs it.
+func (s *xdsConfigStore) onRequest(req *discovery.DiscoveryRequest) {
+	if req.ResponseNonce == "" || req.ErrorDetail != nil {
+		return
+	}
+	s.mu.Lock()
+	resp := s.pending[req.TypeUrl]
+	if resp == nil || resp.Nonce != req.ResponseNonce {
+		s.mu.Unlock()
+		return
+	}
+	delete(s.pending, req.TypeUrl)
+	s.mu.Unlock()
+
+	if err := s.save(resp); err != nil {
+		proxyLog.Warnf("failed to persist %s config: %v", v3.GetShortType(resp.TypeUrl), err)
+	}
+}
+
+// save persists the response, replacing any previously persisted response of the same type.
+func (s *xdsConfigStore) save(resp *discovery.DiscoveryResponse) error {
+	if _, f := persistedTypes[resp.TypeUrl]; !f {
+		return nil
+	}
+	b, err := proto.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first, so a crash never leaves a partially written response behind.
+	tmp, err := ioutil.TempFile(s.dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(resp.TypeUrl))
+}
+
+// load returns the persisted response of the given type, or nil if there is none or it is older than
+// the maximum staleness.
+func (s *xdsConfigStore) load(typeURL string) *discovery.DiscoveryResponse {
+	if _, f := persistedTypes[typeURL]; !f {
+		return nil
+	}
+	p := s.path(typeURL)
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil
+	}
+	if s.maxStaleness > 0 && time.Since(info.ModTime()) > s.maxStaleness {
+		proxyLog.Debugf("ignoring persisted %s config older than %v", v3.GetShortType(typeURL), s.maxStaleness)
+		return nil
+	}
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		proxyLog.Warnf("failed to read persisted %s config: %v", v3.GetShortType(typeURL), err)
+		return nil
+	}
+	resp := &discovery.DiscoveryResponse{}
+	if err := proto.Unmarshal(b, resp); err != nil {
+		proxyLog.Warnf("failed to parse persisted %s config: %v", v3.GetShortType(typeURL), err)
+		return nil
+	}
+	if typeURL == v3.ExtensionConfigurationType {
+		resp.Resources = withLocalWasmModules(resp.Resources)
+	}
+	return resp
+}
+
+// withLocalWasmModules returns the extension configs whose Wasm module is still available. Remote modules
+// are rewritten by the agent to local files, which may have been removed since the config was persisted,
+// for example if the pod was restarted with a new data directory or the module was purged from the cache.
+// Envoy would reject such configs, and the modules cannot be fetched again as the rewritten configs no
+// longer have the module URL, so they are not replayed and wait for istiod instead.
+func withLocalWasmModules(resources []*any.Any) []*any.Any {
+	filtered := make([]*any.Any, 0, len(resources))
+	for _, r := range resources {
+		if f := localWasmModule(r); f != "" {
+			if _, err := os.Stat(f); err != nil {
+				proxyLog.Warnf("not serving persisted extension config, Wasm module %v is not available: %v", f, err)
+				continue
+			}
+		}
+		filtered = append(filtered, r)
+	}
+	return filtered
+}
+
+// localWasmModule returns the local file of the Wasm module of the extension config, or an empty
+// string if it is not a Wasm filter loaded from a local file.
+func localWasmModule(resource *any.Any) string {
+	ec := &core.TypedExtensionConfig{}
+	if err := resource.UnmarshalTo(ec); err != nil {
+		return ""
+	}
+	w := &wasm.Wasm{}
+	if !ec.GetTypedConfig().MessageIs(w) {
+		return ""
+	}
+	if err := ec.GetTypedConfig().UnmarshalTo(w); err != nil {
+		return ""
+	}
+	return w.GetConfig().GetVmConfig().GetCode().GetLocal().GetFilename()
+}
+
+// available returns true if there is persisted configuration usable to bootstrap Envoy, that is
+// at least its clusters and listeners.
+func (s *xdsConfigStore) available() bool {
+	for _, t := range []string{v3.ClusterType, v3.ListenerType} {
+		info, err := os.Stat(s.path(t))
+		if err != nil {
+			return false
+		}
+		if s.maxStaleness > 0 && time.Since(info.ModTime()) > s.maxStaleness {
+			return false
+		}
+	}
+	return true
+}
@@ -29,6 +29,7 @@ import (
 	"sync"
 	"time"
 
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	gogotypes "github.com/gogo/protobuf/types"
 	"github.com/golang/protobuf/ptypes"
@@ -109,6 +110,10 @@ type XdsProxy struct {
 	ecdsLastAckVersion    atomic.String
 	ecdsLastNonce         atomic.String
 	downstreamGrpcOptions []grpc.ServerOption
+
+	// configStore persists the configuration accepted by Envoy, to be served while istiod is unreachable.
+	// It is nil if persistence is disabled.
+	configStore *xdsConfigStore
 }
 
 var proxyLog = log.RegisterScope("xdsproxy", "XDS Proxy in Istio Agent", 0)
@@ -124,6 +129,12 @@ func initXdsProxy(ia *Agent) (*XdsProxy, error) {
 	if ia.cfg.IsIPv6 {
 		localHostAddr = localHostIPv6
 	}
+	wasmPolicy, err := wasm.NewPolicy(ia.cfg.WasmAllowedURLPrefixes, ia.cfg.WasmRequireChecksum, ia.cfg.WasmSignaturePublicKey)
+	if err != nil {
+		return nil, err
+	}
+	wasmCache := wasm.NewLocalFileCache(constants.IstioDataDir, wasm.DefaultWasmModulePurgeInteval, wasm.DefaultWasmModuleExpiry)
+	wasmCache.SetPolicy(wasmPolicy)
 	envoyProbe := &ready.Probe{
 		AdminPort:     uint16(ia.proxyConfig.ProxyAdminPort),
 		LocalHostAddr: localHostAddr,
@@ -136,10 +147,15 @@ func initXdsProxy(ia *Agent) (*XdsProxy, error) {
 		healthChecker:         health.NewWorkloadHealthChecker(ia.proxyConfig.ReadinessProbe, envoyProbe, ia.cfg.ProxyIPAddresses, ia.cfg.IsIPv6),
 		xdsHeaders:            ia.cfg.XDSHeaders,
 		xdsUdsPath:            ia.cfg.XdsUdsPath,
-		wasmCache:             wasm.NewLocalFileCache(constants.IstioDataDir, wasm.DefaultWasmModulePurgeInteval, wasm.DefaultWasmModuleExpiry),
+		wasmCache:             wasmCache,
 		proxyAddresses:        ia.cfg.ProxyIPAddresses,
 		downstreamGrpcOptions: ia.cfg.DownstreamGrpcOptions,
 	}
+	if ia.cfg.XDSConfigPersistenceDir != "" {
+		if proxy.configStore, err = newXdsConfigStore(ia.cfg.XDSConfigPersistenceDir, ia.cfg.XDSConfigMaxStaleness); err != nil {
+			return nil, err
+		}
+	}
 
 	if ia.localDNSServer != nil {
 		proxy.handlers[v3.NameTableType] = func(resp *any.Any) error {
@@ -320,6 +336,9 @@ func (p *XdsProxy) StreamAggregatedResources(downstream discovery.AggregatedDisc
 				}
 				return
 			}
+			if p.configStore != nil {
+				p.configStore.onRequest(req)
+			}
 			// forward to istiod
 			con.sendRequest(req)
 			if !initialRequestsSent && req.TypeUrl == v3.ListenerType {
@@ -366,6 +385,14 @@ func (p *XdsProxy) StreamAggregatedResources(downstream discovery.AggregatedDisc
 func (p *XdsProxy) HandleUpstream(ctx context.Context, con *ProxyConnection, xds discovery.AggregatedDiscoveryServiceClient) error {
 	upstream, err := xds.StreamAggregatedResources(ctx,
 		grpc.MaxCallRecvMsgSize(defaultClientMaxReceiveMessageSize))
+	if err != nil && p.configStore != nil && p.configStore.available() {
+		proxyLog.Warnf("failed to connect to upstream XDS server %s, serving persisted config until connected: %v", p.istiodAddress, err)
+		upstream, err = p.serveUntilConnected(ctx, con, xds)
+		if upstream == nil && err == nil {
+			proxyLog.Debugf("stream stopped")
+			return nil
+		}
+	}
 	if err != nil {
 		// Envoy logs errors again, so no need to log beyond debug level
 		proxyLog.Debugf("failed to create upstream grpc client: %v", err)
@@ -428,6 +455,104 @@ func (p *XdsProxy) HandleUpstream(ctx context.Context, con *ProxyConnection, xds
 	}
 }
 
+// serveUntilConnected serves the persisted configuration to Envoy while waiting for the upstream connection.
+// Once connected, the latest request of each type is sent upstream, so istiod pushes the current configuration.
+// A nil stream and error are returned if the stream is stopped before the connection is established.
+func (p *XdsProxy) serveUntilConnected(ctx context.Context, con *ProxyConnection,
+	xds discovery.AggregatedDiscoveryServiceClient) (discovery.AggregatedDiscoveryService_StreamAggregatedResourcesClient, error) {
+	metrics.XdsProxyServingPersistedConfig.Record(1)
+	defer metrics.XdsProxyServingPersistedConfig.Record(0)
+
+	type result struct {
+		upstream discovery.AggregatedDiscoveryService_StreamAggregatedResourcesClient
+		err      error
+	}
+	// The stream context is only canceled if Envoy goes away before the connection is established, as canceling
+	// it terminates the stream.
+	streamCtx, cancel := context.WithCancel(ctx)
+	established := false
+	defer func() {
+		if !established {
+			cancel()
+		}
+	}()
+	connected := make(chan result, 1)
+	go func() {
+		upstream, err := xds.StreamAggregatedResources(streamCtx,
+			grpc.MaxCallRecvMsgSize(defaultClientMaxReceiveMessageSize), grpc.WaitForReady(true))
+		connected <- result{upstream, err}
+	}()
+
+	// Envoy only sends its node on the first request of the stream, which must be sent to istiod again.
+	var node *core.Node
+	var types []string
+	requests := map[string]*discovery.DiscoveryRequest{}
+	for {
+		select {
+		case r := <-connected:
+			if r.err != nil {
+				return nil, r.err
+			}
+			proxyLog.Debugf("resubscribing %d types to upstream XDS server %s", len(types), p.istiodAddress)
+			for i, t := range types {
+				req := requests[t]
+				if i == 0 {
+					req.Node = node
+				}
+				if err := sendUpstreamWithTimeout(ctx, r.upstream, req); err != nil {
+					return nil, err
+				}
+			}
+			established = true
+			return r.upstream, nil
+		case req := <-con.requestsChan:
+			if node == nil {
+				node = req.Node
+			}
+			if _, f := requests[req.TypeUrl]; !f {
+				types = append(types, req.TypeUrl)
+			}
+			// The persisted response does not match the state of istiod, so the subscription is resent
+			// as an initial request.
+			resubscribe := &discovery.DiscoveryRequest{
+				TypeUrl:       req.TypeUrl,
+				ResourceNames: req.ResourceNames,
+			}
+			if req.TypeUrl == v3.HealthInfoType {
+				// Health checks carry the health status in the error detail.
+				resubscribe.ErrorDetail = req.ErrorDetail
+			}
+			requests[req.TypeUrl] = resubscribe
+			if req.ResponseNonce == "" {
+				p.servePersisted(con, req.TypeUrl)
+			}
+		case err := <-con.downstreamError:
+			return nil, err
+		case <-con.stopChan:
+			return nil, nil
+		}
+	}
+}
+
+// servePersisted sends the persisted response of the given type, if any, to Envoy or the agent handler.
+func (p *XdsProxy) servePersisted(con *ProxyConnection, typeURL string) {
+	resp := p.configStore.load(typeURL)
+	if resp == nil {
+		return
+	}
+	proxyLog.Debugf("serving persisted response for type url %s", typeURL)
+	metrics.PersistedResponseServed(v3.GetMetricType(typeURL))
+	if h, f := p.handlers[typeURL]; f {
+		if len(resp.Resources) > 0 {
+			if err := h(resp.Resources[0]); err != nil {
+				proxyLog.Warnf("failed to handle persisted response for type url %s: %v", typeURL, err)
+			}
+		}
+		return
+	}
+	forwardToEnvoy(con, resp)
+}
+
 func (p *XdsProxy) handleUpstreamRequest(ctx context.Context, con *ProxyConnection) {
 	defer con.upstream.CloseSend() // nolint
 	for {
@@ -472,6 +597,10 @@ func (p *XdsProxy) handleUpstreamResponse(con *ProxyConnection) {
 						Code:    int32(codes.Internal),
 						Message: err.Error(),
 					}
+				} else if p.configStore != nil {
+					if err := p.configStore.save(resp); err != nil {
+						proxyLog.Warnf("failed to persist %s config: %v", v3.GetShortType(resp.TypeUrl), err)
+					}
 				}
 				// Send ACK/NACK
 				con.sendRequest(&discovery.DiscoveryRequest{
@@ -489,10 +618,10 @@ func (p *XdsProxy) handleUpstreamResponse(con *ProxyConnection) {
 					go p.rewriteAndForward(con, resp)
 				} else {
 					// Otherwise, forward ECDS resource update directly to Envoy.
-					forwardToEnvoy(con, resp)
+					p.forwardToEnvoy(con, resp)
 				}
 			default:
-				forwardToEnvoy(con, resp)
+				p.forwardToEnvoy(con, resp)
 			}
 		case <-con.stopChan:
 			return
@@ -516,6 +645,14 @@ func (p *XdsProxy) rewriteAndForward(con *ProxyConnection, resp *discovery.Disco
 		return
 	}
 	proxyLog.Debugf("forward ECDS resources %+v", resp.Resources)
+	p.forwardToEnvoy(con, resp)
+}
+
+// forwardToEnvoy forwards the response to Envoy, recording it to be persisted once Envoy ACKs it.
+func (p *XdsProxy) forwardToEnvoy(con *ProxyConnection, resp *discovery.DiscoveryResponse) {
+	if p.configStore != nil {
+		p.configStore.onResponse(resp)
+	}
 	forwardToEnvoy(con, resp)
 }
 
@@ -29,6 +29,7 @@ import (
 	wasm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/wasm/v3"
 	wasmv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/wasm/v3"
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/golang/protobuf/ptypes/any"
 	google_rpc "google.golang.org/genproto/googleapis/rpc/status"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
@@ -50,6 +51,7 @@ import (
 	"istio.io/istio/pkg/test"
 	"istio.io/istio/pkg/test/env"
 	"istio.io/istio/pkg/test/util/retry"
+	wasmcache "istio.io/istio/pkg/wasm"
 )
 
 // TestXdsLeak is a regression test for https://github.com/istio/istio/issues/34097
@@ -389,18 +391,155 @@ func TestXdsProxyReconnects(t *testing.T) {
 	})
 }
 
+// Validates that the config accepted by Envoy is persisted and served while istiod is unreachable.
+func TestXdsProxyPersistedConfig(t *testing.T) {
+	proxy := setupXdsProxy(t)
+	store, err := newXdsConfigStore(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy.configStore = store
+	f := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{})
+
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy.istiodAddress = listener.Addr().String()
+	// Do not block on dial, so the proxy can accept Envoy streams while istiod is down.
+	proxy.istiodDialOptions = []grpc.DialOption{grpc.WithInsecure()}
+	grpcServer := grpc.NewServer()
+	f.Discovery.Register(grpcServer)
+	go grpcServer.Serve(listener)
+
+	node := &core.Node{
+		Id:       "sidecar~1.1.1.1~debug~cluster.local",
+		Metadata: model.NodeMetadata{Namespace: "default", InstanceIPs: []string{"1.1.1.1"}}.ToStruct(),
+	}
+	request := func(downstream discovery.AggregatedDiscoveryService_StreamAggregatedResourcesClient, typeURL string, ack bool) string {
+		t.Helper()
+		if err := downstream.Send(&discovery.DiscoveryRequest{TypeUrl: typeURL, Node: node}); err != nil {
+			t.Fatal(err)
+		}
+		res, err := downstream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.TypeUrl != typeURL {
+			t.Fatalf("expected %v response, got %v", typeURL, res.TypeUrl)
+		}
+		if ack {
+			if err := downstream.Send(&discovery.DiscoveryRequest{
+				TypeUrl: typeURL, VersionInfo: res.VersionInfo, ResponseNonce: res.Nonce,
+			}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return res.Nonce
+	}
+
+	// Envoy accepts config from istiod, which is persisted.
+	conn := setupDownstreamConnection(t, proxy)
+	downstream := stream(t, conn)
+	request(downstream, v3.ClusterType, true)
+	request(downstream, v3.ListenerType, true)
+	retry.UntilSuccessOrFail(t, func() error {
+		if !store.available() {
+			return fmt.Errorf("config not persisted")
+		}
+		return nil
+	}, retry.Timeout(time.Second*5))
+
+	// Istiod goes away, Envoy reconnects and gets the persisted config.
+	grpcServer.Stop()
+	downstream = stream(t, conn)
+	cdsNonce := request(downstream, v3.ClusterType, true)
+	request(downstream, v3.ListenerType, true)
+
+	// Istiod comes back, the proxy resubscribes and Envoy gets the config from istiod.
+	listener, err = net.Listen("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	grpcServer = grpc.NewServer()
+	t.Cleanup(grpcServer.Stop)
+	f.Discovery.Register(grpcServer)
+	go grpcServer.Serve(listener)
+	res, err := downstream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.TypeUrl != v3.ClusterType || res.Nonce == cdsNonce {
+		t.Fatalf("expected a new cluster response from istiod, got %v with nonce %v", res.TypeUrl, res.Nonce)
+	}
+}
+
+// Validates that persisted extension configs are not replayed if their local Wasm module is gone.
+func TestXdsConfigStoreWasmModules(t *testing.T) {
+	dir := t.TempDir()
+	store, err := newXdsConfigStore(filepath.Join(dir, "xds"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	extensionConfig := func(name, filename string) *any.Any {
+		return util.MessageToAny(&core.TypedExtensionConfig{
+			Name: name,
+			TypedConfig: util.MessageToAny(&wasm.Wasm{
+				Config: &wasmv3.PluginConfig{
+					Vm: &wasmv3.PluginConfig_VmConfig{
+						VmConfig: &wasmv3.VmConfig{
+							Code: &core.AsyncDataSource{Specifier: &core.AsyncDataSource_Local{
+								Local: &core.DataSource{
+									Specifier: &core.DataSource_Filename{Filename: filename},
+								},
+							}},
+						},
+					},
+				},
+			}),
+		})
+	}
+	present := filepath.Join(dir, "present.wasm")
+	if err := ioutil.WriteFile(present, []byte("wasm"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.save(&discovery.DiscoveryResponse{
+		TypeUrl: v3.ExtensionConfigurationType,
+		Resources: []*any.Any{
+			extensionConfig("present", present),
+			extensionConfig("missing", filepath.Join(dir, "missing.wasm")),
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := store.load(v3.ExtensionConfigurationType)
+	if resp == nil {
+		t.Fatal("expected persisted extension configs")
+	}
+	if len(resp.Resources) != 1 || localWasmModule(resp.Resources[0]) != present {
+		t.Fatalf("expected only the extension config with an available module, got %v", resp.Resources)
+	}
+}
+
 type fakeAckCache struct{}
 
-func (f *fakeAckCache) Get(string, string, time.Duration) (string, error) {
+func (f *fakeAckCache) Get(string, string, time.Duration, []byte) (string, error) {
 	return "test", nil
 }
+func (f *fakeAckCache) Policy() *wasmcache.Policy {
+	return nil
+}
 func (f *fakeAckCache) Cleanup() {}
 
 type fakeNackCache struct{}
 
-func (f *fakeNackCache) Get(string, string, time.Duration) (string, error) {
+func (f *fakeNackCache) Get(string, string, time.Duration, []byte) (string, error) {
 	return "", errors.New("errror")
 }
+func (f *fakeNackCache) Policy() *wasmcache.Policy {
+	return nil
+}
 func (f *fakeNackCache) Cleanup() {}
 
 func TestECDSWasmConversion(t *testing.T) {
@@ -18,14 +18,17 @@ package inject
 
 import (
 	"encoding/json"
+	"fmt"
 	"strconv"
 
 	"github.com/gogo/protobuf/types"
+	"gomodules.xyz/jsonpatch/v3"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 
 	"istio.io/api/annotation"
 	"istio.io/istio/pilot/cmd/pilot-agent/status"
+	"istio.io/istio/pkg/kube/apimirror"
 	"istio.io/pkg/log"
 )
 
@@ -60,8 +63,20 @@ func FindContainer(name string, containers []corev1.Container) *corev1.Container
 }
 
 // convertAppProber returns an overwritten `Probe` for pilot agent to take over.
-func convertAppProber(probe *corev1.Probe, newURL string, statusPort int) *corev1.Probe {
-	if probe == nil || probe.HTTPGet == nil {
+func convertAppProber(probe *corev1.Probe, grpc *apimirror.GRPCAction, newURL string, statusPort int) *corev1.Probe {
+	if probe == nil {
+		return nil
+	}
+	if grpc != nil {
+		// The gRPC probe itself is removed from the pod by grpcProbeRemovals.
+		p := probe.DeepCopy()
+		p.HTTPGet = &corev1.HTTPGetAction{
+			Path: newURL,
+			Port: intstr.FromInt(statusPort),
+		}
+		return p
+	}
+	if probe.HTTPGet == nil {
 		return nil
 	}
 	p := probe.DeepCopy()
@@ -81,15 +96,76 @@ type KubeAppProbers map[string]*Prober
 
 // Prober represents a single container prober
 type Prober struct {
-	HTTPGet        *corev1.HTTPGetAction `json:"httpGet"`
+	HTTPGet        *corev1.HTTPGetAction `json:"httpGet,omitempty"`
+	GRPC           *apimirror.GRPCAction `json:"grpc,omitempty"`
 	TimeoutSeconds int32                 `json:"timeoutSeconds,omitempty"`
 }
 
+// grpcProbes holds the gRPC probes of the containers of a pod, keyed by container name.
+// gRPC probes were added in Kubernetes 1.23 and are not part of the Kubernetes API we build against,
+// so they are lost when decoding the pod; they are decoded separately from the raw pod instead.
+type grpcProbes map[string]*containerGRPCProbes
+
+type containerGRPCProbes struct {
+	ReadinessProbe *grpcProbe `json:"readinessProbe,omitempty"`
+	LivenessProbe  *grpcProbe `json:"livenessProbe,omitempty"`
+	StartupProbe   *grpcProbe `json:"startupProbe,omitempty"`
+}
+
+type grpcProbe struct {
+	GRPC *apimirror.GRPCAction `json:"grpc,omitempty"`
+}
+
+func (p *grpcProbe) action() *apimirror.GRPCAction {
+	if p == nil {
+		return nil
+	}
+	return p.GRPC
+}
+
+// grpcProbesFromRawPod returns the gRPC probes of the containers of the raw pod.
+func grpcProbesFromRawPod(raw []byte) grpcProbes {
+	var pod struct {
+		Spec struct {
+			Containers []struct {
+				Name string `json:"name"`
+				containerGRPCProbes
+			} `json:"containers"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(raw, &pod); err != nil {
+		return nil
+	}
+	out := grpcProbes{}
+	for _, c := range pod.Spec.Containers {
+		c := c
+		if c.ReadinessProbe.action() != nil || c.LivenessProbe.action() != nil || c.StartupProbe.action() != nil {
+			out[c.Name] = &c.containerGRPCProbes
+		}
+	}
+	return out
+}
+
+// get returns the gRPC probes of the container, or an empty set if it has none.
+func (g grpcProbes) get(container string) *containerGRPCProbes {
+	if p, f := g[container]; f {
+		return p
+	}
+	return &containerGRPCProbes{}
+}
+
 // DumpAppProbers returns a json encoded string as `status.KubeAppProbers`.
 // Also update the probers so that all usages of named port will be resolved to integer.
 func DumpAppProbers(podspec *corev1.PodSpec, targetPort int32) string {
+	return dumpAppProbers(podspec, nil, targetPort)
+}
+
+func dumpAppProbers(podspec *corev1.PodSpec, grpc grpcProbes, targetPort int32) string {
 	out := KubeAppProbers{}
 	updateNamedPort := func(p *Prober, portMap map[string]int32) *Prober {
+		if p != nil && p.GRPC != nil {
+			return p
+		}
 		if p == nil || p.HTTPGet == nil {
 			return nil
 		}
@@ -116,13 +192,14 @@ func DumpAppProbers(podspec *corev1.PodSpec, targetPort int32) string {
 				portMap[p.Name] = p.ContainerPort
 			}
 		}
-		if h := updateNamedPort(kubeProbeToInternalProber(c.ReadinessProbe), portMap); h != nil {
+		g := grpc.get(c.Name)
+		if h := updateNamedPort(kubeProbeToInternalProber(c.ReadinessProbe, g.ReadinessProbe.action()), portMap); h != nil {
 			out[readyz] = h
 		}
-		if h := updateNamedPort(kubeProbeToInternalProber(c.LivenessProbe), portMap); h != nil {
+		if h := updateNamedPort(kubeProbeToInternalProber(c.LivenessProbe, g.LivenessProbe.action()), portMap); h != nil {
 			out[livez] = h
 		}
-		if h := updateNamedPort(kubeProbeToInternalProber(c.StartupProbe), portMap); h != nil {
+		if h := updateNamedPort(kubeProbeToInternalProber(c.StartupProbe, g.StartupProbe.action()), portMap); h != nil {
 			out[startupz] = h
 		}
 
@@ -140,7 +217,7 @@ func DumpAppProbers(podspec *corev1.PodSpec, targetPort int32) string {
 }
 
 // patchRewriteProbe generates the patch for webhook.
-func patchRewriteProbe(annotations map[string]string, pod *corev1.Pod, defaultPort int32) {
+func patchRewriteProbe(annotations map[string]string, pod *corev1.Pod, grpc grpcProbes, defaultPort int32) {
 	sidecar := FindSidecar(pod.Spec.Containers)
 	if sidecar == nil {
 		return
@@ -163,13 +240,14 @@ func patchRewriteProbe(annotations map[string]string, pod *corev1.Pod, defaultPo
 			portMap[p.Name] = p.ContainerPort
 		}
 		readyz, livez, startupz := status.FormatProberURL(c.Name)
-		if probePatch := convertAppProber(c.ReadinessProbe, readyz, statusPort); probePatch != nil {
+		g := grpc.get(c.Name)
+		if probePatch := convertAppProber(c.ReadinessProbe, g.ReadinessProbe.action(), readyz, statusPort); probePatch != nil {
 			c.ReadinessProbe = probePatch
 		}
-		if probePatch := convertAppProber(c.LivenessProbe, livez, statusPort); probePatch != nil {
+		if probePatch := convertAppProber(c.LivenessProbe, g.LivenessProbe.action(), livez, statusPort); probePatch != nil {
 			c.LivenessProbe = probePatch
 		}
-		if probePatch := convertAppProber(c.StartupProbe, startupz, statusPort); probePatch != nil {
+		if probePatch := convertAppProber(c.StartupProbe, g.StartupProbe.action(), startupz, statusPort); probePatch != nil {
 			c.StartupProbe = probePatch
 		}
 		pod.Spec.Containers[i] = c
@@ -177,11 +255,18 @@ func patchRewriteProbe(annotations map[string]string, pod *corev1.Pod, defaultPo
 }
 
 // kubeProbeToInternalProber converts a Kubernetes Probe to an Istio internal Prober
-func kubeProbeToInternalProber(probe *corev1.Probe) *Prober {
+func kubeProbeToInternalProber(probe *corev1.Probe, grpc *apimirror.GRPCAction) *Prober {
 	if probe == nil {
 		return nil
 	}
 
+	if grpc != nil {
+		return &Prober{
+			GRPC:           grpc,
+			TimeoutSeconds: probe.TimeoutSeconds,
+		}
+	}
+
 	if probe.HTTPGet == nil {
 		return nil
 	}
@@ -191,3 +276,35 @@ func kubeProbeToInternalProber(probe *corev1.Probe) *Prober {
 		TimeoutSeconds: probe.TimeoutSeconds,
 	}
 }
+
+// grpcProbeRemovals returns the patch operations removing the gRPC probes of the original pod that were
+// rewritten to be taken over by the pilot agent. These are not part of the patch created from the
+// decoded pods, as they are not part of the Kubernetes API we build against.
+func grpcProbeRemovals(original *corev1.Pod, rewritten *corev1.Pod, grpc grpcProbes) []jsonpatch.Operation {
+	var ops []jsonpatch.Operation
+	for i, c := range original.Spec.Containers {
+		g, f := grpc[c.Name]
+		if !f {
+			continue
+		}
+		r := FindContainer(c.Name, rewritten.Spec.Containers)
+		if r == nil {
+			continue
+		}
+		for _, probe := range []struct {
+			field     string
+			grpc      *grpcProbe
+			rewritten *corev1.Probe
+		}{
+			{"readinessProbe", g.ReadinessProbe, r.ReadinessProbe},
+			{"livenessProbe", g.LivenessProbe, r.LivenessProbe},
+			{"startupProbe", g.StartupProbe, r.StartupProbe},
+		} {
+			if probe.grpc.action() == nil || probe.rewritten == nil || probe.rewritten.HTTPGet == nil {
+				continue
+			}
+			ops = append(ops, jsonpatch.NewOperation("remove", fmt.Sprintf("/spec/containers/%d/%s/grpc", i, probe.field), nil))
+		}
+	}
+	return ops
+}
@@ -14,8 +14,10 @@
 package inject
 
 import (
+	"encoding/json"
 	"testing"
 
+	jsonpatch "github.com/evanphx/json-patch"
 	"github.com/gogo/protobuf/types"
 	corev1 "k8s.io/api/core/v1"
 
@@ -110,3 +112,66 @@ func TestShouldRewriteAppHTTPProbers(t *testing.T) {
 		}
 	}
 }
+
+func TestRewriteGRPCProbes(t *testing.T) {
+	raw := []byte(`{"spec":{"containers":[
+{"name":"app","readinessProbe":{"grpc":{"port":9000,"service":"ready"},"periodSeconds":5},
+ "livenessProbe":{"httpGet":{"path":"/live","port":8080}}},
+{"name":"istio-proxy"}]}}`)
+	grpc := grpcProbesFromRawPod(raw)
+
+	pod := &corev1.Pod{}
+	if err := json.Unmarshal(raw, pod); err != nil {
+		t.Fatal(err)
+	}
+	original, err := json.Marshal(pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	probers := KubeAppProbers{}
+	if err := json.Unmarshal([]byte(dumpAppProbers(&pod.Spec, grpc, 15020)), &probers); err != nil {
+		t.Fatal(err)
+	}
+	ready := probers["/app-health/app/readyz"]
+	if ready == nil || ready.GRPC == nil || ready.GRPC.Port != 9000 || *ready.GRPC.Service != "ready" {
+		t.Fatalf("expected gRPC readiness prober, got %+v", ready)
+	}
+	if live := probers["/app-health/app/livez"]; live == nil || live.HTTPGet == nil || live.HTTPGet.Path != "/live" {
+		t.Fatalf("expected HTTP liveness prober, got %+v", live)
+	}
+
+	rewritten := pod.DeepCopy()
+	patchRewriteProbe(nil, rewritten, grpc, 15020)
+	patch, err := createPatch(rewritten, original, grpcProbeRemovals(pod, rewritten, grpc)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := jsonpatch.DecodePatch(patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	patched, err := p.Apply(raw)
+	if err != nil {
+		t.Fatalf("failed to apply patch %s: %v", patch, err)
+	}
+
+	var got struct {
+		Spec struct {
+			Containers []struct {
+				ReadinessProbe map[string]interface{} `json:"readinessProbe"`
+			} `json:"containers"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(patched, &got); err != nil {
+		t.Fatal(err)
+	}
+	probe := got.Spec.Containers[0].ReadinessProbe
+	if _, f := probe["grpc"]; f {
+		t.Errorf("expected the gRPC probe to be removed, got %v", probe)
+	}
+	httpGet, _ := probe["httpGet"].(map[string]interface{})
+	if httpGet["path"] != "/app-health/app/readyz" || httpGet["port"] != float64(15020) || probe["periodSeconds"] != float64(5) {
+		t.Errorf("expected the probe to be rewritten to the agent, got %v", probe)
+	}
+}
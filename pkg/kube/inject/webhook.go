@@ -283,6 +283,8 @@ type InjectionParameters struct {
 	revision            string
 	proxyEnvs           map[string]string
 	injectedAnnotations map[string]string
+	// grpcProbes are the gRPC probes of the pod, which are not part of the decoded pod.
+	grpcProbes grpcProbes
 }
 
 func checkPreconditions(params InjectionParameters) {
@@ -362,7 +364,7 @@ func injectPod(req InjectionParameters) ([]byte, error) {
 		return nil, fmt.Errorf("failed to process pod: %v", err)
 	}
 
-	patch, err := createPatch(mergedPod, originalPodSpec)
+	patch, err := createPatch(mergedPod, originalPodSpec, grpcProbeRemovals(req.pod, mergedPod, req.grpcProbes)...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create patch: %v", err)
 	}
@@ -499,7 +501,9 @@ func reinsertOverrides(pod *corev1.Pod) (*corev1.Pod, error) {
 	return pod, nil
 }
 
-func createPatch(pod *corev1.Pod, original []byte) ([]byte, error) {
+// createPatch creates the patch from the original pod to the injected pod. The leading operations, if any,
+// are applied before the created ones.
+func createPatch(pod *corev1.Pod, original []byte, leading ...jsonpatch.Operation) ([]byte, error) {
 	reinjected, err := json.Marshal(pod)
 	if err != nil {
 		return nil, err
@@ -508,7 +512,7 @@ func createPatch(pod *corev1.Pod, original []byte) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	return json.Marshal(p)
+	return json.Marshal(append(leading, p...))
 }
 
 // postProcessPod applies additionally transformations to the pod after merging with the injected template
@@ -603,10 +607,10 @@ func applyRewrite(pod *corev1.Pod, req InjectionParameters) error {
 
 	// We don't have to escape json encoding here when using golang libraries.
 	if rewrite && sidecar != nil {
-		if prober := DumpAppProbers(&pod.Spec, req.meshConfig.GetDefaultConfig().GetStatusPort()); prober != "" {
+		if prober := dumpAppProbers(&pod.Spec, req.grpcProbes, req.meshConfig.GetDefaultConfig().GetStatusPort()); prober != "" {
 			sidecar.Env = append(sidecar.Env, corev1.EnvVar{Name: status.KubeAppProberEnvName, Value: prober})
 		}
-		patchRewriteProbe(pod.Annotations, pod, req.meshConfig.GetDefaultConfig().GetStatusPort())
+		patchRewriteProbe(pod.Annotations, pod, req.grpcProbes, req.meshConfig.GetDefaultConfig().GetStatusPort())
 	}
 	return nil
 }
@@ -744,6 +748,7 @@ func (wh *Webhook) inject(ar *kube.AdmissionReview, path string) *kube.Admission
 		revision:            wh.revision,
 		injectedAnnotations: wh.Config.InjectedAnnotations,
 		proxyEnvs:           parseInjectEnvs(path),
+		grpcProbes:          grpcProbesFromRawPod(req.Object.Raw),
 	}
 	wh.mu.RUnlock()
 
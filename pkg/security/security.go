@@ -16,6 +16,7 @@ package security
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -104,6 +105,10 @@ type Options struct {
 	// OutputKeyCertToDir is the directory for output the key and certificate
 	OutputKeyCertToDir string
 
+	// CRLFilePath is the location of the CRL of the CA. If the file exists, it is added to the
+	// validation context of the workload trust anchor, and proxies reject the revoked peer certificates.
+	CRLFilePath string
+
 	// ProvCert is the directory for client to provide the key and certificate to CA server when authenticating
 	// with mTLS. This is not used for workload mTLS communication, and is
 	ProvCert string
@@ -240,6 +245,9 @@ type SecretItem struct {
 
 	RootCert []byte
 
+	// CRL is the PEM encoded CRL of the CA, distributed with the root cert.
+	CRL []byte
+
 	// ResourceName passed from envoy SDS discovery request.
 	// "ROOTCA" for root cert request, "default" for key/cert request.
 	ResourceName string
@@ -285,19 +293,43 @@ type Caller struct {
 }
 
 type Authenticator interface {
+	// Authenticate returns the caller of the request. If the request does not carry the credentials
+	// the authenticator supports, the error is a NotApplicableError.
 	Authenticate(ctx context.Context) (*Caller, error)
 	AuthenticatorType() string
 }
 
+// NotApplicableError is returned, possibly wrapped, by an Authenticator when the request does not carry
+// the credentials it supports, as opposed to credentials it rejected.
+type NotApplicableError struct {
+	msg string
+}
+
+func (e *NotApplicableError) Error() string {
+	return e.msg
+}
+
+// NewNotApplicableError returns a NotApplicableError with the formatted message.
+func NewNotApplicableError(format string, args ...interface{}) error {
+	return &NotApplicableError{msg: fmt.Sprintf(format, args...)}
+}
+
+// IsNotApplicable returns whether err reports that the authenticator does not apply to the request.
+func IsNotApplicable(err error) bool {
+	var e *NotApplicableError
+	return errors.As(err, &e)
+}
+
+// ExtractBearerToken returns the bearer token of the request. A NotApplicableError is returned if there is none.
 func ExtractBearerToken(ctx context.Context) (string, error) {
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
-		return "", fmt.Errorf("no metadata is attached")
+		return "", NewNotApplicableError("no metadata is attached")
 	}
 
 	authHeader, exists := md[authorizationMeta]
 	if !exists {
-		return "", fmt.Errorf("no HTTP authorization header exists")
+		return "", NewNotApplicableError("no HTTP authorization header exists")
 	}
 
 	for _, value := range authHeader {
@@ -306,5 +338,5 @@ func ExtractBearerToken(ctx context.Context) (string, error) {
 		}
 	}
 
-	return "", fmt.Errorf("no bearer token exists in HTTP authorization header")
+	return "", NewNotApplicableError("no bearer token exists in HTTP authorization header")
 }
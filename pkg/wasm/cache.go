@@ -22,6 +22,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -36,11 +37,16 @@ const (
 
 	// DefaultWasmModuleExpiry is the default duration for least recently touched Wasm module to become stale.
 	DefaultWasmModuleExpiry = 24 * time.Hour
+
+	// OCIScheme is the URL scheme of the Wasm modules fetched from an OCI registry.
+	OCIScheme = "oci://"
 )
 
 // Cache models a Wasm module cache.
 type Cache interface {
-	Get(url, checksum string, timeout time.Duration) (string, error)
+	Get(url, checksum string, timeout time.Duration, pullSecret []byte) (string, error)
+	// Policy returns the policy enforced on Wasm modules, or nil.
+	Policy() *Policy
 	Cleanup()
 }
 
@@ -62,6 +68,9 @@ type LocalFileCache struct {
 	purgeInterval    time.Duration
 	wasmModuleExpiry time.Duration
 
+	// policy restricts which Wasm modules may be fetched. If nil, all modules are allowed.
+	policy *Policy
+
 	// stopChan currently is only used by test
 	stopChan chan struct{}
 }
@@ -98,25 +107,41 @@ func NewLocalFileCache(dir string, purgeInterval, moduleExpiry time.Duration) *L
 	return cache
 }
 
+// SetPolicy sets the policy enforced on Wasm modules. It must be called before the cache is used.
+func (c *LocalFileCache) SetPolicy(p *Policy) {
+	c.policy = p
+}
+
+// Policy returns the policy enforced on Wasm modules, or nil.
+func (c *LocalFileCache) Policy() *Policy {
+	return c.policy
+}
+
 // Get returns path the local Wasm module file.
-func (c *LocalFileCache) Get(downloadURL, checksum string, timeout time.Duration) (string, error) {
+// For oci:// URLs, checksum is the digest of the image rather than the checksum of the module, and
+// pullSecret, if not empty, is used to authenticate with the registry.
+func (c *LocalFileCache) Get(downloadURL, checksum string, timeout time.Duration, pullSecret []byte) (string, error) {
 	url, err := url.Parse(downloadURL)
 	if err != nil {
 		return "", fmt.Errorf("fail to parse Wasm module fetch url: %s", downloadURL)
 	}
+	if err := c.policy.Allow(downloadURL, checksum); err != nil {
+		wasmPolicyRejectionCount.With(reasonTag.Value(err.(*PolicyError).Reason)).Increment()
+		return "", err
+	}
 	// Construct Wasm cache key with downloading URL and provided checksum of the module.
 	key := cacheKey{
 		downloadURL: downloadURL,
 		checksum:    checksum,
 	}
 
+	// First check if the cache entry is already downloaded.
+	if modulePath := c.getEntry(key); modulePath != "" {
+		return modulePath, nil
+	}
+
 	switch url.Scheme {
 	case "http", "https":
-		// First check if the cache entry is already downloaded.
-		if modulePath := c.getEntry(key); modulePath != "" {
-			return modulePath, nil
-		}
-
 		// If the module is not available locally, download the Wasm module with http fetcher.
 		b, err := c.httpFetcher.Fetch(downloadURL, timeout)
 		if err != nil {
@@ -142,6 +167,42 @@ func (c *LocalFileCache) Get(downloadURL, checksum string, timeout time.Duration
 			return "", err
 		}
 
+		return f, nil
+	case "oci":
+		fetcher, err := NewImageFetcher(ImageFetcherOption{PullSecret: pullSecret, Timeout: timeout})
+		if err != nil {
+			wasmRemoteFetchCount.With(resultTag.Value(downloadFailure)).Increment()
+			return "", err
+		}
+		ref := strings.TrimPrefix(downloadURL, OCIScheme)
+		b, imageDigest, err := fetcher.Fetch(ref)
+		if err != nil {
+			wasmRemoteFetchCount.With(resultTag.Value(downloadFailure)).Increment()
+			return "", err
+		}
+		if c.policy != nil && c.policy.PublicKey != nil {
+			if err := fetcher.verifySignature(ref, imageDigest, c.policy.PublicKey); err != nil {
+				wasmPolicyRejectionCount.With(reasonTag.Value(signatureInvalid)).Increment()
+				return "", newPolicyError(signatureInvalid, "Wasm module %v is rejected by the policy: %v", downloadURL, err)
+			}
+		}
+
+		// The module is cached by the image digest.
+		dChecksum := strings.TrimPrefix(imageDigest, "sha256:")
+		if checksum != "" && dChecksum != strings.TrimPrefix(checksum, "sha256:") {
+			wasmRemoteFetchCount.With(resultTag.Value(checksumMismatch)).Increment()
+			return "", fmt.Errorf("image %v has digest %v, which does not match: %v", downloadURL, dChecksum, checksum)
+		}
+
+		wasmRemoteFetchCount.With(resultTag.Value(fetchSuccess)).Increment()
+
+		key.checksum = dChecksum
+		f := filepath.Join(c.dir, fmt.Sprintf("%s.wasm", dChecksum))
+
+		if err := c.addEntry(key, b, f); err != nil {
+			return "", err
+		}
+
 		return f, nil
 	default:
 		return "", fmt.Errorf("unsupported Wasm module downloading URL scheme: %v", url.Scheme)
@@ -78,12 +78,12 @@ func TestWasmCache(t *testing.T) {
 		{
 			name:                 "invalid scheme",
 			initialCachedModules: map[cacheKey]cacheEntry{},
-			fetchURL:             "oci://abc",
+			fetchURL:             "ftp://abc",
 			purgeInterval:        DefaultWasmModulePurgeInteval,
 			wasmModuleExpiry:     DefaultWasmModuleExpiry,
 			checksum:             dataCheckSum,
 			wantFileName:         fmt.Sprintf("%x.wasm", dataCheckSum),
-			wantErrorMsgPrefix:   "unsupported Wasm module downloading URL scheme: oci",
+			wantErrorMsgPrefix:   "unsupported Wasm module downloading URL scheme: ftp",
 			wantServerReqNum:     0,
 		},
 		{
@@ -168,7 +168,7 @@ func TestWasmCache(t *testing.T) {
 				}
 			}
 
-			gotFilePath, gotErr := cache.Get(c.fetchURL, fmt.Sprintf("%x", c.checksum), 0)
+			gotFilePath, gotErr := cache.Get(c.fetchURL, fmt.Sprintf("%x", c.checksum), 0, nil)
 			wantFilePath := filepath.Join(tmpDir, c.wantFileName)
 			if c.wantErrorMsgPrefix != "" {
 				if gotErr == nil {
@@ -210,7 +210,7 @@ func TestWasmCacheMissChecksum(t *testing.T) {
 
 	// Get wasm module three times, since checksum is not specified, it will be fetched from module server every time.
 	// 1st time
-	gotFilePath, err := cache.Get(ts.URL, "", 0)
+	gotFilePath, err := cache.Get(ts.URL, "", 0, nil)
 	if err != nil {
 		t.Fatalf("failed to download Wasm module: %v", err)
 	}
@@ -219,7 +219,7 @@ func TestWasmCacheMissChecksum(t *testing.T) {
 	}
 
 	// 2nd time
-	gotFilePath, err = cache.Get(ts.URL, "", 0)
+	gotFilePath, err = cache.Get(ts.URL, "", 0, nil)
 	if err != nil {
 		t.Fatalf("failed to download Wasm module: %v", err)
 	}
@@ -228,7 +228,7 @@ func TestWasmCacheMissChecksum(t *testing.T) {
 	}
 
 	// 3rd time
-	gotFilePath, err = cache.Get(ts.URL, "", 0)
+	gotFilePath, err = cache.Get(ts.URL, "", 0, nil)
 	if err != nil {
 		t.Fatalf("failed to download Wasm module: %v", err)
 	}
@@ -241,3 +241,44 @@ func TestWasmCacheMissChecksum(t *testing.T) {
 		t.Errorf("wasm download call got %v want %v", gotNumRequest, wantNumRequest)
 	}
 }
+
+func TestWasmCacheOCI(t *testing.T) {
+	tmpDir := t.TempDir()
+	cache := NewLocalFileCache(tmpDir, DefaultWasmModulePurgeInteval, DefaultWasmModuleExpiry)
+	defer close(cache.stopChan)
+
+	reg := newTestRegistry(t)
+	digest := reg.push("wasm/plugin", "v1", wasmConfigMediaType, map[string][]byte{wasmLayerMediaType: []byte("module")})
+	fetchURL := "oci://" + reg.host() + "/wasm/plugin:v1"
+	checksum := strings.TrimPrefix(digest, "sha256:")
+	wantFilePath := filepath.Join(tmpDir, fmt.Sprintf("%s.wasm", checksum))
+
+	// 1st time, the image is pulled from the registry.
+	gotFilePath, err := cache.Get(fetchURL, checksum, 0, nil)
+	if err != nil {
+		t.Fatalf("failed to download Wasm module: %v", err)
+	}
+	if gotFilePath != wantFilePath {
+		t.Errorf("wasm download path got %v want %v", gotFilePath, wantFilePath)
+	}
+	requests := reg.requests
+
+	// 2nd time, the module is served from the cache.
+	gotFilePath, err = cache.Get(fetchURL, checksum, 0, nil)
+	if err != nil {
+		t.Fatalf("failed to download Wasm module: %v", err)
+	}
+	if gotFilePath != wantFilePath {
+		t.Errorf("wasm download path got %v want %v", gotFilePath, wantFilePath)
+	}
+	if reg.requests != requests {
+		t.Errorf("registry request number got %v want %v", reg.requests, requests)
+	}
+
+	// Digest mismatch.
+	wrongChecksum := fmt.Sprintf("%x", sha256.Sum256([]byte("wrong")))
+	if _, err := cache.Get(fetchURL, wrongChecksum, 0, nil); err == nil ||
+		!strings.Contains(err.Error(), "which does not match") {
+		t.Errorf("got error %v, want digest mismatch", err)
+	}
+}
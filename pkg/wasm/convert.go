@@ -33,6 +33,10 @@ const (
 	apiTypePrefix      = "type.googleapis.com/"
 	typedStructType    = apiTypePrefix + "udpa.type.v1.TypedStruct"
 	wasmHTTPFilterType = apiTypePrefix + "envoy.extensions.filters.http.wasm.v3.Wasm"
+
+	// WasmSecretEnv is the Wasm VM environment variable istiod uses to forward the image pull secret
+	// of an oci:// module to the agent. The agent removes it before handing the config to Envoy.
+	WasmSecretEnv = "ISTIO_META_WASM_IMAGE_PULL_SECRET"
 )
 
 // MaybeConvertWasmExtensionConfig converts any presence of module remote download to local file.
@@ -108,29 +112,56 @@ func convert(resource *any.Any, cache Cache) (newExtensionConfig *any.Any, sendN
 		return
 	}
 
-	// Currently Wasm filter can only be configured using typed struct via EnvoyFilter.
+	// The Wasm filter is configured either directly, or using typed struct via EnvoyFilter.
 	wasmLog.Debugf("original extension config resource %+v", ec)
-	if ec.GetTypedConfig() == nil && ec.GetTypedConfig().TypeUrl != typedStructType {
-		wasmLog.Debugf("cannot find typed struct in %+v", ec)
-		return
-	}
-	wasmStruct := &udpa.TypedStruct{}
-	wasmTypedConfig := ec.GetTypedConfig()
-	// nolint: staticcheck
-	if err := ptypes.UnmarshalAny(wasmTypedConfig, wasmStruct); err != nil {
-		wasmLog.Debugf("failed to unmarshal typed config for wasm filter: %v", err)
-		return
-	}
-
-	if wasmStruct.TypeUrl != wasmHTTPFilterType {
-		wasmLog.Debugf("typed extension config %+v does not contain wasm http filter", wasmStruct)
+	wasmHTTPFilterConfig := &wasm.Wasm{}
+	switch ec.GetTypedConfig().GetTypeUrl() {
+	case wasmHTTPFilterType:
+		if err := ec.GetTypedConfig().UnmarshalTo(wasmHTTPFilterConfig); err != nil {
+			wasmLog.Debugf("failed to unmarshal typed config for wasm filter: %v", err)
+			return
+		}
+	case typedStructType:
+		wasmStruct := &udpa.TypedStruct{}
+		// nolint: staticcheck
+		if err := ptypes.UnmarshalAny(ec.GetTypedConfig(), wasmStruct); err != nil {
+			wasmLog.Debugf("failed to unmarshal typed config for wasm filter: %v", err)
+			return
+		}
+		if wasmStruct.TypeUrl != wasmHTTPFilterType {
+			wasmLog.Debugf("typed extension config %+v does not contain wasm http filter", wasmStruct)
+			return
+		}
+		if err := conversion.StructToMessage(wasmStruct.Value, wasmHTTPFilterConfig); err != nil {
+			wasmLog.Debugf("failed to convert extension config struct %+v to Wasm HTTP filter", wasmStruct)
+			return
+		}
+	default:
+		wasmLog.Debugf("cannot find wasm http filter in %+v", ec)
 		return
 	}
 
-	wasmHTTPFilterConfig := &wasm.Wasm{}
-	if err := conversion.StructToMessage(wasmStruct.Value, wasmHTTPFilterConfig); err != nil {
-		wasmLog.Debugf("failed to convert extension config struct %+v to Wasm HTTP filter", wasmStruct)
-		return
+	// The image pull secret is only for the agent. Strip it from the config forwarded to Envoy, whatever
+	// the outcome of the conversion, so it is never readable by the Wasm module.
+	var pullSecret []byte
+	if envs := wasmHTTPFilterConfig.Config.GetVmConfig().GetEnvironmentVariables(); envs != nil {
+		if secret, ok := envs.KeyValues[WasmSecretEnv]; ok {
+			pullSecret = []byte(secret)
+			delete(envs.KeyValues, WasmSecretEnv)
+			defer func() {
+				if newExtensionConfig != resource {
+					return
+				}
+				stripped, err := marshalExtensionConfig(ec, wasmHTTPFilterConfig)
+				if err != nil {
+					status = marshalFailure
+					wasmLog.Errorf("failed to remove the image pull secret from the extension config resource: %v", err)
+					sendNack = true
+					return
+				}
+				newExtensionConfig = stripped
+			}()
+		}
 	}
 
 	if wasmHTTPFilterConfig.Config.GetVmConfig().GetCode().GetRemote() == nil {
@@ -139,8 +170,9 @@ func convert(resource *any.Any, cache Cache) (newExtensionConfig *any.Any, sendN
 	}
 
 	// Wasm plugin configuration has remote load. From this point, any failure should result as a Nack,
-	// unless the plugin is marked as fail open.
-	failOpen := wasmHTTPFilterConfig.Config.GetFailOpen()
+	// unless the plugin is marked as fail open. With a policy, Envoy must never fetch a module the agent
+	// did not check, so failures are always a Nack.
+	failOpen := wasmHTTPFilterConfig.Config.GetFailOpen() && !cache.Policy().Enabled()
 	sendNack = !failOpen
 	status = conversionSuccess
 
@@ -156,9 +188,15 @@ func convert(resource *any.Any, cache Cache) (newExtensionConfig *any.Any, sendN
 	if remote.GetHttpUri().Timeout != nil {
 		timeout = remote.GetHttpUri().Timeout.AsDuration()
 	}
-	f, err := cache.Get(httpURI.GetUri(), remote.GetSha256(), timeout)
+	f, err := cache.Get(httpURI.GetUri(), remote.GetSha256(), timeout, pullSecret)
 	if err != nil {
 		status = fetchFailure
+		if _, ok := err.(*PolicyError); ok {
+			// Always NACK modules rejected by the policy, even if fail open, since otherwise
+			// Envoy would fetch the module itself.
+			status = policyRejected
+			sendNack = true
+		}
 		wasmLog.Errorf("cannot fetch Wasm module %v: %v", remote.GetHttpUri().GetUri(), err)
 		return
 	}
@@ -174,21 +212,13 @@ func convert(resource *any.Any, cache Cache) (newExtensionConfig *any.Any, sendN
 		},
 	}
 
-	wasmTypedConfig, err = anypb.New(wasmHTTPFilterConfig)
-	if err != nil {
-		status = marshalFailure
-		wasmLog.Errorf("failed to marshal new wasm HTTP filter %+v to protobuf Any: %v", wasmHTTPFilterConfig, err)
-		return
-	}
-	ec.TypedConfig = wasmTypedConfig
-	wasmLog.Debugf("new extension config resource %+v", ec)
-
-	nec, err := anypb.New(ec)
+	nec, err := marshalExtensionConfig(ec, wasmHTTPFilterConfig)
 	if err != nil {
 		status = marshalFailure
 		wasmLog.Errorf("failed to marshal new extension config resource: %v", err)
 		return
 	}
+	wasmLog.Debugf("new extension config resource %+v", ec)
 
 	// At this point, we are certain that wasm module has been downloaded and config is rewritten.
 	// ECDS has been rewritten successfully and should not nack.
@@ -196,3 +226,13 @@ func convert(resource *any.Any, cache Cache) (newExtensionConfig *any.Any, sendN
 	sendNack = false
 	return
 }
+
+// marshalExtensionConfig sets the Wasm HTTP filter config as the typed config of ec, and marshals ec.
+func marshalExtensionConfig(ec *core.TypedExtensionConfig, wasmHTTPFilterConfig *wasm.Wasm) (*any.Any, error) {
+	wasmTypedConfig, err := anypb.New(wasmHTTPFilterConfig)
+	if err != nil {
+		return nil, err
+	}
+	ec.TypedConfig = wasmTypedConfig
+	return anypb.New(ec)
+}
@@ -32,9 +32,11 @@ import (
 	"istio.io/istio/pilot/pkg/networking/util"
 )
 
-type mockCache struct{}
+type mockCache struct {
+	policy *Policy
+}
 
-func (c *mockCache) Get(downloadURL, checksum string, timeout time.Duration) (string, error) {
+func (c *mockCache) Get(downloadURL, checksum string, timeout time.Duration, pullSecret []byte) (string, error) {
 	url, _ := url.Parse(downloadURL)
 	query := url.Query()
 
@@ -44,15 +46,23 @@ func (c *mockCache) Get(downloadURL, checksum string, timeout time.Duration) (st
 	if errMsg != "" {
 		err = errors.New(errMsg)
 	}
+	if reason := query.Get("policy"); reason != "" {
+		err = newPolicyError(reason, "rejected")
+	}
 
 	return module, err
 }
+func (c *mockCache) Policy() *Policy {
+	return c.policy
+}
+
 func (c *mockCache) Cleanup() {}
 
 func TestWasmConvert(t *testing.T) {
 	cases := []struct {
 		name       string
 		input      []*core.TypedExtensionConfig
+		policy     *Policy
 		wantOutput []*core.TypedExtensionConfig
 		wantNack   bool
 	}{
@@ -66,6 +76,26 @@ func TestWasmConvert(t *testing.T) {
 			},
 			wantNack: false,
 		},
+		{
+			name: "direct wasm remote load success",
+			input: []*core.TypedExtensionConfig{
+				extensionConfigMap["direct-remote-load-success"],
+			},
+			wantOutput: []*core.TypedExtensionConfig{
+				extensionConfigMap["remote-load-success-local-file"],
+			},
+			wantNack: false,
+		},
+		{
+			name: "direct wasm remote load fail",
+			input: []*core.TypedExtensionConfig{
+				extensionConfigMap["direct-remote-load-fail"],
+			},
+			wantOutput: []*core.TypedExtensionConfig{
+				extensionConfigMap["direct-remote-load-fail"],
+			},
+			wantNack: true,
+		},
 		{
 			name: "remote load fail",
 			input: []*core.TypedExtensionConfig{
@@ -98,6 +128,57 @@ func TestWasmConvert(t *testing.T) {
 			},
 			wantNack: false,
 		},
+		{
+			name: "remote load fail open with policy",
+			input: []*core.TypedExtensionConfig{
+				extensionConfigMap["remote-load-fail-open"],
+			},
+			policy: &Policy{RequireChecksum: true},
+			wantOutput: []*core.TypedExtensionConfig{
+				extensionConfigMap["remote-load-fail-open"],
+			},
+			wantNack: true,
+		},
+		{
+			name: "policy rejection fail open",
+			input: []*core.TypedExtensionConfig{
+				extensionConfigMap["remote-load-policy-rejected-fail-open"],
+			},
+			wantOutput: []*core.TypedExtensionConfig{
+				extensionConfigMap["remote-load-policy-rejected-fail-open"],
+			},
+			wantNack: true,
+		},
+		{
+			name: "pull secret removed on success",
+			input: []*core.TypedExtensionConfig{
+				extensionConfigMap["remote-load-secret"],
+			},
+			wantOutput: []*core.TypedExtensionConfig{
+				extensionConfigMap["remote-load-secret-local-file"],
+			},
+			wantNack: false,
+		},
+		{
+			name: "pull secret removed on fail open",
+			input: []*core.TypedExtensionConfig{
+				extensionConfigMap["remote-load-secret-fail-open"],
+			},
+			wantOutput: []*core.TypedExtensionConfig{
+				extensionConfigMap["remote-load-secret-fail-open-stripped"],
+			},
+			wantNack: false,
+		},
+		{
+			name: "pull secret removed without remote load",
+			input: []*core.TypedExtensionConfig{
+				extensionConfigMap["no-remote-load-secret"],
+			},
+			wantOutput: []*core.TypedExtensionConfig{
+				extensionConfigMap["no-remote-load-secret-stripped"],
+			},
+			wantNack: false,
+		},
 		{
 			name: "no typed struct",
 			input: []*core.TypedExtensionConfig{
@@ -146,7 +227,7 @@ func TestWasmConvert(t *testing.T) {
 			for _, i := range c.input {
 				gotOutput = append(gotOutput, util.MessageToAny(i))
 			}
-			gotNack := MaybeConvertWasmExtensionConfig(gotOutput, &mockCache{})
+			gotNack := MaybeConvertWasmExtensionConfig(gotOutput, &mockCache{policy: c.policy})
 			if len(gotOutput) != len(c.wantOutput) {
 				t.Fatalf("wasm config conversion number of configuration got %v want %v", len(gotOutput), len(c.wantOutput))
 			}
@@ -187,7 +268,59 @@ func buildWasmExtensionConfig(name string, wasm *wasm.Wasm) *core.TypedExtension
 	}
 }
 
+func buildVMConfig(code *core.AsyncDataSource, pullSecret string) *v3.VmConfig {
+	vm := &v3.VmConfig{Code: code, EnvironmentVariables: &v3.EnvironmentVariables{KeyValues: map[string]string{"FOO": "bar"}}}
+	if pullSecret != "" {
+		vm.EnvironmentVariables.KeyValues[WasmSecretEnv] = pullSecret
+	}
+	return vm
+}
+
+func remoteCode(uri string) *core.AsyncDataSource {
+	return &core.AsyncDataSource{Specifier: &core.AsyncDataSource_Remote{
+		Remote: &core.RemoteDataSource{HttpUri: &core.HttpUri{Uri: uri}},
+	}}
+}
+
+func localCode(filename string) *core.AsyncDataSource {
+	return &core.AsyncDataSource{Specifier: &core.AsyncDataSource_Local{
+		Local: &core.DataSource{Specifier: &core.DataSource_Filename{Filename: filename}},
+	}}
+}
+
 var extensionConfigMap = map[string]*core.TypedExtensionConfig{
+	"remote-load-secret": buildTypedStructExtensionConfig("remote-load-secret", &wasm.Wasm{
+		Config: &v3.PluginConfig{
+			Vm: &v3.PluginConfig_VmConfig{VmConfig: buildVMConfig(remoteCode("oci://test?module=test.wasm"), "secret")},
+		},
+	}),
+	"remote-load-secret-local-file": buildWasmExtensionConfig("remote-load-secret", &wasm.Wasm{
+		Config: &v3.PluginConfig{
+			Vm: &v3.PluginConfig_VmConfig{VmConfig: buildVMConfig(localCode("test.wasm"), "")},
+		},
+	}),
+	"remote-load-secret-fail-open": buildTypedStructExtensionConfig("remote-load-secret-fail-open", &wasm.Wasm{
+		Config: &v3.PluginConfig{
+			Vm:       &v3.PluginConfig_VmConfig{VmConfig: buildVMConfig(remoteCode("oci://test?module=test.wasm&error=download-error"), "secret")},
+			FailOpen: true,
+		},
+	}),
+	"remote-load-secret-fail-open-stripped": buildWasmExtensionConfig("remote-load-secret-fail-open", &wasm.Wasm{
+		Config: &v3.PluginConfig{
+			Vm:       &v3.PluginConfig_VmConfig{VmConfig: buildVMConfig(remoteCode("oci://test?module=test.wasm&error=download-error"), "")},
+			FailOpen: true,
+		},
+	}),
+	"no-remote-load-secret": buildTypedStructExtensionConfig("no-remote-load-secret", &wasm.Wasm{
+		Config: &v3.PluginConfig{
+			Vm: &v3.PluginConfig_VmConfig{VmConfig: buildVMConfig(localCode("/etc/istio/test.wasm"), "secret")},
+		},
+	}),
+	"no-remote-load-secret-stripped": buildWasmExtensionConfig("no-remote-load-secret", &wasm.Wasm{
+		Config: &v3.PluginConfig{
+			Vm: &v3.PluginConfig_VmConfig{VmConfig: buildVMConfig(localCode("/etc/istio/test.wasm"), "")},
+		},
+	}),
 	"empty": {
 		Name: "empty",
 		TypedConfig: util.MessageToAny(
@@ -242,6 +375,16 @@ var extensionConfigMap = map[string]*core.TypedExtensionConfig{
 			},
 		},
 	}),
+	"direct-remote-load-success": buildWasmExtensionConfig("remote-load-success", &wasm.Wasm{
+		Config: &v3.PluginConfig{
+			Vm: &v3.PluginConfig_VmConfig{VmConfig: &v3.VmConfig{Code: remoteCode("http://test?module=test.wasm")}},
+		},
+	}),
+	"direct-remote-load-fail": buildWasmExtensionConfig("direct-remote-load-fail", &wasm.Wasm{
+		Config: &v3.PluginConfig{
+			Vm: &v3.PluginConfig_VmConfig{VmConfig: &v3.VmConfig{Code: remoteCode("http://test?module=test.wasm&error=download-error")}},
+		},
+	}),
 	"remote-load-success-local-file": buildWasmExtensionConfig("remote-load-success", &wasm.Wasm{
 		Config: &v3.PluginConfig{
 			Vm: &v3.PluginConfig_VmConfig{
@@ -288,4 +431,20 @@ var extensionConfigMap = map[string]*core.TypedExtensionConfig{
 			FailOpen: true,
 		},
 	}),
+	"remote-load-policy-rejected-fail-open": buildTypedStructExtensionConfig("remote-load-policy-rejected", &wasm.Wasm{
+		Config: &v3.PluginConfig{
+			Vm: &v3.PluginConfig_VmConfig{
+				VmConfig: &v3.VmConfig{
+					Code: &core.AsyncDataSource{Specifier: &core.AsyncDataSource_Remote{
+						Remote: &core.RemoteDataSource{
+							HttpUri: &core.HttpUri{
+								Uri: "http://test?module=test.wasm&policy=url_not_allowed",
+							},
+						},
+					}},
+				},
+			},
+			FailOpen: true,
+		},
+	}),
 }
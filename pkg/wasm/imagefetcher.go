@@ -0,0 +1,455 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wasm
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/docker/distribution/reference"
+)
+
+const (
+	// Media types of the Wasm artifact spec.
+	wasmConfigMediaType = "application/vnd.module.wasm.config.v1+json"
+	wasmLayerMediaType  = "application/vnd.module.wasm.content.layer.v1+wasm"
+
+	// Media types of manifests and layers used by "compat" images, which are regular container images
+	// with a single layer containing the Wasm module.
+	ociManifestMediaType    = "application/vnd.oci.image.manifest.v1+json"
+	dockerManifestMediaType = "application/vnd.docker.distribution.manifest.v2+json"
+	ociLayerMediaType       = "application/vnd.oci.image.layer.v1.tar+gzip"
+	dockerLayerMediaType    = "application/vnd.docker.image.rootfs.diff.tar.gzip"
+
+	// compatWasmFileName is the name of the Wasm module file within the single layer of a compat image.
+	compatWasmFileName = "plugin.wasm"
+
+	// maxManifestSize bounds the size of manifests and token responses read from the registry.
+	maxManifestSize = 4 << 20
+	// maxBlobSize bounds the size of layers read from the registry, and of the Wasm module extracted
+	// from compat images.
+	maxBlobSize = 256 << 20
+)
+
+// ImageFetcherOption contains the options for ImageFetcher.
+type ImageFetcherOption struct {
+	// PullSecret is a docker config json (either the .dockerconfigjson or the legacy .dockercfg format)
+	// used to authenticate with the registry.
+	PullSecret []byte
+	// Timeout bounds every request made to the registry.
+	Timeout time.Duration
+}
+
+// ImageFetcher fetches Wasm modules from OCI registries.
+type ImageFetcher struct {
+	client *http.Client
+	creds  map[string]registryCredential
+	// token is the bearer token obtained from the registry token service, if any.
+	token string
+}
+
+type registryCredential struct {
+	username string
+	password string
+}
+
+// imageDescriptor is the subset of an OCI content descriptor used by the fetcher.
+type imageDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// imageManifest is the subset of an OCI or docker v2 manifest used by the fetcher.
+type imageManifest struct {
+	MediaType string            `json:"mediaType"`
+	Config    imageDescriptor   `json:"config"`
+	Layers    []imageDescriptor `json:"layers"`
+}
+
+// NewImageFetcher creates a new ImageFetcher.
+func NewImageFetcher(opt ImageFetcherOption) (*ImageFetcher, error) {
+	timeout := opt.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	creds, err := parsePullSecret(opt.PullSecret)
+	if err != nil {
+		return nil, err
+	}
+	return &ImageFetcher{
+		client: &http.Client{Timeout: timeout},
+		creds:  creds,
+	}, nil
+}
+
+// Fetch pulls the image with the given reference, e.g. "gcr.io/foo/bar:v1" or "gcr.io/foo/bar@sha256:...",
+// and returns the Wasm module in it together with the image digest.
+// Both images following the Wasm artifact spec and "compat" images with a single layer containing
+// a plugin.wasm file are supported.
+func (f *ImageFetcher) Fetch(ref string) ([]byte, string, error) {
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not parse image reference %v: %v", ref, err)
+	}
+	named = reference.TagNameOnly(named)
+	registry := reference.Domain(named)
+	repository := reference.Path(named)
+	var tagOrDigest string
+	if d, ok := named.(reference.Digested); ok {
+		tagOrDigest = d.Digest().String()
+	} else if t, ok := named.(reference.Tagged); ok {
+		tagOrDigest = t.Tag()
+	}
+
+	base := registryBaseURL(registry) + "/v2/" + repository
+	manifestBytes, err := f.get(registry, base+"/manifests/"+tagOrDigest,
+		strings.Join([]string{ociManifestMediaType, dockerManifestMediaType}, ","), maxManifestSize)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not fetch manifest of %v: %v", ref, err)
+	}
+	imageDigest := fmt.Sprintf("sha256:%x", sha256.Sum256(manifestBytes))
+	if d, ok := named.(reference.Digested); ok && d.Digest().String() != imageDigest {
+		return nil, "", fmt.Errorf("manifest of %v has digest %v, which does not match the reference", ref, imageDigest)
+	}
+
+	manifest := &imageManifest{}
+	if err := json.Unmarshal(manifestBytes, manifest); err != nil {
+		return nil, "", fmt.Errorf("could not parse manifest of %v: %v", ref, err)
+	}
+
+	if manifest.Config.MediaType == wasmConfigMediaType {
+		// Wasm artifact spec: the Wasm module is stored as is in the layer with the Wasm content media type.
+		for _, layer := range manifest.Layers {
+			if layer.MediaType != wasmLayerMediaType {
+				continue
+			}
+			b, err := f.fetchBlob(registry, base, layer)
+			if err != nil {
+				return nil, "", err
+			}
+			return b, imageDigest, nil
+		}
+		return nil, "", fmt.Errorf("image %v does not have a layer with media type %v", ref, wasmLayerMediaType)
+	}
+
+	// Compat image: a single layer containing the plugin.wasm file.
+	if len(manifest.Layers) != 1 {
+		return nil, "", fmt.Errorf("image %v has %d layers, compat Wasm images must have exactly one layer", ref, len(manifest.Layers))
+	}
+	layer := manifest.Layers[0]
+	if layer.MediaType != ociLayerMediaType && layer.MediaType != dockerLayerMediaType {
+		return nil, "", fmt.Errorf("image %v has unsupported layer media type %v", ref, layer.MediaType)
+	}
+	b, err := f.fetchBlob(registry, base, layer)
+	if err != nil {
+		return nil, "", err
+	}
+	wasm, err := extractCompatWasm(b)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not extract Wasm module from image %v: %v", ref, err)
+	}
+	return wasm, imageDigest, nil
+}
+
+// fetchBlob fetches the layer, reading at most the size declared in the manifest and verifying its digest.
+func (f *ImageFetcher) fetchBlob(registry, base string, layer imageDescriptor) ([]byte, error) {
+	limit := int64(maxBlobSize)
+	if layer.Size > maxBlobSize {
+		return nil, fmt.Errorf("layer %v has size %d, larger than the max %d", layer.Digest, layer.Size, limit)
+	}
+	if layer.Size > 0 {
+		limit = layer.Size
+	}
+	body, err := f.open(registry, base+"/blobs/"+layer.Digest, "")
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch layer %v: %v", layer.Digest, err)
+	}
+	defer body.Close()
+	h := sha256.New()
+	// Read one more byte than the limit to detect larger layers.
+	b, err := ioutil.ReadAll(io.TeeReader(io.LimitReader(body, limit+1), h))
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch layer %v: %v", layer.Digest, err)
+	}
+	if int64(len(b)) > limit {
+		return nil, fmt.Errorf("layer %v is larger than %d bytes", layer.Digest, limit)
+	}
+	if got := fmt.Sprintf("sha256:%x", h.Sum(nil)); got != layer.Digest {
+		return nil, fmt.Errorf("layer has digest %v, which does not match %v", got, layer.Digest)
+	}
+	return b, nil
+}
+
+// get issues a GET request against the registry, and reads at most limit bytes of the response body.
+func (f *ImageFetcher) get(registry, u, accept string, limit int64) ([]byte, error) {
+	body, err := f.open(registry, u, accept)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return ioutil.ReadAll(io.LimitReader(body, limit))
+}
+
+// open issues a GET request against the registry, authenticating as requested by the registry if needed,
+// and returns the response body.
+func (f *ImageFetcher) open(registry, u, accept string) (io.ReadCloser, error) {
+	resp, err := f.do(registry, u, accept)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		if err := f.authenticate(registry, challenge); err != nil {
+			return nil, err
+		}
+		if resp, err = f.do(registry, u, accept); err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("registry returned status code %v", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+func (f *ImageFetcher) do(registry, u, accept string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if f.token != "" {
+		req.Header.Set("Authorization", "Bearer "+f.token)
+	} else if c, ok := f.creds[registry]; ok {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	return f.client.Do(req)
+}
+
+// authenticate handles the WWW-Authenticate challenge returned by the registry. For bearer challenges, a token
+// is requested from the token service using the credentials of the registry, if any.
+func (f *ImageFetcher) authenticate(registry, challenge string) error {
+	scheme, params := parseAuthChallenge(challenge)
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if _, ok := f.creds[registry]; !ok {
+			return fmt.Errorf("registry %v requires authentication but no pull secret was provided", registry)
+		}
+		return fmt.Errorf("registry %v rejected the provided credentials", registry)
+	case "bearer":
+		if f.token != "" {
+			return fmt.Errorf("registry %v rejected the bearer token", registry)
+		}
+		realm, err := url.Parse(params["realm"])
+		if err != nil || params["realm"] == "" {
+			return fmt.Errorf("registry %v returned an invalid bearer realm %q", registry, params["realm"])
+		}
+		q := realm.Query()
+		for _, k := range []string{"service", "scope"} {
+			if v, ok := params[k]; ok {
+				q.Set(k, v)
+			}
+		}
+		realm.RawQuery = q.Encode()
+		req, err := http.NewRequest(http.MethodGet, realm.String(), nil)
+		if err != nil {
+			return err
+		}
+		if c, ok := f.creds[registry]; ok {
+			req.SetBasicAuth(c.username, c.password)
+		}
+		resp, err := f.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("could not get token from %v: %v", realm.Host, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("could not get token from %v: status code %v", realm.Host, resp.StatusCode)
+		}
+		token := struct {
+			Token       string `json:"token"`
+			AccessToken string `json:"access_token"`
+		}{}
+		if err := json.NewDecoder(io.LimitReader(resp.Body, maxManifestSize)).Decode(&token); err != nil {
+			return fmt.Errorf("could not parse token response from %v: %v", realm.Host, err)
+		}
+		f.token = token.Token
+		if f.token == "" {
+			f.token = token.AccessToken
+		}
+		if f.token == "" {
+			return fmt.Errorf("token service %v returned an empty token", realm.Host)
+		}
+		return nil
+	default:
+		return fmt.Errorf("registry %v requested unsupported authentication scheme %q", registry, scheme)
+	}
+}
+
+// parseAuthChallenge parses a WWW-Authenticate header such as
+// `Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:foo:pull"`.
+func parseAuthChallenge(challenge string) (string, map[string]string) {
+	params := map[string]string{}
+	parts := strings.SplitN(strings.TrimSpace(challenge), " ", 2)
+	if len(parts) != 2 {
+		return parts[0], params
+	}
+	rest := parts[1]
+	for rest != "" {
+		eq := strings.Index(rest, "=")
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(rest[:eq]))
+		rest = strings.TrimSpace(rest[eq+1:])
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else if comma := strings.Index(rest, ","); comma >= 0 {
+			value, rest = rest[:comma], rest[comma:]
+		} else {
+			value, rest = rest, ""
+		}
+		params[key] = value
+		rest = strings.TrimPrefix(strings.TrimSpace(rest), ",")
+	}
+	return parts[0], params
+}
+
+// parsePullSecret parses the registry credentials from a docker config json.
+func parsePullSecret(secret []byte) (map[string]registryCredential, error) {
+	creds := map[string]registryCredential{}
+	if len(secret) == 0 {
+		return creds, nil
+	}
+	type authEntry struct {
+		Auth     string `json:"auth"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	cfg := struct {
+		Auths map[string]authEntry `json:"auths"`
+	}{}
+	if err := json.Unmarshal(secret, &cfg); err != nil {
+		return nil, fmt.Errorf("could not parse image pull secret: %v", err)
+	}
+	if cfg.Auths == nil {
+		// Legacy .dockercfg format, which does not have the "auths" wrapper.
+		if err := json.Unmarshal(secret, &cfg.Auths); err != nil {
+			return nil, fmt.Errorf("could not parse image pull secret: %v", err)
+		}
+	}
+	for server, entry := range cfg.Auths {
+		c := registryCredential{username: entry.Username, password: entry.Password}
+		if entry.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("could not decode auth of %v in image pull secret: %v", server, err)
+			}
+			userPass := strings.SplitN(string(decoded), ":", 2)
+			if len(userPass) != 2 {
+				return nil, fmt.Errorf("invalid auth of %v in image pull secret", server)
+			}
+			c.username, c.password = userPass[0], userPass[1]
+		}
+		creds[normalizeRegistry(server)] = c
+	}
+	return creds, nil
+}
+
+// normalizeRegistry converts the server keys of a docker config, which may be URLs, to registry domains
+// as returned by reference.Domain.
+func normalizeRegistry(server string) string {
+	if u, err := url.Parse(server); err == nil && u.Host != "" {
+		server = u.Host
+	}
+	server = strings.TrimSuffix(strings.SplitN(server, "/", 2)[0], "/")
+	if server == "index.docker.io" || server == "registry-1.docker.io" {
+		return "docker.io"
+	}
+	return server
+}
+
+// registryBaseURL returns the base URL of the registry API. As with docker, loopback registries
+// are accessed over plain HTTP.
+func registryBaseURL(registry string) string {
+	if registry == "docker.io" {
+		return "https://registry-1.docker.io"
+	}
+	host := registry
+	if h, _, err := net.SplitHostPort(registry); err == nil {
+		host = h
+	}
+	if host == "localhost" {
+		return "http://" + registry
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return "http://" + registry
+	}
+	return "https://" + registry
+}
+
+// extractCompatWasm extracts the plugin.wasm file from a gzipped tar layer.
+func extractCompatWasm(layer []byte) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(layer))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("%v not found in the layer", compatWasmFileName)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag == tar.TypeReg && filepath.Base(hdr.Name) == compatWasmFileName {
+			wasm, err := ioutil.ReadAll(io.LimitReader(tr, maxBlobSize+1))
+			if err != nil {
+				return nil, err
+			}
+			if len(wasm) > maxBlobSize {
+				return nil, fmt.Errorf("%v is larger than %d bytes", compatWasmFileName, maxBlobSize)
+			}
+			return wasm, nil
+		}
+	}
+}
@@ -0,0 +1,313 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wasm
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testRegistry is a minimal in-process stand-in for an OCI registry.
+type testRegistry struct {
+	mu        sync.Mutex
+	manifests map[string][]byte
+	blobs     map[string][]byte
+	requests  int
+
+	// If token is set, the registry requires a bearer token obtained with the given credentials.
+	token    string
+	username string
+	password string
+
+	server *httptest.Server
+}
+
+func newTestRegistry(t *testing.T) *testRegistry {
+	r := &testRegistry{
+		manifests: map[string][]byte{},
+		blobs:     map[string][]byte{},
+	}
+	r.server = httptest.NewServer(http.HandlerFunc(r.serveHTTP))
+	t.Cleanup(r.server.Close)
+	return r
+}
+
+// host returns the registry host, used as the registry part of image references.
+func (r *testRegistry) host() string {
+	return strings.TrimPrefix(r.server.URL, "http://")
+}
+
+func (r *testRegistry) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests++
+
+	if req.URL.Path == "/token" {
+		user, pass, ok := req.BasicAuth()
+		if !ok || user != r.username || pass != r.password {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"token": r.token})
+		return
+	}
+
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+	if r.token != "" && req.Header.Get("Authorization") != "Bearer "+r.token {
+		w.Header().Set("WWW-Authenticate",
+			fmt.Sprintf(`Bearer realm="%s/token",service="test",scope="repository:%s:pull"`, r.server.URL, path))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if i := strings.LastIndex(path, "/manifests/"); i >= 0 {
+		if m, ok := r.manifests[path[:i]+"@"+path[i+len("/manifests/"):]]; ok {
+			_, _ = w.Write(m)
+			return
+		}
+	}
+	if i := strings.LastIndex(path, "/blobs/"); i >= 0 {
+		if b, ok := r.blobs[path[i+len("/blobs/"):]]; ok {
+			_, _ = w.Write(b)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNotFound)
+}
+
+// push stores an image with the given config media type and layers, and returns the image digest.
+func (r *testRegistry) push(repo, tag, configMediaType string, layers map[string][]byte) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m := imageManifest{
+		MediaType: ociManifestMediaType,
+		Config:    r.addBlob(configMediaType, []byte("{}")),
+	}
+	for mediaType, layer := range layers {
+		m.Layers = append(m.Layers, r.addBlob(mediaType, layer))
+	}
+	return r.addManifest(repo, tag, m)
+}
+
+func (r *testRegistry) addManifest(repo, tag string, m imageManifest) string {
+	b, _ := json.Marshal(m)
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(b))
+	r.manifests[repo+"@"+tag] = b
+	r.manifests[repo+"@"+digest] = b
+	return digest
+}
+
+func (r *testRegistry) addBlob(mediaType string, b []byte) imageDescriptor {
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(b))
+	r.blobs[digest] = b
+	return imageDescriptor{MediaType: mediaType, Digest: digest, Size: int64(len(b))}
+}
+
+func compatLayer(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestImageFetcher(t *testing.T) {
+	wasmModule := []byte("\x00asm\x01\x00\x00\x00")
+
+	reg := newTestRegistry(t)
+	artifactDigest := reg.push("wasm/artifact", "v1", wasmConfigMediaType, map[string][]byte{wasmLayerMediaType: wasmModule})
+	compatDigest := reg.push("wasm/compat", "v1", "application/vnd.oci.image.config.v1+json",
+		map[string][]byte{ociLayerMediaType: compatLayer(t, map[string][]byte{"plugin.wasm": wasmModule})})
+	reg.push("wasm/nomodule", "v1", "application/vnd.oci.image.config.v1+json",
+		map[string][]byte{ociLayerMediaType: compatLayer(t, map[string][]byte{"other.wasm": wasmModule})})
+	// Images whose layer does not match the size declared in the manifest.
+	for repo, size := range map[string]int64{"wasm/truncated": 4, "wasm/huge": maxBlobSize + 1} {
+		layer := reg.addBlob(wasmLayerMediaType, wasmModule)
+		layer.Size = size
+		reg.addManifest(repo, "v1", imageManifest{
+			MediaType: ociManifestMediaType,
+			Config:    reg.addBlob(wasmConfigMediaType, []byte("{}")),
+			Layers:    []imageDescriptor{layer},
+		})
+	}
+
+	cases := []struct {
+		name             string
+		ref              string
+		wantDigest       string
+		wantErrorMsgPart string
+	}{
+		{
+			name:       "wasm artifact by tag",
+			ref:        reg.host() + "/wasm/artifact:v1",
+			wantDigest: artifactDigest,
+		},
+		{
+			name:       "wasm artifact by digest",
+			ref:        reg.host() + "/wasm/artifact@" + artifactDigest,
+			wantDigest: artifactDigest,
+		},
+		{
+			name:       "compat image",
+			ref:        reg.host() + "/wasm/compat:v1",
+			wantDigest: compatDigest,
+		},
+		{
+			name:             "compat image without plugin.wasm",
+			ref:              reg.host() + "/wasm/nomodule:v1",
+			wantErrorMsgPart: "plugin.wasm not found in the layer",
+		},
+		{
+			name:             "layer larger than its declared size",
+			ref:              reg.host() + "/wasm/truncated:v1",
+			wantErrorMsgPart: "is larger than 4 bytes",
+		},
+		{
+			name:             "layer larger than the max size",
+			ref:              reg.host() + "/wasm/huge:v1",
+			wantErrorMsgPart: "larger than the max",
+		},
+		{
+			name:             "missing image",
+			ref:              reg.host() + "/wasm/missing:v1",
+			wantErrorMsgPart: "registry returned status code 404",
+		},
+		{
+			name:             "invalid reference",
+			ref:              "INVALID:ref:",
+			wantErrorMsgPart: "could not parse image reference",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fetcher, err := NewImageFetcher(ImageFetcherOption{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotModule, gotDigest, err := fetcher.Fetch(c.ref)
+			if c.wantErrorMsgPart != "" {
+				if err == nil || !strings.Contains(err.Error(), c.wantErrorMsgPart) {
+					t.Fatalf("Fetch(%v) got error %v, want error containing %q", c.ref, err, c.wantErrorMsgPart)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Fetch(%v) failed: %v", c.ref, err)
+			}
+			if !bytes.Equal(gotModule, wasmModule) {
+				t.Errorf("Fetch(%v) got module %q, want %q", c.ref, gotModule, wasmModule)
+			}
+			if gotDigest != c.wantDigest {
+				t.Errorf("Fetch(%v) got digest %v, want %v", c.ref, gotDigest, c.wantDigest)
+			}
+		})
+	}
+}
+
+func TestImageFetcherAuthentication(t *testing.T) {
+	wasmModule := []byte("\x00asm\x01\x00\x00\x00")
+	reg := newTestRegistry(t)
+	reg.token, reg.username, reg.password = "secret-token", "user", "pass"
+	reg.push("wasm/private", "v1", wasmConfigMediaType, map[string][]byte{wasmLayerMediaType: wasmModule})
+	ref := reg.host() + "/wasm/private:v1"
+
+	auth := base64.StdEncoding.EncodeToString([]byte("user:pass"))
+	cases := []struct {
+		name       string
+		pullSecret string
+		wantErr    bool
+	}{
+		{
+			name:       "docker config json",
+			pullSecret: fmt.Sprintf(`{"auths":{%q:{"auth":%q}}}`, reg.host(), auth),
+		},
+		{
+			name:       "legacy docker config with url",
+			pullSecret: fmt.Sprintf(`{"http://%s/v1/":{"username":"user","password":"pass"}}`, reg.host()),
+		},
+		{
+			name:    "no pull secret",
+			wantErr: true,
+		},
+		{
+			name:       "wrong credentials",
+			pullSecret: fmt.Sprintf(`{"auths":{%q:{"username":"user","password":"wrong"}}}`, reg.host()),
+			wantErr:    true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fetcher, err := NewImageFetcher(ImageFetcherOption{PullSecret: []byte(c.pullSecret)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotModule, _, err := fetcher.Fetch(ref)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("Fetch(%v) got no error, want error", ref)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Fetch(%v) failed: %v", ref, err)
+			}
+			if !bytes.Equal(gotModule, wasmModule) {
+				t.Errorf("Fetch(%v) got module %q, want %q", ref, gotModule, wasmModule)
+			}
+		})
+	}
+}
+
+func TestParseAuthChallenge(t *testing.T) {
+	scheme, params := parseAuthChallenge(
+		`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:foo/bar:pull"`)
+	if scheme != "Bearer" {
+		t.Errorf("got scheme %v, want Bearer", scheme)
+	}
+	want := map[string]string{
+		"realm":   "https://auth.docker.io/token",
+		"service": "registry.docker.io",
+		"scope":   "repository:foo/bar:pull",
+	}
+	for k, v := range want {
+		if params[k] != v {
+			t.Errorf("got %v=%q, want %q", k, params[k], v)
+		}
+	}
+}
@@ -29,11 +29,18 @@ const (
 	marshalFailure      = "marshal_failure"
 	fetchFailure        = "fetch_failure"
 	missRemoteFetchHint = "miss_remote_fetch_hint"
+	policyRejected      = "policy_rejected"
+
+	// For Wasm policy rejection metric.
+	urlNotAllowed    = "url_not_allowed"
+	checksumMissing  = "checksum_missing"
+	signatureInvalid = "signature_invalid"
 )
 
 var (
 	hitTag    = monitoring.MustCreateLabel("hit")
 	resultTag = monitoring.MustCreateLabel("result")
+	reasonTag = monitoring.MustCreateLabel("reason")
 
 	wasmCacheEntries = monitoring.NewGauge(
 		"wasm_cache_entries",
@@ -54,10 +61,16 @@ var (
 
 	wasmConfigConversionCount = monitoring.NewSum(
 		"wasm_config_conversion_count",
-		"number of Wasm config conversion count and results, including success, no remote load, marshal failure, remote fetch failure, miss remote fetch hint.",
+		"number of Wasm config conversion count and results, including success, no remote load, marshal failure, remote fetch failure, miss remote fetch hint, policy rejection.",
 		monitoring.WithLabels(resultTag),
 	)
 
+	wasmPolicyRejectionCount = monitoring.NewSum(
+		"wasm_policy_rejection_count",
+		"number of Wasm modules rejected by the Wasm policy, including URL not allowed, checksum missing, and invalid signature.",
+		monitoring.WithLabels(reasonTag),
+	)
+
 	wasmConfigConversionDuration = monitoring.NewDistribution(
 		"wasm_config_conversion_duration",
 		"Total time in milliseconds istio-agent spends on converting remote load in Wasm config.",
@@ -71,6 +84,7 @@ func init() {
 		wasmCacheLookupCount,
 		wasmRemoteFetchCount,
 		wasmConfigConversionCount,
+		wasmPolicyRejectionCount,
 		wasmConfigConversionDuration,
 	)
 }
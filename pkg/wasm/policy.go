@@ -0,0 +1,264 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wasm
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/docker/distribution/reference"
+	"github.com/hashicorp/go-multierror"
+)
+
+const (
+	// Media type and annotation of the layers of cosign signature images.
+	cosignSignatureMediaType  = "application/vnd.dev.cosign.simplesigning.v1+json"
+	cosignSignatureAnnotation = "dev.cosignproject.cosign/signature"
+)
+
+// Policy restricts which Wasm modules may be loaded by the proxy.
+type Policy struct {
+	// AllowedURLPrefixes are the prefixes of module URLs that may be fetched, e.g. "oci://gcr.io/my-project/".
+	// If empty, modules may be fetched from any URL.
+	AllowedURLPrefixes []string
+	// RequireChecksum rejects modules that are not pinned with a sha256 checksum (or image digest for oci:// modules).
+	RequireChecksum bool
+	// PublicKey, if set, is used to verify the cosign signature of oci:// modules. Modules fetched
+	// over HTTP cannot be signed and are rejected.
+	PublicKey crypto.PublicKey
+}
+
+// PolicyError is returned when a Wasm module is rejected by the policy.
+type PolicyError struct {
+	// Reason is the metric label value of the rejection.
+	Reason string
+	msg    string
+}
+
+func (e *PolicyError) Error() string {
+	return e.msg
+}
+
+func newPolicyError(reason, format string, args ...interface{}) *PolicyError {
+	return &PolicyError{Reason: reason, msg: fmt.Sprintf(format, args...)}
+}
+
+// NewPolicy creates a Policy. If publicKeyFile is not empty, the PEM encoded public key in it
+// is used to verify module signatures.
+func NewPolicy(allowedURLPrefixes []string, requireChecksum bool, publicKeyFile string) (*Policy, error) {
+	p := &Policy{
+		RequireChecksum: requireChecksum,
+	}
+	for _, prefix := range allowedURLPrefixes {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			p.AllowedURLPrefixes = append(p.AllowedURLPrefixes, prefix)
+		}
+	}
+	if publicKeyFile != "" {
+		b, err := ioutil.ReadFile(publicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Wasm signature public key: %v", err)
+		}
+		if p.PublicKey, err = parsePublicKey(b); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+func parsePublicKey(b []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode Wasm signature public key: no PEM block found")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Wasm signature public key: %v", err)
+	}
+	switch key.(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported Wasm signature public key type %T", key)
+	}
+}
+
+// Enabled returns whether the policy restricts the Wasm modules.
+func (p *Policy) Enabled() bool {
+	return p != nil && (len(p.AllowedURLPrefixes) > 0 || p.RequireChecksum || p.PublicKey != nil)
+}
+
+// Allow checks the module URL and checksum against the policy.
+func (p *Policy) Allow(downloadURL, checksum string) error {
+	if p == nil {
+		return nil
+	}
+	if len(p.AllowedURLPrefixes) > 0 {
+		allowed := false
+		for _, prefix := range p.AllowedURLPrefixes {
+			if urlHasPrefix(downloadURL, prefix) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return newPolicyError(urlNotAllowed, "Wasm module URL %v is not allowed by the policy", downloadURL)
+		}
+	}
+	if p.RequireChecksum && checksum == "" && !digestPinned(downloadURL) {
+		return newPolicyError(checksumMissing, "Wasm module %v has no checksum, which is required by the policy", downloadURL)
+	}
+	if p.PublicKey != nil && !strings.HasPrefix(downloadURL, OCIScheme) {
+		return newPolicyError(signatureInvalid, "Wasm module %v cannot be verified, only signed oci:// modules are allowed by the policy", downloadURL)
+	}
+	return nil
+}
+
+// digestPinned returns whether downloadURL is an oci:// module referenced by image digest, which the
+// image fetcher verifies like a checksum.
+func digestPinned(downloadURL string) bool {
+	if !strings.HasPrefix(downloadURL, OCIScheme) {
+		return false
+	}
+	named, err := reference.ParseNormalizedNamed(strings.TrimPrefix(downloadURL, OCIScheme))
+	if err != nil {
+		return false
+	}
+	_, ok := named.(reference.Digested)
+	return ok
+}
+
+// urlHasPrefix returns whether downloadURL is under prefix. The scheme and host must be the same, and the
+// path of prefix must end at a path segment boundary of the URL path, so "oci://gcr.io/proj" allows
+// "oci://gcr.io/proj/plugin:v1", but not "oci://gcr.io/proj-evil/plugin:v1". A tag or digest also ends the
+// last segment, so "oci://gcr.io/proj/plugin" allows "oci://gcr.io/proj/plugin:v1".
+// URLs with dot segments or encoded separators are never allowed, as the server could resolve them
+// outside of the prefix.
+func urlHasPrefix(downloadURL, prefix string) bool {
+	lower := strings.ToLower(downloadURL)
+	if strings.Contains(lower, "%2f") || strings.Contains(lower, "%2e") || strings.Contains(lower, "%5c") {
+		return false
+	}
+	u, err := url.Parse(downloadURL)
+	if err != nil {
+		return false
+	}
+	for _, segment := range strings.Split(u.Path, "/") {
+		if segment == ".." || segment == "." {
+			return false
+		}
+	}
+	pu, err := url.Parse(prefix)
+	if err != nil || pu.Host == "" {
+		return false
+	}
+	if !strings.EqualFold(u.Scheme, pu.Scheme) || !strings.EqualFold(u.Host, pu.Host) || u.User != nil {
+		return false
+	}
+	prefixPath := strings.TrimSuffix(path.Clean("/"+pu.Path), "/")
+	if prefixPath == "" {
+		return true
+	}
+	urlPath := path.Clean("/" + u.Path)
+	if !strings.HasPrefix(urlPath, prefixPath) {
+		return false
+	}
+	rest := urlPath[len(prefixPath):]
+	return rest == "" || rest[0] == '/' || rest[0] == ':' || rest[0] == '@'
+}
+
+// verifySignature verifies the cosign signature of the image with the given reference and digest.
+// Cosign stores signatures in the same repository as the image, with the tag "sha256-<digest>.sig".
+// Each layer of the signature image holds a signed payload, with the signature in the layer annotations.
+// The image is accepted if any of the payloads is signed by the key and refers to the image digest.
+func (f *ImageFetcher) verifySignature(ref, imageDigest string, key crypto.PublicKey) error {
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return fmt.Errorf("could not parse image reference %v: %v", ref, err)
+	}
+	registry := reference.Domain(named)
+	base := registryBaseURL(registry) + "/v2/" + reference.Path(named)
+	sigTag := strings.Replace(imageDigest, ":", "-", 1) + ".sig"
+
+	manifestBytes, err := f.get(registry, base+"/manifests/"+sigTag,
+		strings.Join([]string{ociManifestMediaType, dockerManifestMediaType}, ","), maxManifestSize)
+	if err != nil {
+		return fmt.Errorf("could not fetch signature of %v: %v", ref, err)
+	}
+	manifest := &imageManifest{}
+	if err := json.Unmarshal(manifestBytes, manifest); err != nil {
+		return fmt.Errorf("could not parse signature manifest of %v: %v", ref, err)
+	}
+
+	var fetchErrs error
+	for _, layer := range manifest.Layers {
+		if layer.MediaType != cosignSignatureMediaType {
+			continue
+		}
+		sig, err := base64.StdEncoding.DecodeString(layer.Annotations[cosignSignatureAnnotation])
+		if err != nil || len(sig) == 0 {
+			continue
+		}
+		payload, err := f.fetchBlob(registry, base, layer)
+		if err != nil {
+			// Another signature may still be valid.
+			fetchErrs = multierror.Append(fetchErrs, err)
+			continue
+		}
+		if !verifyPayload(key, payload, sig) {
+			continue
+		}
+		signed := struct {
+			Critical struct {
+				Image struct {
+					DockerManifestDigest string `json:"docker-manifest-digest"`
+				} `json:"image"`
+			} `json:"critical"`
+		}{}
+		if err := json.Unmarshal(payload, &signed); err != nil {
+			continue
+		}
+		if signed.Critical.Image.DockerManifestDigest == imageDigest {
+			return nil
+		}
+	}
+	if fetchErrs != nil {
+		return fmt.Errorf("no valid signature found for %v: %v", ref, fetchErrs)
+	}
+	return fmt.Errorf("no valid signature found for %v", ref)
+}
+
+func verifyPayload(key crypto.PublicKey, payload, sig []byte) bool {
+	digest := sha256.Sum256(payload)
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		return ecdsa.VerifyASN1(k, digest[:], sig)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) == nil
+	default:
+		return false
+	}
+}
@@ -0,0 +1,303 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wasm
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPolicyAllow(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name       string
+		policy     *Policy
+		url        string
+		checksum   string
+		wantReason string
+	}{
+		{
+			name: "no policy",
+			url:  "https://any",
+		},
+		{
+			name:   "allowed prefix",
+			policy: &Policy{AllowedURLPrefixes: []string{"https://other/", "oci://registry/"}},
+			url:    "oci://registry/plugin:v1",
+		},
+		{
+			name:       "disallowed prefix",
+			policy:     &Policy{AllowedURLPrefixes: []string{"oci://registry/"}},
+			url:        "oci://other-registry/plugin:v1",
+			wantReason: urlNotAllowed,
+		},
+		{
+			name:     "checksum present",
+			policy:   &Policy{RequireChecksum: true},
+			url:      "https://any",
+			checksum: "abc",
+		},
+		{
+			name:       "checksum missing",
+			policy:     &Policy{RequireChecksum: true},
+			url:        "https://any",
+			wantReason: checksumMissing,
+		},
+		{
+			name:   "checksum pinned by image digest",
+			policy: &Policy{RequireChecksum: true},
+			url:    "oci://registry/plugin@sha256:" + strings.Repeat("a", 64),
+		},
+		{
+			name:       "checksum missing for oci tag",
+			policy:     &Policy{RequireChecksum: true},
+			url:        "oci://registry/plugin:v1",
+			wantReason: checksumMissing,
+		},
+		{
+			name:   "signature required for oci",
+			policy: &Policy{PublicKey: &key.PublicKey},
+			url:    "oci://registry/plugin:v1",
+		},
+		{
+			name:   "allowed path prefix",
+			policy: &Policy{AllowedURLPrefixes: []string{"oci://registry/proj"}},
+			url:    "oci://registry/proj/plugin:v1",
+		},
+		{
+			name:   "allowed image",
+			policy: &Policy{AllowedURLPrefixes: []string{"oci://registry/proj/plugin"}},
+			url:    "oci://registry/proj/plugin@sha256:abc",
+		},
+		{
+			name:       "disallowed path segment",
+			policy:     &Policy{AllowedURLPrefixes: []string{"oci://registry/proj"}},
+			url:        "oci://registry/proj-evil/plugin:v1",
+			wantReason: urlNotAllowed,
+		},
+		{
+			name:       "disallowed dot dot segment",
+			policy:     &Policy{AllowedURLPrefixes: []string{"https://registry/proj"}},
+			url:        "https://registry/proj/../evil/plugin.wasm",
+			wantReason: urlNotAllowed,
+		},
+		{
+			name:       "disallowed encoded dot segment",
+			policy:     &Policy{AllowedURLPrefixes: []string{"https://registry/proj"}},
+			url:        "https://registry/proj/%2E%2E/evil/plugin.wasm",
+			wantReason: urlNotAllowed,
+		},
+		{
+			name:       "disallowed encoded separator",
+			policy:     &Policy{AllowedURLPrefixes: []string{"https://registry/proj"}},
+			url:        "https://registry/proj%2F..%2Fevil/plugin.wasm",
+			wantReason: urlNotAllowed,
+		},
+		{
+			name:   "allowed unclean path",
+			policy: &Policy{AllowedURLPrefixes: []string{"https://registry/proj/"}},
+			url:    "https://registry//proj//plugin.wasm",
+		},
+		{
+			name:       "disallowed host",
+			policy:     &Policy{AllowedURLPrefixes: []string{"oci://registry"}},
+			url:        "oci://registry.evil/plugin:v1",
+			wantReason: urlNotAllowed,
+		},
+		{
+			name:       "disallowed user info",
+			policy:     &Policy{AllowedURLPrefixes: []string{"https://registry/"}},
+			url:        "https://registry@evil/plugin.wasm",
+			wantReason: urlNotAllowed,
+		},
+		{
+			name:       "disallowed scheme",
+			policy:     &Policy{AllowedURLPrefixes: []string{"https://registry/"}},
+			url:        "http://registry/plugin.wasm",
+			wantReason: urlNotAllowed,
+		},
+		{
+			name:       "signature required for http",
+			policy:     &Policy{PublicKey: &key.PublicKey},
+			url:        "https://any",
+			wantReason: signatureInvalid,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.policy.Allow(c.url, c.checksum)
+			if c.wantReason == "" {
+				if err != nil {
+					t.Fatalf("got unexpected error %v", err)
+				}
+				return
+			}
+			pe, ok := err.(*PolicyError)
+			if !ok {
+				t.Fatalf("got error %v, want policy error", err)
+			}
+			if pe.Reason != c.wantReason {
+				t.Errorf("got reason %v, want %v", pe.Reason, c.wantReason)
+			}
+		})
+	}
+}
+
+func TestNewPolicy(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "cosign.pub")
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := NewPolicy([]string{" oci://registry/ ", ""}, true, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.AllowedURLPrefixes) != 1 || p.AllowedURLPrefixes[0] != "oci://registry/" {
+		t.Errorf("got allowed prefixes %v, want [oci://registry/]", p.AllowedURLPrefixes)
+	}
+	if !p.RequireChecksum {
+		t.Errorf("got require checksum false, want true")
+	}
+	if !key.PublicKey.Equal(p.PublicKey) {
+		t.Errorf("got unexpected public key %v", p.PublicKey)
+	}
+
+	if _, err := NewPolicy(nil, false, filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Errorf("got no error for missing public key file")
+	}
+}
+
+// sign pushes a cosign style signature of the image with the given digest to the registry.
+func sign(t *testing.T, reg *testRegistry, repo, digest string, key *ecdsa.PrivateKey) {
+	t.Helper()
+	payload := []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"%s/%s"},`+
+		`"image":{"docker-manifest-digest":"%s"},"type":"cosign container image signature"},"optional":null}`,
+		reg.host(), repo, digest))
+	h := sha256.Sum256(payload)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, h[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	layer := reg.addBlob(cosignSignatureMediaType, payload)
+	layer.Annotations = map[string]string{cosignSignatureAnnotation: base64.StdEncoding.EncodeToString(sig)}
+	reg.addManifest(repo, strings.Replace(digest, ":", "-", 1)+".sig", imageManifest{
+		MediaType: ociManifestMediaType,
+		Config:    reg.addBlob("application/vnd.oci.image.config.v1+json", []byte("{}")),
+		Layers:    []imageDescriptor{layer},
+	})
+}
+
+func TestWasmCacheSignatureVerification(t *testing.T) {
+	trusted, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	untrusted, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reg := newTestRegistry(t)
+	signedDigest := reg.push("wasm/signed", "v1", wasmConfigMediaType, map[string][]byte{wasmLayerMediaType: []byte("signed")})
+	sign(t, reg, "wasm/signed", signedDigest, trusted)
+	untrustedDigest := reg.push("wasm/untrusted", "v1", wasmConfigMediaType, map[string][]byte{wasmLayerMediaType: []byte("untrusted")})
+	sign(t, reg, "wasm/untrusted", untrustedDigest, untrusted)
+	reg.push("wasm/unsigned", "v1", wasmConfigMediaType, map[string][]byte{wasmLayerMediaType: []byte("unsigned")})
+	// The first signature of the image cannot be fetched, the second one is valid.
+	partialDigest := reg.push("wasm/partial", "v1", wasmConfigMediaType, map[string][]byte{wasmLayerMediaType: []byte("partial")})
+	sign(t, reg, "wasm/partial", partialDigest, trusted)
+	sigTag := strings.Replace(partialDigest, ":", "-", 1) + ".sig"
+	sigManifest := imageManifest{}
+	if err := json.Unmarshal(reg.manifests["wasm/partial@"+sigTag], &sigManifest); err != nil {
+		t.Fatal(err)
+	}
+	missing := imageDescriptor{
+		MediaType:   cosignSignatureMediaType,
+		Digest:      "sha256:0000000000000000000000000000000000000000000000000000000000000000",
+		Size:        1,
+		Annotations: map[string]string{cosignSignatureAnnotation: base64.StdEncoding.EncodeToString([]byte("sig"))},
+	}
+	sigManifest.Layers = append([]imageDescriptor{missing}, sigManifest.Layers...)
+	reg.addManifest("wasm/partial", sigTag, sigManifest)
+
+	cases := []struct {
+		name    string
+		repo    string
+		wantErr bool
+	}{
+		{
+			name: "signed by trusted key",
+			repo: "wasm/signed",
+		},
+		{
+			name: "first signature unavailable",
+			repo: "wasm/partial",
+		},
+		{
+			name:    "signed by untrusted key",
+			repo:    "wasm/untrusted",
+			wantErr: true,
+		},
+		{
+			name:    "unsigned",
+			repo:    "wasm/unsigned",
+			wantErr: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cache := NewLocalFileCache(t.TempDir(), DefaultWasmModulePurgeInteval, DefaultWasmModuleExpiry)
+			defer close(cache.stopChan)
+			cache.SetPolicy(&Policy{PublicKey: &trusted.PublicKey})
+
+			_, err := cache.Get("oci://"+reg.host()+"/"+c.repo+":v1", "", 0, nil)
+			if !c.wantErr {
+				if err != nil {
+					t.Fatalf("got unexpected error %v", err)
+				}
+				return
+			}
+			pe, ok := err.(*PolicyError)
+			if !ok || pe.Reason != signatureInvalid {
+				t.Errorf("got error %v, want signature policy error", err)
+			}
+		})
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"context"
 	"crypto/x509"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net"
@@ -101,18 +102,18 @@ func GenKeyCertK8sCA(certClient certclient.CertificateSigningRequestInterface, d
 	}
 
 	certChain, caCert, err := SignCSRK8s(certClient,
-		csrName, csrSpec, dnsName, caFilePath, true)
+		csrName, csrSpec, dnsName, caFilePath, true, true, certWatchTimeout)
 	return certChain, keyPEM, caCert, err
 }
 
 // SignCSRK8sCA generates a certificate from CSR using the K8s CA
 // 1. Submit a CSR
-// 2. Approve a CSR
-// 3. Read the signed certificate
+// 2. Approve a CSR, unless approveCsr is false and approval is left to an external approver
+// 3. Read the signed certificate, waiting up to watchTimeout for it to be issued
 // 4. Clean up the artifacts (e.g., delete CSR)
 func SignCSRK8s(certClient certclient.CertificateSigningRequestInterface,
 	csrName string, csrSpec *cert.CertificateSigningRequestSpec,
-	dnsName, caFilePath string, appendCaCert bool) ([]byte, []byte, error) {
+	dnsName, caFilePath string, approveCsr, appendCaCert bool, watchTimeout time.Duration) ([]byte, []byte, error) {
 	// 1. Submit the CSR
 	numRetries := 3
 	r, err := submitCSR(certClient, csrName, csrSpec, numRetries)
@@ -123,27 +124,31 @@ func SignCSRK8s(certClient certclient.CertificateSigningRequestInterface,
 		return nil, nil, fmt.Errorf("the CSR returned is nil")
 	}
 	// 2. Approve a CSR
-	log.Debugf("approve CSR (%v) ...", csrName)
-	csrMsg := fmt.Sprintf("CSR (%s) for the certificate (%s) is approved", csrName, dnsName)
-	r.Status.Conditions = append(r.Status.Conditions, cert.CertificateSigningRequestCondition{
-		Type:    cert.CertificateApproved,
-		Reason:  csrMsg,
-		Message: csrMsg,
-	})
-	reqApproval, err := certClient.UpdateApproval(context.TODO(), r, metav1.UpdateOptions{})
-	if err != nil {
-		log.Errorf("failed to approve CSR (%v): %v", csrName, err)
-		errCsr := cleanUpCertGen(certClient, csrName)
-		if errCsr != nil {
-			log.Errorf("failed to clean up CSR (%v): %v", csrName, err)
+	if approveCsr {
+		log.Debugf("approve CSR (%v) ...", csrName)
+		csrMsg := fmt.Sprintf("CSR (%s) for the certificate (%s) is approved", csrName, dnsName)
+		r.Status.Conditions = append(r.Status.Conditions, cert.CertificateSigningRequestCondition{
+			Type:    cert.CertificateApproved,
+			Reason:  csrMsg,
+			Message: csrMsg,
+		})
+		reqApproval, err := certClient.UpdateApproval(context.TODO(), r, metav1.UpdateOptions{})
+		if err != nil {
+			log.Errorf("failed to approve CSR (%v): %v", csrName, err)
+			errCsr := cleanUpCertGen(certClient, csrName)
+			if errCsr != nil {
+				log.Errorf("failed to clean up CSR (%v): %v", csrName, err)
+			}
+			return nil, nil, err
 		}
-		return nil, nil, err
+		log.Debugf("CSR (%v) is approved: %v", csrName, reqApproval)
+	} else {
+		log.Debugf("waiting for CSR (%v) to be approved externally ...", csrName)
 	}
-	log.Debugf("CSR (%v) is approved: %v", csrName, reqApproval)
 
 	// 3. Read the signed certificate
 	certChain, caCert, err := readSignedCertificate(certClient,
-		csrName, certReadInterval, certWatchTimeout, maxNumCertRead, caFilePath, appendCaCert)
+		csrName, certReadInterval, watchTimeout, maxNumCertRead, caFilePath, appendCaCert)
 	if err != nil {
 		log.Errorf("failed to read signed cert. (%v): %v", csrName, err)
 		errCsr := cleanUpCertGen(certClient, csrName)
@@ -274,8 +279,8 @@ func readSignedCertificate(certClient certclient.CertificateSigningRequestInterf
 				}
 				return nil, nil, err
 			}
-			if r.Status.Certificate != nil {
-				// Certificate is ready
+			if r.Status.Certificate != nil || isCsrFailed(r) {
+				// Certificate is ready, or will never be issued
 				reqSigned = r
 				break
 			}
@@ -293,11 +298,13 @@ func readSignedCertificate(certClient certclient.CertificateSigningRequestInterf
 	}
 	if reqSigned.Status.Certificate == nil {
 		log.Errorf("failed to read the certificate for CSR (%v), nil cert", csrName)
-		// Output the first CertificateDenied condition, if any, in the status
+		errMsg := fmt.Sprintf("failed to read the certificate for CSR (%v), nil cert", csrName)
+		// Output the first CertificateDenied or CertificateFailed condition, if any, in the status
 		for _, c := range reqSigned.Status.Conditions {
-			if c.Type == cert.CertificateDenied {
-				log.Errorf("CertificateDenied, name: %v, uid: %v, cond-type: %v, cond: %s",
-					reqSigned.Name, reqSigned.UID, c.Type, c.String())
+			if c.Type == cert.CertificateDenied || c.Type == certificateFailed {
+				log.Errorf("%v, name: %v, uid: %v, cond-type: %v, cond: %s",
+					c.Type, reqSigned.Name, reqSigned.UID, c.Type, c.String())
+				errMsg = fmt.Sprintf("CSR (%v) is %v: %v %v", csrName, c.Type, c.Reason, c.Message)
 				break
 			}
 		}
@@ -305,7 +312,7 @@ func readSignedCertificate(certClient certclient.CertificateSigningRequestInterf
 		if errCsr != nil {
 			log.Errorf("failed to clean up CSR (%v): %v", csrName, errCsr)
 		}
-		return nil, nil, fmt.Errorf("failed to read the certificate for CSR (%v), nil cert", csrName)
+		return nil, nil, errors.New(errMsg)
 	}
 
 	log.Debugf("the length of the certificate is %v", len(reqSigned.Status.Certificate))
@@ -337,6 +344,9 @@ func readSignedCertificate(certClient certclient.CertificateSigningRequestInterf
 		}
 		return nil, nil, fmt.Errorf("failed to append CA certificate")
 	}
+	// An external signer may return intermediate certificates after the leaf.
+	intermediates := x509.NewCertPool()
+	intermediates.AppendCertsFromPEM(certPEM)
 	certParsed, err := util.ParsePemEncodedCertificate(certPEM)
 	if err != nil {
 		log.Errorf("failed to parse the certificate: %v", err)
@@ -347,7 +357,8 @@ func readSignedCertificate(certClient certclient.CertificateSigningRequestInterf
 		return nil, nil, fmt.Errorf("failed to parse the certificate: %v", err)
 	}
 	_, err = certParsed.Verify(x509.VerifyOptions{
-		Roots: roots,
+		Roots:         roots,
+		Intermediates: intermediates,
 	})
 	if err != nil {
 		log.Errorf("failed to verify the certificate chain: %v", err)
@@ -366,7 +377,23 @@ func readSignedCertificate(certClient certclient.CertificateSigningRequestInterf
 	return certChain, caCert, nil
 }
 
+// certificateFailed is the condition set by a signer that failed to issue a certificate.
+// It is not defined in the v1beta1 API package.
+const certificateFailed cert.RequestConditionType = "Failed"
+
+// isCsrFailed returns whether the CSR has been denied or has failed, in which case no
+// certificate will be issued for it.
+func isCsrFailed(r *cert.CertificateSigningRequest) bool {
+	for _, c := range r.Status.Conditions {
+		if c.Type == cert.CertificateDenied || c.Type == certificateFailed {
+			return true
+		}
+	}
+	return false
+}
+
 // Return signed CSR through a watcher. If no CSR is read, return nil.
+// A CSR that is denied or has failed is returned as well, without a certificate.
 // The following nonlint is to fix the lint error: `certClient` can be `k8s.io/client-go/tools/cache.Watcher` (interfacer)
 // nolint: interfacer
 func readSignedCsr(certClient certclient.CertificateSigningRequestInterface, csrName string, timeout time.Duration) *cert.CertificateSigningRequest {
@@ -377,13 +404,21 @@ func readSignedCsr(certClient certclient.CertificateSigningRequestInterface, csr
 		log.Errorf("err when watching CSR %v: %v", csrName, err)
 		return nil
 	}
+	defer watcher.Stop()
 	// Set a timeout
 	timer := time.After(timeout)
 	for {
 		select {
-		case r := <-watcher.ResultChan():
-			reqSigned := r.Object.(*cert.CertificateSigningRequest)
-			if reqSigned.Status.Certificate != nil {
+		case r, ok := <-watcher.ResultChan():
+			if !ok {
+				log.Errorf("watch closed for CSR %v", csrName)
+				return nil
+			}
+			reqSigned, ok := r.Object.(*cert.CertificateSigningRequest)
+			if !ok || reqSigned.Name != csrName {
+				continue
+			}
+			if reqSigned.Status.Certificate != nil || isCsrFailed(reqSigned) {
 				return reqSigned
 			}
 		case <-timer:
@@ -17,8 +17,10 @@ package cache
 
 import (
 	"bytes"
+	"crypto/x509"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -42,6 +44,8 @@ var (
 	cacheLog = istiolog.RegisterScope("cache", "cache debugging", 0)
 	// The total timeout for any credential retrieval process, default value of 10s is used.
 	totalTimeout = time.Second * 10
+	// How often to check whether the CRL file was created, as it cannot be watched until it exists.
+	crlCheckInterval = time.Minute
 )
 
 const (
@@ -113,6 +117,11 @@ type SecretManagerClient struct {
 	// Dynamically configured Trust Bundle
 	configTrustBundle []byte
 
+	// crlPending is true while waiting for the CRL file to be created. Protected by certMutex.
+	crlPending bool
+	// crlExpiry is the expiry of the CRL pushed to the proxy. Protected by certMutex.
+	crlExpiry time.Time
+
 	// queue maintains all certificate rotation events that need to be triggered when they are about to expire
 	queue queue.Delayed
 	stop  chan struct{}
@@ -220,6 +229,7 @@ func (sc *SecretManagerClient) getCachedSecret(resourceName string) (secret *sec
 			ns = &security.SecretItem{
 				ResourceName: resourceName,
 				RootCert:     rootCertBundle,
+				CRL:          sc.readCRL(),
 			}
 			cacheLog.WithLabels("ttl", time.Until(c.ExpireTime)).Info("returned workload trust anchor from cache")
 
@@ -301,6 +311,7 @@ func (sc *SecretManagerClient) GenerateSecret(resourceName string) (secret *secu
 
 	if resourceName == security.RootCertReqResourceName {
 		ns.RootCert = sc.mergeConfigTrustBundle(ns.RootCert)
+		ns.CRL = sc.readCRL()
 	} else {
 		// If periodic cert refresh resulted in discovery of a new root, trigger a ROOTCA request to refresh trust anchor
 		oldRoot := sc.cache.GetRoot()
@@ -361,6 +372,85 @@ func (sc *SecretManagerClient) tryAddFileWatcher(file string, resourceName strin
 	return nil
 }
 
+// readCRL returns the CRL of the CA, or nil if there is none. The CRL file is watched, to push
+// the updated CRL to the proxy; an empty file means revocation is enabled, but nothing is revoked
+// by serial number. If the file does not exist, it is checked for periodically. An expired CRL is not returned, as the proxy would reject all the
+// peer certificates: the revoked certificates are not enforced until istiod re-signs the CRL.
+func (sc *SecretManagerClient) readCRL() []byte {
+	if sc.configOptions.CRLFilePath == "" {
+		return nil
+	}
+	crl, err := ioutil.ReadFile(sc.configOptions.CRLFilePath)
+	if err != nil {
+		sc.waitForCRL()
+		return nil
+	}
+	sc.addFileWatcher(sc.configOptions.CRLFilePath, security.RootCertReqResourceName)
+	if len(crl) == 0 {
+		return nil
+	}
+	parsed, err := x509.ParseCRL(crl)
+	if err != nil {
+		cacheLog.Warnf("failed to parse the CRL %s: %v", sc.configOptions.CRLFilePath, err)
+		return crl
+	}
+	expiry := parsed.TBSCertList.NextUpdate
+	if expiry.IsZero() {
+		return crl
+	}
+	if !time.Now().Before(expiry) {
+		cacheLog.Warnf("CRL %s expired at %v, the revoked certificates are not enforced until it is renewed",
+			sc.configOptions.CRLFilePath, expiry)
+		return nil
+	}
+	sc.watchCRLExpiry(expiry)
+	return crl
+}
+
+// watchCRLExpiry pushes the root cert to the proxy when the CRL expires, to remove the expired CRL.
+func (sc *SecretManagerClient) watchCRLExpiry(expiry time.Time) {
+	sc.certMutex.Lock()
+	defer sc.certMutex.Unlock()
+	if sc.crlExpiry.Equal(expiry) {
+		return
+	}
+	sc.crlExpiry = expiry
+	sc.queue.PushDelayed(func() error {
+		sc.certMutex.RLock()
+		renewed := !sc.crlExpiry.Equal(expiry)
+		sc.certMutex.RUnlock()
+		if !renewed {
+			cacheLog.Warnf("CRL %s expired, pushing to proxy", sc.configOptions.CRLFilePath)
+			sc.CallUpdateCallback(security.RootCertReqResourceName)
+		}
+		return nil
+	}, time.Until(expiry))
+}
+
+// waitForCRL pushes the CRL to the proxy once the CRL file is created.
+func (sc *SecretManagerClient) waitForCRL() {
+	sc.certMutex.Lock()
+	defer sc.certMutex.Unlock()
+	if sc.crlPending {
+		return
+	}
+	sc.crlPending = true
+	var check func() error
+	check = func() error {
+		if _, err := os.Stat(sc.configOptions.CRLFilePath); err != nil {
+			sc.queue.PushDelayed(check, crlCheckInterval)
+			return nil
+		}
+		sc.certMutex.Lock()
+		sc.crlPending = false
+		sc.certMutex.Unlock()
+		cacheLog.Infof("CRL %s created, pushing to proxy", sc.configOptions.CRLFilePath)
+		sc.CallUpdateCallback(security.RootCertReqResourceName)
+		return nil
+	}
+	sc.queue.PushDelayed(check, crlCheckInterval)
+}
+
 // If there is existing root certificates under a well known path, return true.
 // Otherwise, return false.
 func (sc *SecretManagerClient) rootCertificateExist(filePath string) bool {
@@ -16,8 +16,15 @@ package cache
 
 import (
 	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"fmt"
 	"io/ioutil"
+	"math/big"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -427,6 +434,176 @@ func checkSecret(t *testing.T, sc *SecretManagerClient, name string, expected se
 	verifySecret(t, got, &expected)
 }
 
+func TestWorkloadAgentCRL(t *testing.T) {
+	fakeCACli, err := mock.NewMockCAClient(time.Hour)
+	if err != nil {
+		t.Fatalf("Error creating Mock CA client: %v", err)
+	}
+	originalInterval := crlCheckInterval
+	crlCheckInterval = time.Millisecond * 10
+	defer func() {
+		crlCheckInterval = originalInterval
+	}()
+	crlPath := filepath.Join(t.TempDir(), "ca-crl.pem")
+	u := NewUpdateTracker(t)
+	sc := createCache(t, fakeCACli, u.Callback, security.Options{CRLFilePath: crlPath})
+	if _, err := sc.GenerateSecret(security.WorkloadKeyCertResourceName); err != nil {
+		t.Fatalf("Failed to get secrets: %v", err)
+	}
+	u.Expect(map[string]int{security.RootCertReqResourceName: 1})
+	u.Reset()
+	rootCert := []byte(fakeCACli.GeneratedCerts[0][2])
+
+	// Without a CRL, only the root cert is distributed.
+	checkSecret(t, sc, security.RootCertReqResourceName, security.SecretItem{
+		ResourceName: security.RootCertReqResourceName,
+		RootCert:     rootCert,
+	})
+
+	// The first CRL is pushed to the proxy when the file is created.
+	crl := []byte("-----BEGIN X509 CRL-----\ncrl1\n-----END X509 CRL-----\n")
+	if err := file.AtomicWrite(crlPath, crl, os.FileMode(0644)); err != nil {
+		t.Fatal(err)
+	}
+	u.Expect(map[string]int{security.RootCertReqResourceName: 1})
+	u.Reset()
+	checkSecret(t, sc, security.RootCertReqResourceName, security.SecretItem{
+		ResourceName: security.RootCertReqResourceName,
+		RootCert:     rootCert,
+		CRL:          crl,
+	})
+
+	// An updated CRL is pushed to the proxy.
+	rotatedCRL := []byte("-----BEGIN X509 CRL-----\ncrl2\n-----END X509 CRL-----\n")
+	if err := file.AtomicWrite(crlPath, rotatedCRL, os.FileMode(0644)); err != nil {
+		t.Fatal(err)
+	}
+	u.Expect(map[string]int{security.RootCertReqResourceName: 1})
+	checkSecret(t, sc, security.RootCertReqResourceName, security.SecretItem{
+		ResourceName: security.RootCertReqResourceName,
+		RootCert:     rootCert,
+		CRL:          rotatedCRL,
+	})
+}
+
+func TestWorkloadAgentEmptyCRL(t *testing.T) {
+	fakeCACli, err := mock.NewMockCAClient(time.Hour)
+	if err != nil {
+		t.Fatalf("Error creating Mock CA client: %v", err)
+	}
+	// An empty CRL file is watched rather than checked for periodically.
+	originalInterval := crlCheckInterval
+	crlCheckInterval = time.Hour
+	defer func() {
+		crlCheckInterval = originalInterval
+	}()
+	crlPath := filepath.Join(t.TempDir(), "ca-crl.pem")
+	if err := file.AtomicWrite(crlPath, nil, os.FileMode(0644)); err != nil {
+		t.Fatal(err)
+	}
+	u := NewUpdateTracker(t)
+	sc := createCache(t, fakeCACli, u.Callback, security.Options{CRLFilePath: crlPath})
+	if _, err := sc.GenerateSecret(security.WorkloadKeyCertResourceName); err != nil {
+		t.Fatalf("Failed to get secrets: %v", err)
+	}
+	u.Expect(map[string]int{security.RootCertReqResourceName: 1})
+	u.Reset()
+	rootCert := []byte(fakeCACli.GeneratedCerts[0][2])
+	checkSecret(t, sc, security.RootCertReqResourceName, security.SecretItem{
+		ResourceName: security.RootCertReqResourceName,
+		RootCert:     rootCert,
+	})
+
+	crl := []byte("-----BEGIN X509 CRL-----\ncrl1\n-----END X509 CRL-----\n")
+	if err := file.AtomicWrite(crlPath, crl, os.FileMode(0644)); err != nil {
+		t.Fatal(err)
+	}
+	u.Expect(map[string]int{security.RootCertReqResourceName: 1})
+	checkSecret(t, sc, security.RootCertReqResourceName, security.SecretItem{
+		ResourceName: security.RootCertReqResourceName,
+		RootCert:     rootCert,
+		CRL:          crl,
+	})
+}
+
+func TestWorkloadAgentExpiredCRL(t *testing.T) {
+	fakeCACli, err := mock.NewMockCAClient(time.Hour)
+	if err != nil {
+		t.Fatalf("Error creating Mock CA client: %v", err)
+	}
+	crlPath := filepath.Join(t.TempDir(), "ca-crl.pem")
+	u := NewUpdateTracker(t)
+	sc := createCache(t, fakeCACli, u.Callback, security.Options{CRLFilePath: crlPath})
+
+	// An expired CRL is not distributed.
+	if err := file.AtomicWrite(crlPath, newTestCRL(t, time.Now().Add(-time.Hour)), os.FileMode(0644)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sc.GenerateSecret(security.WorkloadKeyCertResourceName); err != nil {
+		t.Fatalf("Failed to get secrets: %v", err)
+	}
+	u.Expect(map[string]int{security.RootCertReqResourceName: 1})
+	u.Reset()
+	rootCert := []byte(fakeCACli.GeneratedCerts[0][2])
+	checkSecret(t, sc, security.RootCertReqResourceName, security.SecretItem{
+		ResourceName: security.RootCertReqResourceName,
+		RootCert:     rootCert,
+	})
+
+	// A valid CRL is distributed until it expires, then removed from the proxy.
+	crl := newTestCRL(t, time.Now().Add(2*time.Second))
+	if err := file.AtomicWrite(crlPath, crl, os.FileMode(0644)); err != nil {
+		t.Fatal(err)
+	}
+	u.Expect(map[string]int{security.RootCertReqResourceName: 1})
+	u.Reset()
+	checkSecret(t, sc, security.RootCertReqResourceName, security.SecretItem{
+		ResourceName: security.RootCertReqResourceName,
+		RootCert:     rootCert,
+		CRL:          crl,
+	})
+	u.Expect(map[string]int{security.RootCertReqResourceName: 1})
+	checkSecret(t, sc, security.RootCertReqResourceName, security.SecretItem{
+		ResourceName: security.RootCertReqResourceName,
+		RootCert:     rootCert,
+	})
+}
+
+// newTestCRL returns a PEM encoded CRL, signed by a self-signed CA, valid until nextUpdate.
+func newTestCRL(t *testing.T, nextUpdate time.Time) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{Organization: []string{"cluster.local"}},
+		NotBefore:             time.Now().Add(-2 * time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: nextUpdate.Add(-time.Hour),
+		NextUpdate: nextUpdate,
+	}, issuer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crl})
+}
+
 func TestWorkloadAgentGenerateSecretFromFileOverSdsWithBogusFiles(t *testing.T) {
 	originalTimeout := totalTimeout
 	totalTimeout = time.Millisecond * 1
@@ -479,6 +656,9 @@ func verifySecret(t *testing.T, gotSecret *security.SecretItem, expectedSecret *
 			t.Fatalf("root cert: expected %v but got %v", expectedSecret.RootCert,
 				gotSecret.RootCert)
 		}
+		if !bytes.Equal(expectedSecret.CRL, gotSecret.CRL) {
+			t.Fatalf("CRL: expected %s but got %s", string(expectedSecret.CRL), string(gotSecret.CRL))
+		}
 	} else {
 		if !bytes.Equal(expectedSecret.CertificateChain, gotSecret.CertificateChain) {
 			t.Fatalf("cert chain: expected %s but got %s", string(expectedSecret.CertificateChain),
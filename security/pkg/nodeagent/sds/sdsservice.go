@@ -208,15 +208,23 @@ func toEnvoySecret(s *security.SecretItem) *tls.Secret {
 
 	cfg, ok := model.SdsCertificateConfigFromResourceName(s.ResourceName)
 	if s.ResourceName == security.RootCertReqResourceName || (ok && cfg.IsRootCertificate()) {
-		secret.Type = &tls.Secret_ValidationContext{
-			ValidationContext: &tls.CertificateValidationContext{
-				TrustedCa: &core.DataSource{
-					Specifier: &core.DataSource_InlineBytes{
-						InlineBytes: s.RootCert,
-					},
+		validationContext := &tls.CertificateValidationContext{
+			TrustedCa: &core.DataSource{
+				Specifier: &core.DataSource_InlineBytes{
+					InlineBytes: s.RootCert,
 				},
 			},
 		}
+		if len(s.CRL) > 0 {
+			validationContext.Crl = &core.DataSource{
+				Specifier: &core.DataSource_InlineBytes{
+					InlineBytes: s.CRL,
+				},
+			}
+		}
+		secret.Type = &tls.Secret_ValidationContext{
+			ValidationContext: validationContext,
+		}
 	} else {
 		secret.Type = &tls.Secret_TlsCertificate{
 			TlsCertificate: &tls.TlsCertificate{
@@ -78,6 +78,7 @@ type Expectation struct {
 	CertChain    []byte
 	Key          []byte
 	RootCert     []byte
+	CRL          []byte
 }
 
 func (s *TestServer) Verify(resp *discovery.DiscoveryResponse, expectations ...Expectation) *discovery.DiscoveryResponse {
@@ -93,6 +94,7 @@ func (s *TestServer) Verify(resp *discovery.DiscoveryResponse, expectations ...E
 			Key:          scrt.GetTlsCertificate().GetPrivateKey().GetInlineBytes(),
 			CertChain:    scrt.GetTlsCertificate().GetCertificateChain().GetInlineBytes(),
 			RootCert:     scrt.GetValidationContext().GetTrustedCa().GetInlineBytes(),
+			CRL:          scrt.GetValidationContext().GetCrl().GetInlineBytes(),
 		}
 		if diff := cmp.Diff(e, r); diff != "" {
 			s.t.Fatalf("got diff: %v", diff)
@@ -243,6 +245,23 @@ func TestSDS(t *testing.T) {
 		// No need to push a new root if just the cert changes
 		root.ExpectNoResponse()
 	})
+	t.Run("push crl", func(t *testing.T) {
+		s := setupSDS(t)
+		root := s.Connect()
+		s.Verify(root.RequestResponseAck(&discovery.DiscoveryRequest{ResourceNames: []string{rootResourceName}}), expectRoot)
+
+		crl := []byte("fake crl")
+		s.UpdateSecret(rootResourceName, &ca2.SecretItem{
+			RootCert:     fakeRootCert,
+			CRL:          crl,
+			ResourceName: rootResourceName,
+		})
+		s.Verify(root.ExpectResponse(), Expectation{
+			ResourceName: rootResourceName,
+			RootCert:     fakeRootCert,
+			CRL:          crl,
+		})
+	})
 	t.Run("reconnect", func(t *testing.T) {
 		s := setupSDS(t)
 		c := s.Connect()
@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"sync"
 	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -256,6 +257,14 @@ type IstioCA struct {
 	// rootCertRotator periodically rotates self-signed root cert for CA. It is nil
 	// if CA is not self-signed CA.
 	rootCertRotator *SelfSignedCARootCertRotator
+
+	revocationMutex sync.RWMutex
+	// revocations are the entries of the revocation list.
+	revocations []RevocationEntry
+	// revokedIDs are the SPIFFE IDs the CA does not sign certificates for.
+	revokedIDs map[string]RevocationEntry
+	// revocationCertTTL, if not zero, caps the TTL of the workload certificates.
+	revocationCertTTL time.Duration
 }
 
 // NewIstioCA returns a new IstioCA instance.
@@ -369,6 +378,10 @@ func (ca *IstioCA) sign(csrPEM []byte, subjectIDs []string, requestedLifetime ti
 		return nil, caerror.NewError(caerror.CSRError, err)
 	}
 
+	if id := ca.revokedIdentity(subjectIDs); id != "" {
+		return nil, caerror.NewError(caerror.IdentityRevoked, fmt.Errorf("the certificates of %s are revoked", id))
+	}
+
 	lifetime := requestedLifetime
 	// If the requested requestedLifetime is non-positive, apply the default TTL.
 	if requestedLifetime.Seconds() <= 0 {
@@ -379,6 +392,9 @@ func (ca *IstioCA) sign(csrPEM []byte, subjectIDs []string, requestedLifetime ti
 		return nil, caerror.NewError(caerror.TTLError, fmt.Errorf(
 			"requested TTL %s is greater than the max allowed TTL %s", requestedLifetime, ca.maxCertTTL))
 	}
+	if !forCA {
+		lifetime = ca.capCertTTL(lifetime)
+	}
 
 	certBytes, err := util.GenCertFromCSR(csr, signingCert, csr.PublicKey, *signingKey, subjectIDs, lifetime, forCA)
 	if err != nil {
@@ -0,0 +1,599 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/util/retry"
+
+	"istio.io/pkg/log"
+	"istio.io/pkg/monitoring"
+)
+
+var pluggedCertRotatorLog = log.RegisterScope("cacertrotator", "Plugged-in CA cert rotator log", 0)
+
+var (
+	rotationPhaseGauge = monitoring.NewGauge(
+		"citadel_server_ca_rotation_phase",
+		"The phase of the plugged-in CA certificates rotation: 0 when idle, 1 while distributing "+
+			"the new roots, 2 while retiring the old roots.",
+	)
+	rotationTrustedRootsGauge = monitoring.NewGauge(
+		"citadel_server_ca_rotation_trusted_roots",
+		"The number of root certificates in the trust bundle distributed to workloads.",
+	)
+	rotationNextTransitionTimestamp = monitoring.NewGauge(
+		"citadel_server_ca_rotation_next_transition_timestamp",
+		"The unix timestamp, in seconds, of the next phase of the plugged-in CA certificates rotation. "+
+			"Zero when no rotation is in progress.",
+	)
+	rotationCounts = monitoring.NewSum(
+		"citadel_server_ca_rotation_count",
+		"The number of times the CA switched to a new plugged-in signing certificate.",
+	)
+	rotationErrorCounts = monitoring.NewSum(
+		"citadel_server_ca_rotation_err_count",
+		"The number of errors occurred when reloading the plugged-in CA certificates.",
+	)
+)
+
+func init() {
+	monitoring.MustRegister(
+		rotationPhaseGauge,
+		rotationTrustedRootsGauge,
+		rotationNextTransitionTimestamp,
+		rotationCounts,
+		rotationErrorCounts,
+	)
+}
+
+// RotationPhase is the phase of a rotation of the plugged-in CA certificates.
+type RotationPhase string
+
+const (
+	// RotationIdle means no rotation is in progress: the trust bundle only contains the roots
+	// of the signing certificate.
+	RotationIdle RotationPhase = "Idle"
+	// RotationDistributingTrust means the roots of a new signing certificate are distributed
+	// in the trust bundle, while the old signing certificate is still used.
+	RotationDistributingTrust RotationPhase = "DistributingTrust"
+	// RotationRetiringRoots means the new signing certificate is used, while the old roots are
+	// kept in the trust bundle until the workload certificates they issued expire.
+	RotationRetiringRoots RotationPhase = "RetiringRoots"
+	// RotationDisabled is reported when the CA certificates are not rotated by a PluggedCertRotator.
+	RotationDisabled RotationPhase = "Disabled"
+)
+
+var rotationPhaseValues = map[RotationPhase]float64{
+	RotationIdle:              0,
+	RotationDistributingTrust: 1,
+	RotationRetiringRoots:     2,
+}
+
+// PluggedCertRotatorConfig is the configuration of a PluggedCertRotator.
+type PluggedCertRotatorConfig struct {
+	SigningCertFile string
+	SigningKeyFile  string
+	CertChainFile   string
+	RootCertFile    string
+	// TrustPropagationPeriod is how long new roots are distributed before the signing certificate
+	// they issued is used. It must be long enough for all workloads to refresh their trust bundle.
+	TrustPropagationPeriod time.Duration
+	// OverlapPeriod is how long old roots are kept in the trust bundle after the signing certificate
+	// changed. It must be longer than the TTL of workload certificates.
+	OverlapPeriod time.Duration
+	// StateStore, if set, persists the state of the rotation, so that a restarted CA resumes it.
+	StateStore RotationStateStore
+}
+
+// RotationStateAnnotation is the annotation of the cacerts Secret holding the persisted rotation state.
+const RotationStateAnnotation = "istio.io/ca-rotation-state"
+
+// PersistedRotationState is the state of a rotation that survives restarts. It only holds public
+// certificates: the signing certificate and key of a restarted CA are always read from the plugged-in files.
+type PersistedRotationState struct {
+	Phase          RotationPhase `json:"phase"`
+	PhaseStarted   time.Time     `json:"phaseStarted"`
+	NextTransition time.Time     `json:"nextTransition,omitempty"`
+	// TrustedRoots are the PEM-encoded roots in the trust bundle distributed to workloads.
+	TrustedRoots string `json:"trustedRoots"`
+	// RetiringRoots are the PEM-encoded trusted roots that will be removed at NextTransition.
+	RetiringRoots string `json:"retiringRoots,omitempty"`
+}
+
+// RotationStateStore persists the state of a rotation of the plugged-in CA certificates.
+type RotationStateStore interface {
+	// Load returns the persisted state, or nil if there is none.
+	Load() (*PersistedRotationState, error)
+	Save(state *PersistedRotationState) error
+}
+
+// secretRotationStateStore persists the rotation state as an annotation of the Secret the plugged-in
+// certificates are mounted from.
+type secretRotationStateStore struct {
+	client    corev1.SecretsGetter
+	namespace string
+	name      string
+}
+
+// NewSecretRotationStateStore returns a RotationStateStore backed by the RotationStateAnnotation of the
+// given Secret. Nothing is persisted if the Secret does not exist.
+func NewSecretRotationStateStore(client corev1.SecretsGetter, namespace, name string) RotationStateStore {
+	return &secretRotationStateStore{client: client, namespace: namespace, name: name}
+}
+
+func (s *secretRotationStateStore) Load() (*PersistedRotationState, error) {
+	secret, err := s.client.Secrets(s.namespace).Get(context.TODO(), s.name, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read the %s/%s secret: %v", s.namespace, s.name, err)
+	}
+	value, f := secret.Annotations[RotationStateAnnotation]
+	if !f {
+		return nil, nil
+	}
+	state := &PersistedRotationState{}
+	if err := json.Unmarshal([]byte(value), state); err != nil {
+		return nil, fmt.Errorf("failed to parse the %s annotation of the %s/%s secret: %v",
+			RotationStateAnnotation, s.namespace, s.name, err)
+	}
+	return state, nil
+}
+
+func (s *secretRotationStateStore) Save(state *PersistedRotationState) error {
+	value, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		secret, err := s.client.Secrets(s.namespace).Get(context.TODO(), s.name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if secret.Annotations == nil {
+			secret.Annotations = map[string]string{}
+		}
+		secret.Annotations[RotationStateAnnotation] = string(value)
+		_, err = s.client.Secrets(s.namespace).Update(context.TODO(), secret, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to save the rotation state in the %s/%s secret: %v", s.namespace, s.name, err)
+	}
+	return nil
+}
+
+// CertInfo describes a certificate of the CA.
+type CertInfo struct {
+	Subject      string    `json:"subject"`
+	Issuer       string    `json:"issuer"`
+	SerialNumber string    `json:"serialNumber"`
+	NotAfter     time.Time `json:"notAfter"`
+}
+
+// RotationStatus is the state of the plugged-in CA certificates rotation.
+type RotationStatus struct {
+	Phase          RotationPhase `json:"phase"`
+	PhaseStarted   time.Time     `json:"phaseStarted"`
+	NextTransition *time.Time    `json:"nextTransition,omitempty"`
+	// SigningCert is the certificate currently used to sign workload certificates.
+	SigningCert *CertInfo `json:"signingCert,omitempty"`
+	// PendingSigningCert is the certificate that will be used once its roots are distributed.
+	PendingSigningCert *CertInfo `json:"pendingSigningCert,omitempty"`
+	// TrustedRoots are the roots in the trust bundle distributed to workloads.
+	TrustedRoots []CertInfo `json:"trustedRoots"`
+	// RetiringRoots are the trusted roots that will be removed at the end of the overlap period.
+	RetiringRoots []CertInfo `json:"retiringRoots,omitempty"`
+	LastError     string     `json:"lastError,omitempty"`
+}
+
+// pluggedCerts is a set of PEM-encoded plugged-in CA certificates.
+type pluggedCerts struct {
+	cert, key, chain, roots []byte
+}
+
+func (c *pluggedCerts) equal(o *pluggedCerts) bool {
+	return o != nil && bytes.Equal(c.cert, o.cert) && bytes.Equal(c.key, o.key) &&
+		bytes.Equal(c.chain, o.chain) && bytes.Equal(c.roots, o.roots)
+}
+
+// PluggedCertRotator rotates the plugged-in CA certificates without downtime. When the plugged-in
+// certificates change, roots that workloads do not trust yet are first added to the trust bundle,
+// and only used for signing after TrustPropagationPeriod. Once the CA signs with the new certificate,
+// the old roots stay in the trust bundle for OverlapPeriod before they are retired.
+type PluggedCertRotator struct {
+	config *PluggedCertRotatorConfig
+	ca     *IstioCA
+	// onUpdate is called after the signing certificate or the trust bundle of the CA changed.
+	onUpdate func()
+
+	mu           sync.Mutex
+	active       *pluggedCerts
+	pending      *pluggedCerts
+	retiring     []byte
+	phase        RotationPhase
+	phaseStarted time.Time
+	next         time.Time
+	timer        *time.Timer
+	// generation is incremented on each phase change, so that stale timers are ignored.
+	generation int
+	lastErr    error
+}
+
+// NewPluggedCertRotator returns a rotator for the plugged-in certificates the CA was created from.
+// If the state of a rotation was persisted by a previous instance of the CA, the rotation is resumed.
+func NewPluggedCertRotator(config *PluggedCertRotatorConfig, ca *IstioCA, onUpdate func()) *PluggedCertRotator {
+	cert, key, chain, roots := ca.GetCAKeyCertBundle().GetAllPem()
+	r := &PluggedCertRotator{
+		config:       config,
+		ca:           ca,
+		onUpdate:     onUpdate,
+		active:       &pluggedCerts{cert: cert, key: key, chain: chain, roots: mergeRoots(roots)},
+		phase:        RotationIdle,
+		phaseStarted: time.Now(),
+	}
+	r.mu.Lock()
+	if err := r.resume(); err != nil {
+		r.lastErr = err
+		rotationErrorCounts.Increment()
+		pluggedCertRotatorLog.Errorf("failed to resume the rotation of the plugged-in CA certificates: %v", err)
+	}
+	r.recordMetrics()
+	r.mu.Unlock()
+	return r
+}
+
+// resume restores the persisted rotation state. The CA was created from the plugged-in files, so it already
+// signs with their certificate. If the previous instance was still distributing the roots of that certificate,
+// the key it signed with is gone: the rotation moves on to retiring the old roots, which are kept in the trust
+// bundle for the whole OverlapPeriod.
+func (r *PluggedCertRotator) resume() error {
+	if r.config.StateStore == nil {
+		return nil
+	}
+	state, err := r.config.StateStore.Load()
+	if err != nil || state == nil {
+		return err
+	}
+	switch state.Phase {
+	case RotationRetiringRoots:
+		r.retiring = subtractRoots([]byte(state.RetiringRoots), r.active.roots)
+		if len(r.retiring) == 0 {
+			break
+		}
+		pluggedCertRotatorLog.Infof("resuming the rotation of the plugged-in CA certificates, retiring %d old root(s) at %v",
+			len(pemCertificates(r.retiring)), state.NextTransition)
+		r.setPhase(RotationRetiringRoots, time.Until(state.NextTransition), r.retire)
+		r.phaseStarted = state.PhaseStarted
+		r.next = state.NextTransition
+		return r.apply()
+	case RotationDistributingTrust:
+		r.retiring = subtractRoots([]byte(state.TrustedRoots), r.active.roots)
+		if len(r.retiring) == 0 {
+			break
+		}
+		if time.Now().Before(state.NextTransition) {
+			pluggedCertRotatorLog.Warnf("restarted while distributing the new roots, which are used for signing "+
+				"before the end of the trust propagation period at %v", state.NextTransition)
+		}
+		r.setPhase(RotationRetiringRoots, r.config.OverlapPeriod, r.retire)
+		return r.apply()
+	}
+	r.retiring = nil
+	if state.Phase == RotationIdle {
+		return nil
+	}
+	// The persisted rotation has nothing left to retire.
+	return r.save()
+}
+
+// save persists the state of the rotation, if a StateStore is configured.
+func (r *PluggedCertRotator) save() error {
+	if r.config.StateStore == nil {
+		return nil
+	}
+	return r.config.StateStore.Save(&PersistedRotationState{
+		Phase:          r.phase,
+		PhaseStarted:   r.phaseStarted,
+		NextTransition: r.next,
+		TrustedRoots:   string(r.trustedRoots()),
+		RetiringRoots:  string(r.retiring),
+	})
+}
+
+// Reload reads the plugged-in certificates and starts a rotation if they changed.
+func (r *PluggedCertRotator) Reload() error {
+	certs, err := r.load()
+	r.mu.Lock()
+	if err != nil {
+		r.lastErr = err
+		r.mu.Unlock()
+		rotationErrorCounts.Increment()
+		return err
+	}
+	if certs.equal(r.active) || certs.equal(r.pending) {
+		r.lastErr = nil
+		r.mu.Unlock()
+		return nil
+	}
+	if containsRoots(r.trustedRoots(), certs.roots) {
+		// The new signing certificate chains to roots the workloads already trust, e.g. when only the
+		// intermediate CA changed, so it can be used right away.
+		pluggedCertRotatorLog.Infof("plugged-in CA certificates changed, switching to the new signing certificate")
+		err = r.activate(certs)
+	} else {
+		pluggedCertRotatorLog.Infof("plugged-in CA certificates changed, distributing the new roots for %v "+
+			"before switching to the new signing certificate", r.config.TrustPropagationPeriod)
+		r.pending = certs
+		r.setPhase(RotationDistributingTrust, r.config.TrustPropagationPeriod, r.activatePending)
+		err = r.apply()
+	}
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	r.notify()
+	return nil
+}
+
+// Status returns the state of the rotation.
+func (r *PluggedCertRotator) Status() RotationStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	status := RotationStatus{
+		Phase:         r.phase,
+		PhaseStarted:  r.phaseStarted,
+		SigningCert:   certInfo(r.active.cert),
+		TrustedRoots:  certInfos(r.trustedRoots()),
+		RetiringRoots: certInfos(r.retiring),
+	}
+	if !r.next.IsZero() {
+		next := r.next
+		status.NextTransition = &next
+	}
+	if r.pending != nil {
+		status.PendingSigningCert = certInfo(r.pending.cert)
+	}
+	if r.lastErr != nil {
+		status.LastError = r.lastErr.Error()
+	}
+	return status
+}
+
+// StaticRotationStatus returns the status of a CA whose certificates are not rotated by a PluggedCertRotator.
+func StaticRotationStatus(ca *IstioCA) RotationStatus {
+	cert, _, _, roots := ca.GetCAKeyCertBundle().GetAllPem()
+	return RotationStatus{
+		Phase:        RotationDisabled,
+		SigningCert:  certInfo(cert),
+		TrustedRoots: certInfos(roots),
+	}
+}
+
+// load reads and verifies the plugged-in certificates.
+func (r *PluggedCertRotator) load() (*pluggedCerts, error) {
+	// NewPluggedCertIstioCAOptions generates a self-signed certificate when the key is missing.
+	if _, err := os.Stat(r.config.SigningKeyFile); err != nil {
+		return nil, fmt.Errorf("failed to read the plugged-in CA key: %v", err)
+	}
+	opts, err := NewPluggedCertIstioCAOptions(r.config.CertChainFile, r.config.SigningCertFile,
+		r.config.SigningKeyFile, r.config.RootCertFile, 0, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	cert, key, chain, roots := opts.KeyCertBundle.GetAllPem()
+	return &pluggedCerts{cert: cert, key: key, chain: chain, roots: mergeRoots(roots)}, nil
+}
+
+// activatePending switches to the pending signing certificate, once its roots have been distributed.
+func (r *PluggedCertRotator) activatePending() error {
+	if r.pending == nil {
+		return nil
+	}
+	pluggedCertRotatorLog.Infof("new roots distributed, switching to the new signing certificate")
+	return r.activate(r.pending)
+}
+
+// activate signs with the given certificates, and retires the roots they don't include after OverlapPeriod.
+func (r *PluggedCertRotator) activate(certs *pluggedCerts) error {
+	r.retiring = subtractRoots(r.trustedRoots(), certs.roots)
+	r.active = certs
+	r.pending = nil
+	if len(r.retiring) == 0 {
+		r.setPhase(RotationIdle, 0, nil)
+	} else {
+		r.setPhase(RotationRetiringRoots, r.config.OverlapPeriod, r.retire)
+	}
+	if err := r.apply(); err != nil {
+		return err
+	}
+	rotationCounts.Increment()
+	return nil
+}
+
+// retire removes the old roots from the trust bundle.
+func (r *PluggedCertRotator) retire() error {
+	pluggedCertRotatorLog.Infof("overlap period ended, removing %d old root(s) from the trust bundle",
+		len(pemCertificates(r.retiring)))
+	r.retiring = nil
+	r.setPhase(RotationIdle, 0, nil)
+	return r.apply()
+}
+
+// setPhase moves to the given phase, and schedules the transition to run after d.
+func (r *PluggedCertRotator) setPhase(phase RotationPhase, d time.Duration, transition func() error) {
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+	r.generation++
+	r.phase = phase
+	r.phaseStarted = time.Now()
+	r.next = time.Time{}
+	if transition == nil {
+		return
+	}
+	r.next = r.phaseStarted.Add(d)
+	generation := r.generation
+	r.timer = time.AfterFunc(d, func() {
+		r.mu.Lock()
+		if generation != r.generation {
+			r.mu.Unlock()
+			return
+		}
+		err := transition()
+		r.mu.Unlock()
+		if err != nil {
+			pluggedCertRotatorLog.Errorf("failed to rotate the plugged-in CA certificates: %v", err)
+			return
+		}
+		r.notify()
+	})
+}
+
+// apply updates the CA with the active signing certificate and the trusted roots, and persists the state.
+func (r *PluggedCertRotator) apply() error {
+	err := r.ca.GetCAKeyCertBundle().VerifyAndSetAll(r.active.cert, r.active.key, r.active.chain, r.trustedRoots())
+	r.lastErr = err
+	r.recordMetrics()
+	if err != nil {
+		rotationErrorCounts.Increment()
+		return fmt.Errorf("failed to update the CA certificates: %v", err)
+	}
+	if err := r.save(); err != nil {
+		// The CA is updated, only a restart would lose the rotation state.
+		r.lastErr = err
+		rotationErrorCounts.Increment()
+		pluggedCertRotatorLog.Errorf("failed to persist the rotation state: %v", err)
+	}
+	return nil
+}
+
+func (r *PluggedCertRotator) notify() {
+	if r.onUpdate != nil {
+		r.onUpdate()
+	}
+}
+
+// trustedRoots returns the roots distributed to workloads.
+func (r *PluggedCertRotator) trustedRoots() []byte {
+	if r.pending != nil {
+		return mergeRoots(r.active.roots, r.pending.roots, r.retiring)
+	}
+	return mergeRoots(r.active.roots, r.retiring)
+}
+
+func (r *PluggedCertRotator) recordMetrics() {
+	rotationPhaseGauge.Record(rotationPhaseValues[r.phase])
+	rotationTrustedRootsGauge.Record(float64(len(pemCertificates(r.trustedRoots()))))
+	if r.next.IsZero() {
+		rotationNextTransitionTimestamp.Record(0)
+	} else {
+		rotationNextTransitionTimestamp.Record(float64(r.next.Unix()))
+	}
+}
+
+// pemCertificates returns the PEM blocks of the certificates in b.
+func pemCertificates(b []byte) []*pem.Block {
+	var blocks []*pem.Block
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(b)
+		if block == nil {
+			return blocks
+		}
+		if block.Type == "CERTIFICATE" {
+			blocks = append(blocks, block)
+		}
+	}
+}
+
+// mergeRoots returns the PEM-encoded union of the given roots, in order and without duplicates.
+func mergeRoots(roots ...[]byte) []byte {
+	var merged []byte
+	seen := map[string]bool{}
+	for _, r := range roots {
+		for _, block := range pemCertificates(r) {
+			if seen[string(block.Bytes)] {
+				continue
+			}
+			seen[string(block.Bytes)] = true
+			merged = append(merged, pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: block.Bytes})...)
+		}
+	}
+	return merged
+}
+
+// subtractRoots returns the roots in a that are not in b.
+func subtractRoots(a, b []byte) []byte {
+	exclude := map[string]bool{}
+	for _, block := range pemCertificates(b) {
+		exclude[string(block.Bytes)] = true
+	}
+	var result []byte
+	for _, block := range pemCertificates(a) {
+		if !exclude[string(block.Bytes)] {
+			result = append(result, pem.EncodeToMemory(block)...)
+		}
+	}
+	return result
+}
+
+// containsRoots returns whether all the roots in b are in a.
+func containsRoots(a, b []byte) bool {
+	return len(subtractRoots(b, a)) == 0
+}
+
+func certInfo(certPEM []byte) *CertInfo {
+	blocks := pemCertificates(certPEM)
+	if len(blocks) == 0 {
+		return nil
+	}
+	cert, err := x509.ParseCertificate(blocks[0].Bytes)
+	if err != nil {
+		return nil
+	}
+	return &CertInfo{
+		Subject:      cert.Subject.String(),
+		Issuer:       cert.Issuer.String(),
+		SerialNumber: hex.EncodeToString(cert.SerialNumber.Bytes()),
+		NotAfter:     cert.NotAfter,
+	}
+}
+
+func certInfos(certsPEM []byte) []CertInfo {
+	var infos []CertInfo
+	for _, block := range pemCertificates(certsPEM) {
+		if info := certInfo(pem.EncodeToMemory(block)); info != nil {
+			infos = append(infos, *info)
+		}
+	}
+	return infos
+}
@@ -0,0 +1,330 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"istio.io/istio/pkg/test/util/retry"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+type testCA struct {
+	certPEM, keyPEM []byte
+}
+
+func newTestRoot(t *testing.T, org string) testCA {
+	t.Helper()
+	certPEM, keyPEM, err := util.GenCertKeyFromOptions(util.CertOptions{
+		TTL:          time.Hour,
+		Org:          org,
+		IsCA:         true,
+		IsSelfSigned: true,
+		RSAKeySize:   2048,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testCA{certPEM: certPEM, keyPEM: keyPEM}
+}
+
+func newTestIntermediate(t *testing.T, root testCA, org string) testCA {
+	t.Helper()
+	rootCert, err := util.ParsePemEncodedCertificate(root.certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootKey, err := util.ParsePemEncodedKey(root.keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM, keyPEM, err := util.GenCertKeyFromOptions(util.CertOptions{
+		TTL:        time.Hour,
+		Org:        org,
+		IsCA:       true,
+		SignerCert: rootCert,
+		SignerPriv: rootKey,
+		RSAKeySize: 2048,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testCA{certPEM: certPEM, keyPEM: keyPEM}
+}
+
+// writePluggedCerts writes the files of the cacerts secret, for an intermediate issued by root.
+func writePluggedCerts(t *testing.T, config *PluggedCertRotatorConfig, intermediate, root testCA) {
+	t.Helper()
+	files := map[string][]byte{
+		config.SigningCertFile: intermediate.certPEM,
+		config.SigningKeyFile:  intermediate.keyPEM,
+		config.CertChainFile:   append(append([]byte{}, intermediate.certPEM...), root.certPEM...),
+		config.RootCertFile:    root.certPEM,
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(name, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func checkCACerts(ca *IstioCA, signingCert []byte, roots ...testCA) error {
+	cert, _, _, rootPEM := ca.GetCAKeyCertBundle().GetAllPem()
+	if !bytes.Equal(cert, signingCert) {
+		return fmt.Errorf("CA signs with an unexpected certificate")
+	}
+	want := [][]byte{}
+	for _, r := range roots {
+		want = append(want, r.certPEM)
+	}
+	wantPEM := mergeRoots(want...)
+	if !containsRoots(rootPEM, wantPEM) || !containsRoots(wantPEM, rootPEM) {
+		return fmt.Errorf("trusted roots: got %d certificates, want %d", len(pemCertificates(rootPEM)), len(roots))
+	}
+	return nil
+}
+
+func TestPluggedCertRotator(t *testing.T) {
+	dir := t.TempDir()
+	config := &PluggedCertRotatorConfig{
+		SigningCertFile:        filepath.Join(dir, "ca-cert.pem"),
+		SigningKeyFile:         filepath.Join(dir, "ca-key.pem"),
+		CertChainFile:          filepath.Join(dir, "cert-chain.pem"),
+		RootCertFile:           filepath.Join(dir, "root-cert.pem"),
+		TrustPropagationPeriod: 200 * time.Millisecond,
+		// The retirement is triggered by the test, once it checked the overlap.
+		OverlapPeriod: time.Hour,
+	}
+	root1 := newTestRoot(t, "root1")
+	int1 := newTestIntermediate(t, root1, "intermediate1")
+	writePluggedCerts(t, config, int1, root1)
+
+	caOpts, err := NewPluggedCertIstioCAOptions(config.CertChainFile, config.SigningCertFile, config.SigningKeyFile,
+		config.RootCertFile, time.Hour, 2*time.Hour, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := NewIstioCA(caOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var updates int32
+	rotator := NewPluggedCertRotator(config, ca, func() { atomic.AddInt32(&updates, 1) })
+
+	// Unchanged certificates are ignored.
+	if err := rotator.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := atomic.LoadInt32(&updates); got != 0 {
+		t.Fatalf("got %d updates for unchanged certificates", got)
+	}
+
+	// A new intermediate issued by the same root is used right away.
+	int1b := newTestIntermediate(t, root1, "intermediate1b")
+	writePluggedCerts(t, config, int1b, root1)
+	if err := rotator.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkCACerts(ca, int1b.certPEM, root1); err != nil {
+		t.Fatal(err)
+	}
+	if status := rotator.Status(); status.Phase != RotationIdle {
+		t.Fatalf("got phase %v after intermediate rotation, want %v", status.Phase, RotationIdle)
+	}
+
+	// A new root is distributed before it is used for signing, and the old root is retired after the overlap.
+	root2 := newTestRoot(t, "root2")
+	int2 := newTestIntermediate(t, root2, "intermediate2")
+	writePluggedCerts(t, config, int2, root2)
+	if err := rotator.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkCACerts(ca, int1b.certPEM, root1, root2); err != nil {
+		t.Fatal(err)
+	}
+	status := rotator.Status()
+	if status.Phase != RotationDistributingTrust || status.PendingSigningCert == nil || status.NextTransition == nil {
+		t.Fatalf("unexpected status while distributing the new root: %+v", status)
+	}
+	if len(status.TrustedRoots) != 2 {
+		t.Fatalf("got %d trusted roots while distributing the new root, want 2", len(status.TrustedRoots))
+	}
+
+	retry.UntilSuccessOrFail(t, func() error {
+		if phase := rotator.Status().Phase; phase != RotationRetiringRoots {
+			return fmt.Errorf("got phase %v, want %v", phase, RotationRetiringRoots)
+		}
+		return checkCACerts(ca, int2.certPEM, root1, root2)
+	}, retry.Delay(10*time.Millisecond), retry.Timeout(5*time.Second))
+	if retiring := rotator.Status().RetiringRoots; len(retiring) != 1 || retiring[0].Subject != "O=root1" {
+		t.Fatalf("unexpected retiring roots: %+v", retiring)
+	}
+	rotator.mu.Lock()
+	rotator.timer.Reset(0)
+	rotator.mu.Unlock()
+
+	retry.UntilSuccessOrFail(t, func() error {
+		if phase := rotator.Status().Phase; phase != RotationIdle {
+			return fmt.Errorf("got phase %v, want %v", phase, RotationIdle)
+		}
+		return checkCACerts(ca, int2.certPEM, root2)
+	}, retry.Delay(10*time.Millisecond), retry.Timeout(5*time.Second))
+	if got := atomic.LoadInt32(&updates); got != 4 {
+		t.Fatalf("got %d updates, want 4", got)
+	}
+
+	// Workload certificates are issued by the new intermediate.
+	csrPEM, _, err := util.GenCSR(util.CertOptions{Host: "spiffe://cluster.local/ns/default/sa/foo", RSAKeySize: 2048})
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM, err := ca.Sign(csrPEM, []string{"spiffe://cluster.local/ns/default/sa/foo"}, time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := util.ParsePemEncodedCertificate(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Issuer.String() != "O=intermediate2" {
+		t.Fatalf("workload certificate issued by %v, want O=intermediate2", cert.Issuer)
+	}
+
+	// Invalid certificates are reported, and don't change the CA.
+	writePluggedCerts(t, config, int1, root2)
+	if err := rotator.Reload(); err == nil {
+		t.Fatal("expected an error for an intermediate not issued by the root")
+	}
+	if status := rotator.Status(); status.LastError == "" {
+		t.Fatal("expected the error in the status")
+	}
+	if err := checkCACerts(ca, int2.certPEM, root2); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPluggedCertRotatorResume(t *testing.T) {
+	dir := t.TempDir()
+	client := fake.NewSimpleClientset(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "cacerts", Namespace: "istio-system"}})
+	config := &PluggedCertRotatorConfig{
+		SigningCertFile:        filepath.Join(dir, "ca-cert.pem"),
+		SigningKeyFile:         filepath.Join(dir, "ca-key.pem"),
+		CertChainFile:          filepath.Join(dir, "cert-chain.pem"),
+		RootCertFile:           filepath.Join(dir, "root-cert.pem"),
+		TrustPropagationPeriod: time.Hour,
+		OverlapPeriod:          2 * time.Hour,
+		StateStore:             NewSecretRotationStateStore(client.CoreV1(), "istio-system", "cacerts"),
+	}
+	// start creates the CA from the plugged-in files, as istiod does on startup.
+	start := func() (*IstioCA, *PluggedCertRotator) {
+		t.Helper()
+		caOpts, err := NewPluggedCertIstioCAOptions(config.CertChainFile, config.SigningCertFile, config.SigningKeyFile,
+			config.RootCertFile, time.Hour, 2*time.Hour, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ca, err := NewIstioCA(caOpts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ca, NewPluggedCertRotator(config, ca, nil)
+	}
+	persisted := func() *PersistedRotationState {
+		t.Helper()
+		state, err := config.StateStore.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return state
+	}
+
+	root1 := newTestRoot(t, "root1")
+	int1 := newTestIntermediate(t, root1, "intermediate1")
+	writePluggedCerts(t, config, int1, root1)
+	_, rotator := start()
+	if state := persisted(); state != nil {
+		t.Fatalf("unexpected state persisted without rotation: %+v", state)
+	}
+
+	root2 := newTestRoot(t, "root2")
+	int2 := newTestIntermediate(t, root2, "intermediate2")
+	writePluggedCerts(t, config, int2, root2)
+	if err := rotator.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	state := persisted()
+	if state == nil || state.Phase != RotationDistributingTrust || len(pemCertificates([]byte(state.TrustedRoots))) != 2 {
+		t.Fatalf("unexpected persisted state while distributing the new root: %+v", state)
+	}
+
+	// A restart while distributing the new root signs with the new intermediate, which is the only key
+	// left, and keeps the old root for the overlap period.
+	ca, rotator := start()
+	if err := checkCACerts(ca, int2.certPEM, root1, root2); err != nil {
+		t.Fatal(err)
+	}
+	status := rotator.Status()
+	if status.Phase != RotationRetiringRoots || len(status.RetiringRoots) != 1 || status.RetiringRoots[0].Subject != "O=root1" {
+		t.Fatalf("unexpected status after restart: %+v", status)
+	}
+	state = persisted()
+	if state.Phase != RotationRetiringRoots || !state.NextTransition.Equal(*status.NextTransition) {
+		t.Fatalf("unexpected persisted state while retiring the old root: %+v", state)
+	}
+
+	// A restart while retiring the old root keeps it until the persisted transition.
+	ca, rotator = start()
+	if err := checkCACerts(ca, int2.certPEM, root1, root2); err != nil {
+		t.Fatal(err)
+	}
+	if status := rotator.Status(); status.Phase != RotationRetiringRoots || !status.NextTransition.Equal(state.NextTransition) {
+		t.Fatalf("unexpected status after restart: %+v", status)
+	}
+
+	// Once the transition is past, the old root is retired on startup.
+	state.NextTransition = time.Now().Add(-time.Minute)
+	if err := config.StateStore.Save(state); err != nil {
+		t.Fatal(err)
+	}
+	ca, rotator = start()
+	retry.UntilSuccessOrFail(t, func() error {
+		if phase := rotator.Status().Phase; phase != RotationIdle {
+			return fmt.Errorf("got phase %v, want %v", phase, RotationIdle)
+		}
+		return checkCACerts(ca, int2.certPEM, root2)
+	}, retry.Delay(10*time.Millisecond), retry.Timeout(5*time.Second))
+	if state := persisted(); state.Phase != RotationIdle || state.RetiringRoots != "" {
+		t.Fatalf("unexpected persisted state after the rotation: %+v", state)
+	}
+
+	// Nothing is persisted when the certificates are not mounted from a Secret.
+	if err := client.CoreV1().Secrets("istio-system").Delete(context.TODO(), "cacerts", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.StateStore.Save(state); err != nil {
+		t.Fatalf("unexpected error without a Secret: %v", err)
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// RevocationsConfigMapName is the ConfigMap, in the Istiod namespace, listing the revoked certificates.
+	RevocationsConfigMapName = "istio-ca-revocations"
+	// RevocationsConfigMapKey is the key of the revocation entries in the ConfigMap.
+	RevocationsConfigMapKey = "revocations.json"
+
+	spiffePrefix = "spiffe://"
+)
+
+// RevocationEntry revokes a certificate by serial number, or all the certificates of a SPIFFE ID.
+type RevocationEntry struct {
+	// SerialNumber is the hex encoded serial number of the revoked certificate.
+	SerialNumber string `json:"serialNumber,omitempty"`
+	// SPIFFEID is the identity whose certificates are revoked.
+	SPIFFEID string `json:"spiffeID,omitempty"`
+	// Reason is why the certificates are revoked.
+	Reason string `json:"reason,omitempty"`
+	// RevokedAt is when the entry was added.
+	RevokedAt time.Time `json:"revokedAt"`
+}
+
+// NewRevocationEntry returns the entry revoking target, which is either a SPIFFE ID or the hex encoded
+// serial number of a certificate.
+func NewRevocationEntry(target, reason string, revokedAt time.Time) (RevocationEntry, error) {
+	entry := RevocationEntry{Reason: reason, RevokedAt: revokedAt}
+	if strings.HasPrefix(target, spiffePrefix) {
+		entry.SPIFFEID = target
+		return entry, nil
+	}
+	serial, err := NormalizeSerialNumber(target)
+	if err != nil {
+		return entry, err
+	}
+	entry.SerialNumber = serial
+	return entry, nil
+}
+
+// Target returns the SPIFFE ID or serial number revoked by the entry.
+func (e RevocationEntry) Target() string {
+	if e.SPIFFEID != "" {
+		return e.SPIFFEID
+	}
+	return e.SerialNumber
+}
+
+// NormalizeSerialNumber returns the serial number as formatted in the revocation entries: lower case
+// hex, without separators and leading zero bytes.
+func NormalizeSerialNumber(serial string) (string, error) {
+	n, ok := new(big.Int).SetString(strings.ReplaceAll(serial, ":", ""), 16)
+	if !ok || n.Sign() <= 0 {
+		return "", fmt.Errorf("invalid serial number %q, expected a SPIFFE ID or a hex encoded serial number", serial)
+	}
+	return hex.EncodeToString(n.Bytes()), nil
+}
+
+// ParseRevocations parses the revocation entries stored in the revocations ConfigMap.
+func ParseRevocations(data string) ([]RevocationEntry, error) {
+	if strings.TrimSpace(data) == "" {
+		return nil, nil
+	}
+	var entries []RevocationEntry
+	if err := json.Unmarshal([]byte(data), &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse the revocation entries: %v", err)
+	}
+	for i, e := range entries {
+		if (e.SerialNumber == "") == (e.SPIFFEID == "") {
+			return nil, fmt.Errorf("revocation entry %d must have either a serial number or a SPIFFE ID", i)
+		}
+		if e.SerialNumber != "" {
+			serial, err := NormalizeSerialNumber(e.SerialNumber)
+			if err != nil {
+				return nil, fmt.Errorf("revocation entry %d: %v", i, err)
+			}
+			entries[i].SerialNumber = serial
+		}
+	}
+	return entries, nil
+}
+
+// MarshalRevocations encodes the revocation entries to be stored in the revocations ConfigMap.
+func MarshalRevocations(entries []RevocationEntry) (string, error) {
+	b, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// SetRevocations replaces the revocation entries of the CA. The CA refuses to sign certificates for
+// the revoked SPIFFE IDs.
+func (ca *IstioCA) SetRevocations(entries []RevocationEntry) {
+	revokedIDs := map[string]RevocationEntry{}
+	for _, e := range entries {
+		if e.SPIFFEID != "" {
+			revokedIDs[e.SPIFFEID] = e
+		}
+	}
+	ca.revocationMutex.Lock()
+	defer ca.revocationMutex.Unlock()
+	ca.revocations = append([]RevocationEntry{}, entries...)
+	ca.revokedIDs = revokedIDs
+}
+
+// Revocations returns the revocation entries of the CA.
+func (ca *IstioCA) Revocations() []RevocationEntry {
+	ca.revocationMutex.RLock()
+	defer ca.revocationMutex.RUnlock()
+	return append([]RevocationEntry{}, ca.revocations...)
+}
+
+// SetRevocationCertTTL caps the TTL of the workload certificates signed by the CA, so that the certificates
+// of a revoked SPIFFE ID expire within ttl. A zero ttl removes the cap.
+func (ca *IstioCA) SetRevocationCertTTL(ttl time.Duration) {
+	ca.revocationMutex.Lock()
+	defer ca.revocationMutex.Unlock()
+	ca.revocationCertTTL = ttl
+}
+
+// capCertTTL returns lifetime, capped to the revocation certificate TTL.
+func (ca *IstioCA) capCertTTL(lifetime time.Duration) time.Duration {
+	ca.revocationMutex.RLock()
+	defer ca.revocationMutex.RUnlock()
+	if ca.revocationCertTTL > 0 && lifetime > ca.revocationCertTTL {
+		return ca.revocationCertTTL
+	}
+	return lifetime
+}
+
+// revokedIdentity returns the first revoked identity of ids, or an empty string.
+func (ca *IstioCA) revokedIdentity(ids []string) string {
+	ca.revocationMutex.RLock()
+	defer ca.revocationMutex.RUnlock()
+	for _, id := range ids {
+		if _, f := ca.revokedIDs[id]; f {
+			return id
+		}
+	}
+	return ""
+}
+
+// CheckCRLSign returns an error if the CA certificate is not allowed to sign CRLs. CA certificates
+// generated by older Istio versions, including self-signed roots that are still reused, lack the
+// cRLSign key usage.
+func CheckCRLSign(cert *x509.Certificate) error {
+	if cert.KeyUsage != 0 && cert.KeyUsage&x509.KeyUsageCRLSign == 0 {
+		return fmt.Errorf("the CA signing certificate %v is not allowed to sign CRLs (cRLSign key usage), "+
+			"the CA certificate must be rotated to revoke certificates", cert.Subject)
+	}
+	return nil
+}
+
+// GenerateCRL returns a PEM encoded CRL, signed by the CA signing certificate and valid for validity,
+// listing the certificates revoked by serial number. The revoked SPIFFE IDs are not listed: the CA refuses
+// to sign certificates for them, and their existing certificates expire within the revocation certificate TTL.
+func (ca *IstioCA) GenerateCRL(validity time.Duration) ([]byte, error) {
+	signingCert, signingKey, _, _ := ca.keyCertBundle.GetAll()
+	if signingCert == nil || signingKey == nil {
+		return nil, fmt.Errorf("the CA is not ready")
+	}
+	if err := CheckCRLSign(signingCert); err != nil {
+		return nil, err
+	}
+	signer, ok := (*signingKey).(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("the CA signing key cannot sign CRLs")
+	}
+
+	revoked := map[string]time.Time{}
+	for _, e := range ca.Revocations() {
+		if e.SerialNumber == "" {
+			continue
+		}
+		if t, f := revoked[e.SerialNumber]; !f || e.RevokedAt.Before(t) {
+			revoked[e.SerialNumber] = e.RevokedAt
+		}
+	}
+	serials := make([]string, 0, len(revoked))
+	for s := range revoked {
+		serials = append(serials, s)
+	}
+	sort.Strings(serials)
+	revokedCerts := make([]pkix.RevokedCertificate, 0, len(serials))
+	for _, s := range serials {
+		n, ok := new(big.Int).SetString(s, 16)
+		if !ok {
+			return nil, fmt.Errorf("invalid serial number %q", s)
+		}
+		revokedCerts = append(revokedCerts, pkix.RevokedCertificate{SerialNumber: n, RevocationTime: revoked[s]})
+	}
+
+	now := time.Now()
+	crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		// The CRL number must increase with each CRL, including across Istiod replicas.
+		Number:              big.NewInt(now.UnixNano()),
+		ThisUpdate:          now,
+		NextUpdate:          now.Add(validity),
+		RevokedCertificates: revokedCerts,
+	}, signingCert, signer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign the CRL: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crl}), nil
+}
@@ -0,0 +1,256 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	caerror "istio.io/istio/security/pkg/pki/error"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+func newTestCAFromRoot(t *testing.T, root testCA) *IstioCA {
+	t.Helper()
+	bundle, err := util.NewVerifiedKeyCertBundleFromPem(root.certPEM, root.keyPEM, nil, root.certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := NewIstioCA(&IstioCAOptions{
+		CAType:         pluggedCertCA,
+		DefaultCertTTL: time.Hour,
+		MaxCertTTL:     time.Hour,
+		KeyCertBundle:  bundle,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ca
+}
+
+func TestRevocationEntries(t *testing.T) {
+	now := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		target    string
+		expected  RevocationEntry
+		expectErr bool
+	}{
+		{
+			target:   "spiffe://cluster.local/ns/default/sa/foo",
+			expected: RevocationEntry{SPIFFEID: "spiffe://cluster.local/ns/default/sa/foo", Reason: "compromised", RevokedAt: now},
+		},
+		{
+			target:   "0A:1b:2C",
+			expected: RevocationEntry{SerialNumber: "0a1b2c", Reason: "compromised", RevokedAt: now},
+		},
+		{
+			target:   "00ff",
+			expected: RevocationEntry{SerialNumber: "ff", Reason: "compromised", RevokedAt: now},
+		},
+		{
+			target:    "not-a-serial",
+			expectErr: true,
+		},
+		{
+			target:    "0",
+			expectErr: true,
+		},
+	}
+	for _, c := range cases {
+		entry, err := NewRevocationEntry(c.target, "compromised", now)
+		if gotErr := err != nil; gotErr != c.expectErr {
+			t.Fatalf("%s: got error %v, want error %v", c.target, err, c.expectErr)
+		}
+		if err == nil && entry != c.expected {
+			t.Fatalf("%s: got entry %+v, want %+v", c.target, entry, c.expected)
+		}
+	}
+
+	entries := []RevocationEntry{cases[0].expected, cases[1].expected}
+	data, err := MarshalRevocations(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseRevocations(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, entries) {
+		t.Fatalf("got entries %+v, want %+v", parsed, entries)
+	}
+
+	for _, invalid := range []string{
+		`{}`,
+		`[{"reason": "no target"}]`,
+		`[{"serialNumber": "01", "spiffeID": "spiffe://cluster.local/ns/a/sa/b"}]`,
+		`[{"serialNumber": "xyz"}]`,
+	} {
+		if _, err := ParseRevocations(invalid); err == nil {
+			t.Fatalf("expected an error parsing %s", invalid)
+		}
+	}
+	if entries, err := ParseRevocations(""); err != nil || entries != nil {
+		t.Fatalf("got %v, %v parsing an empty list", entries, err)
+	}
+}
+
+func TestRevokedIdentity(t *testing.T) {
+	ca := newTestCAFromRoot(t, newTestRoot(t, "root"))
+	revokedID := "spiffe://cluster.local/ns/default/sa/revoked"
+	ca.SetRevocations([]RevocationEntry{{SPIFFEID: revokedID, RevokedAt: time.Now()}})
+
+	for id, expectRevoked := range map[string]bool{
+		revokedID: true,
+		"spiffe://cluster.local/ns/default/sa/foo": false,
+	} {
+		csrPEM, _, err := util.GenCSR(util.CertOptions{Host: id, RSAKeySize: 2048})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = ca.Sign(csrPEM, []string{id}, time.Hour, false)
+		if !expectRevoked {
+			if err != nil {
+				t.Fatalf("%s: %v", id, err)
+			}
+			continue
+		}
+		if caErr, ok := err.(*caerror.Error); !ok || caErr.ErrorType() != "IDENTITY_REVOKED" {
+			t.Fatalf("%s: got error %v, want an IDENTITY_REVOKED error", id, err)
+		}
+	}
+
+	ca.SetRevocations(nil)
+	csrPEM, _, err := util.GenCSR(util.CertOptions{Host: revokedID, RSAKeySize: 2048})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ca.Sign(csrPEM, []string{revokedID}, time.Hour, false); err != nil {
+		t.Fatalf("got error %v after the revocation was removed", err)
+	}
+}
+
+func TestRevocationCertTTL(t *testing.T) {
+	ca := newTestCAFromRoot(t, newTestRoot(t, "root"))
+	ca.SetRevocationCertTTL(20 * time.Minute)
+	id := "spiffe://cluster.local/ns/default/sa/foo"
+	csrPEM, _, err := util.GenCSR(util.CertOptions{Host: id, RSAKeySize: 2048})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		requested time.Duration
+		expected  time.Duration
+	}{
+		{requested: 10 * time.Minute, expected: 10 * time.Minute},
+		{requested: time.Hour, expected: 20 * time.Minute},
+		{requested: 0, expected: 20 * time.Minute},
+	} {
+		certPEM, err := ca.Sign(csrPEM, []string{id}, tc.requested, false)
+		if err != nil {
+			t.Fatalf("requested TTL %v: %v", tc.requested, err)
+		}
+		cert, err := util.ParsePemEncodedCertificate(certPEM)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ttl := cert.NotAfter.Sub(cert.NotBefore); ttl != tc.expected {
+			t.Fatalf("requested TTL %v: got TTL %v, want %v", tc.requested, ttl, tc.expected)
+		}
+	}
+}
+
+func TestGenerateCRL(t *testing.T) {
+	root := newTestRoot(t, "root")
+	ca := newTestCAFromRoot(t, root)
+	revokedAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	revokedID := "spiffe://cluster.local/ns/default/sa/revoked"
+	ca.SetRevocations([]RevocationEntry{
+		{SerialNumber: "0a", RevokedAt: revokedAt},
+		{SPIFFEID: revokedID, RevokedAt: revokedAt},
+		{SerialNumber: "0b", RevokedAt: revokedAt},
+	})
+
+	crlPEM, err := ca.GenerateCRL(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(crlPEM)
+	if block == nil || block.Type != "X509 CRL" {
+		t.Fatalf("invalid CRL PEM: %s", crlPEM)
+	}
+	crl, err := x509.ParseCRL(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootCert, err := util.ParsePemEncodedCertificate(root.certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rootCert.CheckCRLSignature(crl); err != nil {
+		t.Fatalf("invalid CRL signature: %v", err)
+	}
+	if crl.HasExpired(time.Now()) || !crl.HasExpired(time.Now().Add(2*time.Hour)) {
+		t.Fatalf("unexpected CRL validity until %v", crl.TBSCertList.NextUpdate)
+	}
+	serials := []string{}
+	for _, r := range crl.TBSCertList.RevokedCertificates {
+		serials = append(serials, r.SerialNumber.Text(16))
+		if !r.RevocationTime.Equal(revokedAt) {
+			t.Fatalf("got revocation time %v, want %v", r.RevocationTime, revokedAt)
+		}
+	}
+	sort.Strings(serials)
+	if want := []string{"a", "b"}; !reflect.DeepEqual(serials, want) {
+		t.Fatalf("got revoked serials %v, want %v", serials, want)
+	}
+}
+
+func TestGenerateCRLWithoutCRLSign(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{Organization: []string{"legacy"}},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := testCA{
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+	}
+	ca := newTestCAFromRoot(t, root)
+	if _, err := ca.GenerateCRL(time.Hour); err == nil || !strings.Contains(err.Error(), "cRLSign") {
+		t.Fatalf("got error %v, want a cRLSign key usage error", err)
+	}
+}
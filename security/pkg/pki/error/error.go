@@ -32,6 +32,8 @@ const (
 	CAIllegalConfig
 	// CAInitFail means some other unexpected and fatal initilization failure
 	CAInitFail
+	// IdentityRevoked means the certificates of the requested identity are revoked.
+	IdentityRevoked
 )
 
 // Error encapsulates the short and long errors.
@@ -56,6 +58,8 @@ func (e Error) ErrorType() string {
 		return "TTL_ERROR"
 	case CertGenError:
 		return "CERT_GEN_ERROR"
+	case IdentityRevoked:
+		return "IDENTITY_REVOKED"
 	}
 	return "UNKNOWN"
 }
@@ -71,6 +75,8 @@ func (e Error) HTTPErrorCode() codes.Code {
 		return codes.InvalidArgument
 	case TTLError:
 		return codes.InvalidArgument
+	case IdentityRevoked:
+		return codes.PermissionDenied
 	}
 	return codes.Internal
 }
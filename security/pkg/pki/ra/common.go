@@ -50,6 +50,10 @@ type IstioRAOptions struct {
 	K8sClient certificatesv1beta1.CertificatesV1beta1Interface
 	// TrustDomain
 	TrustDomain string
+	// SkipCSRApproval : Whether to leave approval of the CSRs sent to the external K8s CA to an external approver
+	SkipCSRApproval bool
+	// CSRTimeout : Maximum time to wait for a CSR sent to the external K8s CA to be issued
+	CSRTimeout time.Duration
 }
 
 const (
@@ -61,6 +65,9 @@ const (
 
 	// DefaultExtCACertDir : Location of external CA certificate
 	DefaultExtCACertDir string = "./etc/external-ca-cert"
+
+	// DefaultCSRTimeout : Default time to wait for a CSR sent to the external K8s CA to be issued
+	DefaultCSRTimeout = 5 * time.Second
 )
 
 // ValidateCSR : Validate all SAN extensions in csrPEM match authenticated identities
@@ -39,6 +39,9 @@ func NewKubernetesRA(raOpts *IstioRAOptions) (*KubernetesRA, error) {
 	if err != nil {
 		return nil, raerror.NewError(raerror.CAInitFail, fmt.Errorf("error processing Certificate Bundle for Kubernetes RA"))
 	}
+	if raOpts.CSRTimeout <= 0 {
+		raOpts.CSRTimeout = DefaultCSRTimeout
+	}
 	istioRA := &KubernetesRA{
 		csrInterface:  raOpts.K8sClient,
 		raOpts:        raOpts,
@@ -59,7 +62,8 @@ func (r *KubernetesRA) kubernetesSign(csrPEM []byte, csrName string, caCertFile
 			cert.UsageClientAuth,
 		},
 	}
-	certChain, _, err := chiron.SignCSRK8s(r.csrInterface.CertificateSigningRequests(), csrName, csrSpec, "", caCertFile, false)
+	certChain, _, err := chiron.SignCSRK8s(r.csrInterface.CertificateSigningRequests(), csrName, csrSpec, "", caCertFile,
+		!r.raOpts.SkipCSRApproval, false, r.raOpts.CSRTimeout)
 	if err != nil {
 		return nil, raerror.NewError(raerror.CertGenError, err)
 	}
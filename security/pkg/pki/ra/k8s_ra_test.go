@@ -15,13 +15,22 @@
 package ra
 
 import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	cert "k8s.io/api/certificates/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/fake"
+	certclient "k8s.io/client-go/kubernetes/typed/certificates/v1beta1"
 	kt "k8s.io/client-go/testing"
 
 	"istio.io/istio/pkg/spiffe"
@@ -146,3 +155,227 @@ func TestValidateCSR(t *testing.T) {
 		t.Errorf("Test 2: CSR Validation failed")
 	}
 }
+
+// fakeExternalSigner acts as the external approver and signer of the CSRs sent to signerName,
+// the way cert-manager would. Certificates are issued by an intermediate CA, and returned
+// together with the intermediate certificate.
+type fakeExternalSigner struct {
+	signerName string
+	// approve makes the signer approve the CSRs itself, instead of waiting for istiod to approve them.
+	approve bool
+	// deny makes the signer deny the CSRs.
+	deny bool
+
+	rootCertFile string
+	rootCert     *x509.Certificate
+	intCert      *x509.Certificate
+	intCertPEM   []byte
+	intKey       crypto.PrivateKey
+}
+
+func newFakeExternalSigner(t *testing.T, signerName string) *fakeExternalSigner {
+	rootCertPEM, rootKeyPEM, err := pkiutil.GenCertKeyFromOptions(pkiutil.CertOptions{
+		TTL:          time.Hour,
+		Org:          "external-ca",
+		IsCA:         true,
+		IsSelfSigned: true,
+		RSAKeySize:   2048,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootCert, _ := pkiutil.ParsePemEncodedCertificate(rootCertPEM)
+	rootKey, _ := pkiutil.ParsePemEncodedKey(rootKeyPEM)
+	intCertPEM, intKeyPEM, err := pkiutil.GenCertKeyFromOptions(pkiutil.CertOptions{
+		TTL:        time.Hour,
+		Org:        "external-ca",
+		IsCA:       true,
+		SignerCert: rootCert,
+		SignerPriv: rootKey,
+		RSAKeySize: 2048,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	intCert, _ := pkiutil.ParsePemEncodedCertificate(intCertPEM)
+	intKey, _ := pkiutil.ParsePemEncodedKey(intKeyPEM)
+
+	rootCertFile := filepath.Join(t.TempDir(), "root-cert.pem")
+	if err := ioutil.WriteFile(rootCertFile, rootCertPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return &fakeExternalSigner{
+		signerName:   signerName,
+		rootCertFile: rootCertFile,
+		rootCert:     rootCert,
+		intCert:      intCert,
+		intCertPEM:   intCertPEM,
+		intKey:       intKey,
+	}
+}
+
+// run handles the CSRs created through client until the test ends.
+func (s *fakeExternalSigner) run(t *testing.T, client *fake.Clientset) {
+	csrs := client.CertificatesV1beta1().CertificateSigningRequests()
+	watcher, err := csrs.Watch(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	t.Cleanup(func() {
+		watcher.Stop()
+		<-done
+	})
+	go func() {
+		defer close(done)
+		for e := range watcher.ResultChan() {
+			csr, ok := e.Object.(*cert.CertificateSigningRequest)
+			if !ok || csr.Spec.SignerName == nil || *csr.Spec.SignerName != s.signerName || csr.Status.Certificate != nil {
+				continue
+			}
+			// Objects sent to watchers are shared with the other watchers of the fake client.
+			// The CSR may already be deleted by istiod, once it gave up on it.
+			if err := s.handle(csrs, csr.DeepCopy()); err != nil && !errors.IsNotFound(err) {
+				t.Errorf("external signer failed to handle CSR %v: %v", csr.Name, err)
+			}
+		}
+	}()
+}
+
+func (s *fakeExternalSigner) handle(csrs certclient.CertificateSigningRequestInterface, csr *cert.CertificateSigningRequest) error {
+	approved := false
+	for _, c := range csr.Status.Conditions {
+		if c.Type == cert.CertificateDenied {
+			return nil
+		}
+		if c.Type == cert.CertificateApproved {
+			approved = true
+		}
+	}
+	if s.deny {
+		csr.Status.Conditions = append(csr.Status.Conditions, cert.CertificateSigningRequestCondition{
+			Type:    cert.CertificateDenied,
+			Reason:  "PolicyViolation",
+			Message: "denied by test policy",
+		})
+		_, err := csrs.UpdateApproval(context.TODO(), csr, metav1.UpdateOptions{})
+		return err
+	}
+	if !approved {
+		if !s.approve {
+			// Wait for istiod to approve the CSR.
+			return nil
+		}
+		csr.Status.Conditions = append(csr.Status.Conditions, cert.CertificateSigningRequestCondition{
+			Type:   cert.CertificateApproved,
+			Reason: "ApprovedByTest",
+		})
+		var err error
+		if csr, err = csrs.UpdateApproval(context.TODO(), csr, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+	}
+	req, err := pkiutil.ParsePemEncodedCSR(csr.Spec.Request)
+	if err != nil {
+		return err
+	}
+	certDER, err := pkiutil.GenCertFromCSR(req, s.intCert, req.PublicKey, s.intKey, nil, time.Hour, false)
+	if err != nil {
+		return err
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+	csr.Status.Certificate = append(certPEM, s.intCertPEM...)
+	_, err = csrs.UpdateStatus(context.TODO(), csr, metav1.UpdateOptions{})
+	return err
+}
+
+func countApprovals(client *fake.Clientset) int {
+	n := 0
+	for _, a := range client.Actions() {
+		if a.GetVerb() == "update" && a.GetSubresource() == "approval" {
+			n++
+		}
+	}
+	return n
+}
+
+func TestK8sSignWithExternalSigner(t *testing.T) {
+	signerName := "example.com/istio-workloads"
+	cases := []struct {
+		name            string
+		skipCSRApproval bool
+		signerApproves  bool
+		signerDenies    bool
+		wantApprovals   int
+		wantErr         string
+	}{
+		{
+			name:          "approved by istiod",
+			wantApprovals: 1,
+		},
+		{
+			name:            "approved externally",
+			skipCSRApproval: true,
+			signerApproves:  true,
+			wantApprovals:   1,
+		},
+		{
+			name:            "denied externally",
+			skipCSRApproval: true,
+			signerDenies:    true,
+			wantApprovals:   1,
+			wantErr:         "denied by test policy",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			signer := newFakeExternalSigner(t, signerName)
+			signer.approve = tc.signerApproves
+			signer.deny = tc.signerDenies
+			client := fake.NewSimpleClientset()
+			signer.run(t, client)
+
+			r, err := NewKubernetesRA(&IstioRAOptions{
+				ExternalCAType:  ExtCAK8s,
+				DefaultCertTTL:  30 * time.Minute,
+				MaxCertTTL:      time.Hour,
+				CaSigner:        signerName,
+				CaCertFile:      signer.rootCertFile,
+				VerifyAppendCA:  true,
+				K8sClient:       client.CertificatesV1beta1(),
+				SkipCSRApproval: tc.skipCSRApproval,
+				CSRTimeout:      10 * time.Second,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			certChain, err := r.SignWithCertChain(createFakeCsr(t), []string{testCsrHostName}, time.Hour, false)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tc.wantErr)
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				roots := x509.NewCertPool()
+				roots.AddCert(signer.rootCert)
+				intermediates := x509.NewCertPool()
+				intermediates.AppendCertsFromPEM(certChain)
+				leaf, err := pkiutil.ParsePemEncodedCertificate(certChain)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := leaf.Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates}); err != nil {
+					t.Errorf("returned certificate chain is invalid: %v", err)
+				}
+			}
+			if got := countApprovals(client); got != tc.wantApprovals {
+				t.Errorf("got %d CSR approvals, want %d", got, tc.wantApprovals)
+			}
+			if csrs, _ := client.CertificatesV1beta1().CertificateSigningRequests().List(context.TODO(), metav1.ListOptions{}); len(csrs.Items) != 0 {
+				t.Errorf("CSRs were not cleaned up: %v", csrs.Items)
+			}
+		})
+	}
+}
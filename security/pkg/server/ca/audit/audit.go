@@ -0,0 +1,169 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package audit records the certificate requests handled by the CA server.
+package audit
+
+import (
+	"crypto/x509"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"istio.io/pkg/log"
+	"istio.io/pkg/monitoring"
+)
+
+var auditLog = log.RegisterScope("caaudit", "CA audit log", 0)
+
+var (
+	recordCounts = monitoring.NewSum(
+		"citadel_server_audit_record_count",
+		"The number of certificate requests recorded in the audit log.",
+	)
+
+	recordErrorCounts = monitoring.NewSum(
+		"citadel_server_audit_record_err_count",
+		"The number of audit records that could not be written to the audit sink.",
+	)
+)
+
+func init() {
+	monitoring.MustRegister(
+		recordCounts,
+		recordErrorCounts,
+	)
+}
+
+// The results of a certificate request.
+const (
+	ResultIssued               = "issued"
+	ResultAuthenticationFailed = "authentication_failed"
+	ResultSigningFailed        = "signing_failed"
+)
+
+// Record is the audit record of a certificate request.
+type Record struct {
+	// Time is when the request was received.
+	Time time.Time `json:"time"`
+	// Result is one of issued, authentication_failed or signing_failed.
+	Result string `json:"result"`
+	// Error is the reason the request failed.
+	Error string `json:"error,omitempty"`
+	// ClientAddress is the address the request was received from.
+	ClientAddress string `json:"clientAddress"`
+	// Authenticator is the type of the authenticator which authenticated the caller.
+	Authenticator string `json:"authenticator,omitempty"`
+	// CallerIdentities are the identities of the authenticated caller.
+	CallerIdentities []string `json:"callerIdentities,omitempty"`
+	// RequestedSANs are the subject alternative names in the CSR.
+	RequestedSANs []string `json:"requestedSANs,omitempty"`
+	// RequestedTTL is the validity duration in the request.
+	RequestedTTL string `json:"requestedTTL,omitempty"`
+	// SerialNumber is the hex encoded serial number of the issued certificate.
+	SerialNumber string `json:"serialNumber,omitempty"`
+	// SANs are the subject alternative names of the issued certificate.
+	SANs []string `json:"sans,omitempty"`
+	// TTL is the validity duration of the issued certificate.
+	TTL string `json:"ttl,omitempty"`
+	// NotAfter is the expiration time of the issued certificate.
+	NotAfter *time.Time `json:"notAfter,omitempty"`
+}
+
+// SetCertificate records the certificate issued for the request.
+func (r *Record) SetCertificate(cert *x509.Certificate, sans []string) {
+	r.Result = ResultIssued
+	r.SerialNumber = SerialNumber(cert)
+	r.SANs = sans
+	r.TTL = cert.NotAfter.Sub(cert.NotBefore).String()
+	notAfter := cert.NotAfter
+	r.NotAfter = &notAfter
+}
+
+// SerialNumber returns the serial number of the certificate, as recorded in the audit log.
+func SerialNumber(cert *x509.Certificate) string {
+	return hex.EncodeToString(cert.SerialNumber.Bytes())
+}
+
+// Sink receives the audit records.
+type Sink interface {
+	// Write writes a record. It must be safe to call concurrently.
+	Write(r *Record) error
+	// Close flushes the pending records, and releases the resources of the sink.
+	Close() error
+}
+
+// Log writes the records of the certificate requests to a sink, and keeps the records of the
+// most recently issued certificates to look them up by serial number.
+type Log struct {
+	sink       Sink
+	maxRecords int
+
+	mu       sync.RWMutex
+	bySerial map[string]*Record
+	// serials are the serial numbers of the indexed records, in insertion order.
+	serials []string
+}
+
+// NewLog creates a Log writing to sink, which indexes up to maxRecords issued certificates.
+func NewLog(sink Sink, maxRecords int) *Log {
+	return &Log{
+		sink:       sink,
+		maxRecords: maxRecords,
+		bySerial:   map[string]*Record{},
+	}
+}
+
+// Record writes the record to the sink. Errors are logged, and don't fail the request.
+func (l *Log) Record(r *Record) {
+	recordCounts.Increment()
+	if err := l.sink.Write(r); err != nil {
+		recordErrorCounts.Increment()
+		auditLog.Errorf("failed to write the audit record of the request from %s: %v", r.ClientAddress, err)
+	}
+	if r.SerialNumber == "" || l.maxRecords <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, f := l.bySerial[r.SerialNumber]; !f {
+		if len(l.serials) >= l.maxRecords {
+			delete(l.bySerial, l.serials[0])
+			l.serials = l.serials[1:]
+		}
+		l.serials = append(l.serials, r.SerialNumber)
+	}
+	l.bySerial[r.SerialNumber] = r
+}
+
+// Lookup returns the record of the certificate with the given hex encoded serial number, or nil if
+// it is not indexed.
+func (l *Log) Lookup(serial string) *Record {
+	serial = strings.TrimLeft(strings.ToLower(strings.ReplaceAll(serial, ":", "")), "0")
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for _, s := range []string{serial, "0" + serial} {
+		if r, f := l.bySerial[s]; f {
+			return r
+		}
+	}
+	return nil
+}
+
+// Close closes the sink.
+func (l *Log) Close() error {
+	return l.sink.Close()
+}
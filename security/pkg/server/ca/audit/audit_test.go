@@ -0,0 +1,142 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLogLookup(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := NewLog(NewWriterSink(buf), 2)
+	records := []*Record{
+		{Result: ResultIssued, SerialNumber: "0a01"},
+		{Result: ResultAuthenticationFailed, ClientAddress: "10.0.0.1:1234"},
+		{Result: ResultIssued, SerialNumber: "0b02"},
+		{Result: ResultIssued, SerialNumber: "0c03"},
+	}
+	for _, r := range records {
+		l.Record(r)
+	}
+
+	// All the records are written to the sink.
+	scanner := bufio.NewScanner(buf)
+	var written []*Record
+	for scanner.Scan() {
+		r := &Record{}
+		if err := json.Unmarshal(scanner.Bytes(), r); err != nil {
+			t.Fatal(err)
+		}
+		written = append(written, r)
+	}
+	if !reflect.DeepEqual(written, records) {
+		t.Fatalf("got records %+v, want %+v", written, records)
+	}
+
+	// Only the most recent certificates are indexed.
+	if r := l.Lookup("0a01"); r != nil {
+		t.Fatalf("expected the oldest certificate to be evicted, got %+v", r)
+	}
+	for _, serial := range []string{"0b02", "0B:02", "b02", "000c03"} {
+		if r := l.Lookup(serial); r == nil {
+			t.Fatalf("certificate %s not found", serial)
+		}
+	}
+	if r := l.Lookup("ffff"); r != nil {
+		t.Fatalf("unexpected record for an unknown serial: %+v", r)
+	}
+}
+
+func TestNewSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	for spec, expectErr := range map[string]bool{
+		StdoutSink:               false,
+		"file://" + path:         false,
+		"http://collector:8080/": false,
+		"file://":                true,
+		"syslog":                 true,
+	} {
+		sink, err := NewSink(spec, FileOptions{MaxSizeMB: 1, MaxBackups: 1})
+		if gotErr := err != nil; gotErr != expectErr {
+			t.Fatalf("sink %q: got error %v, want error %v", spec, err, expectErr)
+		}
+		if sink != nil {
+			if err := sink.Write(&Record{Result: ResultIssued}); err != nil {
+				t.Fatalf("sink %q: %v", spec, err)
+			}
+			if err := sink.Close(); err != nil {
+				t.Fatalf("sink %q: %v", spec, err)
+			}
+		}
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"time":"0001-01-01T00:00:00Z","result":"issued","clientAddress":""}` + "\n"; string(b) != want {
+		t.Fatalf("got file content %q, want %q", b, want)
+	}
+}
+
+func TestHTTPSink(t *testing.T) {
+	var mu sync.Mutex
+	var received []Record
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		record := Record{}
+		if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		received = append(received, record)
+		mu.Unlock()
+	}))
+	defer collector.Close()
+
+	sink := NewHTTPSink(collector.URL)
+	notAfter := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	want := []Record{
+		{Result: ResultIssued, SerialNumber: "01", NotAfter: &notAfter, SANs: []string{"spiffe://cluster.local/ns/a/sa/b"}},
+		{Result: ResultSigningFailed, Error: "invalid CSR"},
+	}
+	for i := range want {
+		if err := sink.Write(&want[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Close sends the pending records.
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(received, want) {
+		t.Fatalf("got records %+v, want %+v", received, want)
+	}
+	if err := sink.Write(&Record{}); err == nil {
+		t.Fatal("expected an error writing to a closed sink")
+	}
+}
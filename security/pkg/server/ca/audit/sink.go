@@ -0,0 +1,178 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	// StdoutSink is the sink writing the records to the standard output.
+	StdoutSink = "stdout"
+
+	fileSinkPrefix = "file://"
+
+	// The number of records the HTTP sink buffers while the collector is unavailable.
+	httpSinkQueueSize = 1000
+	httpSinkTimeout   = 5 * time.Second
+)
+
+// FileOptions configures the rotation of the file sink.
+type FileOptions struct {
+	// MaxSizeMB is the size in megabytes the file is rotated at.
+	MaxSizeMB int
+	// MaxBackups is the number of rotated files to keep.
+	MaxBackups int
+}
+
+// NewSink creates the sink described by spec: "stdout", "file://<path>", or an http(s) URL of a collector.
+func NewSink(spec string, fileOptions FileOptions) (Sink, error) {
+	switch {
+	case spec == StdoutSink:
+		return NewWriterSink(os.Stdout), nil
+	case strings.HasPrefix(spec, fileSinkPrefix):
+		path := strings.TrimPrefix(spec, fileSinkPrefix)
+		if path == "" {
+			return nil, fmt.Errorf("audit sink %q: missing file path", spec)
+		}
+		return NewWriterSink(&lumberjack.Logger{
+			Filename:   path,
+			MaxSize:    fileOptions.MaxSizeMB,
+			MaxBackups: fileOptions.MaxBackups,
+		}), nil
+	case strings.HasPrefix(spec, "http://") || strings.HasPrefix(spec, "https://"):
+		return NewHTTPSink(spec), nil
+	default:
+		return nil, fmt.Errorf("unsupported audit sink %q, must be %s, %s<path> or an http(s) URL", spec, StdoutSink, fileSinkPrefix)
+	}
+}
+
+// writerSink writes the records as JSON lines.
+type writerSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterSink creates a sink writing each record as a line of JSON to w. w is closed with the sink
+// if it is an io.Closer, except for the standard output.
+func NewWriterSink(w io.Writer) Sink {
+	return &writerSink{w: w}
+}
+
+func (s *writerSink) Write(r *Record) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(b)
+	return err
+}
+
+func (s *writerSink) Close() error {
+	if c, ok := s.w.(io.Closer); ok && s.w != os.Stdout {
+		return c.Close()
+	}
+	return nil
+}
+
+// httpSink posts the records to a collector. The records are sent in the background, so that an
+// unavailable collector does not delay the certificate requests.
+type httpSink struct {
+	url    string
+	client *http.Client
+	done   chan struct{}
+
+	mu     sync.RWMutex
+	queue  chan []byte
+	closed bool
+}
+
+// NewHTTPSink creates a sink posting each record as JSON to url.
+func NewHTTPSink(url string) Sink {
+	s := &httpSink{
+		url:    url,
+		client: &http.Client{Timeout: httpSinkTimeout},
+		queue:  make(chan []byte, httpSinkQueueSize),
+		done:   make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *httpSink) Write(r *Record) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return fmt.Errorf("the audit collector sink is closed")
+	}
+	select {
+	case s.queue <- b:
+		return nil
+	default:
+		return fmt.Errorf("the queue of the audit collector %s is full", s.url)
+	}
+}
+
+func (s *httpSink) run() {
+	defer close(s.done)
+	for b := range s.queue {
+		if err := s.post(b); err != nil {
+			recordErrorCounts.Increment()
+			auditLog.Errorf("failed to send an audit record to %s: %v", s.url, err)
+		}
+	}
+}
+
+func (s *httpSink) post(b []byte) error {
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *httpSink) Close() error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+	<-s.done
+	return nil
+}
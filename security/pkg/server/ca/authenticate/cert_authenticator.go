@@ -15,8 +15,6 @@
 package authenticate
 
 import (
-	"fmt"
-
 	"golang.org/x/net/context"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/peer"
@@ -45,17 +43,17 @@ func (cca *ClientCertAuthenticator) AuthenticatorType() string {
 func (cca *ClientCertAuthenticator) Authenticate(ctx context.Context) (*security.Caller, error) {
 	peer, ok := peer.FromContext(ctx)
 	if !ok || peer.AuthInfo == nil {
-		return nil, fmt.Errorf("no client certificate is presented")
+		return nil, security.NewNotApplicableError("no client certificate is presented")
 	}
 
 	if authType := peer.AuthInfo.AuthType(); authType != "tls" {
-		return nil, fmt.Errorf("unsupported auth type: %q", authType)
+		return nil, security.NewNotApplicableError("unsupported auth type: %q", authType)
 	}
 
 	tlsInfo := peer.AuthInfo.(credentials.TLSInfo)
 	chains := tlsInfo.State.VerifiedChains
 	if len(chains) == 0 || len(chains[0]) == 0 {
-		return nil, fmt.Errorf("no verified chain is found")
+		return nil, security.NewNotApplicableError("no verified chain is found")
 	}
 
 	ids, err := util.ExtractIDs(chains[0][0].Extensions)
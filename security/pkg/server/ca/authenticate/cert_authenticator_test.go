@@ -52,22 +52,27 @@ func TestAuthenticate_clientCertAuthenticator(t *testing.T) {
 		caller             *security.Caller
 		authenticateErrMsg string
 		fakeAuthInfo       *mockAuthInfo
+		// notApplicable is set when the request has no client certificate to authenticate.
+		notApplicable bool
 	}{
 		"No client certificate": {
 			certChain:          nil,
 			caller:             nil,
 			authenticateErrMsg: "no client certificate is presented",
+			notApplicable:      true,
 		},
 		"Unsupported auth type": {
 			certChain:          nil,
 			caller:             nil,
 			authenticateErrMsg: "unsupported auth type: \"not-tls\"",
+			notApplicable:      true,
 			fakeAuthInfo:       &mockAuthInfo{"not-tls"},
 		},
 		"Empty cert chain": {
 			certChain:          [][]*x509.Certificate{},
 			caller:             nil,
 			authenticateErrMsg: "no verified chain is found",
+			notApplicable:      true,
 		},
 		"Certificate has no SAN": {
 			certChain: [][]*x509.Certificate{
@@ -113,6 +118,8 @@ func TestAuthenticate_clientCertAuthenticator(t *testing.T) {
 			} else if err.Error() != tc.authenticateErrMsg {
 				t.Errorf("Case %s: Incorrect error message: want %s but got %s",
 					id, tc.authenticateErrMsg, err.Error())
+			} else if security.IsNotApplicable(err) != tc.notApplicable {
+				t.Errorf("Case %s: got not applicable %v, want %v", id, security.IsNotApplicable(err), tc.notApplicable)
 			}
 			continue
 		} else if err != nil {
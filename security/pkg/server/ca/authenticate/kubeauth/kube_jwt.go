@@ -80,7 +80,7 @@ var defaultAllowedKubernetesAudiences = map[string]bool{
 func (a *KubeJWTAuthenticator) Authenticate(ctx context.Context) (*security.Caller, error) {
 	targetJWT, err := security.ExtractBearerToken(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("target JWT extraction error: %v", err)
+		return nil, fmt.Errorf("target JWT extraction error: %w", err)
 	}
 	clusterID := extractClusterID(ctx)
 	var id []string
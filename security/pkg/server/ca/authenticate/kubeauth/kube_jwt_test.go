@@ -60,6 +60,8 @@ func TestAuthenticate(t *testing.T) {
 		jwtPolicy      string
 		expectedID     string
 		expectedErrMsg string
+		// notApplicable is set when the request has no credentials for the authenticator.
+		notApplicable bool
 	}{
 		"No bearer token": {
 			metadata: metadata.MD{
@@ -69,6 +71,7 @@ func TestAuthenticate(t *testing.T) {
 				},
 			},
 			expectedErrMsg: "target JWT extraction error: no bearer token exists in HTTP authorization header",
+			notApplicable:  true,
 		},
 		"token not authenticated": {
 			token: invlidToken,
@@ -163,6 +166,8 @@ func TestAuthenticate(t *testing.T) {
 				} else if err.Error() != tc.expectedErrMsg {
 					t.Errorf("Case %s: Incorrect error message: \n%s\nVS\n%s",
 						id, err.Error(), tc.expectedErrMsg)
+				} else if security.IsNotApplicable(err) != tc.notApplicable {
+					t.Errorf("Case %s: got not applicable %v, want %v", id, security.IsNotApplicable(err), tc.notApplicable)
 				}
 				return
 			} else if err != nil {
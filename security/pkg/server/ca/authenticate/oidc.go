@@ -71,7 +71,7 @@ func NewJwtAuthenticator(jwtRule *v1beta1.JWTRule, trustDomain string) (*JwtAuth
 func (j *JwtAuthenticator) Authenticate(ctx context.Context) (*security.Caller, error) {
 	bearerToken, err := security.ExtractBearerToken(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("ID token extraction error: %v", err)
+		return nil, fmt.Errorf("ID token extraction error: %w", err)
 	}
 
 	idToken, err := j.verifier.Verify(ctx, bearerToken)
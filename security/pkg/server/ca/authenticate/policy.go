@@ -0,0 +1,200 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authenticate
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"istio.io/istio/pkg/security"
+)
+
+// The type of the Kubernetes JWT authenticator, which is defined in the kubeauth package.
+const kubeJWTAuthenticatorType = "KubeJWTAuthenticator"
+
+// Policy configures the authenticators of CA requests.
+type Policy struct {
+	// Authenticators are the enabled authenticators, in the order they are tried. A request is
+	// authenticated by the first authenticator that accepts it.
+	Authenticators []AuthenticatorPolicy `json:"authenticators"`
+}
+
+// AuthenticatorPolicy enables an authenticator, and constrains the requests it accepts.
+type AuthenticatorPolicy struct {
+	// Type is the type of the authenticator: ClientCertAuthenticator, KubeJWTAuthenticator or IDTokenAuthenticator.
+	Type string `json:"type"`
+	// AllowedIssuers are the accepted issuers of the token. All issuers are accepted if empty.
+	AllowedIssuers []string `json:"allowedIssuers,omitempty"`
+	// AllowedAudiences are the accepted audiences of the token. All audiences are accepted if empty.
+	AllowedAudiences []string `json:"allowedAudiences,omitempty"`
+	// AllowedIdentities are the identities certificates may be requested for. An entry ending with
+	// `*` matches the identities starting with the rest of the entry. All identities are allowed if empty.
+	AllowedIdentities []string `json:"allowedIdentities,omitempty"`
+}
+
+// ParsePolicy parses a YAML or JSON encoded Policy.
+func ParsePolicy(s string) (*Policy, error) {
+	policy := &Policy{}
+	if err := yaml.UnmarshalStrict([]byte(s), policy); err != nil {
+		return nil, fmt.Errorf("failed to parse the authentication policy: %v", err)
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// Validate checks that the policy enables at least one authenticator, and only uses the constraints
+// supported by each authenticator.
+func (p *Policy) Validate() error {
+	if len(p.Authenticators) == 0 {
+		return fmt.Errorf("the authentication policy must enable at least one authenticator")
+	}
+	seen := map[string]bool{}
+	for _, a := range p.Authenticators {
+		switch a.Type {
+		case ClientCertAuthenticatorType:
+			if len(a.AllowedIssuers) > 0 || len(a.AllowedAudiences) > 0 {
+				return fmt.Errorf("%s does not support allowed issuers or audiences", a.Type)
+			}
+		case kubeJWTAuthenticatorType, IDTokenAuthenticatorType:
+		default:
+			return fmt.Errorf("unknown authenticator type %q, must be one of %s, %s or %s", a.Type,
+				ClientCertAuthenticatorType, kubeJWTAuthenticatorType, IDTokenAuthenticatorType)
+		}
+		if seen[a.Type] {
+			return fmt.Errorf("authenticator %s is configured more than once", a.Type)
+		}
+		seen[a.Type] = true
+	}
+	return nil
+}
+
+// Apply returns the authenticators enabled by the policy, in the policy order and with its constraints.
+// An error is returned if the policy enables an authenticator that is not available.
+func (p *Policy) Apply(authenticators []security.Authenticator) ([]security.Authenticator, error) {
+	var result []security.Authenticator
+	for _, ap := range p.Authenticators {
+		found := false
+		for _, a := range authenticators {
+			if a.AuthenticatorType() != ap.Type {
+				continue
+			}
+			found = true
+			result = append(result, &policyAuthenticator{Authenticator: a, policy: ap})
+		}
+		if !found {
+			return nil, fmt.Errorf("authenticator %s is enabled by the authentication policy, but is not configured", ap.Type)
+		}
+	}
+	return result, nil
+}
+
+// policyAuthenticator rejects the callers an authenticator accepts when they don't match the policy.
+type policyAuthenticator struct {
+	security.Authenticator
+	policy AuthenticatorPolicy
+}
+
+var _ security.Authenticator = &policyAuthenticator{}
+
+func (a *policyAuthenticator) Authenticate(ctx context.Context) (*security.Caller, error) {
+	caller, err := a.Authenticator.Authenticate(ctx)
+	if err != nil || caller == nil {
+		return caller, err
+	}
+	if len(a.policy.AllowedIssuers) > 0 || len(a.policy.AllowedAudiences) > 0 {
+		// The token was verified by the authenticator, only its claims are checked here.
+		token, err := security.ExtractBearerToken(ctx)
+		if err != nil {
+			return nil, err
+		}
+		iss, aud, err := tokenIssuerAndAudiences(token)
+		if err != nil {
+			return nil, err
+		}
+		if len(a.policy.AllowedIssuers) > 0 && !contains(a.policy.AllowedIssuers, iss) {
+			return nil, fmt.Errorf("issuer %q is not allowed", iss)
+		}
+		if len(a.policy.AllowedAudiences) > 0 && !checkAudience(aud, a.policy.AllowedAudiences) {
+			return nil, fmt.Errorf("audiences %v are not allowed", aud)
+		}
+	}
+	if len(a.policy.AllowedIdentities) > 0 {
+		for _, id := range caller.Identities {
+			if !identityAllowed(id, a.policy.AllowedIdentities) {
+				return nil, fmt.Errorf("identity %q is not allowed", id)
+			}
+		}
+	}
+	return caller, nil
+}
+
+// tokenIssuerAndAudiences returns the issuer and audiences of a JWT, without verifying it.
+func tokenIssuerAndAudiences(token string) (string, []string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", nil, fmt.Errorf("invalid JWT: expected 3 parts, got %d", len(parts))
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to decode the JWT payload: %v", err)
+	}
+	claims := struct {
+		Iss string          `json:"iss"`
+		Aud json.RawMessage `json:"aud"`
+	}{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return "", nil, fmt.Errorf("failed to parse the JWT payload: %v", err)
+	}
+	// The audience is either a string or an array of strings.
+	var aud []string
+	if len(claims.Aud) > 0 {
+		var single string
+		if err := json.Unmarshal(claims.Aud, &single); err == nil {
+			aud = []string{single}
+		} else if err := json.Unmarshal(claims.Aud, &aud); err != nil {
+			return "", nil, fmt.Errorf("failed to parse the JWT audiences: %v", err)
+		}
+	}
+	return claims.Iss, aud, nil
+}
+
+func identityAllowed(id string, allowed []string) bool {
+	for _, a := range allowed {
+		if strings.HasSuffix(a, "*") {
+			if strings.HasPrefix(id, strings.TrimSuffix(a, "*")) {
+				return true
+			}
+		} else if id == a {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,227 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authenticate
+
+import (
+	"context"
+	"encoding/base64"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+
+	"istio.io/istio/pkg/security"
+)
+
+type fakeAuthenticator struct {
+	authType   string
+	identities []string
+}
+
+func (f *fakeAuthenticator) Authenticate(context.Context) (*security.Caller, error) {
+	return &security.Caller{Identities: f.identities}, nil
+}
+
+func (f *fakeAuthenticator) AuthenticatorType() string {
+	return f.authType
+}
+
+// contextWithToken returns a context with an unsigned JWT carrying the given claims.
+func contextWithToken(claims string) context.Context {
+	token := "e30." + base64.RawURLEncoding.EncodeToString([]byte(claims)) + ".sig"
+	md := metadata.MD{"authorization": []string{bearerTokenPrefix + token}}
+	return metadata.NewIncomingContext(context.Background(), md)
+}
+
+func TestParsePolicy(t *testing.T) {
+	cases := []struct {
+		name      string
+		policy    string
+		expectErr string
+	}{
+		{
+			name: "yaml",
+			policy: `
+authenticators:
+- type: IDTokenAuthenticator
+  allowedIssuers: [https://accounts.example.com]
+  allowedIdentities: ["spiffe://cluster.local/ns/vm/*"]
+- type: ClientCertAuthenticator`,
+		},
+		{
+			name:   "json",
+			policy: `{"authenticators": [{"type": "KubeJWTAuthenticator", "allowedAudiences": ["istio-ca"]}]}`,
+		},
+		{
+			name:      "no authenticators",
+			policy:    `authenticators: []`,
+			expectErr: "the authentication policy must enable at least one authenticator",
+		},
+		{
+			name:      "unknown type",
+			policy:    `authenticators: [{type: Foo}]`,
+			expectErr: `unknown authenticator type "Foo", must be one of ClientCertAuthenticator, KubeJWTAuthenticator or IDTokenAuthenticator`,
+		},
+		{
+			name:      "duplicate type",
+			policy:    `authenticators: [{type: ClientCertAuthenticator}, {type: ClientCertAuthenticator}]`,
+			expectErr: "authenticator ClientCertAuthenticator is configured more than once",
+		},
+		{
+			name:      "issuers for client certs",
+			policy:    `authenticators: [{type: ClientCertAuthenticator, allowedIssuers: [foo]}]`,
+			expectErr: "ClientCertAuthenticator does not support allowed issuers or audiences",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParsePolicy(tc.policy)
+			gotErr := ""
+			if err != nil {
+				gotErr = err.Error()
+			}
+			if gotErr != tc.expectErr {
+				t.Fatalf("got error %q, want %q", gotErr, tc.expectErr)
+			}
+		})
+	}
+
+	if _, err := ParsePolicy(`authenticators: [{type: ClientCertAuthenticator, unknown: true}]`); err == nil {
+		t.Fatal("expected an error for an unknown field")
+	}
+}
+
+func TestPolicyApply(t *testing.T) {
+	certAuth := &fakeAuthenticator{authType: ClientCertAuthenticatorType}
+	kubeAuth := &fakeAuthenticator{authType: kubeJWTAuthenticatorType}
+	oidcAuth := &fakeAuthenticator{authType: IDTokenAuthenticatorType}
+	available := []security.Authenticator{certAuth, kubeAuth, oidcAuth}
+
+	policy := &Policy{Authenticators: []AuthenticatorPolicy{
+		{Type: IDTokenAuthenticatorType},
+		{Type: ClientCertAuthenticatorType},
+	}}
+	got, err := policy.Apply(available)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotTypes := []string{}
+	for _, a := range got {
+		gotTypes = append(gotTypes, a.AuthenticatorType())
+	}
+	if want := []string{IDTokenAuthenticatorType, ClientCertAuthenticatorType}; !reflect.DeepEqual(gotTypes, want) {
+		t.Fatalf("got authenticators %v, want %v", gotTypes, want)
+	}
+
+	policy = &Policy{Authenticators: []AuthenticatorPolicy{{Type: IDTokenAuthenticatorType}}}
+	if _, err := policy.Apply([]security.Authenticator{certAuth, kubeAuth}); err == nil {
+		t.Fatal("expected an error for an authenticator that is not configured")
+	}
+}
+
+func TestPolicyAuthenticator(t *testing.T) {
+	vmIdentity := "spiffe://cluster.local/ns/vm/sa/app"
+	cases := []struct {
+		name       string
+		policy     AuthenticatorPolicy
+		claims     string
+		identities []string
+		expectErr  string
+	}{
+		{
+			name:       "no constraints",
+			policy:     AuthenticatorPolicy{Type: IDTokenAuthenticatorType},
+			identities: []string{vmIdentity},
+		},
+		{
+			name:       "allowed issuer and audience",
+			policy:     AuthenticatorPolicy{Type: IDTokenAuthenticatorType, AllowedIssuers: []string{"issuer-a"}, AllowedAudiences: []string{"istio-ca"}},
+			claims:     `{"iss": "issuer-a", "aud": ["other", "istio-ca"]}`,
+			identities: []string{vmIdentity},
+		},
+		{
+			name:       "single audience",
+			policy:     AuthenticatorPolicy{Type: IDTokenAuthenticatorType, AllowedAudiences: []string{"istio-ca"}},
+			claims:     `{"iss": "issuer-a", "aud": "istio-ca"}`,
+			identities: []string{vmIdentity},
+		},
+		{
+			name:       "issuer not allowed",
+			policy:     AuthenticatorPolicy{Type: IDTokenAuthenticatorType, AllowedIssuers: []string{"issuer-a"}},
+			claims:     `{"iss": "issuer-b", "aud": ["istio-ca"]}`,
+			identities: []string{vmIdentity},
+			expectErr:  `issuer "issuer-b" is not allowed`,
+		},
+		{
+			name:       "audience not allowed",
+			policy:     AuthenticatorPolicy{Type: IDTokenAuthenticatorType, AllowedAudiences: []string{"istio-ca"}},
+			claims:     `{"iss": "issuer-a", "aud": ["other"]}`,
+			identities: []string{vmIdentity},
+			expectErr:  "audiences [other] are not allowed",
+		},
+		{
+			name:       "identity allowed by prefix",
+			policy:     AuthenticatorPolicy{Type: IDTokenAuthenticatorType, AllowedIdentities: []string{"spiffe://cluster.local/ns/vm/*"}},
+			identities: []string{vmIdentity},
+		},
+		{
+			name:       "identity allowed exactly",
+			policy:     AuthenticatorPolicy{Type: IDTokenAuthenticatorType, AllowedIdentities: []string{vmIdentity}},
+			identities: []string{vmIdentity},
+		},
+		{
+			name:       "identity not allowed",
+			policy:     AuthenticatorPolicy{Type: IDTokenAuthenticatorType, AllowedIdentities: []string{"spiffe://cluster.local/ns/vm/*"}},
+			identities: []string{"spiffe://cluster.local/ns/istio-system/sa/istiod"},
+			expectErr:  `identity "spiffe://cluster.local/ns/istio-system/sa/istiod" is not allowed`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			policy := &Policy{Authenticators: []AuthenticatorPolicy{tc.policy}}
+			authenticators, err := policy.Apply([]security.Authenticator{
+				&fakeAuthenticator{authType: tc.policy.Type, identities: tc.identities},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := context.Background()
+			if tc.claims != "" {
+				ctx = contextWithToken(tc.claims)
+			}
+			caller, err := authenticators[0].Authenticate(ctx)
+			gotErr := ""
+			if err != nil {
+				gotErr = err.Error()
+			}
+			if gotErr != tc.expectErr {
+				t.Fatalf("got error %q, want %q", gotErr, tc.expectErr)
+			}
+			if err == nil && !reflect.DeepEqual(caller.Identities, tc.identities) {
+				t.Fatalf("got identities %v, want %v", caller.Identities, tc.identities)
+			}
+		})
+	}
+
+	// Issuer constraints require a token.
+	policy := &Policy{Authenticators: []AuthenticatorPolicy{{Type: kubeJWTAuthenticatorType, AllowedIssuers: []string{"issuer-a"}}}}
+	authenticators, err := policy.Apply([]security.Authenticator{&fakeAuthenticator{authType: kubeJWTAuthenticatorType}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := authenticators[0].Authenticate(context.Background()); err == nil {
+		t.Fatal("expected an error without a token")
+	}
+}
@@ -19,11 +19,13 @@ import (
 )
 
 const (
-	errorlabel = "error"
+	errorlabel         = "error"
+	authenticatorLabel = "authenticator"
 )
 
 var (
-	errorTag = monitoring.MustCreateLabel(errorlabel)
+	errorTag         = monitoring.MustCreateLabel(errorlabel)
+	authenticatorTag = monitoring.MustCreateLabel(authenticatorLabel)
 
 	csrCounts = monitoring.NewSum(
 		"citadel_server_csr_count",
@@ -35,6 +37,18 @@ var (
 		"The number of authentication failures.",
 	)
 
+	authenticatorSuccessCounts = monitoring.NewSum(
+		"citadel_server_authenticator_success_count",
+		"The number of requests authenticated, by authenticator.",
+		monitoring.WithLabels(authenticatorTag),
+	)
+
+	authenticatorFailureCounts = monitoring.NewSum(
+		"citadel_server_authenticator_failure_count",
+		"The number of requests rejected, by authenticator.",
+		monitoring.WithLabels(authenticatorTag),
+	)
+
 	csrParsingErrorCounts = monitoring.NewSum(
 		"citadel_server_csr_parsing_err_count",
 		"The number of errors occurred when parsing the CSR.",
@@ -72,6 +86,8 @@ func init() {
 	monitoring.MustRegister(
 		csrCounts,
 		authnErrorCounts,
+		authenticatorSuccessCounts,
+		authenticatorFailureCounts,
 		csrParsingErrorCounts,
 		idExtractionErrorCounts,
 		certSignErrorCounts,
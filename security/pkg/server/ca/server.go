@@ -28,6 +28,7 @@ import (
 	"istio.io/istio/pkg/security"
 	caerror "istio.io/istio/security/pkg/pki/error"
 	"istio.io/istio/security/pkg/pki/util"
+	"istio.io/istio/security/pkg/server/ca/audit"
 	"istio.io/pkg/log"
 )
 
@@ -51,6 +52,8 @@ type Server struct {
 	Authenticators []security.Authenticator
 	ca             CertificateAuthority
 	serverCertTTL  time.Duration
+	// AuditLog records the certificate requests, if set.
+	AuditLog *audit.Log
 }
 
 func getConnectionAddress(ctx context.Context) string {
@@ -71,11 +74,17 @@ func getConnectionAddress(ctx context.Context) string {
 func (s *Server) CreateCertificate(ctx context.Context, request *pb.IstioCertificateRequest) (
 	*pb.IstioCertificateResponse, error) {
 	s.monitoring.CSR.Increment()
-	caller := Authenticate(ctx, s.Authenticators)
+	record := s.newAuditRecord(ctx, request)
+	caller, authenticator := authenticateCaller(ctx, s.Authenticators)
 	if caller == nil {
 		s.monitoring.AuthnError.Increment()
+		s.audit(record, audit.ResultAuthenticationFailed, "request authenticate failure")
 		return nil, status.Error(codes.Unauthenticated, "request authenticate failure")
 	}
+	if record != nil {
+		record.Authenticator = authenticator
+		record.CallerIdentities = caller.Identities
+	}
 
 	// TODO: Call authorizer.
 
@@ -85,6 +94,7 @@ func (s *Server) CreateCertificate(ctx context.Context, request *pb.IstioCertifi
 	if signErr != nil {
 		serverCaLog.Errorf("CSR signing error (%v)", signErr.Error())
 		s.monitoring.GetCertSignError(signErr.(*caerror.Error).ErrorType()).Increment()
+		s.audit(record, audit.ResultSigningFailed, signErr.Error())
 		return nil, status.Errorf(signErr.(*caerror.Error).HTTPErrorCode(), "CSR signing error (%v)", signErr.(*caerror.Error))
 	}
 	respCertChain := []string{string(cert)}
@@ -96,10 +106,55 @@ func (s *Server) CreateCertificate(ctx context.Context, request *pb.IstioCertifi
 		CertChain: respCertChain,
 	}
 	s.monitoring.Success.Increment()
+	s.auditIssued(record, cert)
 	serverCaLog.Debug("CSR successfully signed.")
 	return response, nil
 }
 
+// newAuditRecord returns the audit record of the request, or nil if the audit log is disabled.
+func (s *Server) newAuditRecord(ctx context.Context, request *pb.IstioCertificateRequest) *audit.Record {
+	if s.AuditLog == nil {
+		return nil
+	}
+	record := &audit.Record{
+		Time:          time.Now(),
+		ClientAddress: getConnectionAddress(ctx),
+	}
+	if request.ValidityDuration > 0 {
+		record.RequestedTTL = (time.Duration(request.ValidityDuration) * time.Second).String()
+	}
+	// An invalid CSR is reported by the signing error.
+	if csr, err := util.ParsePemEncodedCSR([]byte(request.Csr)); err == nil {
+		record.RequestedSANs, _ = util.ExtractIDs(csr.Extensions)
+	}
+	return record
+}
+
+// audit records a failed request.
+func (s *Server) audit(record *audit.Record, result, errMsg string) {
+	if record == nil {
+		return
+	}
+	record.Result = result
+	record.Error = errMsg
+	s.AuditLog.Record(record)
+}
+
+// auditIssued records the certificate issued for a request.
+func (s *Server) auditIssued(record *audit.Record, certPEM []byte) {
+	if record == nil {
+		return
+	}
+	cert, err := util.ParsePemEncodedCertificate(certPEM)
+	if err != nil {
+		s.audit(record, audit.ResultIssued, fmt.Sprintf("failed to parse the issued certificate: %v", err))
+		return
+	}
+	sans, _ := util.ExtractIDs(cert.Extensions)
+	record.SetCertificate(cert, sans)
+	s.AuditLog.Record(record)
+}
+
 func recordCertsExpiry(keyCertBundle *util.KeyCertBundle) {
 	rootCertExpiry, err := keyCertBundle.ExtractRootCertExpiryTimestamp()
 	if err != nil {
@@ -142,18 +197,29 @@ func New(ca CertificateAuthority, ttl time.Duration,
 // authenticate goes through a list of authenticators (provided client cert, k8s jwt, and ID token)
 // and authenticates if one of them is valid.
 func Authenticate(ctx context.Context, auth []security.Authenticator) *security.Caller {
-	// TODO: apply different authenticators in specific order / according to configuration.
+	caller, _ := authenticateCaller(ctx, auth)
+	return caller
+}
+
+// authenticateCaller is Authenticate, also returning the type of the authenticator which authenticated the caller.
+func authenticateCaller(ctx context.Context, auth []security.Authenticator) (*security.Caller, string) {
 	var errMsg string
 	for id, authn := range auth {
 		u, err := authn.Authenticate(ctx)
 		if err != nil {
 			errMsg += fmt.Sprintf("Authenticator %s at index %d got error: %v. ", authn.AuthenticatorType(), id, err)
+			// Requests without the credentials of an authenticator are left to the next ones, only
+			// the credentials it rejected are failures.
+			if !security.IsNotApplicable(err) {
+				authenticatorFailureCounts.With(authenticatorTag.Value(authn.AuthenticatorType())).Increment()
+			}
 		}
 		if u != nil && err == nil {
 			serverCaLog.Debugf("Authentication successful through auth source %v", u.AuthSource)
-			return u
+			authenticatorSuccessCounts.With(authenticatorTag.Value(authn.AuthenticatorType())).Increment()
+			return u, authn.AuthenticatorType()
 		}
 	}
 	serverCaLog.Warnf("Authentication failed for %v: %s", getConnectionAddress(ctx), errMsg)
-	return nil
+	return nil, ""
 }
@@ -20,7 +20,10 @@ import (
 	"crypto/x509/pkix"
 	"fmt"
 	"net"
+	"reflect"
+	"sync"
 	"testing"
+	"time"
 
 	"golang.org/x/net/context"
 	"google.golang.org/grpc/codes"
@@ -33,6 +36,7 @@ import (
 	mockca "istio.io/istio/security/pkg/pki/ca/mock"
 	caerror "istio.io/istio/security/pkg/pki/error"
 	"istio.io/istio/security/pkg/pki/util"
+	"istio.io/istio/security/pkg/server/ca/audit"
 	"istio.io/istio/security/pkg/server/ca/authenticate"
 )
 
@@ -257,3 +261,118 @@ func TestCreateCertificate(t *testing.T) {
 		}
 	}
 }
+
+type memorySink struct {
+	mu      sync.Mutex
+	records []*audit.Record
+}
+
+func (s *memorySink) Write(r *audit.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, r)
+	return nil
+}
+
+func (s *memorySink) Close() error {
+	return nil
+}
+
+func TestCreateCertificateAudit(t *testing.T) {
+	callerID := "spiffe://cluster.local/ns/default/sa/foo"
+	csrPEM, _, err := util.GenCSR(util.CertOptions{Host: callerID, RSAKeySize: 2048})
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM, _, err := util.GenCertKeyFromOptions(util.CertOptions{
+		Host:         callerID,
+		TTL:          time.Hour,
+		IsSelfSigned: true,
+		RSAKeySize:   2048,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := util.ParsePemEncodedCertificate(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.IPAddr{IP: net.IPv4(10, 0, 0, 1)}})
+
+	testCases := map[string]struct {
+		authenticators []security.Authenticator
+		ca             CertificateAuthority
+		expected       audit.Record
+	}{
+		"Unauthenticated request": {
+			authenticators: []security.Authenticator{&mockAuthenticator{errMsg: "Not authorized"}},
+			ca:             &mockca.FakeCA{},
+			expected: audit.Record{
+				Result:        audit.ResultAuthenticationFailed,
+				Error:         "request authenticate failure",
+				ClientAddress: "10.0.0.1",
+				RequestedSANs: []string{callerID},
+				RequestedTTL:  "1h0m0s",
+			},
+		},
+		"Failed to sign": {
+			authenticators: []security.Authenticator{&mockAuthenticator{identities: []string{callerID}}},
+			ca:             &mockca.FakeCA{SignErr: caerror.NewError(caerror.CertGenError, fmt.Errorf("cannot sign"))},
+			expected: audit.Record{
+				Result:           audit.ResultSigningFailed,
+				Error:            "cannot sign",
+				ClientAddress:    "10.0.0.1",
+				Authenticator:    "mockAuthenticator",
+				CallerIdentities: []string{callerID},
+				RequestedSANs:    []string{callerID},
+				RequestedTTL:     "1h0m0s",
+			},
+		},
+		"Successful signing": {
+			authenticators: []security.Authenticator{&mockAuthenticator{identities: []string{callerID}}},
+			ca: &mockca.FakeCA{
+				SignedCert:    certPEM,
+				KeyCertBundle: util.NewKeyCertBundleFromPem(nil, nil, []byte("cert_chain"), []byte("root_cert")),
+			},
+			expected: audit.Record{
+				Result:           audit.ResultIssued,
+				ClientAddress:    "10.0.0.1",
+				Authenticator:    "mockAuthenticator",
+				CallerIdentities: []string{callerID},
+				RequestedSANs:    []string{callerID},
+				RequestedTTL:     "1h0m0s",
+				SerialNumber:     audit.SerialNumber(cert),
+				SANs:             []string{callerID},
+				TTL:              "1h0m0s",
+				NotAfter:         &cert.NotAfter,
+			},
+		},
+	}
+
+	for id, c := range testCases {
+		sink := &memorySink{}
+		server := &Server{
+			ca:             c.ca,
+			Authenticators: c.authenticators,
+			monitoring:     newMonitoringMetrics(),
+			AuditLog:       audit.NewLog(sink, 10),
+		}
+		request := &pb.IstioCertificateRequest{Csr: string(csrPEM), ValidityDuration: 3600}
+		_, _ = server.CreateCertificate(ctx, request)
+
+		if len(sink.records) != 1 {
+			t.Fatalf("Case %s: got %d audit records, want 1", id, len(sink.records))
+		}
+		got := *sink.records[0]
+		if got.Time.IsZero() {
+			t.Errorf("Case %s: the audit record has no time", id)
+		}
+		got.Time = time.Time{}
+		if !reflect.DeepEqual(got, c.expected) {
+			t.Errorf("Case %s: got audit record %+v, want %+v", id, got, c.expected)
+		}
+		if found := server.AuditLog.Lookup(audit.SerialNumber(cert)) != nil; found != (c.expected.Result == audit.ResultIssued) {
+			t.Errorf("Case %s: got certificate found by serial %v", id, found)
+		}
+	}
+}
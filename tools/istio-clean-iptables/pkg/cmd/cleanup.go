@@ -54,6 +54,15 @@ func removeOldChains(cfg *config.Config, ext dep.Dependencies, cmd string) {
 	flushAndDeleteChains(ext, cmd, constants.NAT, chains)
 }
 
+// removeNftablesTables deletes the Istio owned nftables tables, which also removes all their chains and rules.
+func removeNftablesTables(ext dep.Dependencies) {
+	for _, family := range builder.NftablesFamilies {
+		for _, table := range builder.NftablesTables {
+			ext.RunQuietlyAndIgnore(constants.NFT, "delete", "table", family, builder.NftablesTableName(table))
+		}
+	}
+}
+
 func cleanup(cfg *config.Config) {
 	var ext dep.Dependencies
 	if cfg.DryRun {
@@ -62,6 +71,15 @@ func cleanup(cfg *config.Config) {
 		ext = &dep.RealDependencies{}
 	}
 
+	if cfg.Nftables {
+		defer func() {
+			// nft list is best efforts
+			_ = ext.Run(constants.NFT, "list", "ruleset")
+		}()
+		removeNftablesTables(ext)
+		return
+	}
+
 	defer func() {
 		for _, cmd := range []string{constants.IPTABLESSAVE, constants.IP6TABLESSAVE} {
 			// iptables-save is best efforts
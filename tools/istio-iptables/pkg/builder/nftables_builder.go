@@ -0,0 +1,352 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"istio.io/istio/tools/istio-iptables/pkg/constants"
+)
+
+// nftables address families matching the IPv4 and IPv6 iptables rules.
+const (
+	nftFamilyV4 = "ip"
+	nftFamilyV6 = "ip6"
+)
+
+// NftablesFamilies are the nftables address families the rules are installed in.
+var NftablesFamilies = []string{nftFamilyV4, nftFamilyV6}
+
+// NftablesTables are the iptables tables which have an nftables counterpart.
+var NftablesTables = []string{constants.RAW, constants.MANGLE, constants.NAT}
+
+// nftBaseChains maps the built-in chains of each iptables table to the declaration of the equivalent
+// nftables base chain. Priorities are the ones iptables-nft uses for the same table.
+var nftBaseChains = map[string]map[string]string{
+	constants.RAW: {
+		constants.PREROUTING: "type filter hook prerouting priority -300; policy accept;",
+		constants.OUTPUT:     "type filter hook output priority -300; policy accept;",
+	},
+	constants.MANGLE: {
+		constants.PREROUTING: "type filter hook prerouting priority -150; policy accept;",
+		constants.OUTPUT:     "type route hook output priority -150; policy accept;",
+	},
+	constants.NAT: {
+		constants.PREROUTING: "type nat hook prerouting priority -100; policy accept;",
+		constants.OUTPUT:     "type nat hook output priority -100; policy accept;",
+	},
+}
+
+// NftablesTableName returns the name of the nftables table holding the rules of the given iptables table.
+func NftablesTableName(table string) string {
+	return "istio-" + table
+}
+
+// NftablesBuilder translates the rules collected by an IptablesBuilderImpl into an equivalent nftables
+// ruleset. Every iptables table is mapped to an Istio owned nftables table, so the ruleset can be
+// replaced as a whole in a single `nft -f` transaction without touching rules owned by others.
+type NftablesBuilder struct {
+	rules Rules
+}
+
+// NewNftablesBuilder creates a new NftablesBuilder for the rules of the given IptablesBuilderImpl
+func NewNftablesBuilder(iptables *IptablesBuilderImpl) *NftablesBuilder {
+	return &NftablesBuilder{
+		rules: iptables.rules,
+	}
+}
+
+// nftTable holds the chains of a single nftables table, in the order they were first referenced
+type nftTable struct {
+	table  string
+	name   string
+	chains []string
+	rules  map[string][]string
+}
+
+// Build returns an `nft -f` script replacing the Istio tables with the translated rules
+func (nb *NftablesBuilder) Build() (string, error) {
+	var b strings.Builder
+	for _, f := range []struct {
+		family string
+		rules  []*Rule
+	}{{nftFamilyV4, nb.rules.rulesv4}, {nftFamilyV6, nb.rules.rulesv6}} {
+		tables, err := nb.buildTables(f.family, f.rules)
+		if err != nil {
+			return "", err
+		}
+		for _, t := range tables {
+			// Adding the table before deleting it makes the deletion succeed when the table does not exist yet.
+			fmt.Fprintf(&b, "add table %s %s\n", f.family, t.name)
+			fmt.Fprintf(&b, "delete table %s %s\n", f.family, t.name)
+			fmt.Fprintf(&b, "add table %s %s\n", f.family, t.name)
+			for _, chain := range t.chains {
+				if base, ok := nftBaseChains[t.table][chain]; ok {
+					fmt.Fprintf(&b, "add chain %s %s %s { %s }\n", f.family, t.name, chain, base)
+				} else {
+					fmt.Fprintf(&b, "add chain %s %s %s\n", f.family, t.name, chain)
+				}
+			}
+			for _, chain := range t.chains {
+				for _, r := range t.rules[chain] {
+					fmt.Fprintf(&b, "add rule %s %s %s %s\n", f.family, t.name, chain, r)
+				}
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+func (nb *NftablesBuilder) buildTables(family string, rules []*Rule) ([]*nftTable, error) {
+	tables := []*nftTable{}
+	lookup := map[string]*nftTable{}
+	for _, r := range rules {
+		if _, ok := nftBaseChains[r.table]; !ok {
+			return nil, fmt.Errorf("table %s is not supported by the nftables backend", r.table)
+		}
+		if _, builtin := constants.BuiltInChainsMap[r.chain]; builtin {
+			if _, ok := nftBaseChains[r.table][r.chain]; !ok {
+				return nil, fmt.Errorf("chain %s of table %s is not supported by the nftables backend", r.chain, r.table)
+			}
+		}
+		t, ok := lookup[r.table]
+		if !ok {
+			t = &nftTable{table: r.table, name: NftablesTableName(r.table), rules: map[string][]string{}}
+			lookup[r.table] = t
+			tables = append(tables, t)
+		}
+		if _, ok := t.rules[r.chain]; !ok {
+			t.chains = append(t.chains, r.chain)
+			t.rules[r.chain] = []string{}
+		}
+
+		// Strip the "-A <chain>" or "-I <chain> <position>" prefix added by the iptables builder.
+		if len(r.params) < 2 {
+			return nil, fmt.Errorf("invalid rule %v", r.params)
+		}
+		position := -1
+		params := r.params[2:]
+		if r.params[0] == "-I" {
+			if len(r.params) < 3 {
+				return nil, fmt.Errorf("invalid rule %v", r.params)
+			}
+			p, err := strconv.Atoi(r.params[2])
+			if err != nil {
+				return nil, fmt.Errorf("invalid position in rule %v: %v", r.params, err)
+			}
+			position = p - 1
+			params = r.params[3:]
+		}
+		expr, err := translateRule(family, params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to translate rule %q: %v", strings.Join(r.params, " "), err)
+		}
+		chainRules := t.rules[r.chain]
+		if position < 0 || position >= len(chainRules) {
+			chainRules = append(chainRules, expr)
+		} else {
+			chainRules = append(chainRules[:position], append([]string{expr}, chainRules[position:]...)...)
+		}
+		t.rules[r.chain] = chainRules
+	}
+	return tables, nil
+}
+
+// translateRule translates the iptables match and target options used by istio-iptables into
+// nftables expressions and a statement.
+func translateRule(family string, params []string) (string, error) {
+	exprs := []string{}
+	var protocol, match, target string
+	targetOpts := map[string]string{}
+	negate := false
+
+	next := func(i int) (string, error) {
+		if i+1 >= len(params) {
+			return "", fmt.Errorf("missing value for %s", params[i])
+		}
+		return params[i+1], nil
+	}
+	op := func() string {
+		if negate {
+			return "!= "
+		}
+		return ""
+	}
+
+	for i := 0; i < len(params); i++ {
+		p := params[i]
+		if p == "!" {
+			negate = true
+			continue
+		}
+		if target != "" {
+			// Options following "-j" belong to the target.
+			switch p {
+			case "--save-mark", "--restore-mark":
+				targetOpts[p] = ""
+			default:
+				v, err := next(i)
+				if err != nil {
+					return "", err
+				}
+				targetOpts[p] = v
+				i++
+			}
+			continue
+		}
+		switch p {
+		case "-m":
+			v, err := next(i)
+			if err != nil {
+				return "", err
+			}
+			match = v
+			i++
+			continue
+		case "-j":
+			v, err := next(i)
+			if err != nil {
+				return "", err
+			}
+			target = v
+			i++
+			continue
+		}
+
+		v, err := next(i)
+		if err != nil {
+			return "", err
+		}
+		i++
+		switch p {
+		case "-p":
+			protocol = v
+			exprs = append(exprs, "meta l4proto "+op()+v)
+		case "--dport", "--sport":
+			if protocol == "" {
+				return "", fmt.Errorf("%s requires a protocol", p)
+			}
+			exprs = append(exprs, fmt.Sprintf("%s %s %s%s", protocol, strings.TrimPrefix(p, "--"), op(), translatePorts(v)))
+		case "-d":
+			exprs = append(exprs, fmt.Sprintf("%s daddr %s%s", family, op(), v))
+		case "-s":
+			exprs = append(exprs, fmt.Sprintf("%s saddr %s%s", family, op(), v))
+		case "-o":
+			exprs = append(exprs, fmt.Sprintf("oifname %s%q", op(), v))
+		case "-i":
+			exprs = append(exprs, fmt.Sprintf("iifname %s%q", op(), v))
+		case "--uid-owner":
+			exprs = append(exprs, "meta skuid "+op()+v)
+		case "--gid-owner":
+			exprs = append(exprs, "meta skgid "+op()+v)
+		case "--ctstate":
+			exprs = append(exprs, "ct state "+op()+strings.ToLower(v))
+		case "--mark":
+			switch match {
+			case "mark":
+				exprs = append(exprs, "meta mark "+op()+v)
+			case "connmark":
+				exprs = append(exprs, "ct mark "+op()+v)
+			default:
+				return "", fmt.Errorf("--mark is not supported for match %q", match)
+			}
+		default:
+			return "", fmt.Errorf("unsupported option %s", p)
+		}
+		negate = false
+	}
+
+	statement, err := translateTarget(target, targetOpts)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(append(exprs, statement), " "), nil
+}
+
+// translatePorts converts an iptables port or port range, "first:last", to the nft syntax "first-last".
+// As with iptables, an omitted first or last port is 0 or 65535.
+func translatePorts(ports string) string {
+	i := strings.Index(ports, ":")
+	if i < 0 {
+		return ports
+	}
+	first, last := ports[:i], ports[i+1:]
+	if first == "" {
+		first = "0"
+	}
+	if last == "" {
+		last = "65535"
+	}
+	return first + "-" + last
+}
+
+func translateTarget(target string, opts map[string]string) (string, error) {
+	switch target {
+	case "":
+		return "", fmt.Errorf("missing target")
+	case constants.RETURN:
+		return "return", nil
+	case constants.ACCEPT:
+		return "accept", nil
+	case constants.REJECT:
+		return "reject", nil
+	case constants.REDIRECT:
+		port, ok := opts["--to-ports"]
+		if !ok {
+			port, ok = opts["--to-port"]
+		}
+		if !ok {
+			return "", fmt.Errorf("%s requires --to-ports", target)
+		}
+		return "redirect to :" + port, nil
+	case constants.MARK:
+		mark, ok := opts["--set-mark"]
+		if !ok {
+			return "", fmt.Errorf("%s requires --set-mark", target)
+		}
+		return "meta mark set " + mark, nil
+	case constants.TPROXY:
+		port, ok := opts["--on-port"]
+		if !ok {
+			return "", fmt.Errorf("%s requires --on-port", target)
+		}
+		// The mask is always the full mark, which is what "meta mark set" does.
+		if mark, ok := opts["--tproxy-mark"]; ok {
+			return fmt.Sprintf("meta mark set %s tproxy to :%s", strings.Split(mark, "/")[0], port), nil
+		}
+		return "tproxy to :" + port, nil
+	case "CONNMARK":
+		if _, ok := opts["--save-mark"]; ok {
+			return "ct mark set meta mark", nil
+		}
+		if _, ok := opts["--restore-mark"]; ok {
+			return "meta mark set ct mark", nil
+		}
+		return "", fmt.Errorf("%s requires --save-mark or --restore-mark", target)
+	case constants.CT:
+		zone, ok := opts["--zone"]
+		if !ok {
+			return "", fmt.Errorf("%s requires --zone", target)
+		}
+		return "ct zone set " + zone, nil
+	}
+	if len(opts) > 0 {
+		return "", fmt.Errorf("unsupported target %s", target)
+	}
+	// Anything else is a jump to an Istio chain.
+	return "jump " + target, nil
+}
@@ -0,0 +1,132 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"strings"
+	"testing"
+
+	"istio.io/istio/tools/istio-iptables/pkg/constants"
+)
+
+func TestNftablesBuildEmpty(t *testing.T) {
+	actual, err := NewNftablesBuilder(NewIptablesBuilder()).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual != "" {
+		t.Errorf("Expected empty ruleset; but got %q", actual)
+	}
+}
+
+func TestNftablesBuild(t *testing.T) {
+	iptables := NewIptablesBuilder()
+	iptables.AppendRuleV4(constants.ISTIOREDIRECT, constants.NAT, "-p", constants.TCP, "-j", constants.REDIRECT, "--to-ports", "15001")
+	iptables.AppendRuleV4(constants.OUTPUT, constants.NAT, "-p", constants.TCP, "-j", constants.ISTIOOUTPUT)
+	iptables.AppendRuleV4(constants.ISTIOOUTPUT, constants.NAT, "-o", "lo", "-m", "owner", "!", "--uid-owner", "1337", "-j", constants.RETURN)
+	iptables.AppendRuleV4(constants.ISTIOOUTPUT, constants.NAT, "-j", constants.ISTIOREDIRECT)
+	iptables.InsertRuleV4(constants.ISTIOOUTPUT, constants.NAT, 1, "-p", constants.TCP, "--dport", "15090", "-j", constants.RETURN)
+	iptables.AppendRuleV4(constants.ISTIOOUTPUT, constants.NAT, "-p", constants.TCP, "--dport", "7000:7010", "-j", constants.RETURN)
+	iptables.AppendRuleV4(constants.ISTIOOUTPUT, constants.NAT, "-p", constants.TCP, "!", "--sport", "30000:", "-j", constants.RETURN)
+	iptables.AppendRuleV4(constants.OUTPUT, constants.RAW, "-p", "udp", "--dport", "53", "-d", "10.0.0.10/32", "-j", constants.CT, "--zone", "2")
+	iptables.AppendRuleV6(constants.ISTIOOUTPUT, constants.NAT, "!", "-d", "::1/128", "-j", constants.RETURN)
+
+	actual, err := NewNftablesBuilder(iptables).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"add table ip istio-nat",
+		"delete table ip istio-nat",
+		"add table ip istio-nat",
+		"add chain ip istio-nat ISTIO_REDIRECT",
+		"add chain ip istio-nat OUTPUT { type nat hook output priority -100; policy accept; }",
+		"add chain ip istio-nat ISTIO_OUTPUT",
+		"add rule ip istio-nat ISTIO_REDIRECT meta l4proto tcp redirect to :15001",
+		"add rule ip istio-nat OUTPUT meta l4proto tcp jump ISTIO_OUTPUT",
+		"add rule ip istio-nat ISTIO_OUTPUT meta l4proto tcp tcp dport 15090 return",
+		`add rule ip istio-nat ISTIO_OUTPUT oifname "lo" meta skuid != 1337 return`,
+		"add rule ip istio-nat ISTIO_OUTPUT jump ISTIO_REDIRECT",
+		"add rule ip istio-nat ISTIO_OUTPUT meta l4proto tcp tcp dport 7000-7010 return",
+		"add rule ip istio-nat ISTIO_OUTPUT meta l4proto tcp tcp sport != 30000-65535 return",
+		"add table ip istio-raw",
+		"delete table ip istio-raw",
+		"add table ip istio-raw",
+		"add chain ip istio-raw OUTPUT { type filter hook output priority -300; policy accept; }",
+		"add rule ip istio-raw OUTPUT meta l4proto udp udp dport 53 ip daddr 10.0.0.10/32 ct zone set 2",
+		"add table ip6 istio-nat",
+		"delete table ip6 istio-nat",
+		"add table ip6 istio-nat",
+		"add chain ip6 istio-nat ISTIO_OUTPUT",
+		"add rule ip6 istio-nat ISTIO_OUTPUT ip6 daddr != ::1/128 return",
+		"",
+	}
+	if actual != strings.Join(expected, "\n") {
+		t.Errorf("Output mismatch.\nExpected:\n%s\nActual:\n%s", strings.Join(expected, "\n"), actual)
+	}
+}
+
+func TestNftablesBuildTproxy(t *testing.T) {
+	iptables := NewIptablesBuilder()
+	iptables.AppendRuleV4(constants.ISTIOTPROXY, constants.MANGLE, "!", "-d", "127.0.0.1/32", "-p", constants.TCP, "-j", constants.TPROXY,
+		"--tproxy-mark", "1337/0xffffffff", "--on-port", "15006")
+	iptables.AppendRuleV4(constants.PREROUTING, constants.MANGLE,
+		"-p", constants.TCP, "-m", "mark", "--mark", "1337", "-j", "CONNMARK", "--save-mark")
+	iptables.AppendRuleV4(constants.OUTPUT, constants.MANGLE,
+		"-p", constants.TCP, "-m", "connmark", "--mark", "1337", "-j", "CONNMARK", "--restore-mark")
+	iptables.AppendRuleV4(constants.ISTIOINBOUND, constants.MANGLE, "-p", constants.TCP,
+		"-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED", "-j", constants.ISTIODIVERT)
+
+	actual, err := NewNftablesBuilder(iptables).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rule := range []string{
+		"add chain ip istio-mangle PREROUTING { type filter hook prerouting priority -150; policy accept; }",
+		"add chain ip istio-mangle OUTPUT { type route hook output priority -150; policy accept; }",
+		"add rule ip istio-mangle ISTIO_TPROXY ip daddr != 127.0.0.1/32 meta l4proto tcp meta mark set 1337 tproxy to :15006",
+		"add rule ip istio-mangle PREROUTING meta l4proto tcp meta mark 1337 ct mark set meta mark",
+		"add rule ip istio-mangle OUTPUT meta l4proto tcp ct mark 1337 meta mark set ct mark",
+		"add rule ip istio-mangle ISTIO_INBOUND meta l4proto tcp ct state related,established jump ISTIO_DIVERT",
+	} {
+		if !strings.Contains(actual, rule+"\n") {
+			t.Errorf("Expected ruleset to contain %q; but got:\n%s", rule, actual)
+		}
+	}
+}
+
+func TestNftablesBuildUnsupported(t *testing.T) {
+	cases := []struct {
+		name   string
+		chain  string
+		table  string
+		params []string
+	}{
+		{"unknown option", constants.ISTIOOUTPUT, constants.NAT, []string{"--foo", "bar", "-j", constants.RETURN}},
+		{"port without protocol", constants.ISTIOOUTPUT, constants.NAT, []string{"--dport", "80", "-j", constants.RETURN}},
+		{"missing target", constants.ISTIOOUTPUT, constants.NAT, []string{"-p", constants.TCP}},
+		{"unknown table", constants.ISTIOOUTPUT, "security", []string{"-j", constants.RETURN}},
+		{"unknown base chain", constants.FORWARD, constants.NAT, []string{"-j", constants.RETURN}},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			iptables := NewIptablesBuilder()
+			iptables.AppendRuleV4(tt.chain, tt.table, tt.params...)
+			if _, err := NewNftablesBuilder(iptables).Build(); err == nil {
+				t.Errorf("Expected an error for rule %v", tt.params)
+			}
+		})
+	}
+}
@@ -101,6 +101,7 @@ func constructConfig() *config.Config {
 		SkipRuleApply:           viper.GetBool(constants.SkipRuleApply),
 		RunValidation:           viper.GetBool(constants.RunValidation),
 		RedirectDNS:             viper.GetBool(constants.RedirectDNS),
+		Nftables:                viper.GetBool(constants.Nftables),
 	}
 
 	// TODO: Make this more configurable, maybe with an allowlist of users to be captured for output instead of a denylist.
@@ -284,71 +285,84 @@ func bindFlags(cmd *cobra.Command, args []string) {
 		handleError(err)
 	}
 	viper.SetDefault(constants.RedirectDNS, dnsCaptureByAgent)
+
+	if err := viper.BindPFlag(constants.Nftables, cmd.Flags().Lookup(constants.Nftables)); err != nil {
+		handleError(err)
+	}
+	viper.SetDefault(constants.Nftables, false)
 }
 
 // https://github.com/spf13/viper/issues/233.
 // Only adding flags in `init()` while moving its binding to Viper and value defaulting as part of the command execution.
 // Otherwise, the flag with the same name shared across subcommands will be overwritten by the last.
 func init() {
-	rootCmd.Flags().StringP(constants.EnvoyPort, "p", "", "Specify the envoy port to which redirect all TCP traffic (default $ENVOY_PORT = 15001)")
+	addFlags(rootCmd)
+}
+
+// addFlags adds the flags describing the rules to the command. They are bound to Viper by bindFlags.
+func addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP(constants.EnvoyPort, "p", "", "Specify the envoy port to which redirect all TCP traffic (default $ENVOY_PORT = 15001)")
 
-	rootCmd.Flags().StringP(constants.InboundCapturePort, "z", "",
+	cmd.Flags().StringP(constants.InboundCapturePort, "z", "",
 		"Port to which all inbound TCP traffic to the pod/VM should be redirected to (default $INBOUND_CAPTURE_PORT = 15006)")
 
-	rootCmd.Flags().StringP(constants.InboundTunnelPort, "e", "",
+	cmd.Flags().StringP(constants.InboundTunnelPort, "e", "",
 		"Specify the istio tunnel port for inbound tcp traffic (default $INBOUND_TUNNEL_PORT = 15008)")
 
-	rootCmd.Flags().StringP(constants.ProxyUID, "u", "",
+	cmd.Flags().StringP(constants.ProxyUID, "u", "",
 		"Specify the UID of the user for which the redirection is not applied. Typically, this is the UID of the proxy container")
 
-	rootCmd.Flags().StringP(constants.ProxyGID, "g", "",
+	cmd.Flags().StringP(constants.ProxyGID, "g", "",
 		"Specify the GID of the user for which the redirection is not applied. (same default value as -u param)")
 
-	rootCmd.Flags().StringP(constants.InboundInterceptionMode, "m", "",
+	cmd.Flags().StringP(constants.InboundInterceptionMode, "m", "",
 		"The mode used to redirect inbound connections to Envoy, either \"REDIRECT\" or \"TPROXY\"")
 
-	rootCmd.Flags().StringP(constants.InboundPorts, "b", "",
+	cmd.Flags().StringP(constants.InboundPorts, "b", "",
 		"Comma separated list of inbound ports for which traffic is to be redirected to Envoy (optional). "+
 			"The wildcard character \"*\" can be used to configure redirection for all ports. An empty list will disable")
 
-	rootCmd.Flags().StringP(constants.LocalExcludePorts, "d", "",
+	cmd.Flags().StringP(constants.LocalExcludePorts, "d", "",
 		"Comma separated list of inbound ports to be excluded from redirection to Envoy (optional). "+
 			"Only applies  when all inbound traffic (i.e. \"*\") is being redirected (default to $ISTIO_LOCAL_EXCLUDE_PORTS)")
 
-	rootCmd.Flags().StringP(constants.ServiceCidr, "i", "",
+	cmd.Flags().StringP(constants.ServiceCidr, "i", "",
 		"Comma separated list of IP ranges in CIDR form to redirect to envoy (optional). "+
 			"The wildcard character \"*\" can be used to redirect all outbound traffic. An empty list will disable all outbound")
 
-	rootCmd.Flags().StringP(constants.ServiceExcludeCidr, "x", "",
+	cmd.Flags().StringP(constants.ServiceExcludeCidr, "x", "",
 		"Comma separated list of IP ranges in CIDR form to be excluded from redirection. "+
 			"Only applies when all  outbound traffic (i.e. \"*\") is being redirected (default to $ISTIO_SERVICE_EXCLUDE_CIDR)")
 
-	rootCmd.Flags().StringP(constants.OutboundPorts, "q", "",
+	cmd.Flags().StringP(constants.OutboundPorts, "q", "",
 		"Comma separated list of outbound ports to be explicitly included for redirection to Envoy")
 
-	rootCmd.Flags().StringP(constants.LocalOutboundPortsExclude, "o", "",
+	cmd.Flags().StringP(constants.LocalOutboundPortsExclude, "o", "",
 		"Comma separated list of outbound ports to be excluded from redirection to Envoy")
 
-	rootCmd.Flags().StringP(constants.KubeVirtInterfaces, "k", "",
+	cmd.Flags().StringP(constants.KubeVirtInterfaces, "k", "",
 		"Comma separated list of virtual interfaces whose inbound traffic (from VM) will be treated as outbound")
 
-	rootCmd.Flags().StringP(constants.InboundTProxyMark, "t", "", "")
+	cmd.Flags().StringP(constants.InboundTProxyMark, "t", "", "")
+
+	cmd.Flags().StringP(constants.InboundTProxyRouteTable, "r", "", "")
 
-	rootCmd.Flags().StringP(constants.InboundTProxyRouteTable, "r", "", "")
+	cmd.Flags().BoolP(constants.DryRun, "n", false, "Do not call any external dependencies like iptables")
 
-	rootCmd.Flags().BoolP(constants.DryRun, "n", false, "Do not call any external dependencies like iptables")
+	cmd.Flags().BoolP(constants.RestoreFormat, "f", true, "Print iptables rules in iptables-restore interpretable format")
 
-	rootCmd.Flags().BoolP(constants.RestoreFormat, "f", true, "Print iptables rules in iptables-restore interpretable format")
+	cmd.Flags().String(constants.IptablesProbePort, strconv.Itoa(constants.DefaultIptablesProbePort), "set listen port for failure detection")
 
-	rootCmd.Flags().String(constants.IptablesProbePort, strconv.Itoa(constants.DefaultIptablesProbePort), "set listen port for failure detection")
+	cmd.Flags().Duration(constants.ProbeTimeout, constants.DefaultProbeTimeout, "failure detection timeout")
 
-	rootCmd.Flags().Duration(constants.ProbeTimeout, constants.DefaultProbeTimeout, "failure detection timeout")
+	cmd.Flags().Bool(constants.SkipRuleApply, false, "Skip iptables apply")
 
-	rootCmd.Flags().Bool(constants.SkipRuleApply, false, "Skip iptables apply")
+	cmd.Flags().Bool(constants.RunValidation, false, "Validate iptables")
 
-	rootCmd.Flags().Bool(constants.RunValidation, false, "Validate iptables")
+	cmd.Flags().Bool(constants.RedirectDNS, dnsCaptureByAgent, "Enable capture of dns traffic by istio-agent")
 
-	rootCmd.Flags().Bool(constants.RedirectDNS, dnsCaptureByAgent, "Enable capture of dns traffic by istio-agent")
+	cmd.Flags().Bool(constants.Nftables, false,
+		"Program the rules as an nftables ruleset applied atomically with nft, instead of using iptables")
 }
 
 func GetCommand() *cobra.Command {
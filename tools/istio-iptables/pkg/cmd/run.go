@@ -146,20 +146,7 @@ func (iptConfigurator *IptablesConfigurator) handleInboundPortsInclude() {
 			iptConfigurator.iptables.AppendRuleV4(constants.ISTIODIVERT, constants.MANGLE, "-j", constants.MARK, "--set-mark",
 				iptConfigurator.cfg.InboundTProxyMark)
 			iptConfigurator.iptables.AppendRuleV4(constants.ISTIODIVERT, constants.MANGLE, "-j", constants.ACCEPT)
-			// Route all packets marked in chain ISTIODIVERT using routing table ${INBOUND_TPROXY_ROUTE_TABLE}.
-			// TODO: (abhide): Move this out of this method
-			iptConfigurator.ext.RunOrFail(
-				constants.IP, "-f", "inet", "rule", "add", "fwmark", iptConfigurator.cfg.InboundTProxyMark, "lookup",
-				iptConfigurator.cfg.InboundTProxyRouteTable)
-			// In routing table ${INBOUND_TPROXY_ROUTE_TABLE}, create a single default rule to route all traffic to
-			// the loopback interface.
-			// TODO: (abhide): Move this out of this method
-			err := iptConfigurator.ext.Run(constants.IP, "-f", "inet", "route", "add", "local", "default", "dev", "lo", "table",
-				iptConfigurator.cfg.InboundTProxyRouteTable)
-			if err != nil {
-				// TODO: (abhide): Move this out of this method
-				iptConfigurator.ext.RunOrFail(constants.IP, "route", "show", "table", "all")
-			}
+			// Packets marked in chain ISTIODIVERT are routed to the loopback interface by setupRouting.
 
 			// Create a new chain for redirecting inbound traffic to the common Envoy
 			// port.
@@ -377,12 +364,45 @@ func SplitV4V6(ips []string) (ipv4 []string, ipv6 []string) {
 func (iptConfigurator *IptablesConfigurator) run() {
 	defer func() {
 		// Best effort since we don't know if the commands exist
+		if iptConfigurator.cfg.Nftables {
+			_ = iptConfigurator.ext.Run(constants.NFT, "list", "ruleset")
+			return
+		}
 		_ = iptConfigurator.ext.Run(constants.IPTABLESSAVE)
 		if iptConfigurator.cfg.EnableInboundIPv6 {
 			_ = iptConfigurator.ext.Run(constants.IP6TABLESSAVE)
 		}
 	}()
 
+	iptConfigurator.buildRules()
+	iptConfigurator.logConfig()
+	iptConfigurator.setupRouting()
+	iptConfigurator.executeCommands()
+}
+
+// setupRouting configures the loopback addresses and policy routing the rules rely on.
+func (iptConfigurator *IptablesConfigurator) setupRouting() {
+	if iptConfigurator.cfg.EnableInboundIPv6 {
+		iptConfigurator.ext.RunOrFail(constants.IP, "-6", "addr", "add", "::6/128", "dev", "lo")
+	}
+
+	if iptConfigurator.cfg.InboundPortsInclude != "" && iptConfigurator.cfg.InboundInterceptionMode == constants.TPROXY {
+		// Route all packets marked in chain ISTIODIVERT using routing table ${INBOUND_TPROXY_ROUTE_TABLE}.
+		iptConfigurator.ext.RunOrFail(
+			constants.IP, "-f", "inet", "rule", "add", "fwmark", iptConfigurator.cfg.InboundTProxyMark, "lookup",
+			iptConfigurator.cfg.InboundTProxyRouteTable)
+		// In routing table ${INBOUND_TPROXY_ROUTE_TABLE}, create a single default rule to route all traffic to
+		// the loopback interface.
+		err := iptConfigurator.ext.Run(constants.IP, "-f", "inet", "route", "add", "local", "default", "dev", "lo", "table",
+			iptConfigurator.cfg.InboundTProxyRouteTable)
+		if err != nil {
+			iptConfigurator.ext.RunOrFail(constants.IP, "route", "show", "table", "all")
+		}
+	}
+}
+
+// buildRules renders the rules for the configuration, without applying them.
+func (iptConfigurator *IptablesConfigurator) buildRules() {
 	// Since OUTBOUND_IP_RANGES_EXCLUDE could carry ipv4 and ipv6 ranges
 	// need to split them in different arrays one for ipv4 and one for ipv6
 	// in order to not to fail
@@ -401,12 +421,6 @@ func (iptConfigurator *IptablesConfigurator) run() {
 	}
 
 	redirectDNS := iptConfigurator.cfg.RedirectDNS
-	iptConfigurator.logConfig()
-
-	if iptConfigurator.cfg.EnableInboundIPv6 {
-		// TODO: (abhide): Move this out of this method
-		iptConfigurator.ext.RunOrFail(constants.IP, "-6", "addr", "add", "::6/128", "dev", "lo")
-	}
 
 	// Do not capture internal interface.
 	iptConfigurator.shortCircuitKubeInternalInterface()
@@ -564,7 +578,6 @@ func (iptConfigurator *IptablesConfigurator) run() {
 		iptConfigurator.iptables.InsertRuleV4(constants.ISTIOINBOUND, constants.MANGLE, 1,
 			"-p", constants.TCP, "-m", "mark", "--mark", iptConfigurator.cfg.InboundTProxyMark, "-j", constants.RETURN)
 	}
-	iptConfigurator.executeCommands()
 }
 
 // HandleDNSUDP is a helper function to tackle with DNS UDP specific operations.
@@ -781,8 +794,32 @@ func (iptConfigurator *IptablesConfigurator) executeIptablesRestoreCommand(isIpv
 	return nil
 }
 
+func (iptConfigurator *IptablesConfigurator) executeNftablesCommand() error {
+	data, err := builder.NewNftablesBuilder(iptConfigurator.iptables).Build()
+	if err != nil {
+		return err
+	}
+	rulesFile, err := ioutil.TempFile("", fmt.Sprintf("nftables-rules-%d.nft", time.Now().UnixNano()))
+	if err != nil {
+		return fmt.Errorf("unable to create nftables rules file: %v", err)
+	}
+	defer os.Remove(rulesFile.Name())
+	if err := iptConfigurator.createRulesFile(rulesFile, data); err != nil {
+		return err
+	}
+	// The whole ruleset, IPv4 and IPv6, is applied in a single transaction
+	iptConfigurator.ext.RunOrFail(constants.NFT, "-f", rulesFile.Name())
+	return nil
+}
+
 func (iptConfigurator *IptablesConfigurator) executeCommands() {
-	if iptConfigurator.cfg.RestoreFormat {
+	if iptConfigurator.cfg.Nftables {
+		// Execute nft
+		if err := iptConfigurator.executeNftablesCommand(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	} else if iptConfigurator.cfg.RestoreFormat {
 		// Execute iptables-restore
 		err := iptConfigurator.executeIptablesRestoreCommand(true)
 		if err != nil {
@@ -17,8 +17,10 @@ package cmd
 import (
 	"net"
 	"reflect"
+	"strings"
 	"testing"
 
+	"istio.io/istio/tools/istio-iptables/pkg/builder"
 	"istio.io/istio/tools/istio-iptables/pkg/config"
 	"istio.io/istio/tools/istio-iptables/pkg/constants"
 	dep "istio.io/istio/tools/istio-iptables/pkg/dependencies"
@@ -882,3 +884,55 @@ func TestRulesWithLoopbackIpInOutboundIpRanges(t *testing.T) {
 		t.Errorf("Output mismatch. Expected: \n%#v ; Actual: \n%#v", expected, actual)
 	}
 }
+
+func TestRulesWithNftables(t *testing.T) {
+	useConntrackZoneDNS = false
+	cfg := constructTestConfig()
+	cfg.InboundPortsInclude = "*"
+	cfg.OutboundIPRangesInclude = "*"
+	cfg.DryRun = true
+	cfg.RedirectDNS = true
+	cfg.Nftables = true
+	cfg.DNSServersV4 = []string{"127.0.0.53"}
+	iptConfigurator := NewIptablesConfigurator(cfg, &dep.StdoutStubDependencies{})
+	iptConfigurator.run()
+	actual, err := builder.NewNftablesBuilder(iptConfigurator.iptables).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"add table ip istio-nat",
+		"delete table ip istio-nat",
+		"add table ip istio-nat",
+		"add chain ip istio-nat ISTIO_INBOUND",
+		"add chain ip istio-nat ISTIO_REDIRECT",
+		"add chain ip istio-nat ISTIO_IN_REDIRECT",
+		"add chain ip istio-nat PREROUTING { type nat hook prerouting priority -100; policy accept; }",
+		"add chain ip istio-nat OUTPUT { type nat hook output priority -100; policy accept; }",
+		"add chain ip istio-nat ISTIO_OUTPUT",
+		"add rule ip istio-nat ISTIO_INBOUND meta l4proto tcp tcp dport 15008 return",
+		"add rule ip istio-nat ISTIO_INBOUND meta l4proto tcp tcp dport 22 return",
+		"add rule ip istio-nat ISTIO_INBOUND meta l4proto tcp jump ISTIO_IN_REDIRECT",
+		"add rule ip istio-nat ISTIO_REDIRECT meta l4proto tcp redirect to :15001",
+		"add rule ip istio-nat ISTIO_IN_REDIRECT meta l4proto tcp redirect to :15006",
+		"add rule ip istio-nat PREROUTING meta l4proto tcp jump ISTIO_INBOUND",
+		"add rule ip istio-nat OUTPUT meta l4proto tcp jump ISTIO_OUTPUT",
+		"add rule ip istio-nat OUTPUT meta l4proto udp udp dport 53 meta skuid 1337 return",
+		"add rule ip istio-nat OUTPUT meta l4proto udp udp dport 53 meta skgid 1337 return",
+		"add rule ip istio-nat OUTPUT meta l4proto udp udp dport 53 ip daddr 127.0.0.53/32 redirect to :15053",
+		`add rule ip istio-nat ISTIO_OUTPUT oifname "lo" ip saddr 127.0.0.6/32 return`,
+		`add rule ip istio-nat ISTIO_OUTPUT oifname "lo" ip daddr != 127.0.0.1/32 meta l4proto tcp tcp dport != 53 meta skuid 1337 jump ISTIO_IN_REDIRECT`,
+		`add rule ip istio-nat ISTIO_OUTPUT oifname "lo" meta l4proto tcp tcp dport != 53 meta skuid != 1337 return`,
+		"add rule ip istio-nat ISTIO_OUTPUT meta skuid 1337 return",
+		`add rule ip istio-nat ISTIO_OUTPUT oifname "lo" ip daddr != 127.0.0.1/32 meta skgid 1337 jump ISTIO_IN_REDIRECT`,
+		`add rule ip istio-nat ISTIO_OUTPUT oifname "lo" meta l4proto tcp tcp dport != 53 meta skgid != 1337 return`,
+		"add rule ip istio-nat ISTIO_OUTPUT meta skgid 1337 return",
+		"add rule ip istio-nat ISTIO_OUTPUT meta l4proto tcp tcp dport 53 ip daddr 127.0.0.53/32 redirect to :15053",
+		"add rule ip istio-nat ISTIO_OUTPUT ip daddr 127.0.0.1/32 return",
+		"add rule ip istio-nat ISTIO_OUTPUT jump ISTIO_REDIRECT",
+		"",
+	}
+	if actual != strings.Join(expected, "\n") {
+		t.Errorf("Output mismatch.\nExpected:\n%s\nActual:\n%s", strings.Join(expected, "\n"), actual)
+	}
+}
@@ -0,0 +1,583 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"istio.io/istio/tools/istio-iptables/pkg/constants"
+	dep "istio.io/istio/tools/istio-iptables/pkg/dependencies"
+)
+
+const (
+	verifyRepairFlag = "repair"
+	verifyOutputFlag = "output"
+)
+
+// VerifyResult is the result of comparing the rules installed in the network namespace with the
+// rules istio-iptables would install for the same configuration.
+type VerifyResult struct {
+	IPv4 RuleDrift `json:"ipv4"`
+	// IPv6 is only set when IPv6 inbound redirection is enabled.
+	IPv6 *RuleDrift `json:"ipv6,omitempty"`
+	// InSync is true when no rule is missing, extra or out of order, after the repair if one was done.
+	InSync bool `json:"inSync"`
+	// Repaired is true when the rules were re-applied because of a drift.
+	Repaired bool `json:"repaired"`
+}
+
+// RuleDrift lists the rules which differ from the expected ones, as `-t <table> -A <chain> <rule>`.
+type RuleDrift struct {
+	// Missing are the expected rules which are not installed.
+	Missing []string `json:"missing,omitempty"`
+	// Extra are the installed rules owned by Istio which are not expected: the rules of the Istio chains and
+	// the jumps to Istio chains from the built-in chains.
+	Extra []string `json:"extra,omitempty"`
+	// OutOfOrder are the chains, as `-t <table> <chain>`, whose Istio rules are not in the expected order.
+	OutOfOrder []string `json:"outOfOrder,omitempty"`
+}
+
+func (d *RuleDrift) inSync() bool {
+	return d == nil || (len(d.Missing) == 0 && len(d.Extra) == 0 && len(d.OutOfOrder) == 0)
+}
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify the iptables rules for Istio Sidecar",
+	Long: "verify compares the iptables rules installed in the network namespace with the rules istio-iptables " +
+		"would install with the same flags, reports missing and extra rules, and optionally re-applies the rules.",
+	PreRun: bindFlags,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := constructConfig()
+		if cfg.Nftables {
+			handleError(fmt.Errorf("verify does not support the nftables backend"))
+		}
+		repair, _ := cmd.Flags().GetBool(verifyRepairFlag)
+		output, _ := cmd.Flags().GetString(verifyOutputFlag)
+		if output != "text" && output != "json" {
+			handleError(fmt.Errorf("invalid output format %q, must be one of text or json", output))
+		}
+
+		var ext dep.Dependencies
+		if cfg.DryRun {
+			ext = &dep.StdoutStubDependencies{}
+		} else {
+			ext = &dep.RealDependencies{}
+		}
+		iptConfigurator := NewIptablesConfigurator(cfg, ext)
+		result, err := iptConfigurator.verify(repair)
+		if err != nil {
+			handleError(err)
+		}
+		if output == "json" {
+			b, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				handleError(err)
+			}
+			fmt.Println(string(b))
+		} else {
+			printVerifyResult(os.Stdout, result)
+		}
+		if !result.InSync {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	addFlags(verifyCmd)
+
+	verifyCmd.Flags().Bool(verifyRepairFlag, false, "Re-apply the rules if they differ from the expected rules")
+
+	verifyCmd.Flags().String(verifyOutputFlag, "text", "Output format, one of text or json")
+
+	rootCmd.AddCommand(verifyCmd)
+}
+
+func printVerifyResult(w io.Writer, result *VerifyResult) {
+	drifts := []struct {
+		name  string
+		drift *RuleDrift
+	}{{"IPv4", &result.IPv4}, {"IPv6", result.IPv6}}
+	for _, d := range drifts {
+		if d.drift == nil {
+			continue
+		}
+		for _, r := range d.drift.Missing {
+			fmt.Fprintf(w, "%s missing: %s\n", d.name, r)
+		}
+		for _, r := range d.drift.Extra {
+			fmt.Fprintf(w, "%s extra: %s\n", d.name, r)
+		}
+		for _, c := range d.drift.OutOfOrder {
+			fmt.Fprintf(w, "%s out of order: %s\n", d.name, c)
+		}
+	}
+	switch {
+	case result.Repaired && result.InSync:
+		fmt.Fprintln(w, "Rules repaired")
+	case result.Repaired:
+		fmt.Fprintln(w, "Rules still differ after repair")
+	case result.InSync:
+		fmt.Fprintln(w, "Rules in sync")
+	default:
+		fmt.Fprintln(w, "Rules differ")
+	}
+}
+
+// verify compares the installed rules with the expected ones and, if repair is set, re-applies
+// the expected rules when they differ.
+func (iptConfigurator *IptablesConfigurator) verify(repair bool) (*VerifyResult, error) {
+	iptConfigurator.buildRules()
+	result, err := iptConfigurator.diffInstalledRules()
+	if err != nil {
+		return nil, err
+	}
+	if result.InSync || !repair {
+		return result, nil
+	}
+
+	if !result.IPv4.inSync() {
+		if err := iptConfigurator.restoreTables(true); err != nil {
+			return nil, err
+		}
+	}
+	if !result.IPv6.inSync() {
+		if err := iptConfigurator.restoreTables(false); err != nil {
+			return nil, err
+		}
+	}
+	result, err = iptConfigurator.diffInstalledRules()
+	if err != nil {
+		return nil, err
+	}
+	result.Repaired = true
+	return result, nil
+}
+
+func (iptConfigurator *IptablesConfigurator) diffInstalledRules() (*VerifyResult, error) {
+	result := &VerifyResult{}
+	out, err := iptConfigurator.ext.RunWithOutput(constants.IPTABLESSAVE)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the installed rules: %v", err)
+	}
+	result.IPv4 = diffRules(iptConfigurator.iptables.BuildV4(), string(out))
+	if iptConfigurator.cfg.EnableInboundIPv6 {
+		out, err := iptConfigurator.ext.RunWithOutput(constants.IP6TABLESSAVE)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the installed rules: %v", err)
+		}
+		drift := diffRules(iptConfigurator.iptables.BuildV6(), string(out))
+		result.IPv6 = &drift
+	}
+	result.InSync = result.IPv4.inSync() && result.IPv6.inSync()
+	return result, nil
+}
+
+// restoreTables re-applies the expected rules. Only the rules istio-iptables owns are replaced: the Istio chains
+// are flushed and refilled, and the Istio rules in the built-in chains are deleted and added again. The other
+// rules of the tables are left untouched.
+func (iptConfigurator *IptablesConfigurator) restoreTables(isIpv4 bool) error {
+	var expected [][]string
+	var saveCmd, cmd string
+	if isIpv4 {
+		expected = iptConfigurator.iptables.BuildV4()
+		saveCmd, cmd = constants.IPTABLESSAVE, constants.IPTABLESRESTORE
+	} else {
+		expected = iptConfigurator.iptables.BuildV6()
+		saveCmd, cmd = constants.IP6TABLESSAVE, constants.IP6TABLESRESTORE
+	}
+	out, err := iptConfigurator.ext.RunWithOutput(saveCmd)
+	if err != nil {
+		return fmt.Errorf("failed to list the installed rules: %v", err)
+	}
+	rulesFile, err := ioutil.TempFile("", cmd+"-repair-")
+	if err != nil {
+		return fmt.Errorf("unable to create %s file: %v", cmd, err)
+	}
+	defer os.Remove(rulesFile.Name())
+	_, err = rulesFile.WriteString(buildRepairRestore(expected, string(out)))
+	if closeErr := rulesFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("unable to write %s file: %v", cmd, err)
+	}
+	// --noflush to keep the rules which are not owned by Istio
+	if _, err := iptConfigurator.ext.RunWithOutput(cmd, "--noflush", rulesFile.Name()); err != nil {
+		return fmt.Errorf("failed to repair the rules: %v", err)
+	}
+	return nil
+}
+
+// buildRepairRestore returns the iptables-restore input, to be applied with --noflush, which replaces the
+// installed Istio rules with the expected ones. Declaring a user-defined chain flushes it, even with --noflush.
+func buildRepairRestore(expectedCommands [][]string, saved string) string {
+	tables := []string{}
+	lines := map[string][]string{}
+	add := func(table, line string) {
+		if _, ok := lines[table]; !ok {
+			tables = append(tables, table)
+		}
+		lines[table] = append(lines[table], line)
+	}
+
+	expected := expectedChainRules(expectedCommands)
+	chains := map[tableChain]struct{}{}
+	declare := func(c tableChain) {
+		if _, ok := chains[c]; ok || !isIstioChain(c.chain) {
+			return
+		}
+		chains[c] = struct{}{}
+		add(c.table, fmt.Sprintf(":%s - [0:0]", c.chain))
+	}
+	for _, c := range expected.order {
+		declare(c)
+	}
+	installedRules, installedChains := parseIptablesSave(saved)
+	for _, c := range installedChains {
+		// Stale Istio chains are flushed, so that they no longer reference other chains
+		declare(c)
+	}
+	for _, r := range installedRules {
+		c := tableChain{r.table, r.chain}
+		if isIstioChain(r.chain) || !expected.owns(c, r.params) {
+			continue
+		}
+		add(r.table, "-D "+strings.TrimPrefix(r.rule, "-A "))
+	}
+	for _, c := range expectedCommands {
+		if len(c) < 5 || c[3] == "-N" {
+			continue
+		}
+		add(c[2], strings.Join(c[3:], " "))
+	}
+
+	var b strings.Builder
+	for _, table := range tables {
+		fmt.Fprintf(&b, "*%s\n", table)
+		for _, l := range lines[table] {
+			fmt.Fprintln(&b, l)
+		}
+		fmt.Fprintln(&b, "COMMIT")
+	}
+	return b.String()
+}
+
+type tableChain struct {
+	table string
+	chain string
+}
+
+// isIstioChain returns whether the chain is created and owned by istio-iptables.
+func isIstioChain(chain string) bool {
+	return strings.HasPrefix(chain, "ISTIO_")
+}
+
+// chainRule is a rule of a chain, in its normalized form and as reported in the drift.
+type chainRule struct {
+	key  string
+	rule string
+}
+
+// chainRules are the rules of each chain, in order.
+type chainRules struct {
+	chains map[tableChain][]chainRule
+	order  []tableChain
+}
+
+func (c *chainRules) add(tc tableChain, position int, r chainRule) {
+	rules, ok := c.chains[tc]
+	if !ok {
+		c.order = append(c.order, tc)
+	}
+	if position < 0 || position > len(rules) {
+		position = len(rules)
+	}
+	rules = append(rules, chainRule{})
+	copy(rules[position+1:], rules[position:])
+	rules[position] = r
+	c.chains[tc] = rules
+}
+
+// owns returns whether an installed rule with the given parameters belongs to Istio. In the built-in chains,
+// these are the expected rules and the jumps to Istio chains, which may be stale.
+func (c *chainRules) owns(tc tableChain, params []string) bool {
+	if isIstioChain(tc.chain) {
+		return true
+	}
+	for i := 0; i+1 < len(params); i++ {
+		if (params[i] == "-j" || params[i] == "-g") && isIstioChain(params[i+1]) {
+			return true
+		}
+	}
+	key := normalizeRule(tc.table, tc.chain, params)
+	for _, r := range c.chains[tc] {
+		if r.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// expectedChainRules returns the rules of each chain after running the commands produced by the builder.
+func expectedChainRules(commands [][]string) *chainRules {
+	rules := &chainRules{chains: map[tableChain][]chainRule{}}
+	for _, c := range commands {
+		// Commands are: iptables -t <table> -A <chain> <params> or -I <chain> <position> <params>
+		if len(c) < 5 || c[3] == "-N" {
+			continue
+		}
+		table, chain, params := c[2], c[4], c[5:]
+		position := -1
+		if c[3] == "-I" && len(params) > 0 {
+			if p, err := strconv.Atoi(params[0]); err == nil {
+				position = p - 1
+			}
+			params = params[1:]
+		}
+		rules.add(tableChain{table, chain}, position, chainRule{
+			key:  normalizeRule(table, chain, params),
+			rule: fmt.Sprintf("-t %s -A %s %s", table, chain, strings.Join(params, " ")),
+		})
+	}
+	return rules
+}
+
+// installedRule is a rule parsed from the iptables-save output
+type installedRule struct {
+	table  string
+	chain  string
+	rule   string
+	params []string
+}
+
+// parseIptablesSave parses the rules and the user-defined chains of each table from the iptables-save output.
+func parseIptablesSave(out string) ([]installedRule, []tableChain) {
+	rules := []installedRule{}
+	chains := []tableChain{}
+	table := ""
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "*"):
+			table = strings.TrimPrefix(line, "*")
+		case strings.HasPrefix(line, ":"):
+			fields := strings.Fields(line)
+			if table == "" {
+				continue
+			}
+			chain := strings.TrimPrefix(fields[0], ":")
+			if _, ok := constants.BuiltInChainsMap[chain]; !ok {
+				chains = append(chains, tableChain{table, chain})
+			}
+		case strings.HasPrefix(line, "-A "):
+			fields := splitRule(line)
+			if len(fields) < 2 || table == "" {
+				continue
+			}
+			rules = append(rules, installedRule{table: table, chain: fields[1], rule: line, params: fields[2:]})
+		}
+	}
+	return rules, chains
+}
+
+// diffRules compares the commands produced by the builder with the iptables-save output. The rules of the
+// Istio chains, and the Istio rules of the built-in chains, are compared in order. The other rules of the
+// built-in chains are not owned by Istio, and are ignored.
+func diffRules(expectedCommands [][]string, saved string) RuleDrift {
+	expected := expectedChainRules(expectedCommands)
+	installed := &chainRules{chains: map[tableChain][]chainRule{}}
+	installedRules, _ := parseIptablesSave(saved)
+	for _, r := range installedRules {
+		c := tableChain{r.table, r.chain}
+		if !expected.owns(c, r.params) {
+			continue
+		}
+		installed.add(c, -1, chainRule{
+			key:  normalizeRule(r.table, r.chain, r.params),
+			rule: fmt.Sprintf("-t %s %s", r.table, r.rule),
+		})
+	}
+
+	drift := RuleDrift{}
+	chains := append([]tableChain{}, expected.order...)
+	for _, c := range installed.order {
+		if _, ok := expected.chains[c]; !ok {
+			chains = append(chains, c)
+		}
+	}
+	for _, c := range chains {
+		missing, extra, ordered := compareChainRules(expected.chains[c], installed.chains[c])
+		drift.Missing = append(drift.Missing, missing...)
+		drift.Extra = append(drift.Extra, extra...)
+		if !ordered {
+			drift.OutOfOrder = append(drift.OutOfOrder, fmt.Sprintf("-t %s %s", c.table, c.chain))
+		}
+	}
+	sort.Strings(drift.Missing)
+	sort.Strings(drift.Extra)
+	sort.Strings(drift.OutOfOrder)
+	return drift
+}
+
+// compareChainRules returns the missing and extra rules of a chain, and whether the rules which are
+// both expected and installed are in the expected order.
+func compareChainRules(expected, installed []chainRule) (missing, extra []string, ordered bool) {
+	unmatched := map[string]int{}
+	for _, r := range expected {
+		unmatched[r.key]++
+	}
+	installedOrder := []string{}
+	for _, r := range installed {
+		if unmatched[r.key] > 0 {
+			unmatched[r.key]--
+			installedOrder = append(installedOrder, r.key)
+			continue
+		}
+		extra = append(extra, r.rule)
+	}
+	expectedOrder := []string{}
+	for _, r := range expected {
+		if unmatched[r.key] > 0 {
+			unmatched[r.key]--
+			missing = append(missing, r.rule)
+			continue
+		}
+		expectedOrder = append(expectedOrder, r.key)
+	}
+	return missing, extra, reflect.DeepEqual(expectedOrder, installedOrder)
+}
+
+// splitRule splits a rule in arguments, honoring the double quotes iptables-save uses for values with spaces.
+func splitRule(rule string) []string {
+	args := []string{}
+	var current strings.Builder
+	quoted := false
+	for _, c := range rule {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == ' ' && !quoted:
+			if current.Len() > 0 {
+				args = append(args, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(c)
+		}
+	}
+	if current.Len() > 0 {
+		args = append(args, current.String())
+	}
+	return args
+}
+
+// noValueOptions are the options used by istio-iptables, or printed by iptables-save, which have no value.
+var noValueOptions = map[string]struct{}{
+	"--save-mark":    {},
+	"--restore-mark": {},
+}
+
+// markOptions are the options whose value is a mark, which iptables-save prints in hexadecimal.
+var markOptions = map[string]struct{}{
+	"--mark":        {},
+	"--set-xmark":   {},
+	"--tproxy-mark": {},
+}
+
+// normalizeRule returns a canonical form of the rule, so that rules as rendered by istio-iptables can be
+// compared with the rules printed by iptables-save, which reorders the options and fills in default values.
+func normalizeRule(table, chain string, params []string) string {
+	var matches, targetOpts []string
+	target := ""
+	negate := false
+	for i := 0; i < len(params); i++ {
+		p := params[i]
+		if p == "!" {
+			negate = true
+			continue
+		}
+		opt := p
+		value := ""
+		if _, ok := noValueOptions[p]; !ok && i+1 < len(params) {
+			value = params[i+1]
+			i++
+		}
+		switch opt {
+		case "--to-port":
+			opt = "--to-ports"
+		case "--set-mark":
+			opt = "--set-xmark"
+		}
+		if _, ok := markOptions[opt]; ok {
+			value = normalizeMark(value)
+		}
+		unit := opt + " " + value
+		if negate {
+			unit = "! " + unit
+			negate = false
+		}
+
+		switch {
+		case opt == "-j":
+			target = unit
+		case target != "":
+			// iptables-save prints the default values of these target options
+			if (opt == "--on-ip" && (value == "0.0.0.0" || value == "::")) ||
+				((opt == "--nfmask" || opt == "--ctmask") && value == "0xffffffff") {
+				continue
+			}
+			targetOpts = append(targetOpts, unit)
+		case opt == "-m" && (value == constants.TCP || value == constants.UDP):
+			// Implicit protocol matches added by iptables-save
+		default:
+			matches = append(matches, unit)
+		}
+	}
+	sort.Strings(matches)
+	sort.Strings(targetOpts)
+	return strings.Join(append(append([]string{table, chain}, matches...), append([]string{target}, targetOpts...)...), " ")
+}
+
+// normalizeMark returns a mark, with an optional mask, in hexadecimal without the full mask.
+func normalizeMark(value string) string {
+	parts := strings.SplitN(value, "/", 2)
+	mark, err := strconv.ParseUint(parts[0], 0, 32)
+	if err != nil {
+		return value
+	}
+	normalized := fmt.Sprintf("0x%x", mark)
+	if len(parts) == 2 {
+		mask, err := strconv.ParseUint(parts[1], 0, 32)
+		if err != nil {
+			return value
+		}
+		if mask != 0xffffffff {
+			normalized += fmt.Sprintf("/0x%x", mask)
+		}
+	}
+	return normalized
+}
@@ -0,0 +1,274 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"istio.io/istio/tools/istio-iptables/pkg/config"
+	"istio.io/istio/tools/istio-iptables/pkg/constants"
+)
+
+// iptables-save output for verifyTestConfig
+const verifyTestSave = `# Generated by iptables-save v1.8.4 on Tue Mar  2 10:00:00 2021
+*filter
+:INPUT ACCEPT [0:0]
+:FORWARD ACCEPT [0:0]
+:OUTPUT ACCEPT [0:0]
+-A INPUT -p icmp -j ACCEPT
+COMMIT
+*nat
+:PREROUTING ACCEPT [0:0]
+:INPUT ACCEPT [0:0]
+:OUTPUT ACCEPT [0:0]
+:POSTROUTING ACCEPT [0:0]
+:ISTIO_INBOUND - [0:0]
+:ISTIO_IN_REDIRECT - [0:0]
+:ISTIO_OUTPUT - [0:0]
+:ISTIO_REDIRECT - [0:0]
+-A PREROUTING -p tcp -j ISTIO_INBOUND
+-A OUTPUT -p tcp -j ISTIO_OUTPUT
+-A ISTIO_INBOUND -p tcp -m tcp --dport 15008 -j RETURN
+-A ISTIO_INBOUND -p tcp -m tcp --dport 22 -j RETURN
+-A ISTIO_INBOUND -p tcp -m tcp --dport 15020 -j RETURN
+-A ISTIO_INBOUND -p tcp -j ISTIO_IN_REDIRECT
+-A ISTIO_IN_REDIRECT -p tcp -j REDIRECT --to-ports 15006
+-A ISTIO_OUTPUT -s 127.0.0.6/32 -o lo -j RETURN
+-A ISTIO_OUTPUT ! -d 127.0.0.1/32 -o lo -m owner --uid-owner 1337 -j ISTIO_IN_REDIRECT
+-A ISTIO_OUTPUT -o lo -m owner ! --uid-owner 1337 -j RETURN
+-A ISTIO_OUTPUT -m owner --uid-owner 1337 -j RETURN
+-A ISTIO_OUTPUT ! -d 127.0.0.1/32 -o lo -m owner --gid-owner 1337 -j ISTIO_IN_REDIRECT
+-A ISTIO_OUTPUT -o lo -m owner ! --gid-owner 1337 -j RETURN
+-A ISTIO_OUTPUT -m owner --gid-owner 1337 -j RETURN
+-A ISTIO_OUTPUT -d 127.0.0.1/32 -j RETURN
+-A ISTIO_OUTPUT -j ISTIO_REDIRECT
+-A ISTIO_REDIRECT -p tcp -j REDIRECT --to-ports 15001
+COMMIT
+# Completed on Tue Mar  2 10:00:00 2021
+`
+
+func verifyTestConfig() *config.Config {
+	cfg := constructTestConfig()
+	cfg.InboundPortsInclude = "*"
+	cfg.InboundPortsExclude = "15020"
+	cfg.OutboundIPRangesInclude = "*"
+	return cfg
+}
+
+// saveDependencies returns the saved rules for iptables-save, and records the other commands.
+type saveDependencies struct {
+	save     string
+	restored string
+	commands []string
+	// restoreArgs and restoreData are the arguments and the input file of the last iptables-restore.
+	restoreArgs []string
+	restoreData string
+}
+
+func (s *saveDependencies) RunOrFail(cmd string, args ...string) {
+	s.commands = append(s.commands, cmd)
+}
+
+func (s *saveDependencies) Run(cmd string, args ...string) error {
+	s.commands = append(s.commands, cmd)
+	return nil
+}
+
+func (s *saveDependencies) RunQuietlyAndIgnore(cmd string, args ...string) {
+	s.commands = append(s.commands, cmd)
+}
+
+func (s *saveDependencies) RunWithOutput(cmd string, args ...string) ([]byte, error) {
+	s.commands = append(s.commands, cmd)
+	switch cmd {
+	case constants.IPTABLESSAVE:
+		return []byte(s.save), nil
+	case constants.IPTABLESRESTORE:
+		s.restoreArgs = args
+		if len(args) > 0 {
+			b, err := ioutil.ReadFile(args[len(args)-1])
+			if err != nil {
+				return nil, err
+			}
+			s.restoreData = string(b)
+		}
+		s.save = s.restored
+	}
+	return nil, nil
+}
+
+func TestVerifyInSync(t *testing.T) {
+	iptConfigurator := NewIptablesConfigurator(verifyTestConfig(), &saveDependencies{save: verifyTestSave})
+	result, err := iptConfigurator.verify(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &VerifyResult{InSync: true}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Result mismatch.\nExpected: %#v\nActual: %#v", expected, result)
+	}
+}
+
+func TestVerifyDrift(t *testing.T) {
+	save := strings.Replace(verifyTestSave, "-A ISTIO_OUTPUT -m owner --gid-owner 1337 -j RETURN\n", "", 1)
+	save = strings.Replace(save, "-A OUTPUT -p tcp -j ISTIO_OUTPUT\n",
+		"-A OUTPUT -p tcp -m tcp --dport 8080 -j ACCEPT\n-A OUTPUT -p tcp -j ISTIO_OUTPUT\n-A OUTPUT -j ISTIO_OUTPUT\n", 1)
+	save = strings.Replace(save, "-A ISTIO_INBOUND -p tcp -j ISTIO_IN_REDIRECT\n",
+		"-A ISTIO_INBOUND -p tcp -m tcp --dport 9090 -j RETURN\n-A ISTIO_INBOUND -p tcp -j ISTIO_IN_REDIRECT\n", 1)
+	iptConfigurator := NewIptablesConfigurator(verifyTestConfig(), &saveDependencies{save: save})
+	result, err := iptConfigurator.verify(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &VerifyResult{
+		IPv4: RuleDrift{
+			Missing: []string{"-t nat -A ISTIO_OUTPUT -m owner --gid-owner 1337 -j RETURN"},
+			Extra: []string{
+				"-t nat -A ISTIO_INBOUND -p tcp -m tcp --dport 9090 -j RETURN",
+				"-t nat -A OUTPUT -j ISTIO_OUTPUT",
+			},
+		},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Result mismatch.\nExpected: %#v\nActual: %#v", expected, result)
+	}
+}
+
+func TestVerifyOutOfOrder(t *testing.T) {
+	save := strings.Replace(verifyTestSave, "-A ISTIO_OUTPUT -s 127.0.0.6/32 -o lo -j RETURN\n", "", 1)
+	save = strings.Replace(save, "-A ISTIO_OUTPUT -j ISTIO_REDIRECT\n",
+		"-A ISTIO_OUTPUT -j ISTIO_REDIRECT\n-A ISTIO_OUTPUT -s 127.0.0.6/32 -o lo -j RETURN\n", 1)
+	iptConfigurator := NewIptablesConfigurator(verifyTestConfig(), &saveDependencies{save: save})
+	result, err := iptConfigurator.verify(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &VerifyResult{
+		IPv4: RuleDrift{
+			OutOfOrder: []string{"-t nat ISTIO_OUTPUT"},
+		},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Result mismatch.\nExpected: %#v\nActual: %#v", expected, result)
+	}
+}
+
+func TestVerifyRepair(t *testing.T) {
+	ext := &saveDependencies{
+		save:     strings.Replace(verifyTestSave, "-A OUTPUT -p tcp -j ISTIO_OUTPUT\n", "", 1),
+		restored: verifyTestSave,
+	}
+	iptConfigurator := NewIptablesConfigurator(verifyTestConfig(), ext)
+	result, err := iptConfigurator.verify(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &VerifyResult{InSync: true, Repaired: true}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Result mismatch.\nExpected: %#v\nActual: %#v", expected, result)
+	}
+	expectedCommands := []string{constants.IPTABLESSAVE, constants.IPTABLESSAVE, constants.IPTABLESRESTORE, constants.IPTABLESSAVE}
+	if !reflect.DeepEqual(ext.commands, expectedCommands) {
+		t.Errorf("Commands mismatch.\nExpected: %v\nActual: %v", expectedCommands, ext.commands)
+	}
+	if len(ext.restoreArgs) == 0 || ext.restoreArgs[0] != "--noflush" {
+		t.Errorf("Expected the rules to be restored with --noflush, got %v", ext.restoreArgs)
+	}
+	if !strings.Contains(ext.restoreData, "-A OUTPUT -p tcp -j ISTIO_OUTPUT\n") {
+		t.Errorf("Expected the missing rule to be restored, got:\n%s", ext.restoreData)
+	}
+}
+
+func TestBuildRepairRestore(t *testing.T) {
+	save := strings.Replace(verifyTestSave, "-A OUTPUT -p tcp -j ISTIO_OUTPUT\n",
+		"-A OUTPUT -p tcp -m tcp --dport 8080 -j ACCEPT\n-A OUTPUT -p tcp -j ISTIO_OUTPUT\n-A OUTPUT -j ISTIO_STALE\n", 1)
+	save = strings.Replace(save, ":ISTIO_REDIRECT - [0:0]\n", ":ISTIO_REDIRECT - [0:0]\n:ISTIO_STALE - [0:0]\n", 1)
+	iptConfigurator := NewIptablesConfigurator(verifyTestConfig(), &saveDependencies{})
+	iptConfigurator.buildRules()
+	restore := buildRepairRestore(iptConfigurator.iptables.BuildV4(), save)
+
+	// Only the nat table has Istio rules, and the foreign rules are kept
+	for _, unexpected := range []string{"*filter", "-D INPUT", "8080"} {
+		if strings.Contains(restore, unexpected) {
+			t.Errorf("Unexpected %q in restore:\n%s", unexpected, restore)
+		}
+	}
+	for _, want := range []string{
+		"*nat\n",
+		":ISTIO_OUTPUT - [0:0]\n",
+		":ISTIO_STALE - [0:0]\n",
+		"-D PREROUTING -p tcp -j ISTIO_INBOUND\n",
+		"-D OUTPUT -p tcp -j ISTIO_OUTPUT\n",
+		"-D OUTPUT -j ISTIO_STALE\n",
+		"-A OUTPUT -p tcp -j ISTIO_OUTPUT\n",
+		"-A ISTIO_REDIRECT -p tcp -j REDIRECT --to-ports 15001\n",
+		"COMMIT\n",
+	} {
+		if !strings.Contains(restore, want) {
+			t.Errorf("Expected %q in restore:\n%s", want, restore)
+		}
+	}
+	if strings.Contains(restore, "-N ") {
+		t.Errorf("Chains must be declared, not created, in restore:\n%s", restore)
+	}
+	if strings.Index(restore, "-D OUTPUT -p tcp -j ISTIO_OUTPUT") > strings.Index(restore, "-A OUTPUT -p tcp -j ISTIO_OUTPUT") {
+		t.Errorf("Expected the Istio rules to be deleted before being added again:\n%s", restore)
+	}
+}
+
+func TestNormalizeRule(t *testing.T) {
+	cases := []struct {
+		name     string
+		rendered string
+		saved    string
+	}{
+		{
+			"tproxy",
+			"! -d 127.0.0.1/32 -p tcp -j TPROXY --tproxy-mark 1337/0xffffffff --on-port 15006",
+			"! -d 127.0.0.1/32 -p tcp -j TPROXY --on-port 15006 --on-ip 0.0.0.0 --tproxy-mark 0x539/0xffffffff",
+		},
+		{
+			"set mark",
+			"-j MARK --set-mark 1337",
+			"-j MARK --set-xmark 0x539/0xffffffff",
+		},
+		{
+			"connmark",
+			"-p tcp -m connmark --mark 1337 -j CONNMARK --restore-mark",
+			"-p tcp -m connmark --mark 0x539 -j CONNMARK --restore-mark --nfmask 0xffffffff --ctmask 0xffffffff",
+		},
+		{
+			"redirect port",
+			"-p udp --dport 53 -d 10.0.0.10/32 -j REDIRECT --to-port 15053",
+			"-d 10.0.0.10/32 -p udp -m udp --dport 53 -j REDIRECT --to-ports 15053",
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			rendered := normalizeRule(constants.MANGLE, constants.ISTIOTPROXY, strings.Split(tt.rendered, " "))
+			saved := normalizeRule(constants.MANGLE, constants.ISTIOTPROXY, strings.Split(tt.saved, " "))
+			if rendered != saved {
+				t.Errorf("Normalized rules differ:\n%s\n%s", rendered, saved)
+			}
+		})
+	}
+	if normalizeRule(constants.MANGLE, constants.PREROUTING, []string{"-m", "mark", "--mark", "1337", "-j", "RETURN"}) ==
+		normalizeRule(constants.MANGLE, constants.PREROUTING, []string{"-m", "connmark", "--mark", "1337", "-j", "RETURN"}) {
+		t.Errorf("Expected mark and connmark matches to differ")
+	}
+}
@@ -90,6 +90,7 @@ const (
 	IptablesProbePort         = "iptables-probe-port"
 	ProbeTimeout              = "probe-timeout"
 	RedirectDNS               = "redirect-dns"
+	Nftables                  = "nftables"
 )
 
 const (
@@ -111,6 +112,7 @@ const (
 	IP6TABLESRESTORE = "ip6tables-restore"
 	IP6TABLESSAVE    = "ip6tables-save"
 	IP               = "ip"
+	NFT              = "nft"
 )
 
 // Constants for syscall
@@ -52,3 +52,10 @@ func (r *RealDependencies) Run(cmd string, args ...string) error {
 func (r *RealDependencies) RunQuietlyAndIgnore(cmd string, args ...string) {
 	_ = r.execute(cmd, true, args...)
 }
+
+// RunWithOutput runs a command without echoing it and returns its standard output
+func (r *RealDependencies) RunWithOutput(cmd string, args ...string) ([]byte, error) {
+	externalCommand := exec.Command(cmd, args...)
+	externalCommand.Stderr = os.Stderr
+	return externalCommand.Output()
+}
@@ -0,0 +1,119 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// pilot-load simulates proxies connected to istiod, to measure how it scales with the number of
+// proxies and services.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"istio.io/istio/tools/pilot-load/pkg/load"
+	"istio.io/pkg/log"
+)
+
+func main() {
+	if err := rootCmd().Execute(); err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+}
+
+func rootCmd() *cobra.Command {
+	loggingOptions := log.DefaultOptions()
+	// Each simulated proxy logs every response at info level.
+	loggingOptions.SetOutputLevel("adsc", log.WarnLevel)
+	loggingOptions.SetOutputLevel("ads", log.WarnLevel)
+	opts := load.DefaultOptions()
+	var churnKind, output string
+
+	cmd := &cobra.Command{
+		Use:   "pilot-load",
+		Short: "Simulates proxies connected to istiod",
+		Long: `Connects simulated sidecars and gateways to istiod, subscribed to all the xDS types, and
+churns synthetic services or endpoints. Reports the time for the proxies to get their initial
+config, the latency for each change to reach each proxy, the time for all the proxies to converge,
+and the resource usage of istiod.
+
+Without --istiod, an in-process istiod with an in-memory service registry is used, and the reported
+resource usage includes the simulated proxies. With --istiod, the services are created as
+ServiceEntries with the Kubernetes client, and the resource usage is read from --istiod-metrics.`,
+		Example: `  # Simulate 1000 sidecars with 500 services against an in-process istiod
+  pilot-load --sidecars 1000 --services 500
+
+  # Simulate proxies against a remote istiod, replacing a service every 5s
+  pilot-load --istiod localhost:15010 --istiod-metrics http://localhost:15014/metrics \
+    --sidecars 2000 --gateways 10 --churn services --interval 5s --connect-rate 100`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		PersistentPreRunE: func(*cobra.Command, []string) error {
+			return log.Configure(loggingOptions)
+		},
+		RunE: func(c *cobra.Command, args []string) error {
+			opts.ChurnKind = load.ChurnKind(churnKind)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			stop := make(chan os.Signal, 1)
+			signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+			go func() {
+				<-stop
+				cancel()
+			}()
+
+			report, err := load.Run(ctx, opts)
+			if err != nil {
+				return err
+			}
+			switch output {
+			case "json":
+				by, err := json.MarshalIndent(report, "", "  ")
+				if err != nil {
+					return err
+				}
+				c.Println(string(by))
+				return nil
+			case "short":
+				return report.Print(c.OutOrStdout())
+			default:
+				return fmt.Errorf("output format %q not supported", output)
+			}
+		},
+	}
+	loggingOptions.AttachCobraFlags(cmd)
+	flags := cmd.Flags()
+	flags.StringVar(&opts.Address, "istiod", opts.Address, "Address of a remote istiod, an in-process istiod is used if empty")
+	flags.StringVar(&opts.Kubeconfig, "kubeconfig", opts.Kubeconfig, "Kubeconfig used to create services for a remote istiod")
+	flags.StringVar(&opts.MetricsURL, "istiod-metrics", opts.MetricsURL, "Prometheus endpoint of a remote istiod")
+	flags.IntVar(&opts.Sidecars, "sidecars", opts.Sidecars, "Number of simulated sidecars")
+	flags.IntVar(&opts.Gateways, "gateways", opts.Gateways, "Number of simulated gateways")
+	flags.StringVar(&opts.Namespace, "namespace", opts.Namespace, "Namespace of the proxies and services")
+	flags.StringVar(&opts.IstioVersion, "istio-version", opts.IstioVersion, "Istio version reported by the proxies")
+	flags.IntVar(&opts.ConnectRate, "connect-rate", opts.ConnectRate, "Proxies connected per second, all at once if 0")
+	flags.Float64Var(&opts.NackRate, "nack-rate", opts.NackRate, "Fraction of the config updates rejected by the proxies")
+	flags.IntVar(&opts.Services, "services", opts.Services, "Number of synthetic services")
+	flags.IntVar(&opts.EndpointsPerService, "endpoints", opts.EndpointsPerService, "Number of endpoints per service")
+	flags.StringVar(&churnKind, "churn", string(opts.ChurnKind), "What to change on each round: endpoints or services")
+	flags.IntVar(&opts.Rounds, "rounds", opts.Rounds, "Number of churn rounds")
+	flags.DurationVar(&opts.ChurnInterval, "interval", opts.ChurnInterval, "Time between churn rounds")
+	flags.DurationVar(&opts.Timeout, "timeout", opts.Timeout, "Maximum time to wait for the proxies to get a change")
+	flags.StringVarP(&output, "output", "o", "short", "Output format: one of json|short")
+	return cmd
+}
@@ -0,0 +1,251 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package load
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	networking "istio.io/api/networking/v1alpha3"
+	clientnetworking "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/serviceregistry/memory"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/config/visibility"
+	"istio.io/istio/pkg/kube"
+)
+
+// ChurnKind is the kind of change applied on each churn round.
+type ChurnKind string
+
+const (
+	// ChurnEndpoints replaces the endpoints of a service, resulting in an incremental EDS push.
+	ChurnEndpoints ChurnKind = "endpoints"
+	// ChurnServices replaces a service by a new one, resulting in a full push.
+	ChurnServices ChurnKind = "services"
+)
+
+// churnLabel is set on the resources created by pilot-load, to clean them up.
+const churnLabel = "istio.io/pilot-load"
+
+// churner creates the synthetic services and changes them on each round.
+type churner interface {
+	// init creates the initial services.
+	init(services, endpoints int) error
+	// churn applies the change for a round. It returns the xDS type the proxies are expected to receive and,
+	// for endpoint changes, the cluster changed.
+	churn(round int) (string, string, error)
+	// cleanup removes the synthetic services.
+	cleanup() error
+}
+
+func serviceName(i int) string {
+	return fmt.Sprintf("load-svc-%d", i)
+}
+
+func serviceHostname(i int, namespace string) string {
+	return fmt.Sprintf("%s.%s.svc.cluster.local", serviceName(i), namespace)
+}
+
+func serviceCluster(i int, namespace string) string {
+	return model.BuildSubsetKey(model.TrafficDirectionOutbound, "", host.Name(serviceHostname(i, namespace)), 80)
+}
+
+func serviceVIP(i int) string {
+	return fmt.Sprintf("240.240.%d.%d", (i>>8)&0xff, i&0xff)
+}
+
+// endpointIP returns the IP of the j-th endpoint of the i-th service, for a round. Each round moves
+// the endpoints to new IPs.
+func endpointIP(i, j, round int) string {
+	n := i*64 + j + round
+	return fmt.Sprintf("10.%d.%d.%d", (n>>16)&0x7f, (n>>8)&0xff, n&0xff)
+}
+
+// churnedService returns the service changed on a round, and, for service churn, the service replacing it.
+func churnedService(round, services int) (int, int) {
+	return round % services, services + round
+}
+
+// memoryChurner changes services in the in-memory registry of an in-process istiod.
+type memoryChurner struct {
+	registry  *memory.ServiceDiscovery
+	updater   model.XDSUpdater
+	namespace string
+	kind      ChurnKind
+	services  int
+	endpoints int
+	// current maps the index of a service slot to the service currently in it.
+	current map[int]int
+}
+
+var _ churner = &memoryChurner{}
+
+func (m *memoryChurner) init(services, endpoints int) error {
+	m.services, m.endpoints = services, endpoints
+	m.current = map[int]int{}
+	for i := 0; i < services; i++ {
+		m.current[i] = i
+		m.addService(i)
+	}
+	m.updater.ConfigUpdate(&model.PushRequest{Full: true, Reason: []model.TriggerReason{model.ServiceUpdate}})
+	for i := 0; i < services; i++ {
+		m.setEndpoints(i, 0)
+	}
+	return nil
+}
+
+func (m *memoryChurner) addService(i int) {
+	hostname := host.Name(serviceHostname(i, m.namespace))
+	m.registry.AddService(hostname, &model.Service{
+		Hostname: hostname,
+		Address:  serviceVIP(i),
+		Ports: model.PortList{
+			{Name: "http", Port: 80, Protocol: protocol.HTTP},
+		},
+		Attributes: model.ServiceAttributes{
+			Name:      serviceName(i),
+			Namespace: m.namespace,
+			ExportTo:  map[visibility.Instance]bool{visibility.Public: true},
+		},
+	})
+}
+
+func (m *memoryChurner) setEndpoints(i, round int) {
+	eps := make([]*model.IstioEndpoint, 0, m.endpoints)
+	for j := 0; j < m.endpoints; j++ {
+		eps = append(eps, &model.IstioEndpoint{
+			Address:         endpointIP(i, j, round),
+			ServicePortName: "http",
+			EndpointPort:    8080,
+			Namespace:       m.namespace,
+			ServiceAccount:  "spiffe://cluster.local/ns/" + m.namespace + "/sa/default",
+			Labels:          map[string]string{"app": serviceName(i)},
+		})
+	}
+	m.registry.SetEndpoints(serviceHostname(i, m.namespace), m.namespace, eps)
+}
+
+func (m *memoryChurner) churn(round int) (string, string, error) {
+	slot, added := churnedService(round, m.services)
+	if m.kind == ChurnEndpoints {
+		m.setEndpoints(m.current[slot], round)
+		return v3.EndpointType, serviceCluster(m.current[slot], m.namespace), nil
+	}
+	removed := m.current[slot]
+	m.current[slot] = added
+	m.addService(added)
+	m.registry.RemoveService(host.Name(serviceHostname(removed, m.namespace)))
+	m.updater.ConfigUpdate(&model.PushRequest{
+		Full: true,
+		ConfigsUpdated: map[model.ConfigKey]struct{}{
+			{Kind: gvk.ServiceEntry, Name: serviceHostname(added, m.namespace), Namespace: m.namespace}:   {},
+			{Kind: gvk.ServiceEntry, Name: serviceHostname(removed, m.namespace), Namespace: m.namespace}: {},
+		},
+		Reason: []model.TriggerReason{model.ServiceUpdate},
+	})
+	m.setEndpoints(added, round)
+	return v3.ClusterType, "", nil
+}
+
+func (m *memoryChurner) cleanup() error {
+	return nil
+}
+
+// serviceEntryChurner changes ServiceEntries through the Kubernetes API, for a remote istiod.
+type serviceEntryChurner struct {
+	client    kube.Client
+	namespace string
+	kind      ChurnKind
+	services  int
+	endpoints int
+	current   map[int]int
+}
+
+var _ churner = &serviceEntryChurner{}
+
+func (s *serviceEntryChurner) serviceEntry(i, round int) *clientnetworking.ServiceEntry {
+	se := &clientnetworking.ServiceEntry{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      serviceName(i),
+			Namespace: s.namespace,
+			Labels:    map[string]string{churnLabel: "true"},
+		},
+		Spec: networking.ServiceEntry{
+			Hosts:      []string{serviceHostname(i, s.namespace)},
+			Addresses:  []string{serviceVIP(i)},
+			Ports:      []*networking.Port{{Name: "http", Number: 80, Protocol: "HTTP", TargetPort: 8080}},
+			Location:   networking.ServiceEntry_MESH_INTERNAL,
+			Resolution: networking.ServiceEntry_STATIC,
+		},
+	}
+	for j := 0; j < s.endpoints; j++ {
+		se.Spec.Endpoints = append(se.Spec.Endpoints, &networking.WorkloadEntry{
+			Address: endpointIP(i, j, round),
+			Labels:  map[string]string{"app": serviceName(i)},
+		})
+	}
+	return se
+}
+
+func (s *serviceEntryChurner) init(services, endpoints int) error {
+	s.services, s.endpoints = services, endpoints
+	s.current = map[int]int{}
+	for i := 0; i < services; i++ {
+		s.current[i] = i
+		if _, err := s.client.Istio().NetworkingV1alpha3().ServiceEntries(s.namespace).
+			Create(context.TODO(), s.serviceEntry(i, 0), metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("failed to create service entry %s: %v", serviceName(i), err)
+		}
+	}
+	return nil
+}
+
+func (s *serviceEntryChurner) churn(round int) (string, string, error) {
+	slot, added := churnedService(round, s.services)
+	client := s.client.Istio().NetworkingV1alpha3().ServiceEntries(s.namespace)
+	if s.kind == ChurnEndpoints {
+		i := s.current[slot]
+		existing, err := client.Get(context.TODO(), serviceName(i), metav1.GetOptions{})
+		if err != nil {
+			return "", "", err
+		}
+		se := s.serviceEntry(i, round)
+		se.ResourceVersion = existing.ResourceVersion
+		if _, err := client.Update(context.TODO(), se, metav1.UpdateOptions{}); err != nil {
+			return "", "", err
+		}
+		return v3.EndpointType, serviceCluster(i, s.namespace), nil
+	}
+	removed := s.current[slot]
+	s.current[slot] = added
+	if _, err := client.Create(context.TODO(), s.serviceEntry(added, round), metav1.CreateOptions{}); err != nil {
+		return "", "", err
+	}
+	if err := client.Delete(context.TODO(), serviceName(removed), metav1.DeleteOptions{}); err != nil {
+		return "", "", err
+	}
+	return v3.ClusterType, "", nil
+}
+
+func (s *serviceEntryChurner) cleanup() error {
+	return s.client.Istio().NetworkingV1alpha3().ServiceEntries(s.namespace).DeleteCollection(context.TODO(),
+		metav1.DeleteOptions{}, metav1.ListOptions{LabelSelector: churnLabel + "=true"})
+}
@@ -0,0 +1,310 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package load simulates proxies connected to istiod, churns services and endpoints,
+// and measures how fast the changes reach the proxies.
+package load
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/xds"
+	"istio.io/istio/pkg/kube"
+	"istio.io/pkg/log"
+)
+
+var loadLog = log.RegisterScope("load", "pilot-load", 0)
+
+// Options configure a load run.
+type Options struct {
+	// Address of a remote istiod. If empty, an in-process istiod with an in-memory registry is used.
+	Address string
+	// Kubeconfig is used to create the services of a remote istiod, as ServiceEntries.
+	Kubeconfig string
+	// MetricsURL is the Prometheus endpoint of a remote istiod, used to report its resource usage.
+	MetricsURL string
+
+	Sidecars  int
+	Gateways  int
+	Namespace string
+	// IstioVersion is the version reported by the simulated proxies.
+	IstioVersion string
+	// ConnectRate is the number of proxies connected per second, or 0 to connect them all at once.
+	ConnectRate int
+	// NackRate is the fraction of the responses rejected by the proxies, after their initial config.
+	NackRate float64
+
+	Services            int
+	EndpointsPerService int
+	ChurnKind           ChurnKind
+	Rounds              int
+	ChurnInterval       time.Duration
+	// Timeout is the maximum time to wait for the initial config, and for each change to reach the proxies.
+	Timeout time.Duration
+}
+
+// DefaultOptions returns options for a small run against an in-process istiod.
+func DefaultOptions() Options {
+	return Options{
+		Sidecars:            100,
+		Gateways:            1,
+		Namespace:           "pilot-load",
+		IstioVersion:        "1.10.0",
+		Services:            50,
+		EndpointsPerService: 3,
+		ChurnKind:           ChurnEndpoints,
+		Rounds:              10,
+		ChurnInterval:       time.Second,
+		Timeout:             time.Minute,
+	}
+}
+
+// Run connects the simulated proxies, churns the services and reports the results.
+func Run(ctx context.Context, opts Options) (*Report, error) {
+	if opts.ChurnKind != ChurnEndpoints && opts.ChurnKind != ChurnServices {
+		return nil, fmt.Errorf("unknown churn kind %q", opts.ChurnKind)
+	}
+	if opts.Services <= 0 {
+		return nil, fmt.Errorf("at least one service is required")
+	}
+	if opts.Address != "" {
+		client, err := kube.NewClient(kube.BuildClientCmd(opts.Kubeconfig, ""))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Kubernetes client: %v", err)
+		}
+		r := &runner{
+			opts:    opts,
+			churner: &serviceEntryChurner{client: client, namespace: opts.Namespace, kind: opts.ChurnKind},
+		}
+		if opts.MetricsURL != "" {
+			r.sampler = metricsSampler{url: opts.MetricsURL, client: &http.Client{Timeout: 10 * time.Second}}
+		}
+		return r.run(ctx)
+	}
+
+	// The in-process istiod is the minimal discovery server of the xds package, backed by in-memory
+	// stores, and served on a local port like a remote one.
+	stop := make(chan struct{})
+	defer close(stop)
+	s := xds.NewXDS(stop)
+	ds := s.DiscoveryServer
+	defer ds.Shutdown()
+	ds.Start(stop)
+	if err := s.StartGRPC("127.0.0.1:0"); err != nil {
+		return nil, fmt.Errorf("failed to start istiod: %v", err)
+	}
+	defer s.GRPCListener.Close()
+	// Initialize the push context, as istiod does once its caches are synced.
+	ds.ConfigUpdate(&model.PushRequest{Full: true})
+
+	opts.Address = s.GRPCListener.Addr().String()
+	r := &runner{
+		opts: opts,
+		churner: &memoryChurner{
+			registry:  ds.MemRegistry,
+			updater:   ds,
+			namespace: opts.Namespace,
+			kind:      opts.ChurnKind,
+		},
+		sampler: runtimeSampler{},
+		// Connect the proxies once the services are in the push context, so their initial config holds them.
+		settle: func() bool {
+			return ds.CommittedUpdates.Load() >= ds.InboundUpdates.Load()
+		},
+	}
+	return r.run(ctx)
+}
+
+type runner struct {
+	opts    Options
+	churner churner
+	sampler sampler
+	settle  func() bool
+
+	proxies   []*proxy
+	resources *ResourceUsage
+	startCPU  float64
+}
+
+func (r *runner) run(ctx context.Context) (*Report, error) {
+	opts := r.opts
+	report := &Report{
+		Proxies:  opts.Sidecars + opts.Gateways,
+		Services: opts.Services,
+	}
+	r.sample()
+
+	if err := r.churner.init(opts.Services, opts.EndpointsPerService); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := r.churner.cleanup(); err != nil {
+			loadLog.Warnf("failed to clean up services: %v", err)
+		}
+	}()
+
+	if r.settle != nil {
+		deadline := time.Now().Add(opts.Timeout)
+		for !r.settle() && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	if err := r.connect(ctx); err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, p := range r.proxies {
+			p.close()
+		}
+	}()
+
+	initial := []time.Duration{}
+	timeout := time.After(opts.Timeout)
+	for _, p := range r.proxies {
+		select {
+		case <-p.ready:
+			d, _ := p.stats()
+			initial = append(initial, d)
+		case <-timeout:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	report.Connected = len(initial)
+	report.InitialConfig = newDistribution(initial)
+	loadLog.Infof("%d/%d proxies received their initial config: %v", report.Connected, report.Proxies, report.InitialConfig)
+	r.sample()
+
+	latencies := []time.Duration{}
+	convergence := []time.Duration{}
+	for round := 1; round <= opts.Rounds; round++ {
+		select {
+		case <-time.After(opts.ChurnInterval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		start := time.Now()
+		typeURL, cluster, err := r.churner.churn(round)
+		if err != nil {
+			return nil, fmt.Errorf("churn round %d failed: %v", round, err)
+		}
+		roundLatencies, timedOut := r.waitForPush(ctx, typeURL, cluster, start)
+		latencies = append(latencies, roundLatencies...)
+		report.TimedOut += timedOut
+		if timedOut == 0 && len(roundLatencies) > 0 {
+			convergence = append(convergence, newDistribution(roundLatencies).Max)
+		}
+		report.Rounds++
+		loadLog.Infof("round %d: %d proxies updated, %d timed out: %v", round, len(roundLatencies), timedOut,
+			newDistribution(roundLatencies))
+		r.sample()
+	}
+	report.PushLatency = newDistribution(latencies)
+	report.Convergence = newDistribution(convergence)
+	for _, p := range r.proxies {
+		_, nacks := p.stats()
+		report.Nacks += nacks
+	}
+	report.Resources = r.resources
+	return report, nil
+}
+
+// connect creates and starts the proxies, at the configured rate.
+func (r *runner) connect(ctx context.Context) error {
+	var ticker <-chan time.Time
+	if r.opts.ConnectRate > 0 {
+		t := time.NewTicker(time.Second / time.Duration(r.opts.ConnectRate))
+		defer t.Stop()
+		ticker = t.C
+	}
+	total := r.opts.Sidecars + r.opts.Gateways
+	for i := 0; i < total; i++ {
+		nodeType := model.SidecarProxy
+		if i >= r.opts.Sidecars {
+			nodeType = model.Router
+		}
+		p, err := newProxy(i, nodeType, r.opts)
+		if err != nil {
+			return fmt.Errorf("failed to create proxy %d: %v", i, err)
+		}
+		if err := p.run(); err != nil {
+			p.close()
+			return fmt.Errorf("failed to connect proxy %s: %v", p.name, err)
+		}
+		r.proxies = append(r.proxies, p)
+		if ticker != nil {
+			select {
+			case <-ticker:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	return nil
+}
+
+// waitForPush waits for the proxies to receive a response of the type after start. If cluster is set, only
+// the proxies watching its endpoints are expected to. It returns the latency for each proxy that did, and the
+// number of proxies that did not within the timeout.
+func (r *runner) waitForPush(ctx context.Context, typeURL, cluster string, start time.Time) ([]time.Duration, int) {
+	deadline := time.Now().Add(r.opts.Timeout)
+	pending := map[*proxy]struct{}{}
+	for _, p := range r.proxies {
+		// Proxies that never became ready are not expected to receive updates, nor are proxies that rejected
+		// the cluster, as they do not watch its endpoints.
+		if d, _ := p.stats(); d == 0 {
+			continue
+		}
+		if _, f := p.client.GetEdsClusters()[cluster]; cluster != "" && !f {
+			continue
+		}
+		pending[p] = struct{}{}
+	}
+	latencies := make([]time.Duration, 0, len(pending))
+	for len(pending) > 0 && time.Now().Before(deadline) && ctx.Err() == nil {
+		for p := range pending {
+			if d, ok := p.receivedSince(typeURL, start); ok {
+				latencies = append(latencies, d)
+				delete(pending, p)
+			}
+		}
+		if len(pending) > 0 {
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	return latencies, len(pending)
+}
+
+// sample records the current resource usage of istiod, if known.
+func (r *runner) sample() {
+	if r.sampler == nil {
+		return
+	}
+	s, err := r.sampler.sample()
+	if err != nil {
+		loadLog.Warnf("failed to read istiod resource usage: %v", err)
+		return
+	}
+	if r.resources == nil {
+		r.resources = &ResourceUsage{}
+		r.startCPU = s.cpuSeconds
+	}
+	r.resources.CPUSeconds = s.cpuSeconds - r.startCPU
+	r.resources.add(s)
+}
@@ -0,0 +1,98 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package load
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	cases := []struct {
+		kind     ChurnKind
+		nackRate float64
+	}{
+		{ChurnEndpoints, 0},
+		// Rejected clusters still count as received, so all the proxies are expected to get each change.
+		{ChurnServices, 0.5},
+	}
+	for _, tt := range cases {
+		t.Run(string(tt.kind), func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.Sidecars = 5
+			opts.Gateways = 1
+			opts.Services = 3
+			opts.EndpointsPerService = 2
+			opts.ChurnKind = tt.kind
+			opts.Rounds = 3
+			opts.ChurnInterval = 10 * time.Millisecond
+			opts.Timeout = 10 * time.Second
+			opts.NackRate = tt.nackRate
+
+			report, err := Run(context.Background(), opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Connected != 6 || report.InitialConfig.Count != 6 {
+				t.Fatalf("expected all proxies to connect, got %+v", report)
+			}
+			if report.Rounds != 3 || report.TimedOut != 0 {
+				t.Fatalf("expected all rounds to converge, got %+v", report)
+			}
+			if report.PushLatency.Count != 18 || report.Convergence.Count != 3 {
+				t.Fatalf("unexpected latencies %+v", report)
+			}
+			if (report.Nacks > 0) != (tt.nackRate > 0) {
+				t.Fatalf("unexpected NACKs %d for rate %v", report.Nacks, tt.nackRate)
+			}
+			if report.Resources == nil || report.Resources.PeakGoroutines == 0 {
+				t.Fatalf("expected resource usage, got %+v", report.Resources)
+			}
+
+			out := &bytes.Buffer{}
+			if err := report.Print(out); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(out.String(), "6 connected of 6") {
+				t.Fatalf("unexpected report:\n%s", out.String())
+			}
+		})
+	}
+}
+
+func TestDistribution(t *testing.T) {
+	values := []time.Duration{}
+	for i := 100; i > 0; i-- {
+		values = append(values, time.Duration(i)*time.Millisecond)
+	}
+	d := newDistribution(values)
+	if d.Count != 100 || d.P50 != 50*time.Millisecond || d.P99 != 99*time.Millisecond || d.Max != 100*time.Millisecond {
+		t.Fatalf("unexpected distribution %+v", d)
+	}
+	if newDistribution(nil).String() != "-" {
+		t.Fatalf("expected empty distribution")
+	}
+}
+
+func TestRunInvalidOptions(t *testing.T) {
+	opts := DefaultOptions()
+	opts.ChurnKind = "pods"
+	if _, err := Run(context.Background(), opts); err == nil {
+		t.Fatal("expected error for unknown churn kind")
+	}
+}
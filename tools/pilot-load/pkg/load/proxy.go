@@ -0,0 +1,163 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package load
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/adsc"
+)
+
+var errInjectedNack = errors.New("rejected by pilot-load")
+
+// proxy is a simulated sidecar or gateway.
+type proxy struct {
+	name     string
+	nodeType model.NodeType
+	client   *adsc.ADSC
+
+	nackRate float64
+	rand     *rand.Rand
+
+	mu sync.Mutex
+	// received is the last time a response was received, by type.
+	received map[string]time.Time
+	// accepted holds the types for which a response was accepted at least once.
+	accepted      map[string]struct{}
+	connected     time.Time
+	initialConfig time.Duration
+	nacks         int
+	ready         chan struct{}
+}
+
+// proxyIP returns a unique IP for the i-th simulated proxy.
+func proxyIP(i int) string {
+	return fmt.Sprintf("10.%d.%d.%d", 128+(i>>16)&0x7f, (i>>8)&0xff, i&0xff)
+}
+
+func newProxy(i int, nodeType model.NodeType, opts Options) (*proxy, error) {
+	p := &proxy{
+		nodeType: nodeType,
+		nackRate: opts.NackRate,
+		rand:     rand.New(rand.NewSource(int64(i))),
+		received: map[string]time.Time{},
+		accepted: map[string]struct{}{},
+		ready:    make(chan struct{}),
+	}
+	ip := proxyIP(i)
+	meta := model.NodeMetadata{
+		Namespace:    opts.Namespace,
+		IstioVersion: opts.IstioVersion,
+		ClusterID:    "Kubernetes",
+		MeshID:       "cluster.local",
+		InstanceIPs:  []string{ip},
+	}
+	if nodeType == model.Router {
+		p.name = fmt.Sprintf("load-gateway-%d", i)
+		meta.Labels = map[string]string{"istio": "ingressgateway", "app": "load-gateway"}
+		meta.ServiceAccount = "load-gateway"
+		meta.RouterMode = string(model.SniDnatRouter)
+	} else {
+		p.name = fmt.Sprintf("load-%d", i)
+		meta.Labels = map[string]string{"app": p.name, "version": "v1"}
+		meta.ServiceAccount = "default"
+		meta.InterceptionMode = model.InterceptionRedirect
+	}
+
+	client, err := adsc.New(opts.Address, &adsc.Config{
+		Namespace: opts.Namespace,
+		Workload:  p.name,
+		NodeType:  string(nodeType),
+		IP:        ip,
+		Meta:      meta.ToStruct(),
+		// LDS and CDS responses trigger the RDS and EDS requests.
+		InitialDiscoveryRequests: []*discovery.DiscoveryRequest{
+			{TypeUrl: v3.ClusterType},
+			{TypeUrl: v3.ListenerType},
+		},
+		ResponseHandler: p,
+		Reject:          p.reject,
+	})
+	if err != nil {
+		return nil, err
+	}
+	p.client = client
+	return p, nil
+}
+
+func (p *proxy) run() error {
+	p.mu.Lock()
+	p.connected = time.Now()
+	p.mu.Unlock()
+	return p.client.Run()
+}
+
+func (p *proxy) close() {
+	p.client.Close()
+}
+
+// HandleResponse records the time the response was received.
+func (p *proxy) HandleResponse(_ *adsc.ADSC, resp *discovery.DiscoveryResponse) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	p.received[resp.TypeUrl] = now
+	if p.initialConfig == 0 {
+		_, cds := p.received[v3.ClusterType]
+		_, lds := p.received[v3.ListenerType]
+		if cds && lds {
+			p.initialConfig = now.Sub(p.connected)
+			close(p.ready)
+		}
+	}
+}
+
+// reject injects NACKs at the configured rate. The first response of each type is always accepted,
+// as a real proxy rejecting its initial config would never become ready.
+func (p *proxy) reject(resp *discovery.DiscoveryResponse) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, f := p.accepted[resp.TypeUrl]; f && p.nackRate > 0 && p.rand.Float64() < p.nackRate {
+		p.nacks++
+		return errInjectedNack
+	}
+	p.accepted[resp.TypeUrl] = struct{}{}
+	return nil
+}
+
+// receivedSince returns how long after since a response of the type was received, if it was.
+func (p *proxy) receivedSince(typeURL string, since time.Time) (time.Duration, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	t, f := p.received[typeURL]
+	if !f || t.Before(since) {
+		return 0, false
+	}
+	return t.Sub(since), true
+}
+
+func (p *proxy) stats() (initialConfig time.Duration, nacks int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.initialConfig, p.nacks
+}
@@ -0,0 +1,179 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package load
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/prometheus/common/expfmt"
+)
+
+// Distribution summarizes a set of durations.
+type Distribution struct {
+	Count int           `json:"count"`
+	P50   time.Duration `json:"p50"`
+	P90   time.Duration `json:"p90"`
+	P99   time.Duration `json:"p99"`
+	Max   time.Duration `json:"max"`
+}
+
+func newDistribution(values []time.Duration) Distribution {
+	if len(values) == 0 {
+		return Distribution{}
+	}
+	sorted := append([]time.Duration{}, values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	percentile := func(p int) time.Duration {
+		return sorted[(len(sorted)-1)*p/100]
+	}
+	return Distribution{
+		Count: len(sorted),
+		P50:   percentile(50),
+		P90:   percentile(90),
+		P99:   percentile(99),
+		Max:   sorted[len(sorted)-1],
+	}
+}
+
+func (d Distribution) String() string {
+	if d.Count == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("p50=%v p90=%v p99=%v max=%v (n=%d)", d.P50, d.P90, d.P99, d.Max, d.Count)
+}
+
+// ResourceUsage is the resource usage of istiod during the run.
+type ResourceUsage struct {
+	// CPUSeconds is the CPU time consumed during the run. Only known for a remote istiod.
+	CPUSeconds     float64 `json:"cpuSeconds,omitempty"`
+	PeakMemory     uint64  `json:"peakMemoryBytes"`
+	PeakGoroutines int     `json:"peakGoroutines"`
+}
+
+func (r *ResourceUsage) add(s resourceSample) {
+	if s.memory > r.PeakMemory {
+		r.PeakMemory = s.memory
+	}
+	if s.goroutines > r.PeakGoroutines {
+		r.PeakGoroutines = s.goroutines
+	}
+}
+
+// Report is the result of a load run.
+type Report struct {
+	Proxies   int `json:"proxies"`
+	Connected int `json:"connected"`
+	Services  int `json:"services"`
+	Rounds    int `json:"rounds"`
+	// InitialConfig is the time for each proxy to receive its initial listeners and clusters.
+	InitialConfig Distribution `json:"initialConfig"`
+	// PushLatency is the time, for each proxy and round, from the change to the proxy receiving it.
+	PushLatency Distribution `json:"pushLatency"`
+	// Convergence is the time, for each round, from the change to all the proxies receiving it.
+	Convergence Distribution `json:"convergence"`
+	// TimedOut is the number of times a proxy did not receive a change within the timeout.
+	TimedOut int `json:"timedOut"`
+	// Nacks is the number of responses rejected by the proxies.
+	Nacks int `json:"nacks"`
+	// Resources is not set if the resource usage of istiod is unknown.
+	Resources *ResourceUsage `json:"resources,omitempty"`
+}
+
+// Print writes the report in a human readable format.
+func (r *Report) Print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	_, _ = fmt.Fprintf(tw, "Proxies:\t%d connected of %d\n", r.Connected, r.Proxies)
+	_, _ = fmt.Fprintf(tw, "Services:\t%d\n", r.Services)
+	_, _ = fmt.Fprintf(tw, "Churn rounds:\t%d\n", r.Rounds)
+	_, _ = fmt.Fprintf(tw, "Initial config:\t%v\n", r.InitialConfig)
+	_, _ = fmt.Fprintf(tw, "Push latency:\t%v\n", r.PushLatency)
+	_, _ = fmt.Fprintf(tw, "Convergence:\t%v\n", r.Convergence)
+	_, _ = fmt.Fprintf(tw, "Timed out:\t%d\n", r.TimedOut)
+	_, _ = fmt.Fprintf(tw, "NACKs:\t%d\n", r.Nacks)
+	if r.Resources != nil {
+		if r.Resources.CPUSeconds > 0 {
+			_, _ = fmt.Fprintf(tw, "Istiod CPU:\t%.2fs\n", r.Resources.CPUSeconds)
+		}
+		_, _ = fmt.Fprintf(tw, "Istiod peak memory:\t%dMB\n", r.Resources.PeakMemory>>20)
+		_, _ = fmt.Fprintf(tw, "Istiod peak goroutines:\t%d\n", r.Resources.PeakGoroutines)
+	}
+	return tw.Flush()
+}
+
+type resourceSample struct {
+	cpuSeconds float64
+	memory     uint64
+	goroutines int
+}
+
+// sampler reads the resource usage of istiod.
+type sampler interface {
+	sample() (resourceSample, error)
+}
+
+// runtimeSampler reads the resource usage of the current process, for an in-process istiod. The usage
+// includes the simulated proxies.
+type runtimeSampler struct{}
+
+func (runtimeSampler) sample() (resourceSample, error) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return resourceSample{memory: m.HeapInuse, goroutines: runtime.NumGoroutine()}, nil
+}
+
+// metricsSampler reads the resource usage of a remote istiod from its Prometheus metrics.
+type metricsSampler struct {
+	url    string
+	client *http.Client
+}
+
+func (m metricsSampler) sample() (resourceSample, error) {
+	resp, err := m.client.Get(m.url)
+	if err != nil {
+		return resourceSample{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return resourceSample{}, fmt.Errorf("failed to get metrics from %s: %s", m.url, resp.Status)
+	}
+	families, err := (&expfmt.TextParser{}).TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return resourceSample{}, err
+	}
+	value := func(name string) float64 {
+		f, ok := families[name]
+		if !ok || len(f.Metric) == 0 {
+			return 0
+		}
+		switch {
+		case f.Metric[0].Counter != nil:
+			return f.Metric[0].Counter.GetValue()
+		case f.Metric[0].Gauge != nil:
+			return f.Metric[0].Gauge.GetValue()
+		}
+		return 0
+	}
+	return resourceSample{
+		cpuSeconds: value("process_cpu_seconds_total"),
+		memory:     uint64(value("process_resident_memory_bytes")),
+		goroutines: int(value("go_goroutines")),
+	}, nil
+}
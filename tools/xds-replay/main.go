@@ -0,0 +1,188 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// xds-replay records the xDS stream of a simulated proxy, and replays it later as a fake control plane
+// for a local istiod client or Envoy.
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/adsc"
+	"istio.io/pkg/log"
+)
+
+func main() {
+	if err := rootCmd().Execute(); err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+}
+
+func rootCmd() *cobra.Command {
+	loggingOptions := log.DefaultOptions()
+	root := &cobra.Command{
+		Use:   "xds-replay",
+		Short: "Records and replays xDS streams",
+		Long: `Records the sequence of xDS requests and responses of a simulated proxy connected to istiod,
+and replays the recorded responses later, acting as a fake control plane.
+
+Captures hold one JSON message per line, with the offset of the message from the start of the
+recording, and the request or response encoded as JSON.`,
+		SilenceUsage: true,
+		PersistentPreRunE: func(*cobra.Command, []string) error {
+			return log.Configure(loggingOptions)
+		},
+	}
+	loggingOptions.AttachCobraFlags(root)
+	root.AddCommand(recordCmd(), replayCmd())
+	return root
+}
+
+func recordCmd() *cobra.Command {
+	var (
+		address  string
+		out      string
+		duration time.Duration
+		cfg      = &adsc.Config{}
+		meta     map[string]string
+		certDir  string
+	)
+	cmd := &cobra.Command{
+		Use:   "record",
+		Short: "Connects to istiod as a proxy and records the xDS stream",
+		Example: `  # Record the config of a sidecar in the default namespace for a minute
+  xds-replay record --address localhost:15010 --workload productpage-v1 --duration 1m -o capture.jsonl`,
+		Args: cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			f, err := os.Create(out)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			if len(meta) > 0 {
+				fields := map[string]interface{}{}
+				for k, v := range meta {
+					fields[k] = v
+				}
+				if cfg.Meta, err = structpb.NewStruct(fields); err != nil {
+					return err
+				}
+			}
+			cfg.CertDir = certDir
+			recorder := adsc.NewRecorder(f)
+			cfg.Recorder = recorder
+			// LDS and CDS responses trigger the RDS and EDS requests.
+			cfg.InitialDiscoveryRequests = []*discovery.DiscoveryRequest{
+				{TypeUrl: v3.ClusterType},
+				{TypeUrl: v3.ListenerType},
+			}
+			client, err := adsc.New(address, cfg)
+			if err != nil {
+				return fmt.Errorf("failed to connect to %s: %v", address, err)
+			}
+			if err := client.Run(); err != nil {
+				return err
+			}
+			defer client.Close()
+
+			stop := make(chan os.Signal, 1)
+			signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+			var timeout <-chan time.Time
+			if duration > 0 {
+				timeout = time.After(duration)
+			}
+			select {
+			case <-stop:
+			case <-timeout:
+			}
+			if err := recorder.Err(); err != nil {
+				return fmt.Errorf("failed to write capture: %v", err)
+			}
+			c.Printf("Recorded xDS stream to %s\n", out)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&address, "address", "localhost:15010", "Address of the xDS server")
+	cmd.Flags().StringVarP(&out, "output", "o", "xds-capture.jsonl", "File to write the capture to")
+	cmd.Flags().DurationVar(&duration, "duration", 0, "How long to record, until interrupted if 0")
+	cmd.Flags().StringVar(&cfg.Namespace, "namespace", "default", "Namespace of the simulated proxy")
+	cmd.Flags().StringVar(&cfg.Workload, "workload", "", "Workload name of the simulated proxy")
+	cmd.Flags().StringVar(&cfg.IP, "ip", "", "IP of the simulated proxy, defaults to a local private IP")
+	cmd.Flags().StringVar(&cfg.NodeType, "node-type", "sidecar", "Node type of the simulated proxy: sidecar or router")
+	cmd.Flags().StringToStringVar(&meta, "meta", nil, "Node metadata of the simulated proxy, for example ISTIO_VERSION=1.10.0")
+	cmd.Flags().StringVar(&certDir, "cert-dir", "", "Directory holding the certificates used for mTLS with the xDS server")
+	cmd.Flags().StringVar(&cfg.XDSSAN, "xds-san", "", "Expected SAN of the xDS server certificate")
+	return cmd
+}
+
+func replayCmd() *cobra.Command {
+	var (
+		in     string
+		listen string
+		opts   adsc.ReplayOptions
+	)
+	cmd := &cobra.Command{
+		Use:   "replay",
+		Short: "Serves a recorded xDS stream as a fake control plane",
+		Long: `Serves the responses of a capture, in order, to each client connecting over ADS. A response is sent
+once the client requested its type. Timing is preserved with --speed 1, compressed with higher values,
+or dropped with --speed 0.`,
+		Example: `  # Replay a capture ten times faster, skipping idle periods longer than 5s
+  xds-replay replay -f capture.jsonl --listen :15010 --speed 10 --max-delay 5s`,
+		Args: cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			f, err := os.Open(in)
+			if err != nil {
+				return err
+			}
+			messages, err := adsc.ReadRecording(f)
+			_ = f.Close()
+			if err != nil {
+				return err
+			}
+			l, err := net.Listen("tcp", listen)
+			if err != nil {
+				return err
+			}
+			server := grpc.NewServer()
+			adsc.NewReplayer(messages, opts).Register(server)
+
+			stop := make(chan os.Signal, 1)
+			signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+			go func() {
+				<-stop
+				server.Stop()
+			}()
+			c.Printf("Replaying %d messages from %s on %s\n", len(messages), in, l.Addr())
+			return server.Serve(l)
+		},
+	}
+	cmd.Flags().StringVarP(&in, "file", "f", "xds-capture.jsonl", "Capture to replay")
+	cmd.Flags().StringVar(&listen, "listen", ":15010", "Address to serve ADS on")
+	cmd.Flags().Float64Var(&opts.Speed, "speed", 1, "Replay speed, relative to the recorded timing. 0 sends responses without delay")
+	cmd.Flags().DurationVar(&opts.MaxDelay, "max-delay", 0, "Maximum delay between two responses, 0 for no limit")
+	return cmd
+}